@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var errNoAgent = errors.New("no ssh-agent running (SSH_AUTH_SOCK is not set)")
+
+// withAgent connects to the running ssh-agent for the duration of fn
+func withAgent(fn func(agent.ExtendedAgent) error) error {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return errNoAgent
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return fn(agent.NewClient(conn))
+}
+
+// agentKeys lists the identities loaded in the agent, like ssh-add -l
+func agentKeys() ([]*agent.Key, error) {
+	var keys []*agent.Key
+	err := withAgent(func(a agent.ExtendedAgent) error {
+		var err error
+		keys, err = a.List()
+		return err
+	})
+	return keys, err
+}
+
+// identityPublicKey returns the public half of an identity file, from its .pub file
+// or, for unencrypted keys, from the private key itself
+func identityPublicKey(path string) (ssh.PublicKey, error) {
+	if content, err := os.ReadFile(path + ".pub"); err == nil {
+		key, _, _, _, err := ssh.ParseAuthorizedKey(content)
+		return key, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(content)
+	if err != nil {
+		return nil, err
+	}
+	return signer.PublicKey(), nil
+}
+
+// missingFromAgent returns the existing identity files whose keys the agent does not hold
+func missingFromAgent(identityFiles []string, loaded []*agent.Key) []string {
+	have := map[string]bool{}
+	for _, k := range loaded {
+		have[ssh.FingerprintSHA256(k)] = true
+	}
+	var missing []string
+	for _, f := range identityFiles {
+		path := expandHome(f)
+		pub, err := identityPublicKey(path)
+		if err != nil {
+			continue
+		}
+		if !have[ssh.FingerprintSHA256(pub)] {
+			missing = append(missing, path)
+		}
+	}
+	return missing
+}
+
+// keyNeedsPassphrase reports whether the private key at path is encrypted
+func keyNeedsPassphrase(path string) bool {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	_, err = ssh.ParseRawPrivateKey(content)
+	var missing *ssh.PassphraseMissingError
+	return errors.As(err, &missing)
+}
+
+// addToAgent loads the private key at path into the agent, like ssh-add
+func addToAgent(path string, passphrase []byte) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var key interface{}
+	if len(passphrase) == 0 {
+		key, err = ssh.ParseRawPrivateKey(content)
+	} else {
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(content, passphrase)
+	}
+	if err != nil {
+		return err
+	}
+	return withAgent(func(a agent.ExtendedAgent) error {
+		return a.Add(agent.AddedKey{PrivateKey: key, Comment: filepath.Base(path)})
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// startTestAgent serves an in-memory keyring on a socket and points SSH_AUTH_SOCK at it
+func startTestAgent(t *testing.T) {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	keyring := agent.NewKeyring()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+}
+
+func TestAddToAgent(t *testing.T) {
+	startTestAgent(t)
+	dir := t.TempDir()
+	encrypted := filepath.Join(dir, "id_encrypted")
+	plain := filepath.Join(dir, "id_plain")
+	if err := generateKey(encrypted, "s3cret", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := generateKey(plain, "", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if !keyNeedsPassphrase(encrypted) || keyNeedsPassphrase(plain) {
+		t.Fatal("expected only the first key to need a passphrase")
+	}
+
+	ids := []string{encrypted, plain, filepath.Join(dir, "id_missing")}
+	keys, err := agentKeys()
+	if err != nil {
+		t.Fatalf("agentKeys failed: %v", err)
+	}
+	if missing := missingFromAgent(ids, keys); len(missing) != 2 {
+		t.Fatalf("expected both existing keys to be missing, got %v", missing)
+	}
+
+	if err := addToAgent(encrypted, []byte("wrong")); err == nil {
+		t.Error("expected a wrong passphrase to fail")
+	}
+	if err := addToAgent(encrypted, []byte("s3cret")); err != nil {
+		t.Fatalf("addToAgent failed: %v", err)
+	}
+	if err := addToAgent(plain, nil); err != nil {
+		t.Fatalf("addToAgent failed: %v", err)
+	}
+	keys, _ = agentKeys()
+	if missing := missingFromAgent(ids, keys); len(missing) != 0 {
+		t.Errorf("expected all keys in the agent, still missing %v", missing)
+	}
+
+	os.Unsetenv("SSH_AUTH_SOCK")
+	if _, err := agentKeys(); err != errNoAgent {
+		t.Errorf("expected errNoAgent, got %v", err)
+	}
+}
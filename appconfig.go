@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultConnectTimeout bounds connecting to a host when config.json sets no connect_timeout
+const defaultConnectTimeout = 10 * time.Second
+
+// defaultExecTimeout bounds a bulk exec command on each host when config.json sets no exec_timeout
+const defaultExecTimeout = 5 * time.Minute
+
+// defaultMaxOutput is how much of a host's output bulk exec keeps in memory and
+// prints when config.json sets no exec_max_output
+const defaultMaxOutput = 64 * 1024
+
+// defaultExecWorkers is how many hosts bulk exec works on at once when config.json sets no exec_workers
+const defaultExecWorkers = 32
+
+// defaultSocksPort is where S starts the SOCKS proxy when config.json sets no socks_port
+const defaultSocksPort = 1080
+
+// appConfig holds the tool's own settings, stored separately from ~/.ssh/config
+type appConfig struct {
+	// SecretBackend selects where host passwords are kept ("vault" or empty for none)
+	SecretBackend string `json:"secret_backend,omitempty"`
+	// BiometricUnlock unlocks the vault with Touch ID on macOS, falling back to the master password
+	BiometricUnlock bool `json:"biometric_unlock,omitempty"`
+	// DisablePasswordCache stops verified passwords from being reused within one run
+	DisablePasswordCache bool `json:"disable_password_cache,omitempty"`
+	// GPUProbeTag enables GPU/temperature metrics for hosts carrying this tag
+	GPUProbeTag string `json:"gpu_probe_tag,omitempty"`
+	// ProbeTimezones looks up the time zone of hovered hosts that have none set in metadata
+	ProbeTimezones bool `json:"probe_timezones,omitempty"`
+	// Groups configures host groups by tag name
+	Groups map[string]groupConfig `json:"groups,omitempty"`
+	// SessionBanner prints the host alias and environment in large colored text before each session
+	SessionBanner bool `json:"session_banner,omitempty"`
+	// DisableTerminalTitle keeps the terminal and tmux titles unchanged during sessions
+	DisableTerminalTitle bool `json:"disable_terminal_title,omitempty"`
+	// PromptInjection exports LSH_HOST/LSH_ENV and prefixes the remote bash prompt with them
+	PromptInjection bool `json:"prompt_injection,omitempty"`
+	// EnvironmentColors overrides the banner color per environment name
+	EnvironmentColors map[string]string `json:"environment_colors,omitempty"`
+	// TagColors overrides the color of the tag chips in the list per tag name
+	TagColors map[string]string `json:"tag_colors,omitempty"`
+	// Sort orders the host list until one is picked with o: config (the order of ~/.ssh/config, the default),
+	// alphabetical, recent (last connected first), frecency (connected to often and lately first) or latency
+	Sort string `json:"sort,omitempty"`
+	// ReturnToList shows the host list again when a session ends, instead of quitting
+	ReturnToList bool `json:"return_to_list,omitempty"`
+	// DisableHistory stops connections from being recorded in history.jsonl
+	DisableHistory bool `json:"disable_history,omitempty"`
+	// SkipLoginTest connects on enter and lets ssh ask for the password, instead of testing it first
+	SkipLoginTest bool `json:"skip_login_test,omitempty"`
+	// ConnectTimeout is the number of seconds ssh may take to connect during the login test
+	ConnectTimeout int `json:"connect_timeout,omitempty"`
+	// DisableLoginRetry stops login tests that timed out or were refused from being tried again
+	DisableLoginRetry bool `json:"disable_login_retry,omitempty"`
+	// DisableMultiplexing makes the session authenticate again instead of reusing the login test's connection
+	DisableMultiplexing bool `json:"disable_multiplexing,omitempty"`
+	// MultiplexIdle is the number of seconds an unused shared connection stays open
+	MultiplexIdle int `json:"multiplex_idle,omitempty"`
+	// MultiplexMax caps how many shared connections are open at once
+	MultiplexMax int `json:"multiplex_max,omitempty"`
+	// Command is run instead of the login shell for sessions on hosts that set none in their metadata
+	Command string `json:"command,omitempty"`
+	// TmuxSessions runs the sessions of all hosts in tmux sessions named after them
+	TmuxSessions bool `json:"tmux_sessions,omitempty"`
+	// Term overrides the TERM announced to hosts that set none in their metadata
+	Term string `json:"term,omitempty"`
+	// ExecTimeout is the number of seconds a bulk exec command may run on each host
+	ExecTimeout int `json:"exec_timeout,omitempty"`
+	// ExecMaxOutput is the number of bytes of each host's output bulk exec keeps and prints;
+	// longer output is written to a file in full
+	ExecMaxOutput int `json:"exec_max_output,omitempty"`
+	// ExecWorkers is the number of hosts bulk exec and broadcasts run a command on at once
+	ExecWorkers int `json:"exec_workers,omitempty"`
+	// Concurrency limits how many hosts bulk operations and probes reach at once
+	Concurrency concurrencyConfig `json:"concurrency,omitempty"`
+	// Freeze blocks bulk operations on all or tagged hosts
+	Freeze freezeConfig `json:"freeze,omitempty"`
+	// SocksPort is the local port of the SOCKS proxy started with S
+	SocksPort int `json:"socks_port,omitempty"`
+	// Forwards lists the port forwards offered for each host on the forwards screen
+	Forwards map[string][]forward `json:"forwards,omitempty"`
+	// ServerAliveInterval makes ssh check idle connections every this many seconds,
+	// dropping them after ServerAliveCountMax checks go unanswered
+	ServerAliveInterval int `json:"server_alive_interval,omitempty"`
+	ServerAliveCountMax int `json:"server_alive_count_max,omitempty"`
+	// TerminalTabs sets how w opens sessions per terminal emulator ("kitty",
+	// "wezterm", "iterm2"): in a new "tab" (the default), "window", or "off"
+	TerminalTabs map[string]string `json:"terminal_tabs,omitempty"`
+	// RecordSessions records the ssh sessions of all hosts in the recordings directory
+	RecordSessions bool `json:"record_sessions,omitempty"`
+	// Recorder is "script" (the default) or "asciinema"
+	Recorder string `json:"recorder,omitempty"`
+}
+
+// connectTimeout returns how long connecting to a host may take
+func (c appConfig) connectTimeout() time.Duration {
+	if c.ConnectTimeout <= 0 {
+		return defaultConnectTimeout
+	}
+	return time.Duration(c.ConnectTimeout) * time.Second
+}
+
+// execTimeout returns how long a bulk exec command may run on each host
+func (c appConfig) execTimeout() time.Duration {
+	if c.ExecTimeout <= 0 {
+		return defaultExecTimeout
+	}
+	return time.Duration(c.ExecTimeout) * time.Second
+}
+
+// execMaxOutput returns how much of each host's output bulk exec keeps
+func (c appConfig) execMaxOutput() int {
+	if c.ExecMaxOutput <= 0 {
+		return defaultMaxOutput
+	}
+	return c.ExecMaxOutput
+}
+
+// execWorkers returns how many hosts bulk exec runs a command on at once
+func (c appConfig) execWorkers() int {
+	if c.ExecWorkers <= 0 {
+		return defaultExecWorkers
+	}
+	return c.ExecWorkers
+}
+
+// socksPort returns the local port of the SOCKS proxy
+func (c appConfig) socksPort() int {
+	if c.SocksPort <= 0 {
+		return defaultSocksPort
+	}
+	return c.SocksPort
+}
+
+// connectTimeoutOption is the ssh option applying timeout
+func connectTimeoutOption(timeout time.Duration) string {
+	return fmt.Sprintf("ConnectTimeout=%d", int(timeout/time.Second))
+}
+
+// appConfigDir returns the directory holding the app config, vault and other state
+func appConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "list-ssh-hosts"), nil
+}
+
+// loadAppConfig reads config.json from the app config directory. A missing file yields the defaults.
+func loadAppConfig() (appConfig, error) {
+	var cfg appConfig
+	dir, err := appConfigDir()
+	if err != nil {
+		return cfg, err
+	}
+	return readAppConfig(filepath.Join(dir, "config.json"))
+}
+
+// readAppConfig reads an app config from the given path
+func readAppConfig(path string) (appConfig, error) {
+	var cfg appConfig
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	err = json.Unmarshal(content, &cfg)
+	return cfg, err
+}
+
+// updateAppConfig applies fn to config.json and returns the config as written,
+// keeping changes other runs made in the meantime
+func updateAppConfig(fn func(*appConfig)) (appConfig, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return appConfig{}, err
+	}
+	path := filepath.Join(dir, "config.json")
+	cfg, err := readAppConfig(path)
+	if err != nil {
+		return cfg, err
+	}
+	fn(&cfg)
+	return cfg, writeAppConfig(path, cfg)
+}
+
+// writeAppConfig writes an app config to the given path
+func writeAppConfig(path string, cfg appConfig) error {
+	content, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0600)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// defaultEnvironmentColors are used when the app config sets no color for an environment
+var defaultEnvironmentColors = map[string]string{
+	"prod":       "#D70000",
+	"production": "#D70000",
+	"staging":    "#D7AF00",
+	"test":       "#0087D7",
+	"dev":        "#00AF5F",
+}
+
+// environmentColor picks the banner color for an environment
+func environmentColor(env string, colors map[string]string) lipgloss.Color {
+	if c, ok := colors[env]; ok {
+		return lipgloss.Color(c)
+	}
+	if c, ok := defaultEnvironmentColors[strings.ToLower(env)]; ok {
+		return lipgloss.Color(c)
+	}
+	return lipgloss.Color("#5F5FD7")
+}
+
+// sessionBanner renders the host alias and its environment as a large colored block,
+// printed right before the SSH session starts
+func sessionBanner(host, env string, colors map[string]string) string {
+	text := host
+	if env != "" {
+		text = strings.ToUpper(env) + "  ·  " + host
+	}
+	return lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("#FFFFFF")).
+		Background(environmentColor(env, colors)).
+		Padding(1, 6).
+		Margin(1, 0).
+		Render(text)
+}
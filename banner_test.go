@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestEnvironmentColor(t *testing.T) {
+	if c := environmentColor("PROD", nil); c != lipgloss.Color("#D70000") {
+		t.Errorf("expected default prod color, got %v", c)
+	}
+	if c := environmentColor("prod", map[string]string{"prod": "#FF0000"}); c != lipgloss.Color("#FF0000") {
+		t.Errorf("expected configured color, got %v", c)
+	}
+}
+
+func TestSessionBanner(t *testing.T) {
+	banner := sessionBanner("db1", "prod", nil)
+	if !strings.Contains(banner, "PROD  ·  db1") {
+		t.Errorf("expected environment and host in banner, got %q", banner)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var errBiometricUnavailable = errors.New("biometric unlock is not available on this platform")
+
+type biometricResultMsg struct {
+	master string
+	err    error
+}
+
+// tryBiometricUnlock asks the platform for the stored master password, gated by biometrics
+func tryBiometricUnlock() tea.Cmd {
+	return func() tea.Msg {
+		master, err := biometricLoad()
+		return biometricResultMsg{master: master, err: err}
+	}
+}
+
+// storeBiometric saves the master password for Touch ID unlocks in the
+// background, as compiling the helper takes a while. It is best effort: the
+// next run asks for the master password when it fails.
+func storeBiometric(master string) tea.Cmd {
+	return func() tea.Msg {
+		_ = biometricStore(master)
+		return nil
+	}
+}
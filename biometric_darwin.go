@@ -0,0 +1,101 @@
+//go:build darwin
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// biometricScript stores the master password in the keychain ("store", read from stdin)
+// or, after a successful Touch ID check, prints it ("load"). The item is bound to the
+// enrolled fingerprints: the keychain releases it only after a Touch ID check, and
+// drops it when fingerprints are added or removed.
+const biometricScript = `
+import Foundation
+import LocalAuthentication
+import Security
+
+let service = "list-ssh-hosts"
+let account = "vault-master"
+let query: [String: Any] = [
+    kSecClass as String: kSecClassGenericPassword,
+    kSecAttrService as String: service,
+    kSecAttrAccount as String: account,
+]
+
+if CommandLine.arguments.count > 1 && CommandLine.arguments[1] == "store" {
+    let data = FileHandle.standardInput.readDataToEndOfFile()
+    SecItemDelete(query as CFDictionary)
+    guard let access = SecAccessControlCreateWithFlags(nil, kSecAttrAccessibleWhenUnlockedThisDeviceOnly, .biometryCurrentSet, nil) else { exit(1) }
+    var item = query
+    item[kSecValueData as String] = data
+    item[kSecAttrAccessControl as String] = access
+    exit(SecItemAdd(item as CFDictionary, nil) == errSecSuccess ? 0 : 1)
+}
+
+let context = LAContext()
+var error: NSError?
+guard context.canEvaluatePolicy(.deviceOwnerAuthenticationWithBiometrics, error: &error) else { exit(2) }
+let done = DispatchSemaphore(value: 0)
+var ok = false
+context.evaluatePolicy(.deviceOwnerAuthenticationWithBiometrics, localizedReason: "unlock the SSH password vault") { success, _ in
+    ok = success
+    done.signal()
+}
+done.wait()
+if !ok { exit(3) }
+
+var load = query
+load[kSecReturnData as String] = true
+// The check above also satisfies the item's access control
+load[kSecUseAuthenticationContext as String] = context
+var result: AnyObject?
+guard SecItemCopyMatching(load as CFDictionary, &result) == errSecSuccess, let data = result as? Data else { exit(4) }
+FileHandle.standardOutput.write(data)
+`
+
+// biometricLoad returns the master password from the keychain after a Touch ID check
+func biometricLoad() (string, error) {
+	cmd, cleanup, err := biometricCommand("load")
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// biometricStore saves the master password in the keychain for later Touch ID unlocks
+func biometricStore(master string) error {
+	cmd, cleanup, err := biometricCommand("store")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Stdin = strings.NewReader(master)
+	return cmd.Run()
+}
+
+// biometricCommand prepares the Swift helper for the given mode
+func biometricCommand(mode string) (*exec.Cmd, func(), error) {
+	if _, err := exec.LookPath("swift"); err != nil {
+		return nil, nil, errBiometricUnavailable
+	}
+	f, err := os.CreateTemp("", "list-ssh-hosts-*.swift")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	if _, err := f.WriteString(biometricScript); err != nil {
+		f.Close()
+		cleanup()
+		return nil, nil, err
+	}
+	f.Close()
+	return exec.Command("swift", f.Name(), mode), cleanup, nil
+}
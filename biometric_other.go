@@ -0,0 +1,11 @@
+//go:build !darwin
+
+package main
+
+func biometricLoad() (string, error) {
+	return "", errBiometricUnavailable
+}
+
+func biometricStore(master string) error {
+	return errBiometricUnavailable
+}
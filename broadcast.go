@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// broadcastHost is one host of a command broadcast to the marked hosts, or of a
+// file copied to them
+type broadcastHost struct {
+	host      string
+	done      bool
+	result    bulkResult
+	output    string
+	truncated bool // output reached the exec_max_output limit and the rest was dropped
+	queued    bool // waiting for its turn in a rolling run
+	skipped   bool // left out when a rolling run was stopped
+}
+
+// status names the state of the host for the results view: queued, running,
+// ok, failed or skipped
+func (h broadcastHost) status() string {
+	switch {
+	case h.skipped:
+		return "skipped"
+	case h.queued:
+		return "queued"
+	case !h.done:
+		return "running"
+	case h.result.succeeded():
+		return "ok"
+	}
+	return "failed"
+}
+
+// outcome describes how the command ended on the host, for failure lists
+func (h broadcastHost) outcome() string {
+	if h.result.Error != "" {
+		return h.result.Error
+	}
+	return fmt.Sprintf("exit %d", h.result.ExitCode)
+}
+
+// broadcastMsg reports the progress of a broadcast: output a host wrote, the
+// result of a host once it finished, or the end of the run
+type broadcastMsg struct {
+	events     <-chan broadcastMsg // where the next message of the run comes from
+	generation int                 // the broadcast the message belongs to, so those of an earlier one are dropped
+	host       string
+	output     string
+	result     *bulkResult
+	end        bool
+}
+
+// waitForBroadcast receives the next message of a broadcast
+func waitForBroadcast(events <-chan broadcastMsg) tea.Cmd {
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+// broadcastWriter passes what a host writes on to the UI as it comes in
+type broadcastWriter struct {
+	events     chan broadcastMsg
+	generation int
+	host       string
+}
+
+func (w broadcastWriter) Write(p []byte) (int, error) {
+	w.events <- broadcastMsg{events: w.events, generation: w.generation, host: w.host, output: string(p)}
+	return len(p), nil
+}
+
+// broadcastRunner does the work of a broadcast on one host, writing its output to w
+type broadcastRunner func(host string, w io.Writer) error
+
+// broadcastCommand runs command on each host within the limits of runRemote.
+// The output includes what the command writes to stderr.
+func broadcastCommand(command string, timeout time.Duration) broadcastRunner {
+	return func(host string, w io.Writer) error {
+		return runRemoteStream(host, "exec 2>&1\n"+command, timeout, w)
+	}
+}
+
+// broadcastOutputDir returns a directory for the output of a broadcast started
+// at t, under broadcasts in the app config directory
+func broadcastOutputDir(t time.Time) (string, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "broadcasts", t.Format("2006-01-02T15-04-05")), nil
+}
+
+// teeBroadcast makes run also write each host's output to <host>.out in dir,
+// and how it ended to <host>.status, as exec -out-dir does
+func teeBroadcast(dir string, run broadcastRunner) broadcastRunner {
+	return func(host string, w io.Writer) error {
+		out, err := os.Create(filepath.Join(dir, host+".out"))
+		if err != nil {
+			return err
+		}
+		err = run(host, io.MultiWriter(out, w))
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if statusErr := writeStatusFile(dir, host, bulkOutcome(err)); err == nil {
+			err = statusErr
+		}
+		return err
+	}
+}
+
+// runBroadcast runs run for hosts in the background, on workers hosts at once,
+// streaming their output
+func runBroadcast(generation int, hosts []string, workers int, run broadcastRunner) tea.Cmd {
+	events := make(chan broadcastMsg, 64)
+	go func() {
+		runParallel(hosts, workers, func(host string) {
+			w := broadcastWriter{events: events, generation: generation, host: host}
+			res := bulkOutcome(run(host, w))
+			events <- broadcastMsg{events: events, generation: generation, host: host, result: &res}
+		})
+		events <- broadcastMsg{events: events, generation: generation, end: true}
+	}()
+	return waitForBroadcast(events)
+}
+
+// broadcastView is a table of the hosts of a broadcast with their state, exit
+// code and the last line of their output, or why the command could not run
+func broadcastView(hosts []broadcastHost) string {
+	width := len("HOST")
+	for _, h := range hosts {
+		width = max(width, len(h.host))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-*s  %-7s  %-4s  %s\n", width, "HOST", "STATUS", "EXIT", "OUTPUT")
+	for _, h := range hosts {
+		exit, detail := "-", ""
+		switch {
+		case !h.done:
+			detail = lastLine(h.output)
+		case h.result.Error != "":
+			detail = h.result.Error
+		default:
+			exit = strconv.Itoa(h.result.ExitCode)
+			detail = lastLine(h.output)
+		}
+		fmt.Fprintf(&b, "%-*s  %-7s  %-4s  %s\n", width, h.host, h.status(), exit, detail)
+	}
+	return b.String()
+}
+
+// broadcastSummary counts the hosts of a broadcast by state and, once all have
+// finished, lists the failed ones with how they failed
+func broadcastSummary(hosts []broadcastHost) string {
+	var running, queued, ok, skipped int
+	var failed []string
+	for _, h := range hosts {
+		switch h.status() {
+		case "running":
+			running++
+		case "queued":
+			queued++
+		case "ok":
+			ok++
+		case "skipped":
+			skipped++
+		default:
+			failed = append(failed, fmt.Sprintf("%s (%s)", h.host, h.outcome()))
+		}
+	}
+	if running > 0 || queued > 0 {
+		summary := fmt.Sprintf("%d running, %d ok, %d failed", running, ok, len(failed))
+		if queued > 0 {
+			summary += fmt.Sprintf(", %d queued", queued)
+		}
+		return summary
+	}
+	summary := fmt.Sprintf("Done: %d ok, %d failed", ok, len(failed))
+	if skipped > 0 {
+		summary += fmt.Sprintf(", %d skipped", skipped)
+	}
+	if len(failed) > 0 {
+		summary += "\nFailed: " + strings.Join(failed, ", ")
+	}
+	return summary
+}
+
+// broadcastTabs is the tab bar of the output viewer: the summary, then a tab per
+// host marked with its state. The tab shown is reversed.
+func broadcastTabs(hosts []broadcastHost, tab int) string {
+	selected := lipgloss.NewStyle().Reverse(true)
+	labels := []string{"summary"}
+	for _, h := range hosts {
+		mark := "…"
+		switch h.status() {
+		case "ok":
+			mark = "✓"
+		case "failed":
+			mark = "✗"
+		case "queued":
+			mark = "·"
+		case "skipped":
+			mark = "-"
+		}
+		labels = append(labels, h.host+" "+mark)
+	}
+	for i, label := range labels {
+		label = " " + label + " "
+		if i == tab {
+			label = selected.Render(label)
+		}
+		labels[i] = label
+	}
+	return strings.Join(labels, "|")
+}
+
+// startBroadcast runs run for hosts and shows their results under title as they
+// come in. A rolling run does one host at a time and waits for confirmation
+// before each next one. Hosts run after the hosts they depend on.
+func (m *model) startBroadcast(title string, hosts []string, run broadcastRunner, rolling bool) tea.Cmd {
+	hosts = buildDependencyGraph(m.metadata, proxyJumps(m.hostItems())).order(hosts)
+	m.broadcastRun++
+	m.broadcastTitle = title
+	m.broadcastHosts = make([]broadcastHost, len(hosts))
+	for i, h := range hosts {
+		m.broadcastHosts[i] = broadcastHost{host: h, queued: rolling}
+	}
+	m.broadcastTab = 0
+	m.showBroadcastTab()
+	m.screen = broadcastScreen
+	m.rollingRunner, m.rollingNext, m.rollingWaiting = nil, 0, false
+	if rolling {
+		m.rollingRunner = run
+		return m.rollNext()
+	}
+	return runBroadcast(m.broadcastRun, hosts, m.config.execWorkers(), run)
+}
+
+// rollNext runs a rolling broadcast on its next host, showing that host's output
+func (m *model) rollNext() tea.Cmd {
+	m.rollingWaiting = false
+	h := &m.broadcastHosts[m.rollingNext]
+	h.queued = false
+	m.broadcastTab = m.rollingNext + 1
+	m.showBroadcastTab()
+	m.broadcastView.GotoBottom()
+	return runBroadcast(m.broadcastRun, []string{h.host}, 1, m.rollingRunner)
+}
+
+// stopRolling skips the hosts a rolling broadcast has not reached. A host it
+// is running on still finishes.
+func (m *model) stopRolling() {
+	m.rollingWaiting = false
+	m.rollingRunner = nil
+	for i := m.rollingNext; i < len(m.broadcastHosts); i++ {
+		if m.broadcastHosts[i].queued {
+			m.broadcastHosts[i].queued, m.broadcastHosts[i].skipped = false, true
+		}
+	}
+	m.rollingNext = len(m.broadcastHosts)
+}
+
+// rollingPrompt asks whether a rolling broadcast goes on after a host finished
+func (m *model) rollingPrompt() string {
+	last, next := m.broadcastHosts[m.rollingNext-1], m.broadcastHosts[m.rollingNext]
+	return fmt.Sprintf("%s: %s. Continue with %s (%d of %d)? y/enter to continue, n to stop",
+		last.host, last.outcome(), next.host, m.rollingNext+1, len(m.broadcastHosts))
+}
+
+// recordBroadcast adds the output or result of a host of the current broadcast.
+// Output beyond exec_max_output is dropped.
+func (m *model) recordBroadcast(msg broadcastMsg) {
+	for i := range m.broadcastHosts {
+		h := &m.broadcastHosts[i]
+		if h.host != msg.host {
+			continue
+		}
+		if msg.result != nil {
+			h.done, h.result = true, *msg.result
+			if m.rollingRunner != nil {
+				m.rollingNext++
+				m.rollingWaiting = m.rollingNext < len(m.broadcastHosts)
+			}
+		}
+		if room := m.config.execMaxOutput() - len(h.output); len(msg.output) > room {
+			h.output += msg.output[:max(0, room)]
+			h.truncated = true
+		} else {
+			h.output += msg.output
+		}
+		if m.broadcastTab == i+1 {
+			m.showBroadcastTab()
+		}
+	}
+}
+
+// showBroadcastTab puts the output of the host of the current tab in the viewer,
+// following the end of the output unless it was scrolled up
+func (m *model) showBroadcastTab() {
+	h, v := docStyle.GetFrameSize()
+	m.broadcastView.Width, m.broadcastView.Height = max(20, m.width-h), max(5, m.height-v-6)
+	if m.broadcastTab == 0 {
+		return
+	}
+	host := m.broadcastHosts[m.broadcastTab-1]
+	content := host.output
+	if host.truncated {
+		content += fmt.Sprintf("\n[output truncated at %d bytes]", m.config.execMaxOutput())
+	}
+	if host.done {
+		content += fmt.Sprintf("\n[%s]", host.outcome())
+	}
+	follow := m.broadcastView.AtBottom()
+	m.broadcastView.SetContent(content)
+	if follow {
+		m.broadcastView.GotoBottom()
+	}
+}
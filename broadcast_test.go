@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBroadcastView(t *testing.T) {
+	hosts := []broadcastHost{
+		{host: "web1", done: true, output: "up 3 days\n"},
+		{host: "db", done: true, result: bulkResult{ExitCode: 2}, output: "disk full\n"},
+		{host: "cache", done: true, result: bulkResult{Error: "ssh failed"}},
+		{host: "edge", done: false},
+	}
+	got := broadcastView(hosts)
+	for _, want := range []string{
+		"web1   ok       0     up 3 days",
+		"db     failed   2     disk full",
+		"cache  failed   -     ssh failed",
+		"edge   running  -",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("broadcastView() missing %q in\n%s", want, got)
+		}
+	}
+	if got, want := broadcastSummary(hosts), "1 running, 1 ok, 2 failed"; got != want {
+		t.Errorf("broadcastSummary() = %q, want %q", got, want)
+	}
+	if got, want := broadcastSummary(hosts[:3]), "Done: 1 ok, 2 failed\nFailed: db (exit 2), cache (ssh failed)"; got != want {
+		t.Errorf("broadcastSummary() = %q, want %q", got, want)
+	}
+}
+
+func TestBroadcastTabs(t *testing.T) {
+	hosts := []broadcastHost{
+		{host: "web1", done: true},
+		{host: "db", done: true, result: bulkResult{ExitCode: 1}},
+		{host: "edge"},
+	}
+	got := broadcastTabs(hosts, 0)
+	for _, want := range []string{"web1 ✓", "db ✗", "edge …"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("broadcastTabs() missing %q in %q", want, got)
+		}
+	}
+}
+
+func TestRollingBroadcast(t *testing.T) {
+	m := &model{}
+	run := func(host string, w io.Writer) error { return nil }
+	m.startBroadcast("uptime", []string{"a", "b", "c"}, run, true)
+	if got := []string{m.broadcastHosts[0].status(), m.broadcastHosts[1].status()}; !slices.Equal(got, []string{"running", "queued"}) {
+		t.Fatalf("unexpected states %q", got)
+	}
+	m.recordBroadcast(broadcastMsg{generation: m.broadcastRun, host: "a", result: &bulkResult{ExitCode: 3}})
+	if !m.rollingWaiting {
+		t.Fatal("expected the run to wait for confirmation")
+	}
+	if got, want := m.rollingPrompt(), "a: exit 3. Continue with b (2 of 3)?"; !strings.HasPrefix(got, want) {
+		t.Errorf("prompt %q does not start with %q", got, want)
+	}
+	m.rollNext()
+	if m.rollingWaiting || m.broadcastHosts[1].status() != "running" || m.broadcastTab != 2 {
+		t.Errorf("expected b to run in its tab, got %+v on tab %d", m.broadcastHosts[1], m.broadcastTab)
+	}
+	m.recordBroadcast(broadcastMsg{generation: m.broadcastRun, host: "b", result: &bulkResult{}})
+	m.stopRolling()
+	if got, want := broadcastSummary(m.broadcastHosts), "Done: 1 ok, 1 failed, 1 skipped\nFailed: a (exit 3)"; got != want {
+		t.Errorf("broadcastSummary() = %q, want %q", got, want)
+	}
+}
+
+func TestStopRollingWhileRunning(t *testing.T) {
+	m := &model{}
+	run := func(host string, w io.Writer) error { return nil }
+	m.startBroadcast("uptime", []string{"a", "b"}, run, true)
+	m.stopRolling()
+	if got := []string{m.broadcastHosts[0].status(), m.broadcastHosts[1].status()}; !slices.Equal(got, []string{"running", "skipped"}) {
+		t.Fatalf("unexpected states %q", got)
+	}
+	m.recordBroadcast(broadcastMsg{generation: m.broadcastRun, host: "a", result: &bulkResult{}})
+	if m.rollingWaiting {
+		t.Error("a stopped run should not wait for confirmation")
+	}
+	if got, want := broadcastSummary(m.broadcastHosts), "Done: 1 ok, 0 failed, 1 skipped"; got != want {
+		t.Errorf("broadcastSummary() = %q, want %q", got, want)
+	}
+}
+
+func TestRollingRunsDependenciesFirst(t *testing.T) {
+	m := &model{metadata: hostMetadata{"app1": {DependsOn: []string{"db1"}}}}
+	run := func(host string, w io.Writer) error { return nil }
+	m.startBroadcast("deploy", []string{"app1", "db1"}, run, true)
+	if got := []string{m.broadcastHosts[0].host, m.broadcastHosts[1].host}; !slices.Equal(got, []string{"db1", "app1"}) {
+		t.Errorf("expected db1 to run before app1, got %v", got)
+	}
+	if m.broadcastHosts[0].queued || !m.broadcastHosts[1].queued {
+		t.Error("expected the rolling run to start with db1")
+	}
+}
+
+func TestTeeBroadcast(t *testing.T) {
+	dir := t.TempDir()
+	run := teeBroadcast(dir, func(host string, w io.Writer) error {
+		fmt.Fprintf(w, "hello from %s\n", host)
+		if host == "db" {
+			return errors.New("ssh failed")
+		}
+		return nil
+	})
+	var shown strings.Builder
+	if err := run("web1", &shown); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("db", io.Discard); err == nil {
+		t.Error("expected the error of the runner")
+	}
+	if shown.String() != "hello from web1\n" {
+		t.Errorf("unexpected output passed on: %q", shown.String())
+	}
+	for file, want := range map[string]string{
+		"web1.out":    "hello from web1\n",
+		"web1.status": "exit 0\n",
+		"db.out":      "hello from db\n",
+		"db.status":   "ssh failed\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: expected %q, got %q (%v)", file, want, got, err)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bulkRun is a command run on many hosts. It is saved after every host, so an
+// interrupted run can be resumed without repeating the hosts that succeeded.
+type bulkRun struct {
+	Command string                `json:"command"`
+	Hosts   []string              `json:"hosts"`
+	Started time.Time             `json:"started"`
+	Results map[string]bulkResult `json:"results,omitempty"`
+}
+
+// bulkResult is the outcome of a bulk command on one host
+type bulkResult struct {
+	ExitCode int       `json:"exit_code"`
+	Error    string    `json:"error,omitempty"` // set when the command could not run at all
+	Finished time.Time `json:"finished"`
+	Output   string    `json:"output,omitempty"` // the file with the full output, when it was truncated
+}
+
+// errTimedOut is the error of a host that did not finish within the time limit
+const errTimedOut = "timed out"
+
+// succeeded reports whether the command ran and exited with status 0
+func (r bulkResult) succeeded() bool {
+	return r.Error == "" && r.ExitCode == 0
+}
+
+// Result filters, as accepted by exec -results
+const (
+	resultSucceeded = "succeeded"
+	resultFailed    = "failed"
+	resultTimeout   = "timeout"
+)
+
+// status names the outcome for filtering: succeeded, timeout, or failed for any other failure
+func (r bulkResult) status() string {
+	switch {
+	case r.succeeded():
+		return resultSucceeded
+	case r.Error == errTimedOut:
+		return resultTimeout
+	}
+	return resultFailed
+}
+
+// matches reports whether the result passes filter. Timeouts count as failed too.
+func (r bulkResult) matches(filter string) bool {
+	switch filter {
+	case "", "all":
+		return true
+	case resultFailed:
+		return !r.succeeded()
+	}
+	return r.status() == filter
+}
+
+// writeBulkResults lists the hosts of a run whose results pass filter, with hosts
+// that never finished shown as not run
+func writeBulkResults(w io.Writer, run *bulkRun, filter string) {
+	fmt.Fprintf(w, "`%s`, started %s\n", run.Command, run.Started.Format("2006-01-02 15:04"))
+	for _, h := range run.Hosts {
+		res, ok := run.Results[h]
+		switch {
+		case !ok && (filter == "" || filter == "all" || filter == resultFailed):
+			fmt.Fprintf(w, "  %-24s not run\n", h)
+		case !ok || !res.matches(filter):
+		case res.Error != "":
+			fmt.Fprintf(w, "  %-24s %s\n", h, res.Error)
+		default:
+			fmt.Fprintf(w, "  %-24s exit %d\n", h, res.ExitCode)
+		}
+		if ok && res.Output != "" && res.matches(filter) {
+			fmt.Fprintf(w, "  %-24s full output in %s\n", "", res.Output)
+		}
+	}
+}
+
+// pending returns the hosts of a run that have not succeeded yet, in run order
+func (r *bulkRun) pending() []string {
+	var hosts []string
+	for _, h := range r.Hosts {
+		if res, ok := r.Results[h]; !ok || !res.succeeded() {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// remoteRunner runs a command on a host, writing its output to stdout; runRemoteStream in production
+type remoteRunner func(host, command string, timeout time.Duration, stdout io.Writer) error
+
+// bulkLimits bounds what one host can cost a bulk run
+type bulkLimits struct {
+	timeout   time.Duration // how long the command may run on each host
+	maxOutput int           // bytes of each host's output kept and printed
+	outputDir string        // where output beyond maxOutput is written in full, one file per host
+	workers   int           // hosts worked on at once, 0 for all of them
+}
+
+// bulkRunPath returns the location of the last bulk run in the app config directory
+func bulkRunPath() (string, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "lastrun.json"), nil
+}
+
+// bulkOutputDir returns the directory for the full output of hosts whose output was truncated
+func bulkOutputDir() (string, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "output"), nil
+}
+
+// readBulkRun reads a saved run. A missing file yields nil.
+func readBulkRun(path string) (*bulkRun, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var run bulkRun
+	err = json.Unmarshal(content, &run)
+	return &run, err
+}
+
+// writeBulkRun saves a run to path
+func writeBulkRun(path string, run *bulkRun) error {
+	content, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0600)
+}
+
+// bulkOutcome turns the error of a remote command into a result. ssh itself exits
+// with 255 when it cannot connect, which is not the command's own status.
+func bulkOutcome(err error) bulkResult {
+	res := bulkResult{Finished: time.Now()}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded):
+		res.Error = errTimedOut
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == sshConnectionError:
+		res.Error = "ssh failed"
+		if len(exitErr.Stderr) > 0 {
+			res.Error += ": " + lastLine(string(exitErr.Stderr))
+		}
+	case errors.As(err, &exitErr) && exitErr.ExitCode() > 0:
+		res.ExitCode = exitErr.ExitCode()
+	default:
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// executeBulkRun runs the command of run on hosts in parallel, on limits.workers hosts
+// at once and within the concurrency limits of runRemote, and prints each host's
+// output as soon as it finishes. Output beyond the limit is only written to a file.
+// The run is saved to path after every host, and each result is passed to sinks.
+func executeBulkRun(run *bulkRun, hosts []string, path string, limits bulkLimits, w io.Writer, runner remoteRunner, sinks []resultSink) error {
+	if run.Results == nil {
+		run.Results = map[string]bulkResult{}
+	}
+	var mu sync.Mutex
+	var saveErr error
+	runParallel(hosts, limits.workers, func(host string) {
+		out := newSpoolWriter(limits.maxOutput, filepath.Join(limits.outputDir, host+".log"))
+		err := runner(host, run.Command, limits.timeout, out)
+		res := bulkOutcome(err)
+		spoolErr := out.Close()
+		if out.truncated() && spoolErr == nil {
+			res.Output = out.path
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		run.Results[host] = res
+		if err := writeBulkRun(path, run); err != nil && saveErr == nil {
+			saveErr = err
+		}
+		for _, sink := range sinks {
+			if err := sink.add(host, res, out.head.String()); err != nil && saveErr == nil {
+				saveErr = err
+			}
+		}
+		status := fmt.Sprintf("exit %d", res.ExitCode)
+		if res.Error != "" {
+			status = res.Error
+		}
+		fmt.Fprintf(w, "== %s (%s)\n", host, status)
+		if head := out.head.String(); head != "" {
+			fmt.Fprint(w, head)
+			if !strings.HasSuffix(head, "\n") {
+				fmt.Fprintln(w)
+			}
+		}
+		switch {
+		case res.Output != "":
+			fmt.Fprintf(w, "[output truncated at %d bytes, the full output is in %s]\n", limits.maxOutput, res.Output)
+		case out.truncated():
+			fmt.Fprintf(w, "[output truncated at %d bytes, the rest could not be saved: %v]\n", limits.maxOutput, spoolErr)
+		}
+	})
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil && saveErr == nil {
+			saveErr = err
+		}
+	}
+	return saveErr
+}
+
+// runExec implements "exec [-tag tag] [-hosts expression] [-timeout duration] [-max-output bytes] <command>",
+// "exec -list-matching", "exec -resume" and "exec -results [filter]"
+func runExec(args []string) int {
+	fs := flag.NewFlagSet("exec", flag.ExitOnError)
+	tag := fs.String("tag", "", "only run on hosts with this tag")
+	selection := fs.String("hosts", "", "only run on hosts matching this expression, e.g. 'tag:prod and not tag:db'")
+	listMatching := fs.Bool("list-matching", false, "list the hosts -tag and -hosts select, without running anything")
+	timeout := fs.Duration("timeout", 0, "time limit per host (default exec_timeout from config.json, or 5m)")
+	workers := fs.Int("workers", 0, "hosts to run on at once (default exec_workers from config.json, or 32)")
+	maxOutput := fs.Int("max-output", 0, "bytes of output to print per host; the rest is saved to a file (default exec_max_output from config.json, or 64 KiB)")
+	resume := fs.Bool("resume", false, "run the last command again on the hosts where it did not succeed")
+	results := fs.String("results", "", "list the hosts of the last run: all, succeeded, failed or timeout")
+	jsonlPath := fs.String("jsonl", "", "also write each host's result as a JSON line to this file")
+	junitPath := fs.String("junit", "", "also write the results as a JUnit XML report to this file")
+	outDir := fs.String("out-dir", "", "also write each host's output and status to <host>.out and <host>.status in this directory")
+	fs.Parse(args)
+
+	if *results != "" {
+		switch *results {
+		case "all", resultSucceeded, resultFailed, resultTimeout:
+		default:
+			fmt.Println("Unknown filter", *results+"; use all, succeeded, failed or timeout")
+			return 2
+		}
+		path, err := bulkRunPath()
+		if err != nil {
+			fmt.Println("Could not find app config directory:", err)
+			return 1
+		}
+		run, err := readBulkRun(path)
+		if err != nil || run == nil {
+			fmt.Println("There is no previous run to show.")
+			return 1
+		}
+		writeBulkResults(os.Stdout, run, *results)
+		return 0
+	}
+
+	cfg, err := loadAppConfig()
+	if err != nil {
+		fmt.Println("Could not read app config:", err)
+		return 1
+	}
+	md, err := loadHostMetadata()
+	if err != nil {
+		fmt.Println("Could not read host metadata:", err)
+		return 1
+	}
+	path, err := bulkRunPath()
+	if err != nil {
+		fmt.Println("Could not find app config directory:", err)
+		return 1
+	}
+
+	var run *bulkRun
+	var hosts []string
+	if *resume {
+		run, err = readBulkRun(path)
+		if err != nil {
+			fmt.Println("Could not read the last run:", err)
+			return 1
+		}
+		if run == nil {
+			fmt.Println("There is no run to resume.")
+			return 1
+		}
+		hosts = run.pending()
+		if len(hosts) == 0 {
+			fmt.Println("The last run succeeded on all hosts.")
+			return 0
+		}
+		fmt.Printf("Resuming `%s` on %d of %d hosts.\n", run.Command, len(hosts), len(run.Hosts))
+	} else {
+		command := strings.Join(fs.Args(), " ")
+		if command == "" && !*listMatching {
+			fmt.Println("Usage: list-ssh-hosts exec [-tag tag] [-hosts expression] [-timeout duration] [-max-output bytes] <command> | exec -resume")
+			return 2
+		}
+		configPath, err := sshConfigPath()
+		if err != nil {
+			fmt.Println("Could not get current user:", err)
+			return 1
+		}
+		items, err := parseSSHConfig(configPath)
+		if err != nil {
+			fmt.Println("Could not parse ~/.ssh/config:", err)
+			return 1
+		}
+		filter := func(hostItem, hostMeta) bool { return true }
+		if *selection != "" {
+			if filter, err = parseHostSelection(*selection); err != nil {
+				fmt.Println(err)
+				return 2
+			}
+		}
+		for _, h := range selectHosts(items, md, filter) {
+			if *tag == "" || md.hasTag(h, *tag) {
+				hosts = append(hosts, h)
+			}
+		}
+		// Hosts start after the hosts they depend on
+		hosts = buildDependencyGraph(md, proxyJumps(items)).order(hosts)
+		if *listMatching {
+			for _, h := range hosts {
+				fmt.Println(h)
+			}
+			fmt.Printf("%d hosts match.\n", len(hosts))
+			return 0
+		}
+		if len(hosts) == 0 {
+			fmt.Println("No hosts to run on.")
+			return 1
+		}
+		run = &bulkRun{Command: command, Hosts: hosts, Started: time.Now()}
+	}
+	if err := checkFreeze(cfg, md, hosts); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	limits := bulkLimits{timeout: cfg.execTimeout(), maxOutput: cfg.execMaxOutput(), workers: cfg.execWorkers()}
+	if *workers > 0 {
+		limits.workers = *workers
+	}
+	if *timeout > 0 {
+		limits.timeout = *timeout
+	}
+	if *maxOutput > 0 {
+		limits.maxOutput = *maxOutput
+	}
+	if limits.outputDir, err = bulkOutputDir(); err != nil {
+		fmt.Println("Could not find app config directory:", err)
+		return 1
+	}
+	if !*resume {
+		// Full output of the previous run would be mistaken for this one's
+		os.RemoveAll(limits.outputDir)
+	}
+
+	var sinks []resultSink
+	if *jsonlPath != "" {
+		sink, err := newJSONLSink(*jsonlPath, run.Command)
+		if err != nil {
+			fmt.Println("Could not create the JSON lines file:", err)
+			return 1
+		}
+		sinks = append(sinks, sink)
+	}
+	if *junitPath != "" {
+		sinks = append(sinks, newJUnitSink(*junitPath, run.Command))
+	}
+	if *outDir != "" {
+		sink, err := newDirSink(*outDir)
+		if err != nil {
+			fmt.Println("Could not create the output directory:", err)
+			return 1
+		}
+		sinks = append(sinks, sink)
+	}
+
+	remoteLimiter = newBulkLimiter(cfg.Concurrency, md)
+	remoteConnectTimeout = cfg.connectTimeout()
+	if err := executeBulkRun(run, hosts, path, limits, os.Stdout, runRemoteStream, sinks); err != nil {
+		fmt.Println("Could not save the results:", err)
+	}
+	failed := len(run.pending())
+	fmt.Printf("\n%d of %d hosts succeeded.\n", len(run.Hosts)-failed, len(run.Hosts))
+	if failed > 0 {
+		fmt.Println("Run `list-ssh-hosts exec -resume` to retry the others.")
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteBulkRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lastrun.json")
+	failing := exec.Command("sh", "-c", "exit 3").Run()
+	runner := func(host, command string, timeout time.Duration, stdout io.Writer) error {
+		switch host {
+		case "web2":
+			fmt.Fprint(stdout, "partial\n")
+			return failing
+		case "web3":
+			return context.DeadlineExceeded
+		case "web4":
+			fmt.Fprint(stdout, strings.Repeat("log line\n", 10))
+			return nil
+		}
+		fmt.Fprint(stdout, host+": "+command)
+		return nil
+	}
+	run := &bulkRun{Command: "uptime", Hosts: []string{"web1", "web2", "web3", "web4"}}
+	limits := bulkLimits{timeout: time.Minute, maxOutput: 18, outputDir: filepath.Join(t.TempDir(), "output")}
+	var out bytes.Buffer
+	if err := executeBulkRun(run, run.Hosts, path, limits, &out, runner, nil); err != nil {
+		t.Fatalf("executeBulkRun failed: %v", err)
+	}
+	full := filepath.Join(limits.outputDir, "web4.log")
+	if !strings.Contains(out.String(), "== web1 (exit 0)\nweb1: uptime\n") ||
+		!strings.Contains(out.String(), "== web2 (exit 3)\npartial\n") ||
+		!strings.Contains(out.String(), "== web3 (timed out)\n") ||
+		!strings.Contains(out.String(), "== web4 (exit 0)\nlog line\nlog line\n[output truncated at 18 bytes, the full output is in "+full+"]\n") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+	if run.Results["web4"].Output != full || run.Results["web1"].Output != "" {
+		t.Errorf("expected only web4 to have its output saved, got %+v", run.Results)
+	}
+
+	saved, err := readBulkRun(path)
+	if err != nil || saved == nil {
+		t.Fatalf("expected the run to be saved: %v", err)
+	}
+	if got := saved.pending(); !slices.Equal(got, []string{"web2", "web3"}) {
+		t.Errorf("expected web2 and web3 to be pending, got %v", got)
+	}
+
+	// Resuming runs only the pending hosts
+	var ran []string
+	resumed := func(host, command string, timeout time.Duration, stdout io.Writer) error {
+		ran = append(ran, host)
+		return nil
+	}
+	if err := executeBulkRun(saved, []string{"web2"}, path, limits, &out, resumed, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ran, []string{"web2"}) {
+		t.Errorf("expected only web2 to run, got %v", ran)
+	}
+	if got := saved.pending(); !slices.Equal(got, []string{"web3"}) {
+		t.Errorf("expected web3 to remain pending, got %v", got)
+	}
+}
+
+func TestReadBulkRunMissing(t *testing.T) {
+	run, err := readBulkRun(filepath.Join(t.TempDir(), "lastrun.json"))
+	if run != nil || err != nil {
+		t.Errorf("expected no run and no error, got %v, %v", run, err)
+	}
+	if res := bulkOutcome(errors.New("exec: \"ssh\": executable file not found")); res.succeeded() {
+		t.Error("expected a command that could not start to fail")
+	}
+}
+
+func TestWriteBulkResults(t *testing.T) {
+	run := &bulkRun{
+		Command: "uptime",
+		Hosts:   []string{"web1", "web2", "web3", "web4"},
+		Started: time.Date(2026, 5, 1, 9, 30, 0, 0, time.UTC),
+		Results: map[string]bulkResult{
+			"web1": {},
+			"web2": {ExitCode: 3},
+			"web3": {Error: "timed out"},
+		},
+	}
+	var out bytes.Buffer
+	writeBulkResults(&out, run, resultFailed)
+	expected := "`uptime`, started 2026-05-01 09:30\n" +
+		"  web2                     exit 3\n" +
+		"  web3                     timed out\n" +
+		"  web4                     not run\n"
+	if out.String() != expected {
+		t.Errorf("unexpected failed results:\n%s", out.String())
+	}
+
+	out.Reset()
+	writeBulkResults(&out, run, resultTimeout)
+	if strings.Contains(out.String(), "web2") || !strings.Contains(out.String(), "web3") {
+		t.Errorf("expected only the timeout, got:\n%s", out.String())
+	}
+	out.Reset()
+	writeBulkResults(&out, run, resultSucceeded)
+	if !strings.Contains(out.String(), "web1") || strings.Contains(out.String(), "web4") {
+		t.Errorf("expected only web1, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// bundleFiles are the files of the app config directory moved by a bundle. The vault
+// is only included on request; it stays encrypted with the master password.
+var bundleFiles = []string{"config.json", "hosts.json", "state.json", "history.jsonl", "snippets.yaml"}
+
+const bundleVault = "vault.json"
+
+// writeBundle packs the named files of dir into a gzipped tar archive. Missing files are skipped.
+func writeBundle(w io.Writer, dir string, names []string) ([]string, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	var written []string
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		hdr := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), ModTime: time.Now()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return nil, err
+		}
+		written = append(written, name)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return written, gz.Close()
+}
+
+// readBundle unpacks a bundle into dir. Only known file names are accepted, and existing
+// files are kept unless overwrite is set.
+func readBundle(r io.Reader, dir string, overwrite bool) ([]string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !contains(append(bundleFiles, bundleVault), hdr.Name) {
+			return nil, fmt.Errorf("unexpected file %q in bundle", hdr.Name)
+		}
+		if files[hdr.Name], err = io.ReadAll(tr); err != nil {
+			return nil, err
+		}
+	}
+
+	var names []string
+	for _, name := range append(bundleFiles, bundleVault) {
+		if _, ok := files[name]; !ok {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil && !overwrite {
+			return nil, fmt.Errorf("%s already exists; use -force to replace it", name)
+		}
+		names = append(names, name)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), files[name], 0600); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// runExportBundle implements "export-bundle [-secrets] <file>"
+func runExportBundle(args []string) int {
+	fs := flag.NewFlagSet("export-bundle", flag.ExitOnError)
+	secrets := fs.Bool("secrets", false, "include the password vault (still encrypted with the master password)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: list-ssh-hosts export-bundle [-secrets] <file>")
+		return 2
+	}
+
+	dir, err := appConfigDir()
+	if err != nil {
+		fmt.Println("Could not find app config directory:", err)
+		return 1
+	}
+	names := bundleFiles
+	if *secrets {
+		names = append(names, bundleVault)
+	}
+	f, err := os.OpenFile(fs.Arg(0), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Println("Could not create bundle:", err)
+		return 1
+	}
+	defer f.Close()
+	written, err := writeBundle(f, dir, names)
+	if err != nil {
+		fmt.Println("Could not write bundle:", err)
+		return 1
+	}
+	fmt.Printf("Wrote %v to %s\n", written, fs.Arg(0))
+	return 0
+}
+
+// runImportBundle implements "import-bundle [-force] <file>"
+func runImportBundle(args []string) int {
+	fs := flag.NewFlagSet("import-bundle", flag.ExitOnError)
+	force := fs.Bool("force", false, "replace existing files")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: list-ssh-hosts import-bundle [-force] <file>")
+		return 2
+	}
+
+	dir, err := appConfigDir()
+	if err != nil {
+		fmt.Println("Could not find app config directory:", err)
+		return 1
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Println("Could not open bundle:", err)
+		return 1
+	}
+	defer f.Close()
+	names, err := readBundle(f, dir, *force)
+	if err != nil {
+		fmt.Println("Could not import bundle:", err)
+		return 1
+	}
+	fmt.Printf("Imported %v into %s\n", names, dir)
+	return 0
+}
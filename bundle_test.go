@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	for name, content := range map[string]string{
+		"config.json": `{"secret_backend":"vault"}`,
+		"hosts.json":  `{"db1":{"tags":["db"]}}`,
+		"vault.json":  `{"version":1}`,
+	} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var bundle bytes.Buffer
+	written, err := writeBundle(&bundle, src, bundleFiles)
+	if err != nil {
+		t.Fatalf("writeBundle failed: %v", err)
+	}
+	if len(written) != 2 {
+		t.Errorf("expected config and metadata without the vault, got %v", written)
+	}
+
+	dst := t.TempDir()
+	if _, err := readBundle(bytes.NewReader(bundle.Bytes()), dst, false); err != nil {
+		t.Fatalf("readBundle failed: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dst, "hosts.json")); string(content) != `{"db1":{"tags":["db"]}}` {
+		t.Errorf("unexpected hosts.json %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "vault.json")); err == nil {
+		t.Error("expected no vault without -secrets")
+	}
+
+	if _, err := readBundle(bytes.NewReader(bundle.Bytes()), dst, false); err == nil {
+		t.Error("expected existing files to be kept without overwrite")
+	}
+	if _, err := readBundle(bytes.NewReader(bundle.Bytes()), dst, true); err != nil {
+		t.Errorf("expected overwrite to succeed: %v", err)
+	}
+}
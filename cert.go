@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// certInfo describes an SSH user certificate available for a host
+type certInfo struct {
+	source      string // file path or agent key comment
+	validAfter  time.Time
+	validBefore time.Time
+	forever     bool
+}
+
+// parseCertificate reads a certificate in authorized_keys format
+func parseCertificate(source string, content []byte) (certInfo, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(content)
+	if err != nil {
+		return certInfo{}, err
+	}
+	return certFromKey(source, pub)
+}
+
+func certFromKey(source string, pub ssh.PublicKey) (certInfo, error) {
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return certInfo{}, fmt.Errorf("%s is not a certificate", source)
+	}
+	c := certInfo{source: source, validAfter: time.Unix(int64(cert.ValidAfter), 0)}
+	if cert.ValidBefore == ssh.CertTimeInfinity {
+		c.forever = true
+	} else {
+		c.validBefore = time.Unix(int64(cert.ValidBefore), 0)
+	}
+	return c, nil
+}
+
+// expired reports whether the certificate is past its validity period
+func (c certInfo) expired(now time.Time) bool {
+	return !c.forever && now.After(c.validBefore)
+}
+
+// describe renders the certificate validity for the info box
+func (c certInfo) describe(now time.Time) string {
+	switch {
+	case c.forever:
+		return fmt.Sprintf("Certificate: %s (no expiry)", c.source)
+	case c.expired(now):
+		return fmt.Sprintf("Certificate: %s EXPIRED %s", c.source, c.validBefore.Format("2006-01-02 15:04"))
+	case now.Before(c.validAfter):
+		return fmt.Sprintf("Certificate: %s not valid before %s", c.source, c.validAfter.Format("2006-01-02 15:04"))
+	}
+	return fmt.Sprintf("Certificate: %s valid until %s (%s left)", c.source,
+		c.validBefore.Format("2006-01-02 15:04"), c.validBefore.Sub(now).Round(time.Minute))
+}
+
+// hostCertificates collects the certificates ssh would offer to a host: its
+// CertificateFile options, the -cert.pub next to each IdentityFile, and agent certificates
+func hostCertificates(host string) []certInfo {
+	var certs []certInfo
+	if target, err := resolveSSHTarget(host); err == nil {
+		files := append([]string(nil), target.certificateFiles...)
+		for _, f := range target.identityFiles {
+			files = append(files, f+"-cert.pub")
+		}
+		for _, f := range files {
+			content, err := os.ReadFile(expandHome(f))
+			if err != nil {
+				continue
+			}
+			if c, err := parseCertificate(f, content); err == nil {
+				certs = append(certs, c)
+			}
+		}
+	}
+	return append(certs, agentCertificates()...)
+}
+
+// agentCertificates returns the certificates held by the SSH agent
+func agentCertificates() []certInfo {
+	keys, err := agentKeys()
+	if err != nil {
+		return nil
+	}
+	var certs []certInfo
+	for _, k := range keys {
+		pub, err := ssh.ParsePublicKey(k.Blob)
+		if err != nil {
+			continue
+		}
+		if c, err := certFromKey("agent "+k.Comment, pub); err == nil {
+			certs = append(certs, c)
+		}
+	}
+	return certs
+}
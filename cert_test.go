@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestCertificate(t *testing.T, validBefore uint64) []byte {
+	t.Helper()
+	_, userKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ssh.NewPublicKey(userKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &ssh.Certificate{
+		Key:         pub,
+		CertType:    ssh.UserCert,
+		ValidAfter:  uint64(time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC).Unix()),
+		ValidBefore: validBefore,
+	}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		t.Fatal(err)
+	}
+	return ssh.MarshalAuthorizedKey(cert)
+}
+
+func TestParseCertificate(t *testing.T) {
+	expiry := time.Date(2025, 7, 22, 0, 0, 0, 0, time.UTC)
+	c, err := parseCertificate("id_ed25519-cert.pub", newTestCertificate(t, uint64(expiry.Unix())))
+	if err != nil {
+		t.Fatalf("parseCertificate failed: %v", err)
+	}
+	if !c.validBefore.Equal(expiry) {
+		t.Errorf("expected expiry %v, got %v", expiry, c.validBefore)
+	}
+	if c.expired(expiry.Add(-time.Hour)) {
+		t.Error("certificate should still be valid an hour before expiry")
+	}
+	if !c.expired(expiry.Add(time.Hour)) {
+		t.Error("certificate should be expired an hour after expiry")
+	}
+}
+
+func TestParseCertificate_NoExpiry(t *testing.T) {
+	c, err := parseCertificate("cert", newTestCertificate(t, ssh.CertTimeInfinity))
+	if err != nil {
+		t.Fatalf("parseCertificate failed: %v", err)
+	}
+	if c.expired(time.Now().AddDate(100, 0, 0)) {
+		t.Error("certificate without expiry should never expire")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// concurrencyConfig limits how many hosts bulk operations work on at once
+type concurrencyConfig struct {
+	// Max caps the remote commands running at the same time; 0 means no limit
+	Max int `json:"max,omitempty"`
+	// PerTag caps the running commands on hosts carrying a tag, e.g. {"pdu": 2};
+	// like Max, 0 means no limit
+	PerTag map[string]int `json:"per_tag,omitempty"`
+	// HostIntervalMs is the minimum time in milliseconds between commands on one host
+	HostIntervalMs int `json:"host_interval_ms,omitempty"`
+}
+
+// bulkLimiter enforces a concurrencyConfig on remote commands
+type bulkLimiter struct {
+	cfg      concurrencyConfig
+	metadata hostMetadata
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	running int
+	perTag  map[string]int
+	nextRun map[string]time.Time // earliest start of the next command per host
+}
+
+// remoteLimiter throttles runRemote; main installs one from the app config
+var remoteLimiter = newBulkLimiter(concurrencyConfig{}, nil)
+
+func newBulkLimiter(cfg concurrencyConfig, md hostMetadata) *bulkLimiter {
+	l := &bulkLimiter{cfg: cfg, metadata: md, perTag: map[string]int{}, nextRun: map[string]time.Time{}}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// limitedTags returns the tags of host that have a cap
+func (l *bulkLimiter) limitedTags(host string) []string {
+	var tags []string
+	for _, tag := range l.metadata[host].Tags {
+		if l.cfg.PerTag[tag] > 0 {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// full reports whether starting a command on a host with tags would exceed a cap
+func (l *bulkLimiter) full(tags []string) bool {
+	if l.cfg.Max > 0 && l.running >= l.cfg.Max {
+		return true
+	}
+	for _, tag := range tags {
+		if l.perTag[tag] >= l.cfg.PerTag[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire blocks until a command may run on host and returns the function that
+// releases its slot
+func (l *bulkLimiter) acquire(host string) func() {
+	tags := l.limitedTags(host)
+	l.mu.Lock()
+	for l.full(tags) {
+		l.cond.Wait()
+	}
+	l.running++
+	for _, tag := range tags {
+		l.perTag[tag]++
+	}
+	// Reserve the host's next start now, so concurrent callers queue up behind it
+	start := time.Now()
+	if next := l.nextRun[host]; next.After(start) {
+		start = next
+	}
+	l.nextRun[host] = start.Add(time.Duration(l.cfg.HostIntervalMs) * time.Millisecond)
+	l.mu.Unlock()
+
+	time.Sleep(time.Until(start))
+	return func() {
+		l.mu.Lock()
+		l.running--
+		for _, tag := range tags {
+			l.perTag[tag]--
+		}
+		l.mu.Unlock()
+		l.cond.Broadcast()
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// peakConcurrency runs one short job per host through l and returns the most jobs
+// that ran at once, overall and on hosts tagged tag
+func peakConcurrency(l *bulkLimiter, hosts []string, md hostMetadata, tag string) (int, int) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	running, tagged, peak, peakTagged := 0, 0, 0, 0
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			release := l.acquire(host)
+			defer release()
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			if md.hasTag(host, tag) {
+				tagged++
+				peakTagged = max(peakTagged, tagged)
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			if md.hasTag(host, tag) {
+				tagged--
+			}
+			mu.Unlock()
+		}(h)
+	}
+	wg.Wait()
+	return peak, peakTagged
+}
+
+func TestBulkLimiter(t *testing.T) {
+	md := hostMetadata{
+		"pdu1": {Tags: []string{"pdu"}},
+		"pdu2": {Tags: []string{"pdu"}},
+		"pdu3": {Tags: []string{"pdu"}},
+		"pdu4": {Tags: []string{"pdu"}},
+	}
+	hosts := []string{"pdu1", "pdu2", "pdu3", "pdu4", "web1", "web2", "web3", "web4"}
+
+	peak, _ := peakConcurrency(newBulkLimiter(concurrencyConfig{Max: 3}, md), hosts, md, "pdu")
+	if peak > 3 {
+		t.Errorf("expected at most 3 jobs at once, got %d", peak)
+	}
+	peak, peakTagged := peakConcurrency(newBulkLimiter(concurrencyConfig{PerTag: map[string]int{"pdu": 1}}, md), hosts, md, "pdu")
+	if peakTagged != 1 {
+		t.Errorf("expected one pdu job at a time, got %d", peakTagged)
+	}
+	if peak < 2 {
+		t.Errorf("expected untagged hosts to run alongside, got a peak of %d", peak)
+	}
+}
+
+func TestBulkLimiterZeroPerTag(t *testing.T) {
+	md := hostMetadata{"pdu1": {Tags: []string{"pdu"}}, "pdu2": {Tags: []string{"pdu", "rack"}}}
+	l := newBulkLimiter(concurrencyConfig{PerTag: map[string]int{"pdu": 0, "rack": -1}}, md)
+	if tags := l.limitedTags("pdu2"); tags != nil {
+		t.Errorf("a limit of 0 or less should not cap a tag, got %q", tags)
+	}
+	done := make(chan struct{})
+	go func() {
+		l.acquire("pdu1")()
+		l.acquire("pdu2")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("acquire blocked on a tag without a limit")
+	}
+}
+
+func TestBulkLimiterHostInterval(t *testing.T) {
+	l := newBulkLimiter(concurrencyConfig{HostIntervalMs: 50}, nil)
+	started := time.Now()
+	l.acquire("web1")()
+	l.acquire("web2")()
+	if time.Since(started) > 40*time.Millisecond {
+		t.Error("expected different hosts not to wait for each other")
+	}
+	l.acquire("web1")()
+	if time.Since(started) < 50*time.Millisecond {
+		t.Error("expected the second command on web1 to wait for the interval")
+	}
+}
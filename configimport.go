@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// How a block fetched from another machine relates to the local config
+const (
+	blockNew = iota
+	blockSame
+	blockConflict
+)
+
+// How a conflicting block is merged
+const (
+	resolveReplace = iota // the remote block replaces the local one
+	resolveRename         // the remote block is added under <host>-<source>
+)
+
+// remoteBlock is a Host block from another machine's SSH config
+type remoteBlock struct {
+	name       string
+	lines      []string
+	status     int
+	selected   bool
+	resolution int
+}
+
+// remoteConfigMsg delivers the config blocks fetched from a host
+type remoteConfigMsg struct {
+	source string
+	blocks []remoteBlock
+	err    error
+}
+
+// fetchRemoteConfig reads ~/.ssh/config of a host over key-based SSH and compares
+// its blocks with the local config
+func fetchRemoteConfig(source, localConfig string) tea.Cmd {
+	return func() tea.Msg {
+		out, err := runRemote(source, "cat ~/.ssh/config")
+		if err != nil {
+			return remoteConfigMsg{source: source, err: err}
+		}
+		return remoteConfigMsg{source: source, blocks: compareRemoteBlocks(localConfig, out)}
+	}
+}
+
+// compareRemoteBlocks classifies each remote Host block against the local config.
+// New blocks start out selected; conflicts have to be picked deliberately.
+func compareRemoteBlocks(local, remote string) []remoteBlock {
+	localLines := strings.Split(local, "\n")
+	var blocks []remoteBlock
+	for _, b := range getAllHostBlocks(strings.Split(remote, "\n")) {
+		rb := remoteBlock{name: b.hostName, lines: trimBlock(b.lines), status: blockNew, selected: true}
+		if existing := getHostBlock(localLines, b.hostName); existing != nil {
+			rb.selected = false
+			rb.status = blockConflict
+			if sameBlock(existing.lines, b.lines) {
+				rb.status = blockSame
+			}
+		}
+		blocks = append(blocks, rb)
+	}
+	return blocks
+}
+
+// trimBlock drops trailing blank lines from a block
+func trimBlock(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// sameBlock compares two blocks ignoring indentation and blank lines
+func sameBlock(a, b []string) bool {
+	normalize := func(lines []string) []string {
+		var out []string
+		for _, l := range lines {
+			if l = strings.Join(strings.Fields(l), " "); l != "" {
+				out = append(out, l)
+			}
+		}
+		return out
+	}
+	na, nb := normalize(a), normalize(b)
+	if len(na) != len(nb) {
+		return false
+	}
+	for i := range na {
+		if na[i] != nb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// removeHostBlock returns lines without the block declaring host. A block shared
+// with other aliases, as in "Host a b", stays for them with only host removed.
+func removeHostBlock(lines []string, host string) []string {
+	var out []string
+	skipping := false
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && strings.EqualFold(fields[0], "host") {
+			skipping = false
+			if !contains(fields[1:], host) {
+				out = append(out, line)
+				continue
+			}
+			others := slices.DeleteFunc(slices.Clone(fields[1:]), func(name string) bool { return name == host })
+			if len(others) == 0 {
+				skipping = true
+				continue
+			}
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			out = append(out, indent+fields[0]+" "+strings.Join(others, " "))
+			continue
+		} else if skipping && len(line) > 0 && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			skipping = false
+		}
+		if !skipping {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// mergeRemoteBlocks applies the selected blocks to a local config and returns the
+// result along with the number of hosts added or replaced
+func mergeRemoteBlocks(local, source string, blocks []remoteBlock) (string, int) {
+	lines := strings.Split(strings.TrimRight(local, "\n"), "\n")
+	if local == "" {
+		lines = nil
+	}
+	merged := 0
+	for _, b := range blocks {
+		if !b.selected || b.status == blockSame {
+			continue
+		}
+		block := append([]string(nil), b.lines...)
+		if b.status == blockConflict {
+			if b.resolution == resolveRename {
+				block[0] = "Host " + b.name + "-" + source
+			} else {
+				lines = trimBlock(removeHostBlock(lines, b.name))
+			}
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, block...)
+		merged++
+	}
+	return strings.Join(lines, "\n") + "\n", merged
+}
+
+// describe renders a block as one line of the import screen
+func (b remoteBlock) describe(source string) string {
+	switch b.status {
+	case blockSame:
+		return fmt.Sprintf("%-30s identical", b.name)
+	case blockConflict:
+		if b.resolution == resolveRename {
+			return fmt.Sprintf("%-30s differs: add as %s-%s", b.name, b.name, source)
+		}
+		return fmt.Sprintf("%-30s differs: replace local block", b.name)
+	}
+	return fmt.Sprintf("%-30s new", b.name)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeRemoteBlocks(t *testing.T) {
+	local := "Host web1\n    Hostname 10.0.0.1\n\nHost db1\n    Hostname 10.0.0.5\n"
+	remote := "Host web1\n  Hostname 10.0.0.1\nHost db1\n    Hostname 10.1.0.5\n\nHost cache1\n    Hostname 10.0.0.9\n"
+
+	blocks := compareRemoteBlocks(local, remote)
+	if len(blocks) != 3 {
+		t.Fatalf("expected 3 blocks, got %+v", blocks)
+	}
+	if blocks[0].status != blockSame || blocks[1].status != blockConflict || blocks[2].status != blockNew {
+		t.Fatalf("unexpected statuses %+v", blocks)
+	}
+	if blocks[1].selected || !blocks[2].selected {
+		t.Errorf("expected only new blocks to start out selected")
+	}
+
+	merged, n := mergeRemoteBlocks(local, "oldlaptop", blocks)
+	if n != 1 || merged != local+"\nHost cache1\n    Hostname 10.0.0.9\n" {
+		t.Errorf("unexpected merge of new blocks (%d):\n%s", n, merged)
+	}
+
+	blocks[1].selected = true
+	merged, n = mergeRemoteBlocks(local, "oldlaptop", blocks)
+	want := "Host web1\n    Hostname 10.0.0.1\n\nHost db1\n    Hostname 10.1.0.5\n\nHost cache1\n    Hostname 10.0.0.9\n"
+	if n != 2 || merged != want {
+		t.Errorf("unexpected merge replacing db1 (%d):\n%s", n, merged)
+	}
+
+	blocks[1].resolution = resolveRename
+	merged, _ = mergeRemoteBlocks(local, "oldlaptop", blocks)
+	want = local + "\nHost db1-oldlaptop\n    Hostname 10.1.0.5\n\nHost cache1\n    Hostname 10.0.0.9\n"
+	if merged != want {
+		t.Errorf("unexpected merge renaming db1:\n%s", merged)
+	}
+}
+
+func TestRemoveHostBlockSharedAliases(t *testing.T) {
+	lines := []string{"Host db1 db2", "    Hostname 10.0.0.5", "", "Host web1", "    Hostname 10.0.0.1"}
+	got := strings.Join(removeHostBlock(lines, "db1"), "\n")
+	want := "Host db2\n    Hostname 10.0.0.5\n\nHost web1\n    Hostname 10.0.0.1"
+	if got != want {
+		t.Errorf("expected only db1 removed from the shared block, got:\n%s", got)
+	}
+	got = strings.Join(removeHostBlock(lines, "web1"), "\n")
+	if want := "Host db1 db2\n    Hostname 10.0.0.5\n"; got != want {
+		t.Errorf("expected the web1 block removed, got:\n%q", got)
+	}
+}
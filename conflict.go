@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+// hostKeyConflict describes the known_hosts entry OpenSSH refused a connection over
+type hostKeyConflict struct {
+	host  string // host as named in known_hosts, e.g. [example.com]:2222
+	file  string
+	line  int
+	entry string // the offending known_hosts line
+}
+
+var (
+	offendingKeyRe = regexp.MustCompile(`Offending (?:\S+ )?key in (.+):(\d+)`)
+	changedHostRe  = regexp.MustCompile(`Host key for (\S+) has changed`)
+)
+
+// parseHostKeyConflict recognizes OpenSSH's "REMOTE HOST IDENTIFICATION HAS CHANGED" failure
+func parseHostKeyConflict(stderr string) (hostKeyConflict, bool) {
+	offending := offendingKeyRe.FindStringSubmatch(stderr)
+	changed := changedHostRe.FindStringSubmatch(stderr)
+	if offending == nil || changed == nil {
+		return hostKeyConflict{}, false
+	}
+	line, _ := strconv.Atoi(offending[2])
+	c := hostKeyConflict{host: changed[1], file: offending[1], line: line}
+	c.entry, _ = knownHostsLine(c.file, c.line)
+	return c, true
+}
+
+// knownHostsLine returns line n (1-based) of a known_hosts file
+func knownHostsLine(path string, n int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024)
+	for i := 1; scanner.Scan(); i++ {
+		if i == n {
+			return scanner.Text(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s has no line %d", path, n)
+}
+
+// remove deletes every key for the host from the known_hosts file, as ssh-keygen -R does
+func (c hostKeyConflict) remove() error {
+	out, err := exec.Command("ssh-keygen", "-R", c.host, "-f", c.file).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ssh-keygen -R failed: %s", lastLine(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseHostKeyConflict(t *testing.T) {
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	content := "other.example.com ssh-ed25519 AAAA1\n[bastion.example.com]:2222 ssh-ed25519 AAAA2\n"
+	if err := os.WriteFile(knownHosts, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stderr := `@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@
+@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @
+@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@
+IT IS POSSIBLE THAT SOMEONE IS DOING SOMETHING NASTY!
+Someone could be eavesdropping on you right now (man-in-the-middle attack)!
+It is also possible that a host key has just been changed.
+The fingerprint for the ED25519 key sent by the remote host is
+SHA256:6GSAx+kl7hYI6/KUQg2+lID5zz6TI3I+c+M5T4XQNew.
+Please contact your system administrator.
+Add correct host key in ` + knownHosts + ` to get rid of this message.
+Offending ED25519 key in ` + knownHosts + `:2
+  remove with:
+  ssh-keygen -f '` + knownHosts + `' -R '[bastion.example.com]:2222'
+Host key for [bastion.example.com]:2222 has changed and you have requested strict checking.
+Host key verification failed.
+`
+	c, ok := parseHostKeyConflict(stderr)
+	if !ok {
+		t.Fatal("expected a host key conflict")
+	}
+	if c.host != "[bastion.example.com]:2222" || c.file != knownHosts || c.line != 2 {
+		t.Errorf("unexpected conflict %+v", c)
+	}
+	if c.entry != "[bastion.example.com]:2222 ssh-ed25519 AAAA2" {
+		t.Errorf("unexpected entry %q", c.entry)
+	}
+
+	if _, ok := parseHostKeyConflict("Permission denied, please try again.\n"); ok {
+		t.Error("expected no conflict for a wrong password")
+	}
+}
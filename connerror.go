@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Causes of a failed login test, told apart to give advice that fits
+const (
+	failureUnknown = iota
+	failureCredentials
+	failurePublicKeyOnly
+	failureDNS
+	failureTimeout
+	failureRefused
+	failureUnreachable
+	failureHostKey
+)
+
+// classifyLoginFailure finds the cause of a failed login test from the error and the
+// stderr of ssh, or from the error of the built-in client, which has no stderr
+func classifyLoginFailure(err error, stderr string) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.ExitCode() {
+		case sshpassWrongPassword:
+			return failureCredentials
+		case sshpassHostKeyUnknown:
+			return failureHostKey
+		}
+	}
+
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return failureDNS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return failureTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return failureRefused
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return failureUnreachable
+	}
+
+	text := stderr
+	if err != nil {
+		text += "\n" + err.Error()
+	}
+	switch {
+	case strings.Contains(text, "Host key verification failed"), strings.Contains(text, "knownhosts:"):
+		return failureHostKey
+	case strings.Contains(text, "Could not resolve hostname"):
+		return failureDNS
+	case strings.Contains(text, "timed out"):
+		return failureTimeout
+	case strings.Contains(text, "Connection refused"):
+		return failureRefused
+	case strings.Contains(text, "No route to host"), strings.Contains(text, "Network is unreachable"):
+		return failureUnreachable
+	case strings.Contains(text, "Permission denied (publickey)"):
+		return failurePublicKeyOnly
+	case strings.Contains(text, "Permission denied"), strings.Contains(text, "unable to authenticate"),
+		strings.Contains(text, "incorrect passphrase"):
+		return failureCredentials
+	}
+	return failureUnknown
+}
+
+// loginFailureMessage explains a failed login test and what to do about it. detail is
+// ssh's last line of output, shown when the cause is unknown.
+func loginFailureMessage(failure int, host string, passphrase bool, detail string) string {
+	switch failure {
+	case failureCredentials:
+		if passphrase {
+			return "Login failed: wrong passphrase for the key."
+		}
+		return "Login failed: wrong password."
+	case failurePublicKeyOnly:
+		return host + " only accepts public keys: press C to install one with a password first, or set IdentityFile in ~/.ssh/config."
+	case failureDNS:
+		return "Could not resolve " + host + ": check its HostName in ~/.ssh/config, your DNS or VPN."
+	case failureTimeout:
+		return "Connecting to " + host + " timed out: the host may be down, firewalled, or only reachable over a VPN or jump host."
+	case failureRefused:
+		return host + " refused the connection: sshd may not be running, or it listens on another Port."
+	case failureUnreachable:
+		return "No route to " + host + ": check your network connection or VPN."
+	case failureHostKey:
+		return "Host key verification failed for " + host + ": its key does not match known_hosts."
+	}
+	if detail != "" {
+		return "Login failed: " + detail
+	}
+	if passphrase {
+		return "Login failed: wrong passphrase or SSH error."
+	}
+	return "Login failed: wrong password or SSH error."
+}
+
+// credentialFailure reports whether asking for the password again can fix a failure
+func credentialFailure(failure int) bool {
+	return failure == failureCredentials || failure == failureUnknown
+}
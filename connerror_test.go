@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyLoginFailure(t *testing.T) {
+	exit := func(code int) error {
+		err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+		if err == nil {
+			t.Fatal("expected an exit error")
+		}
+		return err
+	}
+	tests := []struct {
+		name     string
+		err      error
+		stderr   string
+		expected int
+	}{
+		{"wrong password", exit(sshpassWrongPassword), "", failureCredentials},
+		{"unknown host key", exit(sshpassHostKeyUnknown), "", failureHostKey},
+		{"dns", exit(sshConnectionError), "ssh: Could not resolve hostname nope.example: Name or service not known", failureDNS},
+		{"timeout", exit(sshConnectionError), "ssh: connect to host 10.0.0.1 port 22: Connection timed out", failureTimeout},
+		{"refused", exit(sshConnectionError), "ssh: connect to host 10.0.0.1 port 22: Connection refused", failureRefused},
+		{"no route", exit(sshConnectionError), "ssh: connect to host 10.0.0.1 port 22: No route to host", failureUnreachable},
+		{"host key", exit(sshConnectionError), "Host key verification failed.", failureHostKey},
+		{"public key only", exit(sshConnectionError), "user@host: Permission denied (publickey).", failurePublicKeyOnly},
+		{"denied", exit(sshConnectionError), "user@host: Permission denied (publickey,password).", failureCredentials},
+		{"unknown", exit(sshConnectionError), "kex_exchange_identification: read: Connection reset by peer", failureUnknown},
+		{"native dns", &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "nope.example"}}, "", failureDNS},
+		{"native refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, "", failureRefused},
+		{"native auth", errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password]"), "", failureCredentials},
+		{"native host key", errors.New("ssh: handshake failed: knownhosts: key mismatch"), "", failureHostKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyLoginFailure(tt.err, tt.stderr); got != tt.expected {
+				t.Errorf("expected failure %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoginFailureMessage(t *testing.T) {
+	if got := loginFailureMessage(failureCredentials, "web", true, ""); got != "Login failed: wrong passphrase for the key." {
+		t.Errorf("unexpected message %q", got)
+	}
+	if got := loginFailureMessage(failureUnknown, "web", false, "Connection reset by peer"); got != "Login failed: Connection reset by peer" {
+		t.Errorf("unexpected message %q", got)
+	}
+	if got := loginFailureMessage(failureUnknown, "web", false, ""); got != "Login failed: wrong password or SSH error." {
+		t.Errorf("unexpected message %q", got)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import "strings"
+
+// connectOptions are ssh flags toggled on the password screen for a single connection
+type connectOptions struct {
+	agentForwarding bool // -A
+	x11             bool // -X
+	compression     bool // -C
+	verbose         bool // -v
+}
+
+// args returns the ssh flags for the session
+func (o connectOptions) args() []string {
+	var args []string
+	if o.agentForwarding {
+		args = append(args, "-A")
+	}
+	if o.x11 {
+		args = append(args, "-X")
+	}
+	if o.compression {
+		args = append(args, "-C")
+	}
+	if o.verbose {
+		args = append(args, "-v")
+	}
+	return args
+}
+
+// connectionArgs returns the flags that apply to the connection rather than the
+// session. They are given to the login test too, as the session shares its connection.
+func (o connectOptions) connectionArgs() []string {
+	if o.compression {
+		return []string{"-C"}
+	}
+	return nil
+}
+
+// String renders the options as checkboxes for the password screen
+func (o connectOptions) String() string {
+	box := func(on bool, label string) string {
+		if on {
+			return "[x] " + label
+		}
+		return "[ ] " + label
+	}
+	return strings.Join([]string{
+		box(o.agentForwarding, "-A agent forwarding"),
+		box(o.x11, "-X X11"),
+		box(o.compression, "-C compression"),
+		box(o.verbose, "-v verbose"),
+	}, "  ")
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestConnectOptions(t *testing.T) {
+	var o connectOptions
+	if o.args() != nil || o.connectionArgs() != nil {
+		t.Errorf("expected no flags by default, got %v", o.args())
+	}
+	o = connectOptions{agentForwarding: true, compression: true, verbose: true}
+	if got := o.args(); !slices.Equal(got, []string{"-A", "-C", "-v"}) {
+		t.Errorf("unexpected flags %v", got)
+	}
+	if got := o.connectionArgs(); !slices.Equal(got, []string{"-C"}) {
+		t.Errorf("expected only compression for the connection, got %v", got)
+	}
+	if got := o.String(); got != "[x] -A agent forwarding  [ ] -X X11  [x] -C compression  [x] -v verbose" {
+		t.Errorf("unexpected rendering %q", got)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// copyKeyMsg reports the outcome of installing a public key on a host
+type copyKeyMsg struct {
+	err           error
+	wrongPassword bool
+}
+
+// publicKeys lists the public keys in ~/.ssh, leaving out certificates
+func publicKeys() []string {
+	matches, _ := filepath.Glob(filepath.Join(expandHome("~/.ssh"), "*.pub"))
+	var keys []string
+	for _, m := range matches {
+		if !strings.HasSuffix(m, "-cert.pub") {
+			keys = append(keys, m)
+		}
+	}
+	return keys
+}
+
+// authorizeKeyCommand appends a public key to authorized_keys unless it is already
+// there, like ssh-copy-id, which is not available on every platform
+func authorizeKeyCommand(pubKey string) string {
+	key := shellQuote(strings.TrimSpace(pubKey))
+	return "umask 077; mkdir -p ~/.ssh && touch ~/.ssh/authorized_keys && " +
+		"{ grep -qxF " + key + " ~/.ssh/authorized_keys || printf '%s\\n' " + key + " >> ~/.ssh/authorized_keys; }"
+}
+
+// errWrongPassword is the error of a host that refused the password a key was deployed with
+var errWrongPassword = errors.New("wrong password")
+
+// copyKeyArgs returns the ssh arguments installing pubKey on host over password
+// authentication. The command runs under sh, so a login shell such as fish or
+// csh does not get to parse it.
+func copyKeyArgs(host, pubKey string, timeout time.Duration) []string {
+	return []string{
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", connectTimeoutOption(timeout),
+		"-o", "PreferredAuthentications=password,keyboard-interactive",
+		host, remoteCommand(shellPOSIX, "sh", authorizeKeyCommand(pubKey)),
+	}
+}
+
+// copyPublicKey installs the public key at path on host, logging in with password
+func copyPublicKey(ctx context.Context, host string, password []byte, path string, timeout time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return copyKeyMsg{err: err}
+		}
+		cmd, secret, err := sshpassCommand(ctx, password, "", copyKeyArgs(host, string(content), timeout)...)
+		if err != nil {
+			return copyKeyMsg{err: err}
+		}
+		defer secret.Close()
+		out, err := cmd.CombinedOutput()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == sshpassWrongPassword {
+			return copyKeyMsg{err: err, wrongPassword: true}
+		}
+		if err != nil && len(out) > 0 {
+			err = fmt.Errorf("%s", lastLine(string(out)))
+		}
+		return copyKeyMsg{err: err}
+	}
+}
+
+// deployPublicKey installs the public key at path on the hosts of a broadcast,
+// logging in to each with its password in passwords. Each host gets
+// execTimeout to finish, as ssh may hang after logging in.
+func deployPublicKey(path string, passwords map[string][]byte, timeout, execTimeout time.Duration) broadcastRunner {
+	return func(host string, w io.Writer) error {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		defer remoteLimiter.acquire(host)()
+		ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+		defer cancel()
+		cmd, secret, err := sshpassCommand(ctx, passwords[host], "", copyKeyArgs(host, string(content), timeout)...)
+		if err != nil {
+			return err
+		}
+		defer secret.Close()
+		cmd.Stdout, cmd.Stderr = w, w
+		err = cmd.Run()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == sshpassWrongPassword {
+			return errWrongPassword
+		}
+		return err
+	}
+}
+
+// deployPasswords returns the known password of each host, from those entered
+// earlier in the run or the vault, and the hosts without one
+func (m *model) deployPasswords(hosts []string) (map[string][]byte, []string) {
+	passwords := map[string][]byte{}
+	var missing []string
+	for _, h := range hosts {
+		if pw, ok := m.sessionPasswords[h]; ok {
+			passwords[h] = bytes.Clone(pw)
+			continue
+		}
+		if m.vault != nil {
+			if pw, ok := m.vault.Get(h); ok {
+				passwords[h] = pw
+				continue
+			}
+		}
+		missing = append(missing, h)
+	}
+	return passwords, missing
+}
+
+// startDeployKey installs m.deployKey on m.deployHosts, first asking for a
+// password for the hosts that have none saved. A retry asks for one for all of
+// them, as they refused the password they have.
+func (m *model) startDeployKey(retry bool) tea.Cmd {
+	if err := checkFreeze(m.config, m.metadata, m.deployHosts); err != nil {
+		m.statusMsg = err.Error()
+		m.screen = listScreen
+		return nil
+	}
+	passwords, missing := m.deployPasswords(m.deployHosts)
+	if retry {
+		passwords, missing = map[string][]byte{}, m.deployHosts
+	}
+	m.deployKnown, m.deployMissing = passwords, missing
+	if len(missing) > 0 {
+		m.deployInput.SetValue("")
+		m.errMsg = ""
+		m.screen = deployKeyScreen
+		return m.deployInput.Focus()
+	}
+	return m.runDeployKey()
+}
+
+// runDeployKey installs the key with the known passwords, the one entered
+// going to the hosts that have none
+func (m *model) runDeployKey() tea.Cmd {
+	passwords := m.deployKnown
+	for _, h := range m.deployMissing {
+		passwords[h] = []byte(m.deployInput.Value())
+	}
+	m.deployInput.SetValue("")
+	m.deployKnown, m.deployMissing = nil, nil
+	title := fmt.Sprintf("install %s on %d hosts", filepath.Base(m.deployKey), len(m.deployHosts))
+	m.broadcastSavedTo = ""
+	return m.startBroadcast(title, m.deployHosts, deployPublicKey(m.deployKey, passwords, m.config.connectTimeout(), m.config.execTimeout()), false)
+}
+
+// wrongPasswordHosts returns the hosts of the last broadcast that refused the
+// password a key was deployed with
+func (m *model) wrongPasswordHosts() []string {
+	var hosts []string
+	for _, h := range m.broadcastHosts {
+		if h.done && h.result.Error == errWrongPassword.Error() {
+			hosts = append(hosts, h.host)
+		}
+	}
+	return hosts
+}
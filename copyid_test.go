@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+)
+
+func TestAuthorizeKeyCommand(t *testing.T) {
+	home := t.TempDir()
+	key := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIIWn80asU3t/7OmEYJuD1L75pku86ZgYH+2gFIQ8I4GW it's me\n"
+	for i := 0; i < 2; i++ {
+		cmd := exec.Command("sh", "-c", authorizeKeyCommand(key))
+		cmd.Env = append(os.Environ(), "HOME="+home)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("command failed: %v\n%s", err, out)
+		}
+	}
+	path := filepath.Join(home, ".ssh", "authorized_keys")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != key {
+		t.Errorf("expected the key once, got %q", content)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("expected authorized_keys to be private, got %v", info.Mode().Perm())
+	}
+}
+
+func TestCopyKeyArgs(t *testing.T) {
+	args := copyKeyArgs("web1", "ssh-ed25519 AAAA me@laptop\n", 10*time.Second)
+	want := []string{
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", "ConnectTimeout=10",
+		"-o", "PreferredAuthentications=password,keyboard-interactive",
+		"web1", remoteCommand(shellPOSIX, "sh", authorizeKeyCommand("ssh-ed25519 AAAA me@laptop")),
+	}
+	if !slices.Equal(args, want) {
+		t.Errorf("expected %q, got %q", want, args)
+	}
+}
+
+func TestDeployPasswords(t *testing.T) {
+	m := &model{sessionPasswords: map[string][]byte{"web1": []byte("secret")}}
+	passwords, missing := m.deployPasswords([]string{"web1", "web2"})
+	if string(passwords["web1"]) != "secret" || len(passwords) != 1 {
+		t.Errorf("unexpected passwords %q", passwords)
+	}
+	if !slices.Equal(missing, []string{"web2"}) {
+		t.Errorf("expected web2 to miss a password, got %q", missing)
+	}
+}
+
+func TestStartDeployKeyRetry(t *testing.T) {
+	m := &model{sessionPasswords: map[string][]byte{"web1": []byte("secret")}, deployHosts: []string{"web1", "web2"}, deployInput: textinput.New()}
+	m.startDeployKey(true)
+	if len(m.deployKnown) != 0 || !slices.Equal(m.deployMissing, []string{"web1", "web2"}) {
+		t.Errorf("a retry should ask for a password for every host, got known %q and missing %q", m.deployKnown, m.deployMissing)
+	}
+	if m.screen != deployKeyScreen {
+		t.Errorf("expected the password prompt, got screen %v", m.screen)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// debugLogArgs returns the ssh flags writing a -vvv log of the connection to
+// path. The log goes to a file rather than stderr because the master
+// connection left behind keeps logging after the login test returned.
+func debugLogArgs(path string) []string {
+	return []string{"-vvv", "-E", path}
+}
+
+// newDebugLog creates the file a login test logs to
+func newDebugLog() (string, error) {
+	f, err := os.CreateTemp("", "lsh-ssh-debug-*.log")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	return f.Name(), nil
+}
+
+// withDebugLog runs a login test logging to path and attaches the log to its
+// result. With -E, ssh writes its messages to the log as well, so a failure
+// is classified again from the log without the debug lines.
+func withDebugLog(test tea.Cmd, path string) tea.Cmd {
+	return func() tea.Msg {
+		return debugLogResult(test(), path)
+	}
+}
+
+// debugLogResult adds the debug log at path to the result of a login test, and removes the log
+func debugLogResult(msg tea.Msg, path string) tea.Msg {
+	content, _ := os.ReadFile(path)
+	os.Remove(path)
+	result, ok := msg.(loginResultMsg)
+	if !ok {
+		return msg
+	}
+	if !result.success {
+		result = loginResult(result.err, stripDebugLines(string(content)))
+	}
+	result.debugLog = string(content)
+	return result
+}
+
+// stripDebugLines removes the debug output of ssh -v from a log, leaving its messages
+func stripDebugLines(log string) string {
+	var kept []string
+	for _, line := range strings.Split(log, "\n") {
+		if strings.HasPrefix(line, "debug1: ") || strings.HasPrefix(line, "debug2: ") ||
+			strings.HasPrefix(line, "debug3: ") || strings.HasPrefix(line, "OpenSSH_") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
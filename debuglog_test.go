@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestWithDebugLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "debug.log")
+	log := "OpenSSH_9.2p1 Debian-2+deb12u7, OpenSSL 3.0.17 1 Jul 2025\n" +
+		"debug1: Reading configuration data /etc/ssh/ssh_config\n" +
+		"ssh: Could not resolve hostname nope.invalid: Name or service not known\n"
+	if err := os.WriteFile(path, []byte(log), 0600); err != nil {
+		t.Fatal(err)
+	}
+	exitErr := exec.Command("sh", "-c", "exit 255").Run()
+	test := func() tea.Msg { return loginResultMsg{err: exitErr} }
+	result, ok := withDebugLog(test, path)().(loginResultMsg)
+	if !ok {
+		t.Fatal("expected a login result")
+	}
+	if result.failure != failureDNS || result.detail != "ssh: Could not resolve hostname nope.invalid: Name or service not known" {
+		t.Errorf("expected the failure to be classified from the log without debug lines, got %+v", result)
+	}
+	if result.debugLog != log {
+		t.Errorf("expected the whole log, got %q", result.debugLog)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("expected the log file to be removed")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// diffRow is one option of two hosts side by side
+type diffRow struct {
+	option      string
+	left, right string
+}
+
+// hostSettings returns the effective SSH options of a host followed by its metadata,
+// each as option name to rendered value
+func hostSettings(host string, meta hostMeta) (map[string]string, error) {
+	out, err := exec.Command("ssh", "-G", host).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ssh -G %s: %w", host, err)
+	}
+	settings := map[string]string{}
+	for opt, values := range parseSSHOptions(string(out)) {
+		settings[opt] = strings.Join(values, ", ")
+	}
+	for field, value := range metadataFields(meta) {
+		settings["metadata "+field] = value
+	}
+	return settings, nil
+}
+
+// metadataFields flattens host metadata to its hosts.json field names
+func metadataFields(meta hostMeta) map[string]string {
+	fields := map[string]string{}
+	content, err := json.Marshal(meta)
+	if err != nil {
+		return fields
+	}
+	var raw map[string]json.RawMessage
+	if json.Unmarshal(content, &raw) != nil {
+		return fields
+	}
+	for name, value := range raw {
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			fields[name] = s
+		} else {
+			fields[name] = string(value)
+		}
+	}
+	return fields
+}
+
+// diffSettings returns the options whose values differ between two hosts, sorted
+// by name, and the number of options that are the same
+func diffSettings(left, right map[string]string) ([]diffRow, int) {
+	names := map[string]bool{}
+	for name := range left {
+		names[name] = true
+	}
+	for name := range right {
+		names[name] = true
+	}
+	var rows []diffRow
+	same := 0
+	for name := range names {
+		if left[name] == right[name] {
+			same++
+			continue
+		}
+		rows = append(rows, diffRow{option: name, left: left[name], right: right[name]})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].option < rows[j].option })
+	return rows, same
+}
+
+// diffView renders the differences between two hosts as a side-by-side table
+func diffView(leftHost, rightHost string, rows []diffRow, same int) string {
+	const optionWidth, valueWidth = 26, 34
+	cell := func(s string, width int) string {
+		if r := []rune(s); len(r) > width-1 {
+			s = string(r[:width-2]) + "…"
+		}
+		return lipgloss.NewStyle().Width(width).Render(s)
+	}
+	missing := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	bold := lipgloss.NewStyle().Bold(true)
+
+	var b strings.Builder
+	b.WriteString(bold.Render(cell("option", optionWidth) + cell(leftHost, valueWidth) + cell(rightHost, valueWidth)))
+	b.WriteString("\n")
+	for _, r := range rows {
+		left, right := cell(r.left, valueWidth), cell(r.right, valueWidth)
+		if r.left == "" {
+			left = missing.Render(cell("(unset)", valueWidth))
+		}
+		if r.right == "" {
+			right = missing.Render(cell("(unset)", valueWidth))
+		}
+		b.WriteString(cell(r.option, optionWidth) + left + right + "\n")
+	}
+	if len(rows) == 0 {
+		b.WriteString("No differences.\n")
+	}
+	b.WriteString(fmt.Sprintf("\n%d options are the same.\n", same))
+	return b.String()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffSettings(t *testing.T) {
+	left := map[string]string{"hostname": "10.0.0.1", "port": "22", "user": "admin", "metadata environment": "prod"}
+	right := map[string]string{"hostname": "10.0.0.2", "port": "22", "user": "admin", "proxyjump": "bastion"}
+
+	rows, same := diffSettings(left, right)
+	if same != 2 {
+		t.Errorf("expected 2 identical options, got %d", same)
+	}
+	want := []diffRow{
+		{option: "hostname", left: "10.0.0.1", right: "10.0.0.2"},
+		{option: "metadata environment", left: "prod"},
+		{option: "proxyjump", right: "bastion"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %+v", len(want), rows)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d: expected %+v, got %+v", i, want[i], rows[i])
+		}
+	}
+	if view := diffView("a", "b", rows, same); !strings.Contains(view, "(unset)") {
+		t.Errorf("expected missing values to be marked:\n%s", view)
+	}
+}
+
+func TestMetadataFields(t *testing.T) {
+	fields := metadataFields(hostMeta{Environment: "prod", Tags: []string{"db", "eu"}})
+	if fields["environment"] != "prod" || fields["tags"] != `["db","eu"]` {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	if _, ok := fields["timezone"]; ok {
+		t.Error("expected unset fields to be left out")
+	}
+}
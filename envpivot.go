@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// environmentOrder ranks the usual environment names from production down; others follow alphabetically
+var environmentOrder = []string{"prod", "production", "staging", "stage", "uat", "qa", "test", "dev", "development"}
+
+// envRow is one service across environments, e.g. web-*-1 with web-prod-1 and web-stage-1
+type envRow struct {
+	service string            // host alias with the environment replaced by *
+	hosts   map[string]string // host alias per environment
+}
+
+// serviceOf splits a host alias into its environment and the alias with the environment
+// replaced by *. The environment is the host's metadata environment when the alias
+// contains it, otherwise a well-known environment name in the alias.
+func serviceOf(host string, meta hostMeta) (env, service string, ok bool) {
+	parts := strings.FieldsFunc(host, func(r rune) bool { return r == '-' || r == '_' || r == '.' })
+	env = strings.ToLower(meta.Environment)
+	i := slices.IndexFunc(parts, func(p string) bool { return strings.EqualFold(p, env) })
+	if env == "" || i < 0 {
+		i = slices.IndexFunc(parts, func(p string) bool { return slices.Contains(environmentOrder, strings.ToLower(p)) })
+		if i < 0 {
+			return "", "", false
+		}
+		env = strings.ToLower(parts[i])
+	}
+	// Replace the part in place, keeping the separators of the alias
+	start := 0
+	for j := 0; j < i; j++ {
+		start += strings.Index(host[start:], parts[j]) + len(parts[j])
+	}
+	start += strings.Index(host[start:], parts[i])
+	return env, host[:start] + "*" + host[start+len(parts[i]):], true
+}
+
+// pivotEnvironments groups the hosts that are the same service in different
+// environments. Only services found in at least two environments are returned,
+// sorted by name, together with the environments as columns.
+func pivotEnvironments(items []hostItem, md hostMetadata) ([]envRow, []string) {
+	services := map[string]*envRow{}
+	for _, it := range items {
+		env, service, ok := serviceOf(it.host, md[it.host])
+		if !ok {
+			continue
+		}
+		row := services[service]
+		if row == nil {
+			row = &envRow{service: service, hosts: map[string]string{}}
+			services[service] = row
+		}
+		if _, taken := row.hosts[env]; !taken {
+			row.hosts[env] = it.host
+		}
+	}
+
+	var rows []envRow
+	seen := map[string]bool{}
+	var envs []string
+	for _, row := range services {
+		if len(row.hosts) < 2 {
+			continue
+		}
+		rows = append(rows, *row)
+		for env := range row.hosts {
+			if !seen[env] {
+				seen[env] = true
+				envs = append(envs, env)
+			}
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].service < rows[j].service })
+	sort.Slice(envs, func(i, j int) bool {
+		ri, rj := environmentRank(envs[i]), environmentRank(envs[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return envs[i] < envs[j]
+	})
+	return rows, envs
+}
+
+// environmentRank orders environments by environmentOrder, unknown ones last
+func environmentRank(env string) int {
+	if i := slices.Index(environmentOrder, env); i >= 0 {
+		return i
+	}
+	return len(environmentOrder)
+}
+
+// envPivotView renders the services as rows and environments as columns, with the
+// cell at row, col highlighted
+func envPivotView(rows []envRow, envs []string, row, col int) string {
+	serviceWidth := len("service")
+	colWidth := 0
+	for _, r := range rows {
+		serviceWidth = max(serviceWidth, len(r.service))
+		for _, h := range r.hosts {
+			colWidth = max(colWidth, len(h))
+		}
+	}
+	for _, env := range envs {
+		colWidth = max(colWidth, len(env))
+	}
+	selected := lipgloss.NewStyle().Reverse(true)
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("  %-*s", serviceWidth, "service"))
+	for _, env := range envs {
+		b.WriteString(fmt.Sprintf("  %-*s", colWidth, env))
+	}
+	b.WriteString("\n")
+	for i, r := range rows {
+		cursor := "  "
+		if i == row {
+			cursor = "> "
+		}
+		b.WriteString(fmt.Sprintf("%s%-*s", cursor, serviceWidth, r.service))
+		for j, env := range envs {
+			cell := fmt.Sprintf("%-*s", colWidth, r.hosts[env])
+			if r.hosts[env] == "" {
+				cell = fmt.Sprintf("%-*s", colWidth, "-")
+			}
+			if i == row && j == col {
+				cell = selected.Render(cell)
+			}
+			b.WriteString("  " + cell)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
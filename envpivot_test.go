@@ -0,0 +1,52 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestServiceOf(t *testing.T) {
+	tests := []struct {
+		host    string
+		meta    hostMeta
+		env     string
+		service string
+	}{
+		{"web-prod-1", hostMeta{}, "prod", "web-*-1"},
+		{"web_stage.1", hostMeta{}, "stage", "web_*.1"},
+		{"prod-db", hostMeta{}, "prod", "*-db"},
+		{"api-blue-2", hostMeta{Environment: "Blue"}, "blue", "api-*-2"},
+		// The metadata environment wins over a name that only looks like one
+		{"test-runner-eu", hostMeta{Environment: "eu"}, "eu", "test-runner-*"},
+	}
+	for _, tt := range tests {
+		env, service, ok := serviceOf(tt.host, tt.meta)
+		if !ok || env != tt.env || service != tt.service {
+			t.Errorf("%s: expected %s/%s, got %s/%s (%v)", tt.host, tt.env, tt.service, env, service, ok)
+		}
+	}
+	if _, _, ok := serviceOf("bastion", hostMeta{}); ok {
+		t.Error("expected a host without environment to be left out")
+	}
+}
+
+func TestPivotEnvironments(t *testing.T) {
+	items := []hostItem{{host: "web-dev-1"}, {host: "web-prod-1"}, {host: "web-stage-1"}, {host: "db-prod-1"}, {host: "bastion"}, {host: "api-blue"}, {host: "api-prod"}}
+	md := hostMetadata{"api-blue": {Environment: "blue"}}
+	rows, envs := pivotEnvironments(items, md)
+	if !slices.Equal(envs, []string{"prod", "stage", "dev", "blue"}) {
+		t.Errorf("unexpected environments %v", envs)
+	}
+	if len(rows) != 2 || rows[0].service != "api-*" || rows[1].service != "web-*-1" {
+		t.Fatalf("unexpected rows %+v", rows)
+	}
+	if rows[1].hosts["stage"] != "web-stage-1" || rows[0].hosts["blue"] != "api-blue" {
+		t.Errorf("unexpected hosts %+v", rows)
+	}
+
+	view := envPivotView(rows, envs, 1, 0)
+	if !strings.Contains(view, "> web-*-1") || !strings.Contains(view, "  api-*  ") {
+		t.Errorf("unexpected view:\n%s", view)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// etArgs returns the arguments of an et session on target. The -o options of
+// mux are passed on to the ssh et authenticates with, so it reuses the login
+// test's connection; command is typed into the session once it starts.
+func etArgs(target string, mux []string, command string) []string {
+	var args []string
+	for i := 0; i+1 < len(mux); i += 2 {
+		if mux[i] == "-o" {
+			args = append(args, "--ssh-option", mux[i+1])
+		}
+	}
+	if command != "" {
+		args = append(args, "-c", command)
+	}
+	return append(args, target)
+}
+
+// etUnavailable explains why a session cannot use Eternal Terminal, or returns
+// "" when et is installed locally and etserver on the host
+func (m *model) etUnavailable() string {
+	switch {
+	case m.loginPort != 0:
+		return "et cannot connect to a port chosen for one connection"
+	case len(m.sessionArgs()) > 0:
+		return "et does not take ssh arguments"
+	}
+	if _, err := exec.LookPath("et"); err != nil {
+		return "et is not installed"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	args := append([]string{"-o", "BatchMode=yes"}, m.multiplexArgs()...)
+	check := exec.CommandContext(ctx, "ssh", append(args, m.target(), "command -v etserver")...)
+	if err := check.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() != sshConnectionError {
+			return "etserver is not installed on " + m.selectedHost
+		}
+		return "could not check for etserver on " + m.selectedHost
+	}
+	return ""
+}
+
+// runETSession runs an interactive et session and returns its exit code
+func runETSession(args []string, term string) (int, error) {
+	cmd := exec.Command("et", args...)
+	if term != "" {
+		cmd.Env = append(os.Environ(), "TERM="+term)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return 1, err
+	}
+	stop := forwardSignals(cmd.Process)
+	defer stop()
+	return sessionExitCode(cmd.Wait())
+}
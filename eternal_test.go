@@ -0,0 +1,18 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestETArgs(t *testing.T) {
+	mux := []string{"-o", "ControlMaster=auto", "-o", "ControlPath=/tmp/lsh-0/%r@%n:%p"}
+	got := etArgs("root@web1", mux, "tmux new -A -s main")
+	want := []string{"--ssh-option", "ControlMaster=auto", "--ssh-option", "ControlPath=/tmp/lsh-0/%r@%n:%p", "-c", "tmux new -A -s main", "root@web1"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := etArgs("web1", nil, ""); !slices.Equal(got, []string{"web1"}) {
+		t.Errorf("expected only the host without options, got %v", got)
+	}
+}
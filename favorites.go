@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Starred hosts (*) are listed first, with a ★ before their alias; ctrl+f
+// lists only them. The stars are kept in hosts.json.
+
+// saveFavorites stars or unstars hosts in hosts.json
+func saveFavorites(hosts []string, favorite bool) error {
+	return updateHostMetadata(func(md hostMetadata) {
+		setFavorites(md, hosts, favorite)
+	})
+}
+
+// setFavorites stars or unstars hosts in md
+func setFavorites(md hostMetadata, hosts []string, favorite bool) {
+	for _, h := range hosts {
+		meta := md[h]
+		meta.Favorite = favorite
+		md[h] = meta
+	}
+}
+
+// favoritesFirst moves the starred hosts to the front, keeping the order of both
+func favoritesFirst(hosts []hostItem) []hostItem {
+	sorted := make([]hostItem, 0, len(hosts))
+	for _, h := range hosts {
+		if h.favorite {
+			sorted = append(sorted, h)
+		}
+	}
+	for _, h := range hosts {
+		if !h.favorite {
+			sorted = append(sorted, h)
+		}
+	}
+	return sorted
+}
+
+// toggleFavorites stars the marked hosts, or the selected one, unless all of
+// them are starred already, in which case it unstars them
+func (m *model) toggleFavorites() tea.Cmd {
+	hosts := m.markedHosts()
+	if hosts == nil {
+		selected, ok := m.list.SelectedItem().(hostItem)
+		if !ok {
+			return nil
+		}
+		hosts = []string{selected.host}
+	}
+	favorite := false
+	for _, h := range hosts {
+		if !m.metadata[h].Favorite {
+			favorite = true
+		}
+	}
+	if err := saveFavorites(hosts, favorite); err != nil {
+		m.statusMsg = "Could not save hosts.json: " + err.Error()
+		return nil
+	}
+	setFavorites(m.metadata, hosts, favorite)
+	verb := "Unstarred"
+	if favorite {
+		verb = "Starred"
+	}
+	m.statusMsg = fmt.Sprintf("%s %d hosts", verb, len(hosts))
+	if len(hosts) == 1 {
+		m.statusMsg = verb + " " + hosts[0]
+	}
+	return tea.Batch(m.refreshList(), m.refreshInfoBox())
+}
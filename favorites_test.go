@@ -0,0 +1,45 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHostItemTitleFavorite(t *testing.T) {
+	if got := (hostItem{host: "web1", favorite: true}).Title(); got != "★ web1" {
+		t.Errorf("Title() = %q, want %q", got, "★ web1")
+	}
+	if got := (hostItem{host: "web1", favorite: true, marked: true}).Title(); got != "✓ ★ web1" {
+		t.Errorf("Title() = %q, want %q", got, "✓ ★ web1")
+	}
+}
+
+func TestFavoritesFirst(t *testing.T) {
+	hosts := []hostItem{{host: "a"}, {host: "b", favorite: true}, {host: "c"}, {host: "d", favorite: true}}
+	if got := hostNames(favoritesFirst(hosts)); !slices.Equal(got, []string{"b", "d", "a", "c"}) {
+		t.Errorf("favoritesFirst() = %q", got)
+	}
+}
+
+func TestRefreshListFavorites(t *testing.T) {
+	m := selectionModel("a", "b", "c")
+	m.metadata = hostMetadata{}
+	setFavorites(m.metadata, []string{"c"}, true)
+	m.refreshList()
+	if got := listedHosts(m); !slices.Equal(got, []string{"c", "a", "b"}) {
+		t.Errorf("listed %q, want the favorite first", got)
+	}
+
+	m.favoritesOnly = true
+	m.refreshList()
+	if got := listedHosts(m); !slices.Equal(got, []string{"c"}) {
+		t.Errorf("listed %q, want only the favorite", got)
+	}
+
+	setFavorites(m.metadata, []string{"c"}, false)
+	m.favoritesOnly = false
+	m.refreshList()
+	if got := listedHosts(m); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("listed %q, want the original order", got)
+	}
+}
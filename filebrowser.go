@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/crypto/ssh"
+)
+
+// browserRows is how many entries each pane of the file browser shows
+const browserRows = 20
+
+const (
+	localPane = iota
+	remotePane
+)
+
+// filePane is one side of the file browser
+type filePane struct {
+	dir     string
+	entries []fileEntry
+	cursor  int
+}
+
+// selected returns the entry under the cursor
+func (p filePane) selected() (fileEntry, bool) {
+	if p.cursor >= len(p.entries) {
+		return fileEntry{}, false
+	}
+	return p.entries[p.cursor], true
+}
+
+// fileBrowser holds the state of the two-pane SFTP file browser
+type fileBrowser struct {
+	client   *sftpClient
+	panes    [2]filePane
+	focus    int
+	busy     bool   // an operation is running; keys other than ctrl+c are ignored
+	renaming bool   // the input holds the new name of the selected entry
+	deleting bool   // waiting for y to delete the selected entry
+	status   string // outcome of the last operation
+	input    textinput.Model
+}
+
+// BrowserKeyMap defines the key bindings for the file browser
+type BrowserKeyMap struct {
+	Switch key.Binding
+	Open   key.Binding
+	Up     key.Binding
+	Copy   key.Binding
+	Rename key.Binding
+	Delete key.Binding
+	Esc    key.Binding
+}
+
+func (k BrowserKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Switch, k.Open, k.Up, k.Copy, k.Rename, k.Delete, k.Esc}
+}
+
+func (k BrowserKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Switch, k.Open, k.Up, k.Copy, k.Rename, k.Delete, k.Esc}}
+}
+
+// browserOpenedMsg reports the outcome of starting the SFTP session
+type browserOpenedMsg struct {
+	client *sftpClient
+	err    error
+}
+
+// browserMsg reports the outcome of a browser operation, with fresh listings of both panes
+type browserMsg struct {
+	listed  bool // dirs and entries are set; listing fails e.g. for unreadable directories
+	dirs    [2]string
+	entries [2][]fileEntry
+	status  string
+	err     error
+}
+
+// openSFTP starts the sftp subsystem on target with ssh, reusing the login
+// test's connection through args or authenticating with password when set
+func openSFTP(target string, args []string, password []byte, keyFile string) tea.Cmd {
+	return func() tea.Msg {
+		defer clear(password)
+		sshArgs := append(append([]string{}, args...), "-s", target, "sftp")
+		var cmd *exec.Cmd
+		var secret *os.File
+		if password == nil {
+			// Nothing can answer a prompt while the TUI is showing
+			cmd = exec.Command("ssh", append([]string{"-o", "BatchMode=yes"}, sshArgs...)...)
+		} else {
+			var err error
+			cmd, secret, err = sshpassCommand(context.Background(), password, keyFile, sshArgs...)
+			if err != nil {
+				return browserOpenedMsg{err: err}
+			}
+		}
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return browserOpenedMsg{err: err}
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return browserOpenedMsg{err: err}
+		}
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		err = cmd.Start()
+		if secret != nil {
+			secret.Close()
+		}
+		if err != nil {
+			return browserOpenedMsg{err: err}
+		}
+		client, err := newSFTPClient(stdin, stdout, func() error {
+			stdin.Close()
+			return cmd.Wait()
+		})
+		if err != nil {
+			stdin.Close()
+			cmd.Wait()
+			if detail := strings.TrimSpace(stderr.String()); detail != "" {
+				err = errors.New(detail)
+			}
+			return browserOpenedMsg{err: err}
+		}
+		return browserOpenedMsg{client: client}
+	}
+}
+
+// openNativeSFTP starts the sftp subsystem over a connection of the built-in client
+func openNativeSFTP(conn *ssh.Client) tea.Cmd {
+	return func() tea.Msg {
+		session, err := conn.NewSession()
+		if err != nil {
+			conn.Close()
+			return browserOpenedMsg{err: err}
+		}
+		closeAll := func() error {
+			session.Close()
+			return conn.Close()
+		}
+		stdin, err := session.StdinPipe()
+		if err != nil {
+			closeAll()
+			return browserOpenedMsg{err: err}
+		}
+		stdout, err := session.StdoutPipe()
+		if err != nil {
+			closeAll()
+			return browserOpenedMsg{err: err}
+		}
+		if err := session.RequestSubsystem("sftp"); err != nil {
+			closeAll()
+			return browserOpenedMsg{err: err}
+		}
+		client, err := newSFTPClient(stdin, stdout, closeAll)
+		if err != nil {
+			closeAll()
+			return browserOpenedMsg{err: err}
+		}
+		return browserOpenedMsg{client: client}
+	}
+}
+
+// newFileBrowser shows the local working directory next to the remote home directory
+func newFileBrowser(client *sftpClient) *fileBrowser {
+	input := textinput.New()
+	input.CharLimit = 255
+	b := &fileBrowser{client: client, input: input, busy: true}
+	b.panes[localPane].dir, _ = os.Getwd()
+	b.panes[remotePane].dir = "."
+	return b
+}
+
+// readLocalDir lists a local directory like sftpClient.readDir lists a remote one
+func readLocalDir(dir string) ([]fileEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []fileEntry
+	for _, e := range dirEntries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, fileEntry{name: e.Name(), size: info.Size(), mode: info.Mode(), modTime: info.ModTime()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries, nil
+}
+
+// run performs op in the background, then lists dirs, the directories the
+// panes show afterwards. status describes a successful op.
+func (b *fileBrowser) run(dirs [2]string, status string, op func() error) tea.Cmd {
+	b.busy = true
+	b.status = ""
+	client := b.client
+	return func() tea.Msg {
+		msg := browserMsg{status: status}
+		if op != nil {
+			if msg.err = op(); msg.err != nil {
+				msg.status = ""
+			}
+		}
+		remoteDir, err := client.realPath(dirs[remotePane])
+		var remote, local []fileEntry
+		if err == nil {
+			remote, err = client.readDir(remoteDir)
+		}
+		if err == nil {
+			local, err = readLocalDir(dirs[localPane])
+		}
+		if err != nil {
+			if msg.err == nil {
+				msg.err = err
+			}
+			return msg
+		}
+		msg.listed = true
+		msg.dirs = [2]string{dirs[localPane], remoteDir}
+		msg.entries = [2][]fileEntry{local, remote}
+		return msg
+	}
+}
+
+// dirs returns the directories shown by the panes
+func (b *fileBrowser) dirs() [2]string {
+	return [2]string{b.panes[localPane].dir, b.panes[remotePane].dir}
+}
+
+// joinPane returns the path of name in dir, a directory of pane
+func joinPane(pane int, dir, name string) string {
+	if pane == remotePane {
+		return path.Join(dir, name)
+	}
+	return filepath.Join(dir, name)
+}
+
+// parentDir returns the directory above dir in pane
+func parentDir(pane int, dir string) string {
+	if pane == remotePane {
+		return path.Dir(dir)
+	}
+	return filepath.Dir(dir)
+}
+
+// loaded applies the outcome of an operation
+func (b *fileBrowser) loaded(msg browserMsg) {
+	b.busy = false
+	b.status = msg.status
+	if msg.err != nil {
+		b.status = "Error: " + msg.err.Error()
+	}
+	if !msg.listed {
+		return
+	}
+	for i := range b.panes {
+		p := &b.panes[i]
+		p.dir = msg.dirs[i]
+		p.entries = msg.entries[i]
+		p.cursor = max(0, min(p.cursor, len(p.entries)-1))
+	}
+}
+
+// update handles a key on the browser screen and reports whether the browser should close
+func (b *fileBrowser) update(msg tea.KeyMsg) (tea.Cmd, bool) {
+	if b.busy {
+		return nil, false
+	}
+	pane := &b.panes[b.focus]
+	entry, ok := pane.selected()
+	switch {
+	case b.renaming:
+		switch msg.String() {
+		case "esc":
+			b.renaming = false
+		case "enter":
+			b.renaming = false
+			name := strings.TrimSpace(b.input.Value())
+			if !ok || name == "" || name == entry.name {
+				return nil, false
+			}
+			from := joinPane(b.focus, pane.dir, entry.name)
+			to := joinPane(b.focus, pane.dir, name)
+			op := func() error { return os.Rename(from, to) }
+			if b.focus == remotePane {
+				op = func() error { return b.client.rename(from, to) }
+			}
+			return b.run(b.dirs(), "Renamed "+entry.name+" to "+name, op), false
+		default:
+			var cmd tea.Cmd
+			b.input, cmd = b.input.Update(msg)
+			return cmd, false
+		}
+		return nil, false
+	case b.deleting:
+		b.deleting = false
+		if msg.String() != "y" || !ok {
+			b.status = ""
+			return nil, false
+		}
+		target := joinPane(b.focus, pane.dir, entry.name)
+		op := func() error { return os.Remove(target) }
+		if b.focus == remotePane {
+			op = func() error { return b.client.remove(target, entry.mode.IsDir()) }
+		}
+		return b.run(b.dirs(), "Deleted "+entry.name, op), false
+	}
+
+	switch msg.String() {
+	case "esc", "q":
+		return nil, true
+	case "tab":
+		b.focus = 1 - b.focus
+	case "up", "k":
+		pane.cursor = max(0, pane.cursor-1)
+	case "down", "j":
+		pane.cursor = max(0, min(len(pane.entries)-1, pane.cursor+1))
+	case "enter", "right", "l":
+		// Symbolic links are followed when they point at a directory
+		if !ok || !entry.mode.IsDir() && entry.mode&os.ModeSymlink == 0 {
+			break
+		}
+		dirs := b.dirs()
+		dirs[b.focus] = joinPane(b.focus, pane.dir, entry.name)
+		pane.cursor = 0
+		return b.run(dirs, "", nil), false
+	case "backspace", "left", "h":
+		dirs := b.dirs()
+		dirs[b.focus] = parentDir(b.focus, pane.dir)
+		pane.cursor = 0
+		return b.run(dirs, "", nil), false
+	case "c":
+		if !ok {
+			break
+		}
+		if !entry.mode.IsRegular() {
+			b.status = "Only files can be copied."
+			break
+		}
+		return b.run(b.dirs(), b.copyStatus(entry), b.copyOp(entry)), false
+	case "r":
+		if !ok {
+			break
+		}
+		b.renaming = true
+		b.input.SetValue(entry.name)
+		b.input.CursorEnd()
+		b.input.Focus()
+	case "x", "d", "delete":
+		if !ok {
+			break
+		}
+		b.deleting = true
+		b.status = fmt.Sprintf("Delete %s? Press y to confirm.", entry.name)
+	}
+	return nil, false
+}
+
+// copyStatus describes copying entry to the other pane
+func (b *fileBrowser) copyStatus(entry fileEntry) string {
+	if b.focus == localPane {
+		return "Uploaded " + entry.name
+	}
+	return "Downloaded " + entry.name
+}
+
+// validEntryName reports whether name is a plain file name, so that a name sent
+// by the server cannot make a copy land outside the directory of the pane
+func validEntryName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// copyOp copies entry from the focused pane into the directory of the other one.
+// Downloads never replace a local file, and drop the group and world write bits
+// of the remote mode.
+func (b *fileBrowser) copyOp(entry fileEntry) func() error {
+	local := b.panes[localPane].dir
+	remote := b.panes[remotePane].dir
+	if !validEntryName(entry.name) {
+		return func() error { return fmt.Errorf("refusing to copy %q", entry.name) }
+	}
+	if b.focus == localPane {
+		return func() error {
+			f, err := os.Open(filepath.Join(local, entry.name))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return b.client.upload(f, path.Join(remote, entry.name), entry.mode)
+		}
+	}
+	return func() error {
+		f, err := os.OpenFile(filepath.Join(local, entry.name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, entry.mode.Perm()&^0022)
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%s already exists here; rename or delete it first", entry.name)
+		}
+		if err != nil {
+			return err
+		}
+		if err := b.client.download(path.Join(remote, entry.name), f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// paneView renders one pane, scrolled to keep the cursor visible
+func paneView(p filePane, focused bool, width int) string {
+	title := lipgloss.NewStyle().Bold(true)
+	if focused {
+		title = title.Foreground(lipgloss.Color("205"))
+	}
+	var b strings.Builder
+	b.WriteString(title.Render(truncateLeft(p.dir, width)))
+	b.WriteString("\n")
+	if len(p.entries) == 0 {
+		b.WriteString("  (empty)\n")
+	}
+	start := max(0, min(p.cursor-browserRows/2, len(p.entries)-browserRows))
+	for i := start; i < len(p.entries) && i < start+browserRows; i++ {
+		e := p.entries[i]
+		cursor := "  "
+		if i == p.cursor && focused {
+			cursor = "> "
+		}
+		name := e.name
+		size := formatSize(e.size)
+		switch {
+		case e.mode.IsDir():
+			name += "/"
+			size = ""
+		case e.mode&os.ModeSymlink != 0:
+			name += "@"
+		}
+		nameWidth := width - 2 - 8
+		if len(name) > nameWidth {
+			name = name[:nameWidth-1] + "…"
+		}
+		fmt.Fprintf(&b, "%s%-*s%8s\n", cursor, nameWidth, name, size)
+	}
+	return b.String()
+}
+
+// truncateLeft shortens s to width, keeping its end
+func truncateLeft(s string, width int) string {
+	if len(s) <= width {
+		return s
+	}
+	return "…" + s[len(s)-width+1:]
+}
+
+// formatSize renders a file size with a binary unit
+func formatSize(n int64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	value := float64(n)
+	i := -1
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%c", value, units[i])
+}
+
+// view renders both panes side by side with the status line
+func (b *fileBrowser) view() string {
+	const width = 40
+	local := paneView(b.panes[localPane], b.focus == localPane, width)
+	remote := paneView(b.panes[remotePane], b.focus == remotePane, width)
+	var s strings.Builder
+	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Top,
+		lipgloss.NewStyle().Width(width+4).Render(local),
+		lipgloss.NewStyle().Width(width).Render(remote)))
+	s.WriteString("\n")
+	switch {
+	case b.renaming:
+		s.WriteString("rename to: " + b.input.View())
+	case b.busy:
+		s.WriteString("Working...")
+	default:
+		s.WriteString(b.status)
+	}
+	s.WriteString("\n")
+	return s.String()
+}
+
+// closeBrowser ends the SFTP session; errors of a session that was used are of no interest
+func closeBrowser(b *fileBrowser) {
+	if b != nil && b.client != nil {
+		b.client.Close()
+	}
+}
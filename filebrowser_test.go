@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestValidEntryName(t *testing.T) {
+	for _, name := range []string{"notes.txt", ".bashrc", "..hidden"} {
+		if !validEntryName(name) {
+			t.Errorf("%q should be accepted", name)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "../.bashrc", "dir/file", `..\evil`} {
+		if validEntryName(name) {
+			t.Errorf("%q should be refused", name)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckFreeze(t *testing.T) {
+	md := hostMetadata{
+		"db1":  {Tags: []string{"prod"}},
+		"web1": {Tags: []string{"staging"}},
+	}
+	hosts := []string{"db1", "web1"}
+
+	if err := checkFreeze(appConfig{}, md, hosts); err != nil {
+		t.Errorf("expected no freeze, got %v", err)
+	}
+
+	cfg := appConfig{Freeze: freezeConfig{Tags: []string{"prod"}, Reason: "release 2.0"}}
+	err := checkFreeze(cfg, md, hosts)
+	var frozen *frozenError
+	if !errors.As(err, &frozen) {
+		t.Fatalf("expected frozenError, got %v", err)
+	}
+	if len(frozen.hosts) != 1 || frozen.hosts[0] != "db1" {
+		t.Errorf("expected only db1 frozen, got %v", frozen.hosts)
+	}
+	if !strings.Contains(err.Error(), "release 2.0") {
+		t.Errorf("expected reason in message, got %q", err.Error())
+	}
+
+	if err := checkFreeze(appConfig{Freeze: freezeConfig{All: true}}, md, []string{"web1"}); err == nil {
+		t.Error("expected global freeze to block web1")
+	}
+}
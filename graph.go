@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dependencyGraph maps each host to the hosts it depends on: the hosts declared in
+// its metadata plus its ProxyJump bastion
+type dependencyGraph map[string][]string
+
+// buildDependencyGraph combines declared dependencies with the ProxyJump hosts in jumps
+func buildDependencyGraph(md hostMetadata, jumps map[string]string) dependencyGraph {
+	g := dependencyGraph{}
+	for host, meta := range md {
+		for _, dep := range meta.DependsOn {
+			g.add(host, dep)
+		}
+	}
+	for host, jump := range jumps {
+		g.add(host, jump)
+	}
+	return g
+}
+
+func (g dependencyGraph) add(host, dep string) {
+	if !contains(g[host], dep) {
+		g[host] = append(g[host], dep)
+	}
+}
+
+// dependents returns the reverse graph: for each host, the hosts depending on it
+func (g dependencyGraph) dependents() dependencyGraph {
+	rev := dependencyGraph{}
+	for host, deps := range g {
+		for _, dep := range deps {
+			rev.add(dep, host)
+		}
+	}
+	return rev
+}
+
+// order returns hosts sorted so that every host comes after its dependencies,
+// also when they only depend on each other through hosts that are left out.
+// Hosts in a cycle keep their original order.
+func (g dependencyGraph) order(hosts []string) []string {
+	var sorted []string
+	visited := map[string]bool{}
+	var visit func(h string)
+	visit = func(h string) {
+		if visited[h] {
+			return
+		}
+		visited[h] = true
+		for _, dep := range g[h] {
+			visit(dep)
+		}
+		if contains(hosts, h) {
+			sorted = append(sorted, h)
+		}
+	}
+	for _, h := range hosts {
+		visit(h)
+	}
+	return sorted
+}
+
+// renderTree draws host and everything reachable from it in g as an ASCII tree
+func (g dependencyGraph) renderTree(host string) string {
+	var b strings.Builder
+	b.WriteString(host + "\n")
+	g.renderChildren(&b, host, "", map[string]bool{host: true})
+	return b.String()
+}
+
+func (g dependencyGraph) renderChildren(b *strings.Builder, host, prefix string, seen map[string]bool) {
+	children := append([]string(nil), g[host]...)
+	sort.Strings(children)
+	for i, child := range children {
+		branch, indent := "├── ", "│   "
+		if i == len(children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		if seen[child] {
+			fmt.Fprintf(b, "%s%s%s (cycle)\n", prefix, branch, child)
+			continue
+		}
+		b.WriteString(prefix + branch + child + "\n")
+		seen[child] = true
+		g.renderChildren(b, child, prefix+indent, seen)
+		delete(seen, child)
+	}
+}
+
+// proxyJumps reads the ProxyJump host of every host from ~/.ssh/config
+func proxyJumps(hosts []hostItem) map[string]string {
+	jumps := map[string]string{}
+	usr, err := user.Current()
+	if err != nil {
+		return jumps
+	}
+	content, err := os.ReadFile(filepath.Join(usr.HomeDir, ".ssh", "config"))
+	if err != nil {
+		return jumps
+	}
+	lines := strings.Split(string(content), "\n")
+	for _, h := range hosts {
+		if block := getHostBlock(lines, h.host); block != nil {
+			if jump := getProxyJumpHost(block.lines); jump != "" {
+				jumps[h.host] = jump
+			}
+		}
+	}
+	return jumps
+}
+
+// dependencyView renders what a host depends on and what would be affected if it went down
+func dependencyView(g dependencyGraph, host string) string {
+	var b strings.Builder
+	b.WriteString("Depends on:\n")
+	b.WriteString(g.renderTree(host))
+	b.WriteString("\nBlast radius (hosts depending on it):\n")
+	b.WriteString(g.dependents().renderTree(host))
+	return b.String()
+}
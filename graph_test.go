@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildDependencyGraph(t *testing.T) {
+	md := hostMetadata{
+		"app1": {DependsOn: []string{"db1"}},
+		"db1":  {},
+	}
+	g := buildDependencyGraph(md, map[string]string{"db1": "bastion"})
+	expected := dependencyGraph{"app1": {"db1"}, "db1": {"bastion"}}
+	if !reflect.DeepEqual(g, expected) {
+		t.Errorf("expected %v, got %v", expected, g)
+	}
+}
+
+func TestDependencyGraph_Order(t *testing.T) {
+	g := dependencyGraph{"app1": {"db1"}, "app2": {"db1"}, "db1": {"bastion"}}
+	got := g.order([]string{"app1", "db1", "app2", "bastion"})
+	if expected := []string{"bastion", "db1", "app1", "app2"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected dependencies before their dependents %v, got %v", expected, got)
+	}
+	// app1 depends on bastion through db1, which is not among the hosts
+	if got := g.order([]string{"app1", "bastion"}); !reflect.DeepEqual(got, []string{"bastion", "app1"}) {
+		t.Errorf("expected indirect dependencies first, got %v", got)
+	}
+}
+
+func TestDependencyGraph_RenderTree(t *testing.T) {
+	g := dependencyGraph{
+		"app1": {"db1", "cache1"},
+		"db1":  {"bastion"},
+	}
+	expected := "app1\n" +
+		"├── cache1\n" +
+		"└── db1\n" +
+		"    └── bastion\n"
+	if got := g.renderTree("app1"); got != expected {
+		t.Errorf("unexpected tree:\n%s\nexpected:\n%s", got, expected)
+	}
+
+	rev := g.dependents()
+	if !reflect.DeepEqual(rev["bastion"], []string{"db1"}) {
+		t.Errorf("expected db1 to depend on bastion, got %v", rev["bastion"])
+	}
+}
+
+func TestDependencyGraph_Cycle(t *testing.T) {
+	g := dependencyGraph{"a": {"b"}, "b": {"a"}}
+	expected := "a\n└── b\n    └── a (cycle)\n"
+	if got := g.renderTree("a"); got != expected {
+		t.Errorf("unexpected tree:\n%s", got)
+	}
+	if got := g.order([]string{"b", "a"}); len(got) != 2 {
+		t.Errorf("expected both hosts in order, got %v", got)
+	}
+}
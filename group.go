@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Selection policies for host groups
+const (
+	policyLeastLoaded = "least-loaded"
+	policyRoundRobin  = "round-robin"
+	policyRandom      = "random"
+	policySticky      = "sticky-per-day"
+)
+
+// groupConfig configures a group of interchangeable hosts, identified by a shared tag
+// or, for smart groups, by a host selection expression
+type groupConfig struct {
+	Policy string `json:"policy,omitempty"`
+	// Hosts selects the group's hosts, e.g. "tag:web and env:prod", instead of the tag of the group's name
+	Hosts string `json:"hosts,omitempty"`
+	// Key is a digit that opens all hosts of the group in a tmux window, arranged as in Tmux
+	Key  string     `json:"key,omitempty"`
+	Tmux tmuxLayout `json:"tmux,omitempty"`
+}
+
+type groupPickMsg struct {
+	host string
+	err  error
+}
+
+// groupPolicy returns the selection policy for a group, defaulting to least-loaded
+func (c appConfig) groupPolicy(group string) string {
+	if p := c.Groups[group].Policy; p != "" {
+		return p
+	}
+	return policyLeastLoaded
+}
+
+// groupHosts returns the hosts of a group from items, in list order: those its
+// expression selects, or those carrying the tag of the group's name
+func (c appConfig) groupHosts(group string, md hostMetadata, items []hostItem) ([]string, error) {
+	expr := c.Groups[group].Hosts
+	if expr == "" {
+		return md.hostsWithTag(items, group), nil
+	}
+	filter, err := parseHostSelection(expr)
+	if err != nil {
+		return nil, fmt.Errorf("group %q: %w", group, err)
+	}
+	return selectHosts(items, md, filter), nil
+}
+
+// groupWithKey returns the group opened in tmux with key, or "" when no group has that key
+func (c appConfig) groupWithKey(key string) string {
+	for name, g := range c.Groups {
+		if g.Key == key {
+			return name
+		}
+	}
+	return ""
+}
+
+// groupOf returns the group of a host: the first smart group, by name, that
+// selects it, or else its first tag. It is empty for hosts in no group.
+func (c appConfig) groupOf(item hostItem, meta hostMeta) string {
+	names := make([]string, 0, len(c.Groups))
+	for name := range c.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if c.Groups[name].Hosts == "" {
+			continue
+		}
+		if filter, err := parseHostSelection(c.Groups[name].Hosts); err == nil && filter(item, meta) {
+			return name
+		}
+	}
+	if len(meta.Tags) > 0 {
+		return meta.Tags[0]
+	}
+	return ""
+}
+
+// pickGroupHost chooses a host from a group according to policy.
+// Round-robin progress is read from and written to the state file.
+func pickGroupHost(group, policy string, hosts []string) (string, error) {
+	if len(hosts) == 0 {
+		return "", fmt.Errorf("group %q has no hosts", group)
+	}
+	switch policy {
+	case policyLeastLoaded:
+		return leastLoaded(hosts, probeLoads(hosts))
+	case policyRandom:
+		return hosts[rand.Intn(len(hosts))], nil
+	case policySticky:
+		return stickyHost(group, hosts, time.Now()), nil
+	case policyRoundRobin:
+		var host string
+		err := updateAppState(func(st *appState) {
+			host = roundRobinHost(group, hosts, st)
+		})
+		return host, err
+	}
+	return "", fmt.Errorf("unknown selection policy %q for group %q", policy, group)
+}
+
+// roundRobinHost returns the next host of the group and advances the stored position
+func roundRobinHost(group string, hosts []string, st *appState) string {
+	if st.RoundRobin == nil {
+		st.RoundRobin = map[string]int{}
+	}
+	i := st.RoundRobin[group] % len(hosts)
+	st.RoundRobin[group] = i + 1
+	return hosts[i]
+}
+
+// stickyHost returns the same host of the group for the whole calendar day
+func stickyHost(group string, hosts []string, now time.Time) string {
+	h := fnv.New32a()
+	h.Write([]byte(group + "/" + now.Format("2006-01-02")))
+	return hosts[int(h.Sum32()%uint32(len(hosts)))]
+}
+
+// pickFromGroup runs pickGroupHost in the background
+func pickFromGroup(group, policy string, hosts []string) tea.Cmd {
+	return func() tea.Msg {
+		host, err := pickGroupHost(group, policy, hosts)
+		return groupPickMsg{host: host, err: err}
+	}
+}
+
+// resolveConnectTarget turns a command-line target into a host alias.
+// Targets are either an alias or "group:<tag>", which applies the group's selection policy.
+func resolveConnectTarget(target string, cfg appConfig, md hostMetadata, hosts []hostItem) (string, error) {
+	if group, ok := strings.CutPrefix(target, "group:"); ok {
+		members, err := cfg.groupHosts(group, md, hosts)
+		if err != nil {
+			return "", err
+		}
+		return pickGroupHost(group, cfg.groupPolicy(group), members)
+	}
+	for _, h := range hosts {
+		if h.host == target {
+			return target, nil
+		}
+	}
+	return "", fmt.Errorf("host %q not found in ~/.ssh/config", target)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRoundRobinHost(t *testing.T) {
+	hosts := []string{"build1", "build2", "build3"}
+	var st appState
+
+	var picked []string
+	for i := 0; i < 4; i++ {
+		picked = append(picked, roundRobinHost("builders", hosts, &st))
+	}
+	expected := []string{"build1", "build2", "build3", "build1"}
+	for i := range expected {
+		if picked[i] != expected[i] {
+			t.Errorf("pick %d: expected %s, got %s", i, expected[i], picked[i])
+		}
+	}
+}
+
+func TestStickyHost(t *testing.T) {
+	hosts := []string{"build1", "build2", "build3"}
+	morning := time.Date(2025, 7, 21, 8, 0, 0, 0, time.UTC)
+	evening := time.Date(2025, 7, 21, 20, 0, 0, 0, time.UTC)
+
+	if stickyHost("builders", hosts, morning) != stickyHost("builders", hosts, evening) {
+		t.Error("expected the same host for the whole day")
+	}
+}
+
+func TestResolveConnectTarget(t *testing.T) {
+	hosts := []hostItem{{host: "web1"}, {host: "build1"}}
+	cfg := appConfig{Groups: map[string]groupConfig{"builders": {Policy: policyRandom}}}
+	md := hostMetadata{"build1": {Tags: []string{"builders"}}}
+
+	if host, err := resolveConnectTarget("web1", cfg, md, hosts); err != nil || host != "web1" {
+		t.Errorf("expected web1, got %q (%v)", host, err)
+	}
+	if host, err := resolveConnectTarget("group:builders", cfg, md, hosts); err != nil || host != "build1" {
+		t.Errorf("expected build1, got %q (%v)", host, err)
+	}
+	if _, err := resolveConnectTarget("missing", cfg, md, hosts); err == nil {
+		t.Error("expected error for unknown host")
+	}
+}
+
+func TestSmartGroups(t *testing.T) {
+	hosts := []hostItem{{host: "web1"}, {host: "web2"}, {host: "db1"}}
+	cfg := appConfig{Groups: map[string]groupConfig{
+		"frontends": {Policy: policyRandom, Hosts: "web* and not tag:draining"},
+		"broken":    {Hosts: "tag:a and"},
+	}}
+	md := hostMetadata{"web2": {Tags: []string{"draining"}}, "db1": {Tags: []string{"db"}}}
+
+	if members, err := cfg.groupHosts("frontends", md, hosts); err != nil || len(members) != 1 || members[0] != "web1" {
+		t.Errorf("expected only web1, got %v (%v)", members, err)
+	}
+	if host, err := resolveConnectTarget("group:frontends", cfg, md, hosts); err != nil || host != "web1" {
+		t.Errorf("expected web1, got %q (%v)", host, err)
+	}
+	if _, err := cfg.groupHosts("broken", md, hosts); err == nil {
+		t.Error("expected an invalid expression to be reported")
+	}
+
+	if got := cfg.groupOf(hosts[0], md["web1"]); got != "frontends" {
+		t.Errorf("expected web1 to be in frontends, got %q", got)
+	}
+	if got := cfg.groupOf(hosts[2], md["db1"]); got != "db" {
+		t.Errorf("expected db1 to fall back to its tag, got %q", got)
+	}
+	if got := cfg.groupOf(hostItem{host: "mail1"}, hostMeta{}); got != "" {
+		t.Errorf("expected no group, got %q", got)
+	}
+}
+
+func TestGroupWithKey(t *testing.T) {
+	cfg := appConfig{Groups: map[string]groupConfig{
+		"web tier": {Hosts: "tag:web", Key: "1"},
+		"builders": {Policy: policyRoundRobin},
+	}}
+	if got := cfg.groupWithKey("1"); got != "web tier" {
+		t.Errorf("expected web tier, got %q", got)
+	}
+	if got := cfg.groupWithKey("2"); got != "" {
+		t.Errorf("expected no group, got %q", got)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The grouped view (z) lists the hosts in sections: the folder set in
+// hosts.json, else the group of groupOf, else the Include file declaring the
+// host. Enter on a section heading folds or unfolds it, Z folds or unfolds all
+// of them. The view and the folded sections are kept in state.json.
+
+// ungroupedSection heads the hosts without a folder, smart group or tag
+const ungroupedSection = "(no group)"
+
+// groupHeader is the heading of a section of the grouped view
+type groupHeader struct {
+	name      string
+	hosts     int
+	collapsed bool
+}
+
+func (g groupHeader) Title() string {
+	if g.collapsed {
+		return "▸ " + g.name
+	}
+	return "▾ " + g.name
+}
+func (g groupHeader) Description() string {
+	if g.hosts == 1 {
+		return "1 host"
+	}
+	return fmt.Sprintf("%d hosts", g.hosts)
+}
+
+// FilterValue is empty so that searches pass headings by
+func (g groupHeader) FilterValue() string { return "" }
+
+// hostSection returns the section of a host in the grouped view
+func (c appConfig) hostSection(item hostItem, meta hostMeta) string {
+	if meta.Folder != "" {
+		return meta.Folder
+	}
+	if group := c.groupOf(item, meta); group != "" {
+		return group
+	}
+	if item.source != "" {
+		return item.source
+	}
+	return ungroupedSection
+}
+
+// groupedItems arranges hosts in sections under headings, sorted by name with
+// the ungrouped hosts last. The hosts of collapsed sections are left out.
+func groupedItems(hosts []hostItem, section func(hostItem) string, collapsed map[string]bool) []list.Item {
+	var names []string
+	members := map[string][]hostItem{}
+	for _, h := range hosts {
+		name := section(h)
+		if _, ok := members[name]; !ok {
+			names = append(names, name)
+		}
+		members[name] = append(members[name], h)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == ungroupedSection) != (names[j] == ungroupedSection) {
+			return names[j] == ungroupedSection
+		}
+		return names[i] < names[j]
+	})
+	var items []list.Item
+	for _, name := range names {
+		items = append(items, groupHeader{name: name, hosts: len(members[name]), collapsed: collapsed[name]})
+		if collapsed[name] {
+			continue
+		}
+		for _, h := range members[name] {
+			items = append(items, h)
+		}
+	}
+	return items
+}
+
+// refreshList lays out the list again from all hosts: it puts in their tag
+// chips and stars, hides the hosts missing a tag of the tag filter (and the
+// unstarred ones with favoritesOnly), sorts them with the starred hosts first
+// and, in the grouped view, arranges them in sections
+func (m *model) refreshList() tea.Cmd {
+	all := m.hostItems()
+	var shown []hostItem
+	for i, h := range all {
+		h.chips = tagChips(m.metadata[h.host].Tags, m.config.TagColors)
+		h.favorite = m.metadata[h.host].Favorite
+		all[i] = h
+		if m.metadata.hasAllTags(h.host, m.tagFilter) && (h.favorite || !m.favoritesOnly) {
+			shown = append(shown, h)
+		}
+	}
+	shown = favoritesFirst(sortHosts(shown, m.sortMode, m.usage))
+	m.visualAnchor = -1
+	m.fullList = all
+	var items []list.Item
+	if m.grouped {
+		section := func(h hostItem) string { return m.config.hostSection(h, m.metadata[h.host]) }
+		items = groupedItems(shown, section, m.collapsed)
+	} else {
+		for _, h := range shown {
+			items = append(items, h)
+		}
+	}
+	// SetItems filters again when a search is active, which needs the new index
+	m.indexHosts()
+	return m.list.SetItems(items)
+}
+
+// toggleSection folds or unfolds a section of the grouped view
+func (m *model) toggleSection(name string) tea.Cmd {
+	m.collapsed[name] = !m.collapsed[name]
+	if !m.collapsed[name] {
+		delete(m.collapsed, name)
+	}
+	m.saveGroupView()
+	return m.refreshList()
+}
+
+// toggleAllSections folds all sections, or unfolds them when all are folded
+func (m *model) toggleAllSections() tea.Cmd {
+	var headers []string
+	folded := true
+	for _, it := range m.list.Items() {
+		if g, ok := it.(groupHeader); ok {
+			headers = append(headers, g.name)
+			folded = folded && g.collapsed
+		}
+	}
+	clear(m.collapsed)
+	if !folded {
+		for _, name := range headers {
+			m.collapsed[name] = true
+		}
+	}
+	m.saveGroupView()
+	return m.refreshList()
+}
+
+// saveGroupView keeps the grouped view and its folded sections in state.json
+func (m *model) saveGroupView() {
+	var collapsed []string
+	for name := range m.collapsed {
+		collapsed = append(collapsed, name)
+	}
+	slices.Sort(collapsed)
+	_ = updateAppState(func(st *appState) {
+		st.GroupedView = m.grouped
+		st.CollapsedGroups = collapsed
+	})
+}
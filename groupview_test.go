@@ -0,0 +1,77 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHostSection(t *testing.T) {
+	cfg := appConfig{Groups: map[string]groupConfig{"dbs": {Hosts: "name:db*"}}}
+	for _, tc := range []struct {
+		host   string
+		source string
+		meta   hostMeta
+		want   string
+	}{
+		{"web1", "", hostMeta{Folder: "customers/acme", Tags: []string{"web"}}, "customers/acme"},
+		{"db1", "", hostMeta{Tags: []string{"prod"}}, "dbs"},
+		{"web2", "", hostMeta{Tags: []string{"web", "prod"}}, "web"},
+		{"lonely", "", hostMeta{}, ungroupedSection},
+		{"ci1", "config.d/work", hostMeta{}, "config.d/work"},
+		{"ci2", "config.d/work", hostMeta{Tags: []string{"ci"}}, "ci"},
+	} {
+		if got := cfg.hostSection(hostItem{host: tc.host, source: tc.source}, tc.meta); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.host, got, tc.want)
+		}
+	}
+}
+
+func TestGroupedItems(t *testing.T) {
+	sections := map[string]string{"a": "web", "b": ungroupedSection, "c": "db", "d": "web"}
+	hosts := []hostItem{{host: "a"}, {host: "b"}, {host: "c"}, {host: "d"}}
+	section := func(h hostItem) string { return sections[h.host] }
+
+	var got []string
+	for _, it := range groupedItems(hosts, section, map[string]bool{"db": true}) {
+		got = append(got, it.(interface{ Title() string }).Title())
+	}
+	want := []string{"▸ db", "▾ web", "a", "d", "▾ (no group)", "b"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if d := (groupHeader{name: "db", hosts: 1}).Description(); d != "1 host" {
+		t.Errorf("unexpected description %q", d)
+	}
+}
+
+func TestRefreshListGrouped(t *testing.T) {
+	m := selectionModel("web1", "db1", "web2")
+	m.metadata = hostMetadata{
+		"web1": {Tags: []string{"web"}},
+		"db1":  {Folder: "databases"},
+		"web2": {Tags: []string{"web"}},
+	}
+	m.grouped = true
+	m.collapsed = map[string]bool{"web": true}
+	m.refreshList()
+	if got := listedHosts(m); !slices.Equal(got, []string{"db1"}) {
+		t.Errorf("listed %q, want only the unfolded section", got)
+	}
+	if got := hostNames(m.hostItems()); !slices.Equal(got, []string{"web1", "db1", "web2"}) {
+		t.Errorf("hostItems() = %q, want every host", got)
+	}
+
+	m.grouped = false
+	m.refreshList()
+	if got := listedHosts(m); !slices.Equal(got, []string{"web1", "db1", "web2"}) {
+		t.Errorf("listed %q, want the flat list", got)
+	}
+}
+
+func hostNames(items []hostItem) []string {
+	var names []string
+	for _, h := range items {
+		names = append(names, h.host)
+	}
+	return names
+}
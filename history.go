@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// historyEntry records one connection attempt. Failed attempts have no duration.
+type historyEntry struct {
+	Host     string        `json:"host"`
+	Time     time.Time     `json:"time"`
+	Failed   bool          `json:"failed,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// historyPath returns the location of the connection history in the app config directory
+func historyPath() (string, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// appendHistory adds an entry to the history file at path, one JSON object per line
+func appendHistory(path string, e historyEntry) error {
+	content, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(content, '\n'))
+	return err
+}
+
+// readHistory reads the history file at path. A missing file yields no entries;
+// lines that do not parse are skipped.
+func readHistory(path string) ([]historyEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e historyEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil && e.Host != "" {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// recordHistory appends an entry to the history unless history is disabled. Errors are
+// ignored: a read-only config directory must not get in the way of connecting.
+func recordHistory(cfg appConfig, e historyEntry) {
+	if cfg.DisableHistory {
+		return
+	}
+	if path, err := historyPath(); err == nil {
+		_ = appendHistory(path, e)
+	}
+}
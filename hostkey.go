@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Outcomes of comparing a server's host key with known_hosts
+const (
+	hostKeyKnown = iota
+	hostKeyNew
+	hostKeyChanged
+	hostKeyPinMismatch // the key differs from the one pinned in host metadata
+)
+
+// hostKeyMsg reports the host key a server presented and how it compares with known_hosts
+type hostKeyMsg struct {
+	host       string
+	status     int
+	key        ssh.PublicKey
+	patterns   []string // known_hosts host patterns as OpenSSH writes them, e.g. [example.com]:2222
+	knownHosts string   // the user's known_hosts file new keys are added to
+	pin        string   // pinned fingerprint, if any
+	err        error
+}
+
+// checkHostKey fetches the host key of a server and compares it with known_hosts
+// and, when set, the pinned fingerprint
+func checkHostKey(ctx context.Context, host, pin string, timeout time.Duration, sshOpts []string) tea.Cmd {
+	return func() tea.Msg {
+		algorithms := ""
+		if pin != "" {
+			// Ask for the pinned key's type, as the server may offer several keys
+			if known, err := knownHostKeys(host); err == nil {
+				for _, k := range known {
+					if ssh.FingerprintSHA256(k) == pin {
+						algorithms = hostKeyAlgorithms(k)
+					}
+				}
+			}
+		}
+		msg := scanHostKey(ctx, host, algorithms, timeout, sshOpts)
+		msg.host = host
+		msg.pin = pin
+		if msg.err == nil && pin != "" && ssh.FingerprintSHA256(msg.key) != pin {
+			msg.status = hostKeyPinMismatch
+		}
+		return msg
+	}
+}
+
+// hostKeyAlgorithms returns the HostKeyAlgorithms value that makes a server present key
+func hostKeyAlgorithms(key ssh.PublicKey) string {
+	if key.Type() == ssh.KeyAlgoRSA {
+		return ssh.KeyAlgoRSASHA512 + "," + ssh.KeyAlgoRSASHA256 + "," + ssh.KeyAlgoRSA
+	}
+	return key.Type()
+}
+
+// knownHostKeys returns the keys known_hosts holds for a host, without connecting to it
+func knownHostKeys(host string) ([]ssh.PublicKey, error) {
+	target, err := resolveSSHTarget(host)
+	if err != nil {
+		return nil, err
+	}
+	existing := existingFiles(target.knownHostsFiles)
+	if len(existing) == 0 {
+		return nil, nil
+	}
+	check, err := knownhosts.New(existing...)
+	if err != nil {
+		return nil, err
+	}
+	// Checking a key that cannot be known makes the callback list the known ones
+	_, probe, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(probe)
+	if err != nil {
+		return nil, err
+	}
+	address := net.JoinHostPort(target.hostname, target.port)
+	err = check(address, &net.TCPAddr{IP: net.IPv4zero, Port: 22}, signer.PublicKey())
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return nil, nil
+	}
+	var keys []ssh.PublicKey
+	for _, want := range keyErr.Want {
+		keys = append(keys, want.Key)
+	}
+	return keys, nil
+}
+
+// describeHostKey renders a key as one line for the info box
+func describeHostKey(key ssh.PublicKey, pin string) string {
+	keyType, _ := keyTypeAndBits(key)
+	fp := ssh.FingerprintSHA256(key)
+	line := "Host key " + keyType + " " + fp
+	if fp == pin {
+		line += " (pinned)"
+	}
+	return line
+}
+
+// nextPin cycles the pin through the known keys of a host and then back to no pin
+func nextPin(keys []ssh.PublicKey, pin string) string {
+	if pin == "" {
+		if len(keys) == 0 {
+			return ""
+		}
+		return ssh.FingerprintSHA256(keys[0])
+	}
+	for i, k := range keys {
+		if ssh.FingerprintSHA256(k) == pin && i+1 < len(keys) {
+			return ssh.FingerprintSHA256(keys[i+1])
+		}
+	}
+	return ""
+}
+
+// scanHostKey lets OpenSSH record the server's key in a scratch known_hosts file,
+// so ProxyJump, HostKeyAlias and ports are handled exactly as for the real
+// connection, then looks the recorded key up in the user's known_hosts files
+func scanHostKey(ctx context.Context, host, algorithms string, timeout time.Duration, sshOpts []string) hostKeyMsg {
+	target, err := resolveSSHTarget(host)
+	if err != nil {
+		return hostKeyMsg{err: err}
+	}
+	files := target.knownHostsFiles
+	if len(files) == 0 {
+		files = []string{"~/.ssh/known_hosts"}
+	}
+	msg := hostKeyMsg{knownHosts: expandHome(files[0])}
+
+	scratch, err := os.CreateTemp("", "list-ssh-hosts-known_hosts")
+	if err != nil {
+		msg.err = err
+		return msg
+	}
+	scratch.Close()
+	defer os.Remove(scratch.Name())
+
+	// No authentication method is enabled, so this never logs in or prompts
+	args := []string{"-o", "UserKnownHostsFile=" + scratch.Name(),
+		"-o", "GlobalKnownHostsFile=" + os.DevNull,
+		"-o", "StrictHostKeyChecking=accept-new",
+		"-o", "HashKnownHosts=no",
+		"-o", "BatchMode=yes",
+		"-o", connectTimeoutOption(timeout),
+		"-o", "PubkeyAuthentication=no",
+		"-o", "PasswordAuthentication=no",
+		"-o", "KbdInteractiveAuthentication=no",
+		"-o", "GSSAPIAuthentication=no",
+	}
+	if algorithms != "" {
+		args = append(args, "-o", "HostKeyAlgorithms="+algorithms)
+	}
+	args = append(args, sshOpts...)
+	exec.CommandContext(ctx, "ssh", append(args, host, "exit")...).Run()
+
+	content, err := os.ReadFile(scratch.Name())
+	if err != nil {
+		msg.err = err
+		return msg
+	}
+	// The target's key is the last one recorded; jump hosts come first
+	for len(content) > 0 {
+		_, patterns, key, _, rest, err := ssh.ParseKnownHosts(content)
+		if err != nil {
+			break
+		}
+		msg.patterns, msg.key = patterns, key
+		content = rest
+	}
+	if msg.key == nil {
+		msg.err = fmt.Errorf("could not retrieve the host key of %s", host)
+		return msg
+	}
+
+	msg.status = compareHostKey(existingFiles(files), msg.patterns[0], msg.key)
+	return msg
+}
+
+// existingFiles expands the given paths and keeps those that exist
+func existingFiles(files []string) []string {
+	var existing []string
+	for _, f := range files {
+		if _, err := os.Stat(expandHome(f)); err == nil {
+			existing = append(existing, expandHome(f))
+		}
+	}
+	return existing
+}
+
+// compareHostKey looks a key up in the given known_hosts files. A known key of a
+// different type does not count as a change, as the server may simply offer several.
+func compareHostKey(files []string, pattern string, key ssh.PublicKey) int {
+	if len(files) == 0 {
+		return hostKeyNew
+	}
+	check, err := knownhosts.New(files...)
+	if err != nil {
+		return hostKeyNew
+	}
+	address := pattern
+	if !strings.HasPrefix(pattern, "[") {
+		address = net.JoinHostPort(pattern, "22")
+	} else {
+		address = strings.NewReplacer("[", "", "]", "").Replace(pattern)
+		if i := strings.LastIndex(address, ":"); i >= 0 {
+			address = net.JoinHostPort(address[:i], address[i+1:])
+		}
+	}
+
+	err = check(address, &net.TCPAddr{IP: net.IPv4zero, Port: 22}, key)
+	var keyErr *knownhosts.KeyError
+	switch {
+	case err == nil:
+		return hostKeyKnown
+	case errors.As(err, &keyErr):
+		for _, want := range keyErr.Want {
+			if want.Key.Type() == key.Type() {
+				return hostKeyChanged
+			}
+		}
+	}
+	return hostKeyNew
+}
+
+// acceptHostKey records a verified key in known_hosts, replacing any old key for the host
+func acceptHostKey(msg hostKeyMsg) error {
+	if msg.status == hostKeyChanged {
+		if err := exec.Command("ssh-keygen", "-R", msg.patterns[0], "-f", msg.knownHosts).Run(); err != nil {
+			return fmt.Errorf("could not remove the old key: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(msg.knownHosts), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(msg.knownHosts, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, knownhosts.Line(msg.patterns, msg.key))
+	return err
+}
+
+// keyTypeAndBits returns the key type and size as ssh-keygen prints them, e.g. ED25519 256
+func keyTypeAndBits(key ssh.PublicKey) (string, int) {
+	if ck, ok := key.(ssh.CryptoPublicKey); ok {
+		switch k := ck.CryptoPublicKey().(type) {
+		case *rsa.PublicKey:
+			return "RSA", k.N.BitLen()
+		case *ecdsa.PublicKey:
+			return "ECDSA", k.Curve.Params().BitSize
+		}
+	}
+	switch key.Type() {
+	case ssh.KeyAlgoED25519:
+		return "ED25519", 256
+	case ssh.KeyAlgoSKED25519:
+		return "ED25519-SK", 256
+	case ssh.KeyAlgoSKECDSA256:
+		return "ECDSA-SK", 256
+	}
+	return strings.ToUpper(key.Type()), 0
+}
+
+// randomArt draws the OpenSSH "drunken bishop" visualization of a key's SHA256 fingerprint
+func randomArt(key ssh.PublicKey) string {
+	const width, height = 17, 9
+	const symbols = " .o+=*BOX@%&#/^SE"
+	var field [width][height]int
+
+	digest := sha256.Sum256(key.Marshal())
+	x, y := width/2, height/2
+	for _, b := range digest {
+		for i := 0; i < 4; i++ {
+			if b&1 != 0 {
+				x++
+			} else {
+				x--
+			}
+			if b&2 != 0 {
+				y++
+			} else {
+				y--
+			}
+			x = max(0, min(x, width-1))
+			y = max(0, min(y, height-1))
+			if field[x][y] < len(symbols)-3 {
+				field[x][y]++
+			}
+			b >>= 2
+		}
+	}
+	field[width/2][height/2] = len(symbols) - 2 // S
+	field[x][y] = len(symbols) - 1              // E
+
+	keyType, bits := keyTypeAndBits(key)
+	var b strings.Builder
+	b.WriteString(artBorder(fmt.Sprintf("[%s %d]", keyType, bits), width) + "\n")
+	for row := 0; row < height; row++ {
+		b.WriteString("|")
+		for col := 0; col < width; col++ {
+			b.WriteByte(symbols[field[col][row]])
+		}
+		b.WriteString("|\n")
+	}
+	b.WriteString(artBorder("[SHA256]", width))
+	return b.String()
+}
+
+// artBorder centers a title in a randomart border line
+func artBorder(title string, width int) string {
+	if len(title) > width {
+		title = title[:width]
+	}
+	left := (width - len(title)) / 2
+	return "+" + strings.Repeat("-", left) + title + strings.Repeat("-", width-left-len(title)) + "+"
+}
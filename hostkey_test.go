@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Generated with ssh-keygen; the expected art is the output of ssh-keygen -lv
+const testHostKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIIWn80asU3t/7OmEYJuD1L75pku86ZgYH+2gFIQ8I4GW"
+
+func TestRandomArt(t *testing.T) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(testHostKey))
+	if err != nil {
+		t.Fatalf("failed to parse key: %v", err)
+	}
+	expected := `+--[ED25519 256]--+
+|       .o        |
+|.. o o ...       |
+|=.. B o.         |
+|o++= = oE        |
+|.=o.+ = S        |
+|+ .= = .         |
+|ooo =.o          |
+|o+=oOo           |
+| +o*+B.          |
++----[SHA256]-----+`
+	if got := randomArt(key); got != expected {
+		t.Errorf("unexpected randomart:\n%s\nexpected:\n%s", got, expected)
+	}
+	if ssh.FingerprintSHA256(key) != "SHA256:6GSAx+kl7hYI6/KUQg2+lID5zz6TI3I+c+M5T4XQNew" {
+		t.Errorf("unexpected fingerprint %s", ssh.FingerprintSHA256(key))
+	}
+}
+
+func TestCompareHostKey(t *testing.T) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(testHostKey))
+	if err != nil {
+		t.Fatalf("failed to parse key: %v", err)
+	}
+	dir := t.TempDir()
+	knownHosts := dir + "/known_hosts"
+	if err := acceptHostKey(hostKeyMsg{status: hostKeyNew, key: key, patterns: []string{"[example.com]:2222"}, knownHosts: knownHosts}); err != nil {
+		t.Fatalf("acceptHostKey failed: %v", err)
+	}
+
+	if got := compareHostKey([]string{knownHosts}, "[example.com]:2222", key); got != hostKeyKnown {
+		t.Errorf("expected known key, got %d", got)
+	}
+	if got := compareHostKey([]string{knownHosts}, "example.com", key); got != hostKeyNew {
+		t.Errorf("expected new key for a different port, got %d", got)
+	}
+	if got := compareHostKey(nil, "example.com", key); got != hostKeyNew {
+		t.Errorf("expected new key without known_hosts, got %d", got)
+	}
+
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ssh.NewPublicKey(otherKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := compareHostKey([]string{knownHosts}, "[example.com]:2222", other); got != hostKeyChanged {
+		t.Errorf("expected changed key, got %d", got)
+	}
+}
+
+func TestNextPin(t *testing.T) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(testHostKey))
+	if err != nil {
+		t.Fatalf("failed to parse key: %v", err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ssh.NewPublicKey(otherKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []ssh.PublicKey{key, other}
+
+	pin := nextPin(keys, "")
+	if pin != ssh.FingerprintSHA256(key) {
+		t.Errorf("expected the first key to be pinned, got %q", pin)
+	}
+	if pin = nextPin(keys, pin); pin != ssh.FingerprintSHA256(other) {
+		t.Errorf("expected the second key to be pinned, got %q", pin)
+	}
+	if pin = nextPin(keys, pin); pin != "" {
+		t.Errorf("expected the pin to be removed after the last key, got %q", pin)
+	}
+	if pin = nextPin(nil, ""); pin != "" {
+		t.Errorf("expected no pin without known keys, got %q", pin)
+	}
+	if got := describeHostKey(key, ssh.FingerprintSHA256(key)); got != "Host key ED25519 SHA256:6GSAx+kl7hYI6/KUQg2+lID5zz6TI3I+c+M5T4XQNew (pinned)" {
+		t.Errorf("unexpected description %q", got)
+	}
+}
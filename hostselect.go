@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"strings"
+)
+
+// hostFilter reports whether a host is selected by an expression
+type hostFilter func(item hostItem, meta hostMeta) bool
+
+// parseHostSelection compiles a host selection expression, such as
+// "tag:prod and not (tag:db or web-*)". Terms are:
+//
+//	tag:<tag>        hosts carrying the tag
+//	env:<name>       hosts in the environment
+//	<glob>           hosts whose alias matches the glob, also written name:<glob>
+//	<cidr>           hosts whose address is in the network, also written cidr:<cidr>
+//
+// and combine with and, or, not and parentheses; and binds tighter than or.
+// Addresses are those of HostName in the SSH config; names are not resolved.
+func parseHostSelection(expr string) (hostFilter, error) {
+	p := &selectionParser{tokens: tokenizeSelection(expr)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty host selection")
+	}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in host selection", p.tokens[p.pos])
+	}
+	return f, nil
+}
+
+// tokenizeSelection splits an expression into words and parentheses
+func tokenizeSelection(expr string) []string {
+	expr = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr)
+	return strings.Fields(expr)
+}
+
+// selectionParser is a recursive descent parser over the tokens of an expression
+type selectionParser struct {
+	tokens []string
+	pos    int
+}
+
+// next returns the current token without consuming it, or "" at the end
+func (p *selectionParser) next() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *selectionParser) or() (hostFilter, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.next(), "or") {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item hostItem, meta hostMeta) bool { return l(item, meta) || right(item, meta) }
+	}
+	return left, nil
+}
+
+func (p *selectionParser) and() (hostFilter, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.next(), "and") {
+		p.pos++
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item hostItem, meta hostMeta) bool { return l(item, meta) && right(item, meta) }
+	}
+	return left, nil
+}
+
+func (p *selectionParser) not() (hostFilter, error) {
+	if strings.EqualFold(p.next(), "not") {
+		p.pos++
+		f, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(item hostItem, meta hostMeta) bool { return !f(item, meta) }, nil
+	}
+	return p.primary()
+}
+
+func (p *selectionParser) primary() (hostFilter, error) {
+	tok := p.next()
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("host selection ends unexpectedly")
+	case tok == "(":
+		p.pos++
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ) in host selection")
+		}
+		p.pos++
+		return f, nil
+	case tok == ")", strings.EqualFold(tok, "and"), strings.EqualFold(tok, "or"):
+		return nil, fmt.Errorf("unexpected %q in host selection", tok)
+	}
+	p.pos++
+	return selectionTerm(tok)
+}
+
+// selectionTerm compiles a single term of an expression
+func selectionTerm(tok string) (hostFilter, error) {
+	// A bare term is a network when it parses as one (IPv6 ones contain colons too),
+	// a name glob otherwise
+	kind, value, ok := strings.Cut(tok, ":")
+	if _, err := netip.ParsePrefix(tok); err == nil {
+		kind, value = "cidr", tok
+	} else if !ok {
+		kind, value = "name", tok
+	}
+	switch kind {
+	case "tag":
+		return func(_ hostItem, meta hostMeta) bool { return contains(meta.Tags, value) }, nil
+	case "env":
+		return func(_ hostItem, meta hostMeta) bool { return strings.EqualFold(meta.Environment, value) }, nil
+	case "name":
+		if _, err := path.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q", value)
+		}
+		return func(item hostItem, _ hostMeta) bool {
+			ok, _ := path.Match(value, item.host)
+			return ok
+		}, nil
+	case "cidr":
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", value)
+		}
+		return func(item hostItem, _ hostMeta) bool {
+			addr, ok := item.address()
+			return ok && prefix.Contains(addr)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown term %q in host selection; use tag:, env:, name: or cidr:", tok)
+}
+
+// hostname returns the HostName the host connects to, without the user
+func (i hostItem) hostname() string {
+	if _, after, ok := strings.Cut(i.desc, "@"); ok {
+		return after
+	}
+	return i.desc
+}
+
+// address returns the IP address the host connects to, when its HostName is one
+func (i hostItem) address() (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(i.hostname())
+	return addr, err == nil
+}
+
+// selectHosts returns the hosts from items that filter selects, in list order
+func selectHosts(items []hostItem, md hostMetadata, filter hostFilter) []string {
+	var hosts []string
+	for _, it := range items {
+		if filter(it, md[it.host]) {
+			hosts = append(hosts, it.host)
+		}
+	}
+	return hosts
+}
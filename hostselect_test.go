@@ -0,0 +1,50 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseHostSelection(t *testing.T) {
+	items := []hostItem{
+		{host: "web-prod-1", desc: "deploy@10.0.1.5"},
+		{host: "web-stage-1", desc: "10.1.1.5"},
+		{host: "db-prod-1", desc: "10.0.2.7"},
+		{host: "bastion", desc: "bastion.example.com"},
+		{host: "v6", desc: "fd00::10"},
+	}
+	md := hostMetadata{
+		"web-prod-1":  {Tags: []string{"prod", "web"}, Environment: "prod"},
+		"web-stage-1": {Tags: []string{"web"}, Environment: "staging"},
+		"db-prod-1":   {Tags: []string{"prod", "db"}, Environment: "prod"},
+	}
+	tests := []struct {
+		expr     string
+		expected []string
+	}{
+		{"tag:prod and not tag:db", []string{"web-prod-1"}},
+		{"tag:db or web-*", []string{"web-prod-1", "web-stage-1", "db-prod-1"}},
+		{"not (tag:prod or env:staging)", []string{"bastion", "v6"}},
+		{"NOT tag:web AND name:*-prod-*", []string{"db-prod-1"}},
+		{"10.0.0.0/16", []string{"web-prod-1", "db-prod-1"}},
+		{"cidr:10.0.0.0/8 and env:STAGING", []string{"web-stage-1"}},
+		{"fd00::/8", []string{"v6"}},
+		{"tag:web or tag:db and env:staging", []string{"web-prod-1", "web-stage-1"}},
+	}
+	for _, tt := range tests {
+		filter, err := parseHostSelection(tt.expr)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if got := selectHosts(items, md, filter); !slices.Equal(got, tt.expected) {
+			t.Errorf("%q: expected %v, got %v", tt.expr, tt.expected, got)
+		}
+	}
+
+	for _, expr := range []string{"", "tag:a and", "(tag:a", "tag:a tag:b", "or tag:a", "role:web", "cidr:10.0.0.0", "name:[a"} {
+		if _, err := parseHostSelection(expr); err == nil {
+			t.Errorf("expected %q to be rejected", expr)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const icsTimeFormat = "20060102T150405Z"
+
+// writeICS writes the maintenance windows of all hosts as an iCalendar feed
+func writeICS(w io.Writer, md hostMetadata, now time.Time) error {
+	hosts := make([]string, 0, len(md))
+	for host := range md {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//list-ssh-hosts//maintenance//EN",
+	}
+	for _, host := range hosts {
+		for i, mw := range md[host].Maintenance {
+			summary := host + ": maintenance"
+			if mw.Summary != "" {
+				summary = host + ": " + mw.Summary
+			}
+			lines = append(lines,
+				"BEGIN:VEVENT",
+				fmt.Sprintf("UID:%s-%d@list-ssh-hosts", host, i),
+				"DTSTAMP:"+now.UTC().Format(icsTimeFormat),
+				"DTSTART:"+mw.Start.UTC().Format(icsTimeFormat),
+				"DTEND:"+mw.End.UTC().Format(icsTimeFormat),
+				"SUMMARY:"+icsEscape(summary),
+			)
+			if mw.RRule != "" {
+				lines = append(lines, "RRULE:"+mw.RRule)
+			}
+			lines = append(lines, "END:VEVENT")
+		}
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for i, line := range lines {
+		lines[i] = icsFold(line)
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\r\n")+"\r\n")
+	return err
+}
+
+// icsMaxLine is the longest content line RFC 5545 allows, in octets
+const icsMaxLine = 75
+
+// icsFold folds a content line longer than 75 octets as RFC 5545 requires:
+// each continuation starts with a space after a CRLF. UTF-8 sequences are not
+// split across lines.
+func icsFold(line string) string {
+	var b strings.Builder
+	limit := icsMaxLine
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space counts towards the limit
+		limit = icsMaxLine - 1
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+// icsEscape escapes text values as required by RFC 5545
+func icsEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// runExportICS implements the export-ics command: export-ics [file]
+func runExportICS(args []string) int {
+	md, err := loadHostMetadata()
+	if err != nil {
+		fmt.Println("Could not read host metadata:", err)
+		return 1
+	}
+
+	out := os.Stdout
+	if len(args) > 0 {
+		f, err := os.Create(args[0])
+		if err != nil {
+			fmt.Println("Could not create calendar file:", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := writeICS(out, md, time.Now()); err != nil {
+		fmt.Println("Could not write calendar:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestWriteICS(t *testing.T) {
+	md := hostMetadata{
+		"db1": {Maintenance: []maintenanceWindow{{
+			Summary: "kernel patching, reboot",
+			Start:   time.Date(2025, 8, 3, 22, 0, 0, 0, time.UTC),
+			End:     time.Date(2025, 8, 4, 1, 0, 0, 0, time.UTC),
+			RRule:   "FREQ=WEEKLY;BYDAY=SU",
+		}}},
+		"web1": {Tags: []string{"web"}},
+	}
+
+	var b strings.Builder
+	if err := writeICS(&b, md, time.Date(2025, 7, 21, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("writeICS failed: %v", err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:db1-0@list-ssh-hosts\r\n",
+		"DTSTART:20250803T220000Z\r\n",
+		"DTEND:20250804T010000Z\r\n",
+		"SUMMARY:db1: kernel patching\\, reboot\r\n",
+		"RRULE:FREQ=WEEKLY;BYDAY=SU\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected calendar to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "BEGIN:VEVENT") != 1 {
+		t.Errorf("expected exactly one event, got:\n%s", out)
+	}
+}
+
+func TestWriteICSFoldsLongLines(t *testing.T) {
+	summary := strings.Repeat("patch web1, web2, web3 and the load balancers ", 4) + "für alle"
+	md := hostMetadata{"db1": {Maintenance: []maintenanceWindow{{
+		Summary: summary,
+		Start:   time.Date(2025, 8, 3, 22, 0, 0, 0, time.UTC),
+		End:     time.Date(2025, 8, 4, 1, 0, 0, 0, time.UTC),
+	}}}}
+	var b strings.Builder
+	if err := writeICS(&b, md, time.Date(2025, 7, 21, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("writeICS failed: %v", err)
+	}
+	for _, line := range strings.Split(b.String(), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("folding split a character: %q", line)
+		}
+	}
+	unfolded := strings.ReplaceAll(b.String(), "\r\n ", "")
+	if !strings.Contains(unfolded, "SUMMARY:"+icsEscape("db1: "+summary)+"\r\n") {
+		t.Errorf("expected the summary to unfold to the original, got:\n%s", b.String())
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bastionCheckedMsg reports whether a bastion lets us in without a password
+type bastionCheckedMsg struct {
+	jump string
+	save bool // save it as ProxyJump once the login through it works
+	err  error
+}
+
+// checkBastion tests that jump logs in with a key or the agent. A password
+// cannot be given to it: sshpass answers the first prompt it sees, which with
+// -J is the bastion's, with the password of the host behind it. Only a failure
+// of ssh itself counts, as bastions often refuse to run commands.
+func checkBastion(jump string, timeout time.Duration, save bool) tea.Cmd {
+	return func() tea.Msg {
+		cmd := exec.Command("ssh", "-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=yes",
+			"-o", connectTimeoutOption(timeout), jump, "exit")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() != sshConnectionError {
+			err = nil
+		}
+		if err != nil && lastLine(stderr.String()) != "" {
+			err = errors.New(lastLine(stderr.String()))
+		}
+		return bastionCheckedMsg{jump: jump, save: save, err: err}
+	}
+}
+
+// jumpCandidates returns the hosts that can serve as bastion for host: every
+// other host in the list, with the host's configured ProxyJump first
+func jumpCandidates(items []hostItem, host, configured string) []string {
+	var hosts []string
+	for _, h := range items {
+		if h.host == host {
+			continue
+		}
+		if h.host == configured {
+			hosts = append([]string{h.host}, hosts...)
+			continue
+		}
+		hosts = append(hosts, h.host)
+	}
+	return hosts
+}
+
+// jumpArgs returns the ssh flags routing a connection through jump
+func jumpArgs(jump string) []string {
+	if jump == "" {
+		return nil
+	}
+	return []string{"-J", jump}
+}
+
+// setHostProxyJump makes the host connect through jump in ~/.ssh/config
+func setHostProxyJump(host, jump string) error {
+	return writeHostOption(host, "ProxyJump", jump)
+}
+
+// jumpHostView lists the bastion candidates with a cursor, marking the one
+// the SSH config already uses
+func jumpHostView(hosts []string, selected int, configured string) string {
+	if len(hosts) == 0 {
+		return "There are no other hosts to connect through.\n"
+	}
+	var s string
+	for i, h := range hosts {
+		cursor := "  "
+		if i == selected {
+			cursor = "> "
+		}
+		if h == configured {
+			h += " (ProxyJump)"
+		}
+		s += fmt.Sprintf("%s%s\n", cursor, h)
+	}
+	return s
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestJumpCandidates(t *testing.T) {
+	items := []hostItem{{host: "web1"}, {host: "db1"}, {host: "bastion"}}
+	got := jumpCandidates(items, "web1", "bastion")
+	if want := []string{"bastion", "db1"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := jumpCandidates(items, "web1", ""); !slices.Equal(got, []string{"db1", "bastion"}) {
+		t.Errorf("expected list order without a ProxyJump, got %v", got)
+	}
+	if args := jumpArgs(""); args != nil {
+		t.Errorf("expected no flags without a bastion, got %v", args)
+	}
+	if args := jumpArgs("bastion"); !slices.Equal(args, []string{"-J", "bastion"}) {
+		t.Errorf("unexpected flags %v", args)
+	}
+}
+
+func TestSetProxyJump(t *testing.T) {
+	config := "Host web1\n  Hostname 10.0.0.1\n  ProxyJump old\nHost db1\n  Hostname 10.0.0.5\n"
+
+	got, err := setHostOption(config, "web1", "ProxyJump", "bastion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "  Hostname 10.0.0.1\n  ProxyJump bastion\nHost db1") {
+		t.Errorf("expected the existing ProxyJump to be replaced:\n%s", got)
+	}
+
+	got, err = setHostOption(config, "db1", "ProxyJump", "bastion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "Host db1\n  ProxyJump bastion\n  Hostname 10.0.0.5\n") {
+		t.Errorf("expected a ProxyJump line to be added:\n%s", got)
+	}
+}
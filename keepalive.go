@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// keepalive returns the ServerAliveInterval (in seconds) and ServerAliveCountMax
+// for host: its metadata, else config.json. Zero leaves a setting to ~/.ssh/config.
+func keepalive(meta hostMeta, cfg appConfig) (interval, countMax int) {
+	interval, countMax = cfg.ServerAliveInterval, cfg.ServerAliveCountMax
+	if meta.ServerAliveInterval > 0 {
+		interval = meta.ServerAliveInterval
+	}
+	if meta.ServerAliveCountMax > 0 {
+		countMax = meta.ServerAliveCountMax
+	}
+	return interval, countMax
+}
+
+// keepaliveArgs returns the ssh options for the keepalive settings of a host.
+// Options on the command line take precedence over ~/.ssh/config.
+func keepaliveArgs(meta hostMeta, cfg appConfig) []string {
+	interval, countMax := keepalive(meta, cfg)
+	var args []string
+	if interval > 0 {
+		args = append(args, "-o", "ServerAliveInterval="+strconv.Itoa(interval))
+	}
+	if countMax > 0 {
+		args = append(args, "-o", "ServerAliveCountMax="+strconv.Itoa(countMax))
+	}
+	return args
+}
+
+// keepAlive sends keepalives over a connection of the built-in client every
+// interval seconds, like ssh's ServerAlive options, and closes it after
+// countMax (3 when 0) go unanswered. It does nothing when interval is 0.
+func keepAlive(client *ssh.Client, interval, countMax int) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	if countMax <= 0 {
+		countMax = 3
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		answered := make(chan error, 1)
+		pending, missed := false, 0
+		for {
+			select {
+			case <-done:
+				return
+			case err := <-answered:
+				if err != nil {
+					// The connection is gone already
+					return
+				}
+				pending, missed = false, 0
+			case <-ticker.C:
+				if pending {
+					if missed++; missed >= countMax {
+						client.Close()
+						return
+					}
+					continue
+				}
+				pending = true
+				go func() {
+					_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+					answered <- err
+				}()
+			}
+		}
+	}()
+	return func() { close(done) }
+}
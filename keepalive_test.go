@@ -0,0 +1,21 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestKeepaliveArgs(t *testing.T) {
+	if args := keepaliveArgs(hostMeta{}, appConfig{}); args != nil {
+		t.Errorf("expected the settings to be left to ~/.ssh/config, got %q", args)
+	}
+	cfg := appConfig{ServerAliveInterval: 30, ServerAliveCountMax: 4}
+	want := []string{"-o", "ServerAliveInterval=30", "-o", "ServerAliveCountMax=4"}
+	if args := keepaliveArgs(hostMeta{}, cfg); !slices.Equal(args, want) {
+		t.Errorf("expected the app defaults %q, got %q", want, args)
+	}
+	want = []string{"-o", "ServerAliveInterval=10", "-o", "ServerAliveCountMax=4"}
+	if args := keepaliveArgs(hostMeta{ServerAliveInterval: 10}, cfg); !slices.Equal(args, want) {
+		t.Errorf("expected the host's interval over the default %q, got %q", want, args)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/ssh"
+)
+
+// hostKeyChangesMsg carries the known_hosts keys of all hosts, looked up at startup
+type hostKeyChangesMsg struct {
+	keys    map[string][]ssh.PublicKey
+	changed []string // hosts whose keys differ from the previous run
+}
+
+// hostKeyFingerprints returns the sorted SHA256 fingerprints of keys
+func hostKeyFingerprints(keys []ssh.PublicKey) []string {
+	fps := make([]string, 0, len(keys))
+	for _, k := range keys {
+		fps = append(fps, ssh.FingerprintSHA256(k))
+	}
+	slices.Sort(fps)
+	return fps
+}
+
+// changedHostKeys lists the hosts that lost a cached fingerprint, because a key
+// was replaced or removed. A host that only gained a key, such as one of
+// another type, is not reported, nor are hosts seen for the first time or
+// without any known key now.
+func changedHostKeys(cached, current map[string][]string) []string {
+	var changed []string
+	for host, fps := range current {
+		prev, ok := cached[host]
+		if !ok || len(prev) == 0 || len(fps) == 0 {
+			continue
+		}
+		for _, fp := range prev {
+			if !slices.Contains(fps, fp) {
+				changed = append(changed, host)
+				break
+			}
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// checkHostKeyChanges compares the known_hosts keys of hosts with the fingerprints
+// cached in the state file by the previous run, then caches the current ones
+func checkHostKeyChanges(hosts []string, cached map[string][]string) tea.Cmd {
+	return func() tea.Msg {
+		keys := map[string][]ssh.PublicKey{}
+		current := map[string][]string{}
+		for _, host := range hosts {
+			k, err := knownHostKeys(host)
+			if err != nil {
+				continue
+			}
+			keys[host] = k
+			current[host] = hostKeyFingerprints(k)
+		}
+		changed := changedHostKeys(cached, current)
+		_ = updateAppState(func(st *appState) {
+			if st.HostKeys == nil {
+				st.HostKeys = map[string][]string{}
+			}
+			for host, fps := range current {
+				if len(fps) > 0 {
+					st.HostKeys[host] = fps
+				}
+			}
+		})
+		return hostKeyChangesMsg{keys: keys, changed: changed}
+	}
+}
+
+// rememberHostKeys caches the current known_hosts fingerprints of a host, so a key
+// accepted in the app is not reported as changed on the next start
+func rememberHostKeys(host string) {
+	keys, err := knownHostKeys(host)
+	if err != nil || len(keys) == 0 {
+		return
+	}
+	_ = updateAppState(func(st *appState) {
+		if st.HostKeys == nil {
+			st.HostKeys = map[string][]string{}
+		}
+		st.HostKeys[host] = hostKeyFingerprints(keys)
+	})
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestChangedHostKeys(t *testing.T) {
+	cached := map[string][]string{
+		"web":   {"SHA256:a", "SHA256:b"},
+		"db":    {"SHA256:c"},
+		"gone":  {"SHA256:d"},
+		"empty": {},
+		"more":  {"SHA256:h"},
+		"fewer": {"SHA256:i", "SHA256:j"},
+	}
+	current := map[string][]string{
+		"web":   {"SHA256:a", "SHA256:b"},
+		"more":  {"SHA256:h", "SHA256:k"},
+		"fewer": {"SHA256:i"},
+		"db":    {"SHA256:e"},
+		"gone":  {},
+		"empty": {"SHA256:f"},
+		"new":   {"SHA256:g"},
+	}
+	if got := changedHostKeys(cached, current); !slices.Equal(got, []string{"db", "fewer"}) {
+		t.Errorf("expected only db and fewer to be reported, got %v", got)
+	}
+	if got := changedHostKeys(nil, current); got != nil {
+		t.Errorf("expected nothing to be reported on the first run, got %v", got)
+	}
+}
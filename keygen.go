@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Steps of the key generation wizard
+const (
+	keygenPath = iota
+	keygenPassphrase
+	keygenConfirm
+	keygenDeploy
+)
+
+// generateKey writes a new ed25519 key pair to path and path.pub, like ssh-keygen -t ed25519.
+// An empty passphrase leaves the private key unencrypted. Existing files are never replaced.
+func generateKey(path, passphrase, comment string) error {
+	for _, p := range []string{path, path + ".pub"} {
+		if _, err := os.Stat(p); err == nil {
+			return fmt.Errorf("%s already exists", p)
+		}
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, comment)
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, comment, []byte(passphrase))
+	}
+	if err != nil {
+		return err
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return err
+	}
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub))) + " " + comment + "\n"
+	return os.WriteFile(path+".pub", []byte(authorized), 0644)
+}
+
+// setIdentityFile returns config with path as the host's first IdentityFile. The
+// IdentityFile lines already in its block are kept after it, so the host still
+// accepts a login while the new key is not installed there yet.
+func setIdentityFile(config, host, path string) (string, error) {
+	lines := strings.Split(config, "\n")
+	start, found, indent := findHostOption(lines, host, "IdentityFile")
+	if start < 0 {
+		return "", errHostNotInConfig
+	}
+	at := start + 1
+	if found >= 0 {
+		at = found
+	}
+	lines = slices.Insert(lines, at, indent+"IdentityFile "+path)
+	return strings.Join(lines, "\n"), nil
+}
+
+var errHostNotInConfig = errors.New("host not found in ~/.ssh/config")
+
+// setHostOption returns config with keyword set to value in the host's block,
+// replacing the first existing line for keyword or adding one after the Host line
+func setHostOption(config, host, keyword, value string) (string, error) {
+	lines := strings.Split(config, "\n")
+	start, found, indent := findHostOption(lines, host, keyword)
+	if start < 0 {
+		return "", errHostNotInConfig
+	}
+	if found >= 0 {
+		lines[found] = indent + keyword + " " + value
+	} else {
+		lines = slices.Insert(lines, start+1, indent+keyword+" "+value)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// findHostOption returns the index of the Host line of host in lines and of the
+// first line for keyword in its block, -1 when missing, and the indentation of
+// the block up to that line
+func findHostOption(lines []string, host, keyword string) (start, found int, indent string) {
+	start, found, indent = -1, -1, "    "
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && strings.EqualFold(fields[0], "host") && contains(fields[1:], host) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return start, found, indent
+	}
+	for i := start + 1; i < len(lines); i++ {
+		line := lines[i]
+		if len(line) > 0 && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			break
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if fields := strings.Fields(trimmed); strings.EqualFold(fields[0], keyword) {
+			return start, i, indent
+		}
+	}
+	return start, found, indent
+}
+
+// setHostIdentityFile adds path as the first IdentityFile of the host in ~/.ssh/config
+func setHostIdentityFile(host, path string) error {
+	return updateSSHConfig(func(config string) (string, error) {
+		return setIdentityFile(config, host, path)
+	})
+}
+
+// writeHostOption sets keyword to value in the host's block of ~/.ssh/config
+func writeHostOption(host, keyword, value string) error {
+	return updateSSHConfig(func(config string) (string, error) {
+		return setHostOption(config, host, keyword, value)
+	})
+}
+
+// updateSSHConfig rewrites ~/.ssh/config with update
+func updateSSHConfig(update func(config string) (string, error)) error {
+	configPath, err := sshConfigPath()
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	updated, err := update(string(content))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configPath, []byte(updated), 0600)
+}
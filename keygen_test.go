@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGenerateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "id_ed25519_db1")
+	if err := generateKey(path, "s3cret", "me@laptop"); err != nil {
+		t.Fatalf("generateKey failed: %v", err)
+	}
+	private, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ssh.ParsePrivateKey(private); err == nil {
+		t.Error("expected the private key to need its passphrase")
+	}
+	signer, err := ssh.ParsePrivateKeyWithPassphrase(private, []byte("s3cret"))
+	if err != nil {
+		t.Fatalf("could not decrypt the key: %v", err)
+	}
+	public, _ := os.ReadFile(path + ".pub")
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey(public)
+	if err != nil || comment != "me@laptop" || ssh.FingerprintSHA256(pub) != ssh.FingerprintSHA256(signer.PublicKey()) {
+		t.Errorf("public key does not match: %q (%v)", public, err)
+	}
+
+	if err := generateKey(path, "", "me@laptop"); err == nil {
+		t.Error("expected an existing key to be left alone")
+	}
+}
+
+func TestSetIdentityFile(t *testing.T) {
+	config := strings.Join([]string{
+		"Host web1",
+		"\tHostname 10.0.0.1",
+		"",
+		"Host db1 db",
+		"  Hostname 10.0.0.5",
+		"  IdentityFile ~/.ssh/old",
+		"Host other",
+		"  IdentityFile ~/.ssh/other",
+	}, "\n")
+
+	got, err := setIdentityFile(config, "db1", "~/.ssh/new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "  Hostname 10.0.0.5\n  IdentityFile ~/.ssh/new\n  IdentityFile ~/.ssh/old\nHost other\n  IdentityFile ~/.ssh/other") {
+		t.Errorf("expected the new IdentityFile before the existing one:\n%s", got)
+	}
+
+	got, err = setIdentityFile(config, "web1", "~/.ssh/new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "Host web1\n\tIdentityFile ~/.ssh/new\n\tHostname 10.0.0.1\n") {
+		t.Errorf("expected an IdentityFile line to be added:\n%s", got)
+	}
+
+	if _, err := setIdentityFile(config, "missing", "~/.ssh/new"); err == nil {
+		t.Error("expected an unknown host to fail")
+	}
+}
+
+func TestKeygenConfirmPassphrase(t *testing.T) {
+	m := &model{keygenInput: textinput.New(), keygenStep: keygenPassphrase, keygenPath: filepath.Join(t.TempDir(), "id")}
+	m.keygenInput.SetValue("secret")
+	m.keygenNext()
+	m.keygenInput.SetValue("typo")
+	m.keygenNext()
+	if m.keygenStep != keygenPassphrase || m.errMsg == "" {
+		t.Errorf("expected a mismatch to ask for the passphrase again, got step %d", m.keygenStep)
+	}
+	if _, err := os.Stat(m.keygenPath); err == nil {
+		t.Error("no key should be created when the passphrases differ")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshKeyInfo describes a key pair in ~/.ssh for the key manager screen
+type sshKeyInfo struct {
+	path        string // private key; the public half is path + ".pub"
+	keyType     string
+	bits        int
+	fingerprint string
+	comment     string
+	hosts       []string // Host patterns whose IdentityFile is this key
+	err         error
+}
+
+// identityReferences maps each IdentityFile in an SSH config, expanded, to the
+// Host patterns (first pattern of each block) that use it
+func identityReferences(config string) map[string][]string {
+	refs := map[string][]string{}
+	for _, block := range getAllHostBlocks(strings.Split(config, "\n")) {
+		for _, line := range block.lines[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || !strings.EqualFold(fields[0], "identityfile") {
+				continue
+			}
+			path := expandHome(strings.Trim(strings.Join(fields[1:], " "), `"`))
+			if !contains(refs[path], block.hostName) {
+				refs[path] = append(refs[path], block.hostName)
+			}
+		}
+	}
+	return refs
+}
+
+// inspectKey reads a public key and the hosts that reference its private key
+func inspectKey(pubPath string, refs map[string][]string) sshKeyInfo {
+	info := sshKeyInfo{path: strings.TrimSuffix(pubPath, ".pub")}
+	info.hosts = refs[info.path]
+	content, err := os.ReadFile(pubPath)
+	if err != nil {
+		info.err = err
+		return info
+	}
+	key, comment, _, _, err := ssh.ParseAuthorizedKey(content)
+	if err != nil {
+		info.err = err
+		return info
+	}
+	info.keyType, info.bits = keyTypeAndBits(key)
+	info.fingerprint = ssh.FingerprintSHA256(key)
+	info.comment = comment
+	return info
+}
+
+// sshKeys lists the key pairs in ~/.ssh with the hosts in the SSH config using them
+func sshKeys() ([]sshKeyInfo, error) {
+	path, err := sshConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	refs := identityReferences(string(content))
+	var keys []sshKeyInfo
+	for _, pub := range publicKeys() {
+		keys = append(keys, inspectKey(pub, refs))
+	}
+	return keys, nil
+}
+
+// keysView renders the key manager table
+func keysView(keys []sshKeyInfo) string {
+	home, _ := os.UserHomeDir()
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-30s %-15s %-50s %-20s %s\n", "KEY", "TYPE", "FINGERPRINT", "COMMENT", "USED BY")
+	for _, k := range keys {
+		path := k.path
+		if rest, ok := strings.CutPrefix(path, home); ok && home != "" {
+			path = "~" + rest
+		}
+		if k.err != nil {
+			fmt.Fprintf(&b, "%-30s unreadable: %v\n", path, k.err)
+			continue
+		}
+		usedBy := strings.Join(k.hosts, ", ")
+		if usedBy == "" {
+			usedBy = "-"
+		}
+		fmt.Fprintf(&b, "%-30s %-15s %-50s %-20s %s\n", path, fmt.Sprintf("%s %d", k.keyType, k.bits), k.fingerprint, k.comment, usedBy)
+	}
+	if len(keys) == 0 {
+		b.WriteString("No keys found in ~/.ssh.\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIdentityReferences(t *testing.T) {
+	config := strings.Join([]string{
+		"Host web1 web",
+		"    IdentityFile /keys/deploy",
+		"Host db1",
+		"    IdentityFile /keys/deploy",
+		"    IdentityFile \"/keys/db key\"",
+		"Host *",
+		"    IdentityFile /keys/default",
+	}, "\n")
+	refs := identityReferences(config)
+	if got := strings.Join(refs["/keys/deploy"], ","); got != "web1,db1" {
+		t.Errorf("unexpected hosts for deploy: %s", got)
+	}
+	if got := strings.Join(refs["/keys/db key"], ","); got != "db1" {
+		t.Errorf("unexpected hosts for quoted path: %s", got)
+	}
+	if got := strings.Join(refs["/keys/default"], ","); got != "*" {
+		t.Errorf("unexpected hosts for default: %s", got)
+	}
+}
+
+func TestInspectKey(t *testing.T) {
+	dir := t.TempDir()
+	pub := filepath.Join(dir, "id_ed25519.pub")
+	if err := os.WriteFile(pub, []byte(testHostKey+" me@laptop\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := inspectKey(pub, map[string][]string{filepath.Join(dir, "id_ed25519"): {"web1"}})
+	if info.err != nil {
+		t.Fatalf("inspectKey failed: %v", info.err)
+	}
+	if info.keyType != "ED25519" || info.bits != 256 || info.comment != "me@laptop" ||
+		info.fingerprint != "SHA256:6GSAx+kl7hYI6/KUQg2+lID5zz6TI3I+c+M5T4XQNew" || len(info.hosts) != 1 {
+		t.Errorf("unexpected key info %+v", info)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// dnsTimeout bounds the lookup of each known_hosts name during cleanup
+const dnsTimeout = 3 * time.Second
+
+// staleKnownHost is a known_hosts entry offered for removal
+type staleKnownHost struct {
+	file     string
+	line     int // 1-based
+	patterns []string
+	keyType  string
+	reason   string
+}
+
+// staleKnownHostsMsg delivers the result of scanning known_hosts for stale entries
+type staleKnownHostsMsg struct {
+	entries []staleKnownHost
+	err     error
+}
+
+// findStaleKnownHosts scans the UserKnownHostsFile files of the hosts in the background
+func findStaleKnownHosts(hosts []hostItem) tea.Cmd {
+	return func() tea.Msg {
+		var names, files []string
+		seen := map[string]bool{}
+		for _, h := range hosts {
+			n, f := knownHostsNames(h.host, "", seen)
+			names = append(names, n...)
+			for _, file := range f {
+				if !slices.Contains(files, file) {
+					files = append(files, file)
+				}
+			}
+		}
+		if len(files) == 0 {
+			files = []string{expandHome("~/.ssh/known_hosts")}
+		}
+		var entries []staleKnownHost
+		var readErr error
+		read := 0
+		for _, path := range files {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					readErr = err
+				}
+				continue
+			}
+			read++
+			entries = append(entries, staleEntries(path, string(content), names, resolves)...)
+		}
+		if read == 0 && readErr != nil {
+			return staleKnownHostsMsg{err: readErr}
+		}
+		return staleKnownHostsMsg{entries: entries}
+	}
+}
+
+// knownHostsNames returns the names OpenSSH files the keys of host (on port,
+// when not "") and of the bastions it jumps through under, and the
+// UserKnownHostsFile files it keeps them in, as ssh -G resolves them. seen
+// holds the hosts looked up already.
+func knownHostsNames(host, port string, seen map[string]bool) (names, files []string) {
+	if seen[host+":"+port] {
+		return nil, nil
+	}
+	seen[host+":"+port] = true
+	args := []string{"-G"}
+	if port != "" {
+		args = append(args, "-p", port)
+	}
+	out, err := exec.Command("ssh", append(args, host)...).Output()
+	if err != nil {
+		return []string{host}, nil
+	}
+	target := parseKnownHostsOptions(host, parseSSHOptions(string(out)))
+	names, files = target.names, target.files
+	for _, jump := range target.jumps {
+		n, f := knownHostsNames(jump.host, jump.port, seen)
+		names, files = append(names, n...), append(files, f...)
+	}
+	return names, files
+}
+
+// knownHostsTarget is what the options of a host tell about its known_hosts entries
+type knownHostsTarget struct {
+	names []string
+	files []string
+	jumps []jumpHop
+}
+
+// jumpHop is a bastion of a ProxyJump list
+type jumpHop struct {
+	host string
+	port string // "" for the bastion's own port
+}
+
+// parseKnownHostsOptions reads the options of ssh -G for host. A HostKeyAlias
+// replaces the host name and port in known_hosts.
+func parseKnownHostsOptions(host string, options map[string][]string) knownHostsTarget {
+	target := knownHostsTarget{names: []string{host}}
+	port := "22"
+	if p := options["port"]; len(p) > 0 {
+		port = p[0]
+	}
+	if alias := options["hostkeyalias"]; len(alias) > 0 && alias[0] != "none" {
+		target.names = append(target.names, alias...)
+	} else {
+		for _, name := range options["hostname"] {
+			if port != "22" {
+				name = "[" + name + "]:" + port
+			}
+			target.names = append(target.names, name)
+		}
+	}
+	for _, value := range options["userknownhostsfile"] {
+		for _, f := range strings.Fields(value) {
+			target.files = append(target.files, expandHome(f))
+		}
+	}
+	for _, value := range options["proxyjump"] {
+		if value == "none" {
+			continue
+		}
+		for _, hop := range strings.Split(value, ",") {
+			hop = strings.TrimPrefix(hop, "ssh://")
+			if i := strings.LastIndex(hop, "@"); i >= 0 {
+				hop = hop[i+1:]
+			}
+			h := jumpHop{host: hop}
+			if name, p, err := net.SplitHostPort(hop); err == nil {
+				h = jumpHop{host: name, port: p}
+			}
+			target.jumps = append(target.jumps, h)
+		}
+	}
+	return target
+}
+
+// staleEntries returns the entries of a known_hosts file that match none of the
+// given names, or whose plain host name does not resolve. Marker lines
+// (@cert-authority, @revoked) and wildcard patterns are never offered.
+func staleEntries(path, content string, names []string, resolve func(string) bool) []staleKnownHost {
+	var candidates []staleKnownHost
+	for i, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "@") || strings.ContainsAny(fields[0], "*?!") {
+			continue
+		}
+		candidates = append(candidates, staleKnownHost{file: path, line: i + 1, patterns: strings.Split(fields[0], ","), keyType: fields[1]})
+	}
+
+	// Lookups run in parallel, as each may take up to dnsTimeout
+	var wg sync.WaitGroup
+	for i := range candidates {
+		e := &candidates[i]
+		if !matchesAny(e.patterns, names) {
+			e.reason = "not in ~/.ssh/config"
+			continue
+		}
+		name := e.patterns[0]
+		if strings.HasPrefix(name, "[") {
+			name = strings.TrimPrefix(name[:strings.LastIndex(name, "]")], "[")
+		}
+		if strings.HasPrefix(name, "|") || net.ParseIP(name) != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !resolve(name) {
+				e.reason = "does not resolve"
+			}
+		}()
+	}
+	wg.Wait()
+
+	var entries []staleKnownHost
+	for _, e := range candidates {
+		if e.reason != "" {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// matchesAny reports whether any known_hosts pattern matches any of the names
+func matchesAny(patterns, names []string) bool {
+	for _, p := range patterns {
+		for _, name := range names {
+			if knownHostsPatternMatches(p, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// knownHostsPatternMatches compares a known_hosts host pattern, plain or hashed
+// (|1|salt|hash), with a name
+func knownHostsPatternMatches(pattern, name string) bool {
+	if !strings.HasPrefix(pattern, "|1|") {
+		return pattern == name
+	}
+	parts := strings.Split(pattern, "|")
+	if len(parts) != 4 {
+		return false
+	}
+	salt, err1 := base64.StdEncoding.DecodeString(parts[2])
+	hash, err2 := base64.StdEncoding.DecodeString(parts[3])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(name))
+	return hmac.Equal(mac.Sum(nil), hash)
+}
+
+// resolves reports whether a host name resolves in DNS
+func resolves(name string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+	defer cancel()
+	_, err := net.DefaultResolver.LookupHost(ctx, name)
+	return err == nil
+}
+
+// removeKnownHostsLines deletes the given 1-based lines from a known_hosts file,
+// keeping the previous version as <file>.old like ssh-keygen -R does
+func removeKnownHostsLines(path string, lines map[int]bool) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".old", content, 0600); err != nil {
+		return fmt.Errorf("could not back up %s: %w", path, err)
+	}
+	var kept []string
+	for i, line := range strings.Split(string(content), "\n") {
+		if !lines[i+1] {
+			kept = append(kept, line)
+		}
+	}
+	return os.WriteFile(path, []byte(strings.Join(kept, "\n")), 0600)
+}
+
+// describe renders an entry as one line of the cleanup screen
+func (e staleKnownHost) describe() string {
+	name := strings.Join(e.patterns, ",")
+	if strings.HasPrefix(name, "|1|") {
+		name = "(hashed)"
+	}
+	return fmt.Sprintf("%-18s %-40s %-20s %s", fmt.Sprintf("%s:%d", filepath.Base(e.file), e.line), name, e.keyType, e.reason)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStaleEntries(t *testing.T) {
+	// Hashed entry for "db1.example.com", generated with ssh-keygen -H
+	hashed := "|1|2YE6i0PzGMLlvkBeZG7zKyYWiC4=|0Eumf547xTmVrh5TRIM7ZvV9nc0="
+	content := strings.Join([]string{
+		"# comment",
+		"web1.example.com,10.0.0.1 ssh-ed25519 AAAA1",
+		"[gone.example.com]:2222 ssh-ed25519 AAAA2",
+		hashed + " ssh-ed25519 AAAA3",
+		"|1|c2FsdA==|aGFzaA== ssh-rsa AAAA4",
+		"old.example.com ssh-ed25519 AAAA5",
+		"@cert-authority *.example.com ssh-ed25519 AAAA6",
+		"",
+	}, "\n")
+	names := []string{"web1", "web1.example.com", "db1", "db1.example.com", "old", "old.example.com"}
+	resolve := func(name string) bool { return name != "old.example.com" }
+
+	entries := staleEntries("known_hosts", content, names, resolve)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 stale entries, got %+v", entries)
+	}
+	want := []struct {
+		line   int
+		reason string
+	}{{3, "not in ~/.ssh/config"}, {5, "not in ~/.ssh/config"}, {6, "does not resolve"}}
+	for i, w := range want {
+		if entries[i].line != w.line || entries[i].reason != w.reason {
+			t.Errorf("entry %d: expected line %d (%s), got %+v", i, w.line, w.reason, entries[i])
+		}
+	}
+}
+
+func TestRemoveKnownHostsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, []byte("a key1\nb key2\nc key3\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeKnownHostsLines(path, map[int]bool{1: true, 3: true}); err != nil {
+		t.Fatalf("removeKnownHostsLines failed: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "b key2\n" {
+		t.Errorf("unexpected known_hosts %q", content)
+	}
+	if backup, _ := os.ReadFile(path + ".old"); string(backup) != "a key1\nb key2\nc key3\n" {
+		t.Errorf("unexpected backup %q", backup)
+	}
+}
+
+func TestParseKnownHostsOptions(t *testing.T) {
+	target := parseKnownHostsOptions("web1", map[string][]string{
+		"hostname":           {"10.0.0.1"},
+		"port":               {"2222"},
+		"userknownhostsfile": {"/home/me/.ssh/known_hosts /home/me/.ssh/known_hosts2"},
+		"proxyjump":          {"admin@bastion,ssh://jump2:2200"},
+	})
+	if want := []string{"web1", "[10.0.0.1]:2222"}; !slices.Equal(target.names, want) {
+		t.Errorf("names = %q, want %q", target.names, want)
+	}
+	if want := []string{"/home/me/.ssh/known_hosts", "/home/me/.ssh/known_hosts2"}; !slices.Equal(target.files, want) {
+		t.Errorf("files = %q, want %q", target.files, want)
+	}
+	if want := []jumpHop{{host: "bastion"}, {host: "jump2", port: "2200"}}; !slices.Equal(target.jumps, want) {
+		t.Errorf("jumps = %+v, want %+v", target.jumps, want)
+	}
+
+	target = parseKnownHostsOptions("db1", map[string][]string{"hostname": {"10.0.0.5"}, "port": {"2222"}, "hostkeyalias": {"db-primary"}})
+	if want := []string{"db1", "db-primary"}; !slices.Equal(target.names, want) {
+		t.Errorf("names = %q, want the alias in place of the host name", target.names)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// latencyTimeout bounds each connection the latency sort opens
+const latencyTimeout = 2 * time.Second
+
+// latencyMsg reports how long it took to reach the ssh port of each host.
+// Hosts that could not be reached are left out.
+type latencyMsg struct {
+	latencies map[string]time.Duration
+}
+
+// measureLatency times opening a TCP connection to the address ssh connects to
+// for host. Hosts behind a ProxyJump are measured to their first jump host.
+func measureLatency(host string) (time.Duration, error) {
+	t, err := resolveSSHTarget(host)
+	if err != nil {
+		return 0, err
+	}
+	if t.proxyJump != "" {
+		first, _, _ := strings.Cut(t.proxyJump, ",")
+		// ssh -G takes jump hosts given as [user@]host[:port] in URI form
+		if t, err = resolveSSHTarget("ssh://" + first); err != nil {
+			return 0, err
+		}
+	}
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(t.hostname, t.port), latencyTimeout)
+	if err != nil {
+		return 0, err
+	}
+	conn.Close()
+	return time.Since(start), nil
+}
+
+// probeLatencies measures the latency of hosts in the background, on workers
+// hosts at once
+func probeLatencies(hosts []string, workers int) tea.Cmd {
+	return func() tea.Msg {
+		var mu sync.Mutex
+		latencies := map[string]time.Duration{}
+		runParallel(hosts, workers, func(host string) {
+			if d, err := measureLatency(host); err == nil {
+				mu.Lock()
+				latencies[host] = d
+				mu.Unlock()
+			}
+		})
+		return latencyMsg{latencies: latencies}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// loadProbeCommand prints the uptime line followed by the CPU count (Linux, then BSD/macOS)
+const loadProbeCommand = "uptime; nproc 2>/dev/null || sysctl -n hw.ncpu"
+
+var errNoCandidates = errors.New("no reachable hosts in group")
+
+// parseLoad extracts the 1-minute load average per CPU from loadProbeCommand output
+func parseLoad(out string) (float64, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	info, err := parseUptime(lines[0])
+	if err != nil {
+		return 0, err
+	}
+	load := info.load[0]
+
+	cpus := 1
+	if len(lines) > 1 {
+		if n, err := strconv.Atoi(strings.TrimSpace(lines[len(lines)-1])); err == nil && n > 0 {
+			cpus = n
+		}
+	}
+	return load / float64(cpus), nil
+}
+
+// probeLoads measures the per-CPU load of each host in parallel. Unreachable hosts are left out.
+func probeLoads(hosts []string) map[string]float64 {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	loads := map[string]float64{}
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			out, err := runRemote(host, loadProbeCommand)
+			if err != nil {
+				return
+			}
+			if load, err := parseLoad(out); err == nil {
+				mu.Lock()
+				loads[host] = load
+				mu.Unlock()
+			}
+		}(h)
+	}
+	wg.Wait()
+	return loads
+}
+
+// leastLoaded picks the host with the lowest load, keeping the group order on ties
+func leastLoaded(hosts []string, loads map[string]float64) (string, error) {
+	best := ""
+	for _, h := range hosts {
+		load, ok := loads[h]
+		if !ok {
+			continue
+		}
+		if best == "" || load < loads[best] {
+			best = h
+		}
+	}
+	if best == "" {
+		return "", errNoCandidates
+	}
+	return best, nil
+}
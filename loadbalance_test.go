@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestParseLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		out      string
+		expected float64
+	}{
+		{
+			name:     "linux",
+			out:      " 10:01:02 up 3 days,  2:03,  1 user,  load average: 4.00, 3.50, 3.00\n8\n",
+			expected: 0.5,
+		},
+		{
+			name:     "macos",
+			out:      "10:01  up 3 days, 2:03, 2 users, load averages: 2.00 1.50 1.00\n4\n",
+			expected: 0.5,
+		},
+		{
+			name:     "unknown cpu count",
+			out:      "10:01  up 3 days, load average: 1.25, 1.00, 0.75\n",
+			expected: 1.25,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			load, err := parseLoad(tt.out)
+			if err != nil {
+				t.Fatalf("parseLoad failed: %v", err)
+			}
+			if load != tt.expected {
+				t.Errorf("expected load %v, got %v", tt.expected, load)
+			}
+		})
+	}
+}
+
+func TestLeastLoaded(t *testing.T) {
+	hosts := []string{"build1", "build2", "build3"}
+
+	best, err := leastLoaded(hosts, map[string]float64{"build1": 0.8, "build2": 0.2, "build3": 0.2})
+	if err != nil {
+		t.Fatalf("leastLoaded failed: %v", err)
+	}
+	if best != "build2" {
+		t.Errorf("expected build2, got %s", best)
+	}
+
+	if _, err := leastLoaded(hosts, map[string]float64{}); err != errNoCandidates {
+		t.Errorf("expected errNoCandidates, got %v", err)
+	}
+}
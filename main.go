@@ -2,21 +2,29 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/crypto/ssh"
 )
 
 var docStyle = lipgloss.NewStyle().Margin(1, 2)
@@ -36,47 +44,296 @@ const (
 	listScreen = iota
 	passwordScreen
 	spinnerScreen
+	unlockScreen
+	challengeScreen
+	graphScreen
+	hostKeyScreen
+	conflictScreen
+	cleanupScreen
+	diffScreen
+	pubKeyScreen
+	keygenScreen
+	qrScreen
+	keysScreen
+	importScreen
+	agentScreen
+	pivotScreen
+	connectionsScreen
+	overrideScreen
+	jumpScreen
+	browserScreen
+	transferScreen
+	forwardsScreen
+	tunnelsScreen
+	snippetsScreen
+	snippetOutputScreen
+	debugLogScreen
+	broadcastInputScreen
+	broadcastScreen
+	tagsScreen
+	deployKeyScreen
+	outputDiffScreen
 )
 
 type hostItem struct {
-	host string
-	desc string // user@ip, ip, or empty
+	host     string
+	desc     string      // user@ip, ip, or empty
+	metrics  *gpuMetrics // GPU/temperature probe results, nil when not probed
+	marked   bool        // selected for bulk operations
+	chips    string      // the host's tags rendered by tagChips
+	favorite bool        // starred, listed first
+	source   string      // the Include file declaring the host, empty for the config itself
 }
 
-func (i hostItem) Title() string       { return i.host }
-func (i hostItem) Description() string { return i.desc }
+func (i hostItem) Title() string {
+	title := i.host
+	if i.favorite {
+		title = "★ " + title
+	}
+	if i.marked {
+		title = "✓ " + title
+	}
+	return title
+}
+func (i hostItem) Description() string {
+	desc := i.desc
+	if i.chips != "" {
+		desc += "  " + i.chips
+	}
+	if i.metrics == nil {
+		return strings.TrimSpace(desc)
+	}
+	return strings.TrimSpace(desc + "  " + i.metrics.String())
+}
 func (i hostItem) FilterValue() string { return i.host }
 
 type loginResultMsg struct {
 	success bool
 	err     error
+	// restricted is set when authentication worked but the server refused the test
+	// command (forced command or no shell); detail holds the server's last line of output
+	restricted bool
+	detail     string
+	failure    int // cause of a failed login, see classifyLoginFailure
+	// conflict is set when ssh refused a changed host key, possibly of a jump host
+	conflict *hostKeyConflict
+	// debugLog is the ssh -vvv output of the test when debugLogin is on
+	debugLog string
 }
 
 // ListKeyMap defines the key bindings for the main list screen
 type ListKeyMap struct {
-	Enter  key.Binding
-	Delete key.Binding
+	Enter       key.Binding
+	Delete      key.Binding
+	LeastLoaded key.Binding
+	Graph       key.Binding
+	Pin         key.Binding
+	Cleanup     key.Binding
+	Diff        key.Binding
+	CopyKey     key.Binding
+	NewKey      key.Binding
+	QR          key.Binding
+	Keys        key.Binding
+	Import      key.Binding
+	Agent       key.Binding
+	Pivot       key.Binding
+	Connections key.Binding
+	User        key.Binding
+	Port        key.Binding
+	Jump        key.Binding
+	SFTP        key.Binding
+	Files       key.Binding
+	Transfer    key.Binding
+	Forwards    key.Binding
+	Socks       key.Binding
+	Tunnels     key.Binding
+	Snippets    key.Binding
+	DebugLog    key.Binding
+	TmuxWindow  key.Binding
+	TmuxSplit   key.Binding
+	Mark        key.Binding
+	Visual      key.Binding
+	Broadcast   key.Binding
+	LastRun     key.Binding
+	Cluster     key.Binding
+	Tags        key.Binding
+	GroupLayout key.Binding
+	RegexFilter key.Binding
+	TagFilter   key.Binding
+	GroupView   key.Binding
+	FoldAll     key.Binding
+	Favorite    key.Binding
+	Favorites   key.Binding
+	Sort        key.Binding
 }
 
 func (k ListKeyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Enter, k.Delete}
+	return []key.Binding{k.Enter, k.Delete, k.LeastLoaded, k.Graph, k.Pin, k.Cleanup, k.Diff, k.CopyKey, k.NewKey, k.QR, k.Keys, k.Import, k.Agent, k.Pivot, k.Connections, k.User, k.Port, k.Jump, k.SFTP, k.Files, k.Transfer, k.Forwards, k.Socks, k.Tunnels, k.Snippets, k.DebugLog, k.TmuxWindow, k.TmuxSplit, k.Mark, k.Visual, k.Broadcast, k.LastRun, k.Cluster, k.Tags, k.GroupLayout, k.RegexFilter, k.TagFilter, k.GroupView, k.FoldAll, k.Favorite, k.Favorites, k.Sort}
 }
 
 func (k ListKeyMap) FullHelp() [][]key.Binding {
-	return [][]key.Binding{{k.Enter, k.Delete}}
+	return [][]key.Binding{{k.Enter, k.Delete, k.LeastLoaded, k.Graph, k.Pin, k.Cleanup, k.Diff, k.CopyKey, k.NewKey, k.QR, k.Keys, k.Import, k.Agent, k.Pivot, k.Connections, k.User, k.Port, k.Jump, k.SFTP, k.Files, k.Transfer, k.Forwards, k.Socks, k.Tunnels, k.Snippets, k.DebugLog, k.TmuxWindow, k.TmuxSplit, k.Mark, k.Visual, k.Broadcast, k.LastRun, k.Cluster, k.Tags, k.GroupLayout, k.RegexFilter, k.TagFilter, k.GroupView, k.FoldAll, k.Favorite, k.Favorites, k.Sort}}
+}
+
+// CleanupKeyMap defines the key bindings for the known_hosts cleanup screen
+type CleanupKeyMap struct {
+	Toggle key.Binding
+	All    key.Binding
+	Remove key.Binding
+	Esc    key.Binding
+}
+
+func (k CleanupKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Toggle, k.All, k.Remove, k.Esc}
+}
+
+func (k CleanupKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Toggle, k.All, k.Remove, k.Esc}}
+}
+
+// ImportKeyMap defines the key bindings for the config import screen
+type ImportKeyMap struct {
+	Toggle  key.Binding
+	Resolve key.Binding
+	Import  key.Binding
+	Esc     key.Binding
+}
+
+func (k ImportKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Toggle, k.Resolve, k.Import, k.Esc}
+}
+
+func (k ImportKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Toggle, k.Resolve, k.Import, k.Esc}}
+}
+
+// PivotKeyMap defines the key bindings for the environment pivot screen
+type PivotKeyMap struct {
+	Move    key.Binding
+	Connect key.Binding
+	Esc     key.Binding
+}
+
+func (k PivotKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Move, k.Connect, k.Esc}
+}
+
+func (k PivotKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Move, k.Connect, k.Esc}}
+}
+
+// ConnectionsKeyMap defines the key bindings for the open connections screen
+type ConnectionsKeyMap struct {
+	Close key.Binding
+	Esc   key.Binding
+}
+
+func (k ConnectionsKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Close, k.Esc}
+}
+
+func (k ConnectionsKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Close, k.Esc}}
+}
+
+// JumpKeyMap defines the key bindings for the jump host picker
+type JumpKeyMap struct {
+	Connect key.Binding
+	Persist key.Binding
+	Esc     key.Binding
+}
+
+func (k JumpKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Connect, k.Persist, k.Esc}
+}
+
+func (k JumpKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Connect, k.Persist, k.Esc}}
+}
+
+// ForwardsKeyMap defines the key bindings for the port forwarding screen
+type ForwardsKeyMap struct {
+	Toggle key.Binding
+	Add    key.Binding
+	Save   key.Binding
+	Start  key.Binding
+	Remove key.Binding
+	Esc    key.Binding
+}
+
+func (k ForwardsKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Toggle, k.Add, k.Save, k.Start, k.Remove, k.Esc}
+}
+
+func (k ForwardsKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Toggle, k.Add, k.Save, k.Start, k.Remove, k.Esc}}
+}
+
+// SnippetsKeyMap defines the key bindings for the snippets screen
+type SnippetsKeyMap struct {
+	Run    key.Binding
+	Reload key.Binding
+	Esc    key.Binding
+}
+
+func (k SnippetsKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Run, k.Reload, k.Esc}
+}
+
+func (k SnippetsKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Run, k.Reload, k.Esc}}
+}
+
+// TunnelsKeyMap defines the key bindings for the tunnels dashboard
+type TunnelsKeyMap struct {
+	Start   key.Binding
+	Stop    key.Binding
+	Restart key.Binding
+	Esc     key.Binding
+}
+
+func (k TunnelsKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Start, k.Stop, k.Restart, k.Esc}
+}
+
+func (k TunnelsKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Start, k.Stop, k.Restart, k.Esc}}
+}
+
+// BroadcastKeyMap defines the key bindings for the output viewer of a broadcast
+type BroadcastKeyMap struct {
+	Tabs key.Binding
+	Diff key.Binding
+	Esc  key.Binding
+}
+
+func (k BroadcastKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Tabs, k.Diff, k.Esc}
+}
+
+func (k BroadcastKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Tabs, k.Diff, k.Esc}}
 }
 
 // PasswordKeyMap defines the key bindings for the password screen
 type PasswordKeyMap struct {
-	Esc key.Binding
+	Esc             key.Binding
+	Remember        key.Binding
+	AddToAgent      key.Binding
+	Reveal          key.Binding
+	AgentForwarding key.Binding
+	X11             key.Binding
+	Compression     key.Binding
+	Verbose         key.Binding
+	DebugLog        key.Binding
 }
 
 func (k PasswordKeyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Esc}
+	return []key.Binding{k.Esc, k.Reveal, k.Remember, k.AddToAgent, k.AgentForwarding, k.X11, k.Compression, k.Verbose, k.DebugLog}
 }
 
 func (k PasswordKeyMap) FullHelp() [][]key.Binding {
-	return [][]key.Binding{{k.Esc}}
+	return [][]key.Binding{{k.Esc, k.Reveal, k.Remember, k.AddToAgent}, {k.AgentForwarding, k.X11, k.Compression, k.Verbose, k.DebugLog}}
 }
 
 type model struct {
@@ -84,16 +341,192 @@ type model struct {
 	selectedHost string
 	selectedDesc string
 	screen       int
-	password     string
+	password     []byte // secret of the current login, wiped once it is no longer needed
 	pwInput      textinput.Model
 	errMsg       string
 	spinner      spinner.Model
 	loggingIn    bool
-	shouldSSH    bool // NEW: set to true after successful login
+	loginStarted time.Time // shown as elapsed time on the spinner screen
+	loginAttempt int       // attempt of the current login test, counting automatic retries
+	retryPending bool      // a retry of the login test is waiting for its backoff
+	loginCtx     context.Context
+	cancelLogin  context.CancelFunc // aborts the commands of the current login
+	shouldSSH    bool               // NEW: set to true after successful login
 	help         help.Model
 	listKeys     ListKeyMap
 	keys         PasswordKeyMap
 	infoBox      string // Info box content for hovered host
+	config       appConfig
+	vault        *vault // unlocked vault, nil until the master password is entered
+	unlockInput  textinput.Model
+	metadata     hostMetadata
+
+	biometricPending bool // waiting for a Touch ID check to unlock the vault
+	spinnerText      string
+	statusMsg        string // feedback shown below the host list
+	remember         bool   // store the password in the secret backend after a successful login
+	rememberErr      error
+	startCmd         tea.Cmd           // run on startup, e.g. when a host was picked on the command line
+	sessionPasswords map[string][]byte // verified passwords cached until the session starts
+	timezones        map[string]string // probed time zones, cached in the state file
+	tzProbed         map[string]bool
+
+	// Keyboard-interactive login with the built-in SSH client
+	nativeEvents     chan tea.Msg
+	nativeClient     *ssh.Client // authenticated connection to reuse for the session
+	challenge        *challengeMsg
+	challengeAnswers []string
+	challengeInput   textinput.Model
+
+	keyFile     string // passphrase-protected key to unlock, in which case password holds its passphrase
+	securityKey string // FIDO2 identity the host authenticates with; no password is used
+	direct      bool   // connect without testing the login, leaving authentication to ssh
+
+	extraSSHArgs  []string       // given after -- on the command line, added to the session's ssh command
+	connectOpts   connectOptions // flags toggled on the password screen for this connection
+	loginUser     string         // user chosen with U for this connection, overriding the SSH config
+	loginPort     int            // port chosen with O for this connection, overriding the SSH config
+	overrideInput textinput.Model
+	overridePort  bool // overrideScreen asks for the port rather than the user
+
+	jumpHost       string // bastion chosen with J for this connection, passed as -J
+	saveJump       bool   // save jumpHost as ProxyJump once the login through it works
+	jumpChecking   string // bastion being tested on jumpScreen
+	jumpErr        error
+	jumpHosts      []string // bastion candidates shown on jumpScreen
+	jumpCursor     int
+	jumpConfigured string // ProxyJump of the selected host in ~/.ssh/config
+	sftp           bool   // open sftp (s) rather than a shell once logged in
+	browse         bool   // open the file browser (F) rather than a shell once logged in
+	browser        *fileBrowser
+
+	transfer         bool         // run the copy set up on transferScreen (T) once logged in
+	transferSpec     transferSpec // direction, tool and paths of the copy
+	transferInputs   [2]textinput.Model
+	transferField    int      // focused input: 0 local, 1 remote path
+	transferHosts    []string // marked hosts a file is copied to, rather than a copy with the selected host
+	transferRunning  bool
+	transferResult   string // outcome of the finished copy
+	transferEvents   chan tea.Msg
+	transferCancel   context.CancelFunc
+	transferProgress transferProgressMsg
+	transferStarted  time.Time
+
+	forwards      []forward // offered on forwardsScreen (W): the saved ones and those added
+	forwardOn     []bool    // forwards selected for the next tunnel
+	forwardInput  textinput.Model
+	forwardAdding bool
+	forwardCursor int       // over forwards, then tunnels
+	forwardStatus string    // outcome of the last action on forwardsScreen
+	tunnels       []tunnel  // running tunnels of the selected host
+	openTunnel    []forward // forwards of the tunnel to start once logged in
+	socksStarting bool      // the tunnel being started is the SOCKS proxy of S
+	socksProxy    *tunnel   // running SOCKS proxy, shown under the host list
+
+	tunnelRows    []tunnelRow // tunnels of all hosts shown on tunnelsScreen (t)
+	tunnelCursor  int
+	tunnelStatus  string // outcome of the last action on tunnelsScreen
+	tunnelTick    int    // generation of the refresh timer of tunnelsScreen
+	fromDashboard bool   // the tunnel being started was asked for on tunnelsScreen
+
+	snippets       []snippet // commands of snippets.yaml, offered on snippetsScreen (!)
+	snippetCursor  int
+	snippetStatus  string
+	snippet        *snippet // snippet to run once logged in
+	snippetRunning bool
+	snippetCancel  func()
+	snippetTitle   string         // snippet, host and outcome shown above the output
+	snippetOutput  viewport.Model // pager of the last snippet's output
+
+	visualAnchor   int             // list index where visual mode started, -1 outside it
+	regexFilter    bool            // the list filter takes a regular expression, toggled with ctrl+r
+	tagFilter      []string        // only hosts with all of these tags are listed
+	fullList       []hostItem      // every host in config order, including those hidden by tagFilter or collapsed sections; nil until refreshList runs
+	grouped        bool            // the list is arranged in sections, toggled with z
+	collapsed      map[string]bool // folded sections of the grouped view
+	favoritesOnly  bool            // only starred hosts are listed, toggled with ctrl+f
+	sortMode       string          // order of the list, one of the sort constants
+	usage          hostUsage       // what history.jsonl tells about the hosts, and their latency, for sorting
+	latencyProbing bool            // the latency of the hosts is being measured for sortLatency
+	tagBar         bool            // the tag bar has the keys
+	tagBarCursor   int
+
+	broadcastInput   textinput.Model
+	broadcastCommand string          // command last broadcast to the marked hosts
+	broadcastTitle   string          // what the broadcast does, shown above its results
+	broadcastHosts   []broadcastHost // hosts of that broadcast with their results
+	broadcastRun     int             // generation of the broadcast, counted up per run
+	broadcastTab     int             // tab of the output viewer: 0 for the summary, then one per host
+	broadcastView    viewport.Model  // output of the host of the current tab
+	outputDiff       viewport.Model  // differences between the outputs of the broadcast, opened with D
+	broadcastRolling bool            // B runs on one host at a time, toggled with ctrl+r
+	broadcastSave    bool            // B saves each host's output to a file, toggled with ctrl+s
+	broadcastSavedTo string          // directory with the output files of the broadcast
+	rollingRunner    broadcastRunner // work of the rolling broadcast, nil when it runs on all hosts at once
+	rollingNext      int             // host of the rolling broadcast to run next
+	rollingWaiting   bool            // the rolling broadcast waits for confirmation to run on the next host
+
+	deployHosts   []string          // marked hosts the chosen public key is installed on
+	deployKey     string            // public key installed on them
+	deployKnown   map[string][]byte // saved passwords of the hosts
+	deployMissing []string          // hosts without a saved password, which get the one entered
+	deployInput   textinput.Model
+
+	tagInput textinput.Model
+	tagHosts []string // hosts whose tags are edited: the marked ones, or the selected one
+
+	debugLogin  bool           // run login tests with ssh -vvv, toggled with V or ctrl+d
+	debugView   viewport.Model // -vvv log of the last failed login test
+	debugReturn int            // screen to show once the log is closed
+
+	width, height int // size of the terminal
+
+	graphView string // rendered dependency trees of the selected host
+	diffHost  string // first host picked for a comparison
+	diffView  string // rendered differences between two hosts
+	qrView    string // connection QR code of the selected host
+	keysView  string // key manager table
+
+	importSource string        // host whose SSH config is being imported
+	importBlocks []remoteBlock // its Host blocks, compared with the local config
+	importCursor int
+
+	pivotRows []envRow // services found in more than one environment
+	pivotEnvs []string // environments, as columns of pivotRows
+	pivotRow  int
+	pivotCol  int
+
+	masters      []masterConnection // shared connections kept open for later sessions
+	masterCursor int
+
+	identities    map[string][]string // IdentityFiles per host, looked up on first hover
+	agentInput    textinput.Model
+	agentKeyFile  string // key waiting for its passphrase before it is added to the agent
+	addKeyToAgent bool   // add the key unlocked on the password screen to the agent after login
+	agentErr      error
+
+	pubKeys      []string // public keys offered for installing on a host, or on the marked hosts
+	pubKeyCursor int
+	copyKey      string // public key to install instead of logging in
+
+	keygenInput  textinput.Model
+	keygenStep   int    // keygenPath, keygenPassphrase, keygenConfirm or keygenDeploy
+	keygenPath   string // private key being created
+	keygenPhrase string // passphrase entered, to be confirmed
+
+	certs         map[string][]certInfo // certificates per host, looked up on first hover
+	confirmedHost string                // host whose expired-certificate warning was shown
+
+	hostKey         hostKeyMsg      // new or changed host key awaiting acceptance
+	conflict        hostKeyConflict // known_hosts entry ssh refused, offered for removal
+	hostKeyVerified map[string]bool
+	knownKeys       map[string][]ssh.PublicKey // known_hosts keys per host, looked up on first hover
+	changedKeys     []string                   // hosts whose known_hosts keys changed since the last run
+	cachedKeys      map[string][]string        // known_hosts fingerprints cached by the last run
+
+	staleHosts    []staleKnownHost // known_hosts entries offered for removal
+	staleSelected map[int]bool     // selected indexes into staleHosts
+	staleCursor   int
 }
 
 func initialModel(items []list.Item) *model {
@@ -105,6 +538,31 @@ func initialModel(items []list.Item) *model {
 	pw.EchoCharacter = '•'
 	pw.Focus()
 
+	unlock := textinput.New()
+	unlock.EchoMode = textinput.EchoPassword
+	unlock.EchoCharacter = '•'
+	unlock.Focus()
+
+	deployInput := textinput.New()
+	deployInput.EchoMode = textinput.EchoPassword
+	deployInput.EchoCharacter = '•'
+
+	agentInput := textinput.New()
+	agentInput.EchoMode = textinput.EchoPassword
+	agentInput.EchoCharacter = '•'
+	agentInput.Focus()
+
+	keygen := textinput.New()
+	keygen.EchoCharacter = '•'
+	keygen.Focus()
+
+	overrideInput := textinput.New()
+	overrideInput.Focus()
+
+	challenge := textinput.New()
+	challenge.EchoCharacter = '•'
+	challenge.Focus()
+
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
@@ -118,6 +576,182 @@ func initialModel(items []list.Item) *model {
 			key.WithKeys("delete", "x"),
 			key.WithHelp("x", "remove host"),
 		),
+		LeastLoaded: key.NewBinding(
+			key.WithKeys("L"),
+			key.WithHelp("L", "pick from group"),
+		),
+		Graph: key.NewBinding(
+			key.WithKeys("H"),
+			key.WithHelp("H", "dependencies"),
+		),
+		Pin: key.NewBinding(
+			key.WithKeys("P"),
+			key.WithHelp("P", "pin host key"),
+		),
+		Cleanup: key.NewBinding(
+			key.WithKeys("K"),
+			key.WithHelp("K", "clean known_hosts"),
+		),
+		Diff: key.NewBinding(
+			key.WithKeys("D"),
+			key.WithHelp("D", "compare two hosts"),
+		),
+		CopyKey: key.NewBinding(
+			key.WithKeys("C"),
+			key.WithHelp("C", "copy public key"),
+		),
+		NewKey: key.NewBinding(
+			key.WithKeys("N"),
+			key.WithHelp("N", "new key"),
+		),
+		QR: key.NewBinding(
+			key.WithKeys("Q"),
+			key.WithHelp("Q", "QR code"),
+		),
+		Keys: key.NewBinding(
+			key.WithKeys("I"),
+			key.WithHelp("I", "ssh keys"),
+		),
+		Import: key.NewBinding(
+			key.WithKeys("R"),
+			key.WithHelp("R", "import config from host"),
+		),
+		Agent: key.NewBinding(
+			key.WithKeys("A"),
+			key.WithHelp("A", "add key to agent"),
+		),
+		Pivot: key.NewBinding(
+			key.WithKeys("E"),
+			key.WithHelp("E", "across environments"),
+		),
+		Connections: key.NewBinding(
+			key.WithKeys("M"),
+			key.WithHelp("M", "open connections"),
+		),
+		User: key.NewBinding(
+			key.WithKeys("U"),
+			key.WithHelp("U", "connect as user"),
+		),
+		Port: key.NewBinding(
+			key.WithKeys("O"),
+			key.WithHelp("O", "connect to port"),
+		),
+		Jump: key.NewBinding(
+			key.WithKeys("J"),
+			key.WithHelp("J", "connect via bastion"),
+		),
+		SFTP: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "sftp"),
+		),
+		Files: key.NewBinding(
+			key.WithKeys("F"),
+			key.WithHelp("F", "browse files"),
+		),
+		Transfer: key.NewBinding(
+			key.WithKeys("T"),
+			key.WithHelp("T", "copy files"),
+		),
+		Forwards: key.NewBinding(
+			key.WithKeys("W"),
+			key.WithHelp("W", "port forwards"),
+		),
+		Socks: key.NewBinding(
+			key.WithKeys("S"),
+			key.WithHelp("S", "SOCKS proxy on/off"),
+		),
+		Tunnels: key.NewBinding(
+			key.WithKeys("t"),
+			key.WithHelp("t", "tunnels"),
+		),
+		Snippets: key.NewBinding(
+			key.WithKeys("!"),
+			key.WithHelp("!", "snippets"),
+		),
+		DebugLog: key.NewBinding(
+			key.WithKeys("V"),
+			key.WithHelp("V", "ssh -vvv log on/off"),
+		),
+		// Only offered inside tmux or a terminal whose tabs can be opened
+		TmuxWindow: key.NewBinding(
+			key.WithKeys("w"),
+			key.WithHelp("w", "tmux window"),
+			key.WithDisabled(),
+		),
+		TmuxSplit: key.NewBinding(
+			key.WithKeys("%"),
+			key.WithHelp("%", "tmux split"),
+			key.WithDisabled(),
+		),
+		Mark: key.NewBinding(
+			key.WithKeys(" "),
+			key.WithHelp("space", "mark"),
+		),
+		Visual: key.NewBinding(
+			key.WithKeys("v"),
+			key.WithHelp("v", "mark range"),
+		),
+		Broadcast: key.NewBinding(
+			key.WithKeys("B"),
+			key.WithHelp("B", "run on marked"),
+		),
+		LastRun: key.NewBinding(
+			key.WithKeys("X"),
+			key.WithHelp("X", "last run results"),
+		),
+		Cluster: key.NewBinding(
+			key.WithKeys("Y"),
+			key.WithHelp("Y", "sync panes"),
+			key.WithDisabled(),
+		),
+		Tags: key.NewBinding(
+			key.WithKeys("#"),
+			key.WithHelp("#", "edit tags"),
+		),
+		// Only offered inside tmux when config.json gives a group a key
+		GroupLayout: key.NewBinding(
+			key.WithKeys("0", "1", "2", "3", "4", "5", "6", "7", "8", "9"),
+			key.WithHelp("0-9", "open group"),
+			key.WithDisabled(),
+		),
+		RegexFilter: key.NewBinding(
+			key.WithKeys("ctrl+r"),
+			key.WithHelp("ctrl+r", "regex filter"),
+		),
+		TagFilter: key.NewBinding(
+			key.WithKeys("ctrl+t"),
+			key.WithHelp("ctrl+t", "filter by tags"),
+		),
+		GroupView: key.NewBinding(
+			key.WithKeys("z"),
+			key.WithHelp("z", "group view"),
+		),
+		// Only offered in the grouped view
+		FoldAll: key.NewBinding(
+			key.WithKeys("Z"),
+			key.WithHelp("Z", "fold all"),
+			key.WithDisabled(),
+		),
+		Favorite: key.NewBinding(
+			key.WithKeys("*"),
+			key.WithHelp("*", "star"),
+		),
+		Favorites: key.NewBinding(
+			key.WithKeys("ctrl+f"),
+			key.WithHelp("ctrl+f", "favorites only"),
+		),
+		Sort: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "sort"),
+		),
+	}
+	if insideTmux() {
+		listKeys.TmuxWindow.SetEnabled(true)
+		listKeys.TmuxSplit.SetEnabled(true)
+		listKeys.Cluster.SetEnabled(true)
+	} else if terminal := detectTerminal(os.Getenv); terminal != "" {
+		listKeys.TmuxWindow.SetHelp("w", terminal+" tab")
+		listKeys.TmuxWindow.SetEnabled(true)
 	}
 
 	keys := PasswordKeyMap{
@@ -125,41 +759,253 @@ func initialModel(items []list.Item) *model {
 			key.WithKeys("esc"),
 			key.WithHelp("esc", "go back"),
 		),
+		Remember: key.NewBinding(
+			key.WithKeys("ctrl+s"),
+			key.WithHelp("ctrl+s", "remember password"),
+		),
+		AddToAgent: key.NewBinding(
+			key.WithKeys("ctrl+a"),
+			key.WithHelp("ctrl+a", "add key to agent"),
+		),
+		Reveal: key.NewBinding(
+			key.WithKeys("ctrl+r"),
+			key.WithHelp("ctrl+r", "show password"),
+		),
+		AgentForwarding: key.NewBinding(
+			key.WithKeys("ctrl+g"),
+			key.WithHelp("ctrl+g", "-A"),
+		),
+		X11: key.NewBinding(
+			key.WithKeys("ctrl+x"),
+			key.WithHelp("ctrl+x", "-X"),
+		),
+		Compression: key.NewBinding(
+			key.WithKeys("ctrl+o"),
+			key.WithHelp("ctrl+o", "-C"),
+		),
+		Verbose: key.NewBinding(
+			key.WithKeys("ctrl+t"),
+			key.WithHelp("ctrl+t", "-v"),
+		),
+		DebugLog: key.NewBinding(
+			key.WithKeys("ctrl+d"),
+			key.WithHelp("ctrl+d", "-vvv log"),
+		),
 	}
 
 	return &model{
-		list:     l,
-		screen:   listScreen,
-		pwInput:  pw,
-		spinner:  s,
-		help:     help.New(),
-		listKeys: listKeys,
-		keys:     keys,
-		infoBox:  "hello world",
+		list:         l,
+		screen:       listScreen,
+		visualAnchor: -1,
+		pwInput:      pw,
+		spinner:      s,
+		help:         help.New(),
+		listKeys:     listKeys,
+		keys:         keys,
+		infoBox:      "hello world",
+
+		unlockInput:    unlock,
+		keygenInput:    keygen,
+		overrideInput:  overrideInput,
+		agentInput:     agentInput,
+		transferInputs: [2]textinput.Model{textinput.New(), textinput.New()},
+		forwardInput:   textinput.New(),
+		broadcastInput: textinput.New(),
+		tagInput:       textinput.New(),
+		deployInput:    deployInput,
+		identities:     map[string][]string{},
+
+		challengeInput: challenge,
+		timezones:      map[string]string{},
+		certs:          map[string][]certInfo{},
+		collapsed:      map[string]bool{},
+
+		hostKeyVerified: map[string]bool{},
+		knownKeys:       map[string][]ssh.PublicKey{},
+		tzProbed:        map[string]bool{},
 	}
 }
 
 func (m *model) Init() tea.Cmd {
-	return nil
+	cmds := []tea.Cmd{m.startCmd}
+	var hosts []string
+	for _, h := range m.hostItems() {
+		hosts = append(hosts, h.host)
+	}
+	cmds = append(cmds, checkHostKeyChanges(hosts, m.cachedKeys))
+	if m.sortMode == sortLatency {
+		m.latencyProbing = true
+		cmds = append(cmds, probeLatencies(hosts, m.config.execWorkers()))
+	}
+	// A proxy started in an earlier run stays on until stopped
+	if tunnels, err := listTunnels(""); err == nil {
+		m.socksProxy = findSocksProxy(tunnels, m.config.socksPort())
+	}
+	if m.config.GPUProbeTag == "" {
+		return tea.Batch(cmds...)
+	}
+	for _, h := range m.hostItems() {
+		if m.metadata.hasTag(h.host, m.config.GPUProbeTag) {
+			cmds = append(cmds, probeGPU(h.host))
+		}
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.WindowSizeMsg); ok {
+		m.width, m.height = msg.Width, msg.Height
+		h, v := docStyle.GetFrameSize()
+		m.snippetOutput.Width, m.snippetOutput.Height = max(20, m.width-h), max(5, m.height-v-4)
+		m.debugView.Width, m.debugView.Height = max(20, m.width-h), max(5, m.height-v-4)
+		m.broadcastView.Width, m.broadcastView.Height = max(20, m.width-h), max(5, m.height-v-6)
+		m.outputDiff.Width, m.outputDiff.Height = max(20, m.width-h), max(5, m.height-v-4)
+	}
+	// Probe results can arrive on any screen
+	if msg, ok := msg.(gpuMetricsMsg); ok {
+		for _, h := range m.hostItems() {
+			if h.host == msg.host {
+				h.metrics = &msg.metrics
+				m.setHost(h)
+			}
+		}
+		return m, nil
+	}
+	if msg, ok := msg.(latencyMsg); ok {
+		m.usage.latency = msg.latencies
+		m.latencyProbing = false
+		return m, m.refreshList()
+	}
+	if msg, ok := msg.(hostKeyChangesMsg); ok {
+		for host, keys := range msg.keys {
+			if _, ok := m.knownKeys[host]; !ok {
+				m.knownKeys[host] = keys
+			}
+		}
+		m.changedKeys = msg.changed
+		return m, m.refreshInfoBox()
+	}
+	// Broadcast output keeps arriving when the viewer is closed early
+	if msg, ok := msg.(broadcastMsg); ok {
+		if msg.generation == m.broadcastRun {
+			m.recordBroadcast(msg)
+		}
+		if msg.end {
+			return m, nil
+		}
+		return m, waitForBroadcast(msg.events)
+	}
+	// A native login abandoned with esc still finishes in the background
+	if m.screen != spinnerScreen && m.screen != challengeScreen {
+		switch msg := msg.(type) {
+		case challengeMsg:
+			msg.reply <- nil
+			return m, waitForNative(m.nativeEvents)
+		case nativeLoginMsg:
+			if msg.client != nil {
+				msg.client.Close()
+			}
+			return m, nil
+		case browserOpenedMsg:
+			if msg.client != nil {
+				msg.client.Close()
+			}
+			return m, nil
+		}
+	}
+	if msg, ok := msg.(timezoneMsg); ok {
+		if msg.err == nil {
+			m.timezones[msg.host] = msg.zone
+			_ = updateAppState(func(st *appState) {
+				if st.Timezones == nil {
+					st.Timezones = map[string]string{}
+				}
+				st.Timezones[msg.host] = msg.zone
+			})
+		}
+		return m, m.refreshInfoBox()
+	}
+
 	switch m.screen {
 	case listScreen:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
+			if m.tagBar {
+				return m.updateTagBar(msg)
+			}
+			if msg.String() == "ctrl+r" {
+				m.toggleRegexFilter()
+				return m, nil
+			}
+			if m.list.FilterState() == list.Filtering {
+				// Keys belong to the filter input while typing
+				break
+			}
+			m.statusMsg = ""
 			switch msg.String() {
 			case "ctrl+c":
 				return m, tea.Quit
+			case " ":
+				if m.visualAnchor >= 0 {
+					m.setMarked(m.visualRange(), true)
+					m.visualAnchor = -1
+				} else if selected, ok := m.list.SelectedItem().(hostItem); ok {
+					m.setMarked([]string{selected.host}, !selected.marked)
+				}
+				return m, nil
+			case "o":
+				return m, m.cycleSortMode()
+			case "*":
+				return m, m.toggleFavorites()
+			case "ctrl+f":
+				if !m.favoritesOnly && !slices.ContainsFunc(m.hostItems(), func(h hostItem) bool { return h.favorite }) {
+					m.statusMsg = "No favorites yet; star hosts with *"
+					return m, nil
+				}
+				m.favoritesOnly = !m.favoritesOnly
+				return m, tea.Batch(m.refreshList(), m.refreshInfoBox())
+			case "z":
+				m.grouped = !m.grouped
+				m.listKeys.FoldAll.SetEnabled(m.grouped)
+				m.saveGroupView()
+				return m, tea.Batch(m.refreshList(), m.refreshInfoBox())
+			case "Z":
+				if m.grouped {
+					return m, tea.Batch(m.toggleAllSections(), m.refreshInfoBox())
+				}
+			case "ctrl+t":
+				if len(m.tagBarTags()) == 0 {
+					m.statusMsg = "No host has tags yet; add them with #"
+					return m, nil
+				}
+				m.tagBar = true
+				return m, nil
+			case "v":
+				if m.visualAnchor >= 0 {
+					m.setMarked(m.visualRange(), true)
+					m.visualAnchor = -1
+				} else if len(m.list.VisibleItems()) > 0 {
+					m.visualAnchor = m.list.Index()
+				}
+				return m, nil
+			case "esc":
+				// Leaves the marks before esc clears the filter or quits
+				if m.visualAnchor >= 0 {
+					m.visualAnchor = -1
+					return m, nil
+				}
+				if marked := m.markedHosts(); len(marked) > 0 && m.list.FilterState() == list.Unfiltered {
+					m.setMarked(marked, false)
+					return m, nil
+				}
 			case "enter":
+				if header, ok := m.list.SelectedItem().(groupHeader); ok {
+					return m, m.toggleSection(header.name)
+				}
 				selected, ok := m.list.SelectedItem().(hostItem)
 				if ok {
-					m.selectedHost = selected.host
-					m.selectedDesc = selected.desc
-					m.pwInput.SetValue("")
-					m.errMsg = ""
-					m.screen = passwordScreen
-					return m, nil
+					m.selectHost(selected.host)
+					return m.connectSelected()
 				}
 			case "delete", "x":
 				selected, ok := m.list.SelectedItem().(hostItem)
@@ -169,158 +1015,3052 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						// Could show error message here if needed
 						return m, nil
 					}
-					// Reload the list
-					usr, _ := user.Current()
-					sshConfigPath := filepath.Join(usr.HomeDir, ".ssh", "config")
-					if hosts, err := parseSSHConfig(sshConfigPath); err == nil {
-						items := make([]list.Item, len(hosts))
-						for i, h := range hosts {
-							items[i] = h
-						}
-						m.list.SetItems(items)
+					m.reloadHosts()
+					return m, nil
+				}
+			case "L":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				group := m.config.groupOf(selected, m.metadata[selected.host])
+				if group == "" {
+					m.statusMsg = selected.host + " has no tags and no smart group selects it, so it is not part of a group"
+					return m, nil
+				}
+				hosts, err := m.config.groupHosts(group, m.metadata, m.hostItems())
+				if err != nil {
+					m.statusMsg = err.Error()
+					return m, nil
+				}
+				policy := m.config.groupPolicy(group)
+				m.spinnerText = fmt.Sprintf("Picking one of %d %q hosts (%s)...", len(hosts), group, policy)
+				m.screen = spinnerScreen
+				return m, tea.Batch(m.spinner.Tick, pickFromGroup(group, policy, hosts))
+			case "U":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				m.selectHost(selected.host)
+				m.overridePort = false
+				m.overrideInput.SetValue("root")
+				m.overrideInput.CursorEnd()
+				m.errMsg = ""
+				m.screen = overrideScreen
+				return m, nil
+			case "O":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				m.selectHost(selected.host)
+				m.overridePort = true
+				m.overrideInput.SetValue("")
+				m.errMsg = ""
+				m.screen = overrideScreen
+				return m, nil
+			case "s":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				m.selectHost(selected.host)
+				m.sftp = true
+				return m.connectSelected()
+			case "T":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				m.transferHosts = m.markedHosts()
+				if m.transferHosts != nil {
+					m.transferSpec = transferSpec{upload: true}
+					m.transferResult = ""
+					m.transferField = 0
+					for i := range m.transferInputs {
+						m.transferInputs[i].SetValue("")
+						m.transferInputs[i].Blur()
 					}
+					m.transferInputs[0].Focus()
+					m.errMsg = ""
+					m.screen = transferScreen
+					return m, textinput.Blink
+				}
+				if m.metadata[selected.host].NativeClient {
+					m.statusMsg = "scp and rsync cannot use the built-in SSH client of " + selected.host + "; press F to browse its files instead"
 					return m, nil
 				}
-			}
-		case tea.WindowSizeMsg:
-			h, v := docStyle.GetFrameSize()
-			// Reserve space for info box (60 chars + 2 spaces)
-			m.list.SetSize(msg.Width-h-62, msg.Height-v)
+				m.selectHost(selected.host)
+				m.transferSpec = transferSpec{rsync: hasRsync(), legacyRsync: !rsyncProgress2()}
+				m.transferResult = ""
+				m.transferField = 0
+				for i := range m.transferInputs {
+					m.transferInputs[i].SetValue("")
+					m.transferInputs[i].Blur()
+				}
+				m.transferInputs[0].Focus()
+				m.errMsg = ""
+				m.screen = transferScreen
+				return m, textinput.Blink
+			case "S":
+				if m.socksProxy != nil {
+					if err := stopTunnel(*m.socksProxy); err != nil {
+						m.statusMsg = "Could not stop the SOCKS proxy: " + err.Error()
+						return m, nil
+					}
+					m.statusMsg = "SOCKS proxy via " + m.socksProxy.Host + " stopped."
+					m.socksProxy = nil
+					return m, nil
+				}
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				if m.metadata[selected.host].NativeClient {
+					m.statusMsg = "The SOCKS proxy runs ssh, which cannot answer the prompts of " + selected.host + " (built-in client)."
+					return m, nil
+				}
+				m.selectHost(selected.host)
+				m.openTunnel = []forward{{Kind: "D", Spec: strconv.Itoa(m.config.socksPort())}}
+				m.socksStarting = true
+				return m.connectSelected()
+			case "W":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				m.selectHost(selected.host)
+				m.forwards = slices.Clone(m.config.Forwards[selected.host])
+				m.forwardOn = make([]bool, len(m.forwards))
+				m.forwardCursor = 0
+				m.forwardAdding = false
+				m.forwardStatus = ""
+				m.refreshTunnels()
+				m.screen = forwardsScreen
+				return m, nil
+			case "w", "%":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				if terminal := detectTerminal(os.Getenv); !insideTmux() && terminal != "" && msg.String() == "w" {
+					mode := m.config.terminalMode(terminal)
+					if mode == "off" {
+						m.statusMsg = "Opening " + terminal + " tabs is turned off in config.json."
+						return m, nil
+					}
+					if err := openInTerminal(terminal, mode, selected.host); err != nil {
+						m.statusMsg = "Could not open " + selected.host + " in a " + terminal + " " + mode + ": " + err.Error()
+						return m, nil
+					}
+					m.statusMsg = "Opened " + selected.host + " in a new " + terminal + " " + mode + "."
+					return m, nil
+				}
+				if !insideTmux() {
+					break
+				}
+				split := msg.String() == "%"
+				if err := openInTmux(selected.host, split); err != nil {
+					m.statusMsg = "Could not open " + selected.host + " in tmux: " + err.Error()
+					return m, nil
+				}
+				m.statusMsg = "Opened " + selected.host + " in a new tmux window."
+				if split {
+					m.statusMsg = "Opened " + selected.host + " in a new tmux pane."
+				}
+				return m, nil
+			case "V":
+				m.debugLogin = !m.debugLogin
+				m.statusMsg = "Login tests run with ssh -vvv; the log opens when one fails."
+				if !m.debugLogin {
+					m.statusMsg = "Login tests run without a debug log."
+				}
+				return m, nil
+			case "0", "1", "2", "3", "4", "5", "6", "7", "8", "9":
+				group := m.config.groupWithKey(msg.String())
+				if group == "" || !insideTmux() {
+					break
+				}
+				hosts, err := m.config.groupHosts(group, m.metadata, m.hostItems())
+				if err != nil {
+					m.statusMsg = err.Error()
+					return m, nil
+				}
+				if len(hosts) == 0 {
+					m.statusMsg = "Group " + group + " has no hosts."
+					return m, nil
+				}
+				if err := openTmuxCluster(group, hosts, m.config.Groups[group].Tmux); err != nil {
+					m.statusMsg = "Could not open " + group + " in tmux: " + err.Error()
+					return m, nil
+				}
+				m.statusMsg = fmt.Sprintf("Opened the %d hosts of %s in a tmux window.", len(hosts), group)
+				return m, nil
+			case "#":
+				m.tagHosts = m.markedHosts()
+				if m.tagHosts == nil {
+					selected, ok := m.list.SelectedItem().(hostItem)
+					if !ok {
+						break
+					}
+					m.tagHosts = []string{selected.host}
+				}
+				m.errMsg = ""
+				m.tagInput.SetValue("")
+				m.screen = tagsScreen
+				return m, m.tagInput.Focus()
+			case "Y":
+				if !insideTmux() {
+					break
+				}
+				hosts := m.markedHosts()
+				if len(hosts) == 0 {
+					m.statusMsg = "Mark hosts with space or v first."
+					return m, nil
+				}
+				if err := openTmuxCluster("cluster", hosts, tmuxLayout{Sync: true}); err != nil {
+					m.statusMsg = "Could not open the hosts in tmux: " + err.Error()
+					return m, nil
+				}
+				m.statusMsg = fmt.Sprintf("Opened %d hosts in a tmux window with synchronized panes.", len(hosts))
+				return m, nil
+			case "B":
+				if len(m.markedHosts()) == 0 {
+					m.statusMsg = "Mark hosts with space or v first."
+					return m, nil
+				}
+				m.errMsg = ""
+				m.broadcastInput.SetValue(m.broadcastCommand)
+				m.broadcastInput.CursorEnd()
+				m.screen = broadcastInputScreen
+				return m, m.broadcastInput.Focus()
+			case "X":
+				if m.broadcastHosts == nil {
+					m.statusMsg = "Nothing has run on marked hosts yet."
+					return m, nil
+				}
+				m.errMsg = ""
+				m.screen = broadcastScreen
+				return m, nil
+			case "!":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				m.selectHost(selected.host)
+				m.snippetCursor = 0
+				m.snippetStatus = ""
+				m.loadSnippetLibrary()
+				m.screen = snippetsScreen
+				return m, nil
+			case "t":
+				m.tunnelCursor = 0
+				m.tunnelStatus = ""
+				m.refreshDashboard()
+				m.screen = tunnelsScreen
+				m.tunnelTick++
+				return m, tickTunnels(m.tunnelTick)
+			case "F":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				m.selectHost(selected.host)
+				m.browse = true
+				return m.connectSelected()
+			case "J":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				m.selectHost(selected.host)
+				m.jumpConfigured = proxyJumps(m.hostItems())[selected.host]
+				m.jumpHosts = jumpCandidates(m.hostItems(), selected.host, m.jumpConfigured)
+				m.jumpCursor = 0
+				m.jumpChecking = ""
+				m.errMsg = ""
+				m.screen = jumpScreen
+				return m, nil
+			case "M":
+				m.masters = masterConnections()
+				m.masterCursor = 0
+				m.errMsg = ""
+				m.screen = connectionsScreen
+				return m, nil
+			case "E":
+				m.pivotRows, m.pivotEnvs = pivotEnvironments(m.hostItems(), m.metadata)
+				if len(m.pivotRows) == 0 {
+					m.statusMsg = "No host was found in more than one environment (e.g. web-prod-1 and web-stage-1)"
+					return m, nil
+				}
+				m.pivotRow, m.pivotCol = 0, 0
+				// Start at the selected host, when it is one of them
+				if selected, ok := m.list.SelectedItem().(hostItem); ok {
+					for i, r := range m.pivotRows {
+						for j, env := range m.pivotEnvs {
+							if r.hosts[env] == selected.host {
+								m.pivotRow, m.pivotCol = i, j
+							}
+						}
+					}
+				}
+				m.screen = pivotScreen
+				return m, nil
+			case "H":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				g := buildDependencyGraph(m.metadata, proxyJumps(m.hostItems()))
+				m.selectHost(selected.host)
+				m.graphView = dependencyView(g, selected.host)
+				m.screen = graphScreen
+				return m, nil
+			case "P":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				m.pinHostKey(selected.host)
+				return m, m.refreshInfoBox()
+			case "K":
+				m.spinnerText = "Scanning known_hosts..."
+				m.screen = spinnerScreen
+				return m, tea.Batch(m.spinner.Tick, findStaleKnownHosts(m.hostItems()))
+			case "D":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				return m.compareHosts(selected.host)
+			case "A":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				return m.addHostKeyToAgent(selected.host)
+			case "R":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				path, err := sshConfigPath()
+				if err != nil {
+					m.statusMsg = "Could not read ~/.ssh/config: " + err.Error()
+					return m, nil
+				}
+				local, _ := os.ReadFile(path)
+				m.spinnerText = "Fetching ~/.ssh/config from " + selected.host + "..."
+				m.screen = spinnerScreen
+				return m, tea.Batch(m.spinner.Tick, fetchRemoteConfig(selected.host, string(local)))
+			case "I":
+				keys, err := sshKeys()
+				if err != nil {
+					m.statusMsg = "Could not list keys: " + err.Error()
+					return m, nil
+				}
+				m.keysView = keysView(keys)
+				m.screen = keysScreen
+				return m, nil
+			case "Q":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				view, err := qrView(selected.host)
+				if err != nil {
+					m.statusMsg = "Could not create a QR code: " + err.Error()
+					return m, nil
+				}
+				m.selectHost(selected.host)
+				m.qrView = view
+				m.screen = qrScreen
+				return m, nil
+			case "N":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				m.selectHost(selected.host)
+				m.keygenStep = keygenPath
+				m.keygenPhrase = ""
+				m.keygenInput.EchoMode = textinput.EchoNormal
+				m.keygenInput.SetValue("~/.ssh/id_ed25519_" + selected.host)
+				m.keygenInput.CursorEnd()
+				m.errMsg = ""
+				m.screen = keygenScreen
+				return m, nil
+			case "C":
+				selected, ok := m.list.SelectedItem().(hostItem)
+				if !ok {
+					break
+				}
+				m.pubKeys = publicKeys()
+				if len(m.pubKeys) == 0 {
+					m.statusMsg = "No public keys found in ~/.ssh; generate one with ssh-keygen first."
+					return m, nil
+				}
+				m.deployHosts = m.markedHosts()
+				m.selectHost(selected.host)
+				m.pubKeyCursor = 0
+				m.screen = pubKeyScreen
+				return m, nil
+			}
+		case tea.WindowSizeMsg:
+			h, v := docStyle.GetFrameSize()
+			// Reserve space for info box (60 chars + 2 spaces)
+			m.list.SetSize(msg.Width-h-62, msg.Height-v)
+		}
+
+		var cmd tea.Cmd
+		m.list, cmd = m.list.Update(msg)
+
+		// Update info box content after list update
+		return m, tea.Batch(cmd, m.refreshInfoBox())
+	case passwordScreen:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "esc":
+				m.screen = listScreen
+				m.errMsg = ""
+				m.copyKey = ""
+				m.pwInput.EchoMode = textinput.EchoPassword
+				return m, nil
+			case "enter":
+				return m.login([]byte(m.pwInput.Value()))
+			case "ctrl+r":
+				if m.pwInput.EchoMode == textinput.EchoPassword {
+					m.pwInput.EchoMode = textinput.EchoNormal
+				} else {
+					m.pwInput.EchoMode = textinput.EchoPassword
+				}
+				return m, nil
+			case "ctrl+s":
+				if m.vault == nil {
+					m.errMsg = "No secret backend configured to remember passwords in."
+					return m, nil
+				}
+				m.remember = !m.remember
+				return m, nil
+			case "ctrl+a":
+				if m.keyFile != "" {
+					m.addKeyToAgent = !m.addKeyToAgent
+				}
+				return m, nil
+			case "ctrl+g":
+				m.connectOpts.agentForwarding = !m.connectOpts.agentForwarding
+				return m, nil
+			case "ctrl+x":
+				m.connectOpts.x11 = !m.connectOpts.x11
+				return m, nil
+			case "ctrl+o":
+				m.connectOpts.compression = !m.connectOpts.compression
+				return m, nil
+			case "ctrl+t":
+				m.connectOpts.verbose = !m.connectOpts.verbose
+				return m, nil
+			case "ctrl+d":
+				m.debugLogin = !m.debugLogin
+				return m, nil
+			}
+		}
+		var cmd tea.Cmd
+		m.pwInput, cmd = m.pwInput.Update(trimPastedSecret(msg))
+		return m, cmd
+	case unlockScreen:
+		switch msg := msg.(type) {
+		case biometricResultMsg:
+			m.biometricPending = false
+			if msg.err != nil {
+				// Fall back to typing the master password
+				return m, nil
+			}
+			path, err := vaultPath()
+			if err != nil {
+				return m, nil
+			}
+			v, err := openVault(path, msg.master)
+			if err != nil {
+				return m, nil
+			}
+			m.vault = v
+			return m.askPassword()
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "esc":
+				m.screen = listScreen
+				m.errMsg = ""
+				return m, nil
+			case "enter":
+				master := m.unlockInput.Value()
+				v, err := unlockVault(master)
+				m.unlockInput.SetValue("")
+				if err != nil {
+					m.errMsg = "Could not unlock vault: " + err.Error()
+					return m, nil
+				}
+				m.vault = v
+				if !m.config.BiometricUnlock {
+					return m.askPassword()
+				}
+				// The next run can then unlock with Touch ID
+				model, cmd := m.askPassword()
+				return model, tea.Batch(storeBiometric(master), cmd)
+			}
+		}
+		var cmd tea.Cmd
+		m.unlockInput, cmd = m.unlockInput.Update(trimPastedSecret(msg))
+		return m, cmd
+	case hostKeyScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "y":
+				if m.hostKey.status == hostKeyPinMismatch {
+					// Only changing the pin in hosts.json lets this key through
+					return m, nil
+				}
+				if err := acceptHostKey(m.hostKey); err != nil {
+					m.errMsg = "Could not update known_hosts: " + err.Error()
+					return m, nil
+				}
+				delete(m.knownKeys, m.hostKey.host)
+				rememberHostKeys(m.hostKey.host)
+				m.hostKeyVerified[m.hostKey.host] = true
+				return m, m.startLoginTest()
+			case "n", "esc":
+				m.loggingIn = false
+				m.screen = listScreen
+				m.statusMsg = "Host key of " + m.hostKey.host + " was not accepted."
+				m.forgetPassword()
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+		return m, nil
+	case conflictScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "y":
+				if err := m.conflict.remove(); err != nil {
+					m.errMsg = err.Error()
+					return m, nil
+				}
+				// Verify the new key before trying again
+				clear(m.hostKeyVerified)
+				clear(m.knownKeys)
+				return m.login(m.password)
+			case "n", "esc":
+				m.loggingIn = false
+				m.screen = listScreen
+				m.statusMsg = "Host key of " + m.conflict.host + " has changed; known_hosts was left as is."
+				m.forgetPassword()
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+		return m, nil
+	case cleanupScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "up", "k":
+				m.staleCursor = max(0, m.staleCursor-1)
+			case "down", "j":
+				m.staleCursor = min(len(m.staleHosts)-1, m.staleCursor+1)
+			case " ":
+				if m.staleSelected[m.staleCursor] {
+					delete(m.staleSelected, m.staleCursor)
+				} else {
+					m.staleSelected[m.staleCursor] = true
+				}
+			case "a":
+				if len(m.staleSelected) == len(m.staleHosts) {
+					clear(m.staleSelected)
+					break
+				}
+				for i := range m.staleHosts {
+					m.staleSelected[i] = true
+				}
+			case "d", "enter":
+				return m.removeStaleHosts()
+			case "esc", "q":
+				m.screen = listScreen
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+		return m, nil
+	case pubKeyScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "up", "k":
+				m.pubKeyCursor = max(0, m.pubKeyCursor-1)
+			case "down", "j":
+				m.pubKeyCursor = min(len(m.pubKeys)-1, m.pubKeyCursor+1)
+			case "enter":
+				if m.deployHosts != nil {
+					m.deployKey = m.pubKeys[m.pubKeyCursor]
+					return m, m.startDeployKey(false)
+				}
+				return m.startCopyKey(m.pubKeys[m.pubKeyCursor])
+			case "esc", "q":
+				m.screen = listScreen
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+		return m, nil
+	case keygenScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "esc":
+				m.screen = listScreen
+				m.errMsg = ""
+				if m.keygenStep == keygenDeploy {
+					m.statusMsg = "Created " + m.keygenPath + " for " + m.selectedHost + "; install it later with C."
+				}
+				return m, nil
+			case "ctrl+c":
+				return m, tea.Quit
+			case "enter":
+				return m.keygenNext()
+			case "y":
+				if m.keygenStep == keygenDeploy {
+					return m.startCopyKey(expandHome(m.keygenPath) + ".pub")
+				}
+			case "n":
+				if m.keygenStep == keygenDeploy {
+					m.screen = listScreen
+					m.statusMsg = "Created " + m.keygenPath + " for " + m.selectedHost + "; install it later with C."
+					return m, nil
+				}
+			}
+		}
+		if m.keygenStep == keygenDeploy {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.keygenInput, cmd = m.keygenInput.Update(msg)
+		return m, cmd
+	case agentScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "esc":
+				m.screen = listScreen
+				m.errMsg = ""
+				m.agentInput.SetValue("")
+				return m, nil
+			case "ctrl+c":
+				return m, tea.Quit
+			case "enter":
+				err := addToAgent(m.agentKeyFile, []byte(m.agentInput.Value()))
+				m.agentInput.SetValue("")
+				if err != nil {
+					m.errMsg = "Could not add the key: " + err.Error()
+					return m, nil
+				}
+				m.errMsg = ""
+				m.screen = listScreen
+				m.statusMsg = "Added " + filepath.Base(m.agentKeyFile) + " to the agent."
+				return m, m.refreshInfoBox()
+			}
+		}
+		var cmd tea.Cmd
+		m.agentInput, cmd = m.agentInput.Update(trimPastedSecret(msg))
+		return m, cmd
+	case importScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			b := &m.importBlocks[m.importCursor]
+			switch msg.String() {
+			case "up", "k":
+				m.importCursor = max(0, m.importCursor-1)
+			case "down", "j":
+				m.importCursor = min(len(m.importBlocks)-1, m.importCursor+1)
+			case " ":
+				if b.status != blockSame {
+					b.selected = !b.selected
+				}
+			case "r":
+				if b.status == blockConflict {
+					b.resolution = 1 - b.resolution
+				}
+			case "enter":
+				return m.mergeImport()
+			case "esc", "q":
+				m.screen = listScreen
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+		return m, nil
+	case overrideScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "esc":
+				m.screen = listScreen
+				return m, nil
+			case "ctrl+c":
+				return m, tea.Quit
+			case "enter":
+				value := strings.TrimSpace(m.overrideInput.Value())
+				if value == "" {
+					return m, nil
+				}
+				if m.overridePort {
+					port, err := strconv.Atoi(value)
+					if err != nil || port < 1 || port > 65535 {
+						m.errMsg = value + " is not a port number."
+						return m, nil
+					}
+					m.loginPort = port
+				} else {
+					m.loginUser = value
+				}
+				m.screen = listScreen
+				return m.connectSelected()
+			}
+		}
+		var cmd tea.Cmd
+		m.overrideInput, cmd = m.overrideInput.Update(msg)
+		return m, cmd
+	case forwardsScreen:
+		msg, ok := msg.(tea.KeyMsg)
+		if !ok {
+			return m, nil
+		}
+		if m.forwardAdding {
+			switch msg.String() {
+			case "esc":
+				m.forwardAdding = false
+			case "enter":
+				f, err := parseForward(m.forwardInput.Value())
+				if err != nil {
+					m.forwardStatus = err.Error()
+					return m, nil
+				}
+				m.forwardAdding = false
+				m.forwards = append(m.forwards, f)
+				m.forwardOn = append(m.forwardOn, true)
+				m.forwardCursor = len(m.forwards) - 1
+				m.forwardStatus = ""
+			default:
+				var cmd tea.Cmd
+				m.forwardInput, cmd = m.forwardInput.Update(msg)
+				return m, cmd
+			}
+			return m, nil
+		}
+		onForward := m.forwardCursor < len(m.forwards)
+		switch msg.String() {
+		case "up", "k":
+			m.forwardCursor = max(0, m.forwardCursor-1)
+		case "down", "j":
+			m.forwardCursor = max(0, min(len(m.forwards)+len(m.tunnels)-1, m.forwardCursor+1))
+		case " ":
+			if onForward {
+				m.forwardOn[m.forwardCursor] = !m.forwardOn[m.forwardCursor]
+			}
+		case "a":
+			m.forwardAdding = true
+			m.forwardInput.SetValue("")
+			m.forwardInput.Placeholder = "L 8080:localhost:80"
+			m.forwardInput.Focus()
+			return m, textinput.Blink
+		case "w":
+			host, forwards := m.selectedHost, slices.Clone(m.forwards)
+			cfg, err := updateAppConfig(func(c *appConfig) {
+				if c.Forwards == nil {
+					c.Forwards = map[string][]forward{}
+				}
+				c.Forwards[host] = forwards
+				if len(forwards) == 0 {
+					delete(c.Forwards, host)
+				}
+			})
+			if err != nil {
+				m.forwardStatus = "Could not save the forwards: " + err.Error()
+				break
+			}
+			m.config.Forwards = cfg.Forwards
+			m.forwardStatus = fmt.Sprintf("Saved %d forwards for %s.", len(forwards), host)
+		case "x", "d":
+			if onForward && len(m.forwards) > 0 {
+				m.forwards = slices.Delete(m.forwards, m.forwardCursor, m.forwardCursor+1)
+				m.forwardOn = slices.Delete(m.forwardOn, m.forwardCursor, m.forwardCursor+1)
+				m.forwardStatus = "Removed; press w to save the list."
+			} else if !onForward {
+				t := m.tunnels[m.forwardCursor-len(m.forwards)]
+				if err := stopTunnel(t); err != nil {
+					m.forwardStatus = "Could not stop the tunnel: " + err.Error()
+				} else {
+					m.forwardStatus = "Tunnel stopped."
+					if m.socksProxy != nil && m.socksProxy.PID == t.PID {
+						m.socksProxy = nil
+					}
+				}
+			}
+			m.refreshTunnels()
+		case "r":
+			m.refreshTunnels()
+		case "enter":
+			if !slices.Contains(m.forwardOn, true) {
+				m.forwardStatus = "Select the forwards of the tunnel with space first."
+				break
+			}
+			if m.metadata[m.selectedHost].NativeClient {
+				m.forwardStatus = "Tunnels run ssh, which cannot answer the prompts of hosts using the built-in client."
+				break
+			}
+			for i, f := range m.forwards {
+				if m.forwardOn[i] {
+					m.openTunnel = append(m.openTunnel, f)
+				}
+			}
+			return m.connectSelected()
+		case "esc", "q":
+			m.screen = listScreen
+		case "ctrl+c":
+			return m, tea.Quit
+		}
+		return m, nil
+	case snippetsScreen:
+		switch msg := msg.(type) {
+		case snippetOutputMsg:
+			m.snippetRunning = false
+			if errors.Is(msg.err, context.Canceled) {
+				m.snippetStatus = msg.snippet.Name + " cancelled."
+				return m, nil
+			}
+			m.snippetStatus = ""
+			m.snippetTitle = fmt.Sprintf("%s on %s (%s)", msg.snippet.Name, m.selectedHost, snippetOutcome(msg.err))
+			h, v := docStyle.GetFrameSize()
+			m.snippetOutput = viewport.New(max(20, m.width-h), max(5, m.height-v-4))
+			m.snippetOutput.SetContent(msg.output)
+			m.screen = snippetOutputScreen
+			return m, nil
+		case spinner.TickMsg:
+			if !m.snippetRunning {
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.spinner, cmd = m.spinner.Update(msg)
+			return m, cmd
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "ctrl+c":
+				if m.snippetCancel != nil {
+					m.snippetCancel()
+				}
+				return m, tea.Quit
+			case "esc", "q":
+				if m.snippetRunning {
+					// The output message follows once the command has stopped
+					m.snippetCancel()
+					return m, nil
+				}
+				m.screen = listScreen
+				return m, nil
+			}
+			if m.snippetRunning {
+				return m, nil
+			}
+			switch msg.String() {
+			case "up", "k":
+				m.snippetCursor = max(0, m.snippetCursor-1)
+			case "down", "j":
+				m.snippetCursor = max(0, min(len(m.snippets)-1, m.snippetCursor+1))
+			case "r":
+				m.loadSnippetLibrary()
+			case "enter":
+				if len(m.snippets) > 0 {
+					return m.runSnippetOnHost(m.snippets[m.snippetCursor])
+				}
+			default:
+				for _, s := range m.snippets {
+					if s.Key == msg.String() {
+						return m.runSnippetOnHost(s)
+					}
+				}
+			}
+		}
+		return m, nil
+	case debugLogScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "esc", "q":
+				m.screen = m.debugReturn
+				return m, nil
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+		var cmd tea.Cmd
+		m.debugView, cmd = m.debugView.Update(msg)
+		return m, cmd
+	case broadcastInputScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "esc":
+				m.screen = listScreen
+				return m, nil
+			case "ctrl+c":
+				return m, tea.Quit
+			case "enter":
+				command := strings.TrimSpace(m.broadcastInput.Value())
+				if command == "" {
+					return m, nil
+				}
+				hosts := m.markedHosts()
+				if err := checkFreeze(m.config, m.metadata, hosts); err != nil {
+					m.errMsg = err.Error()
+					return m, nil
+				}
+				m.broadcastCommand = command
+				run := broadcastCommand(command, m.config.execTimeout())
+				m.broadcastSavedTo = ""
+				if m.broadcastSave {
+					dir, err := broadcastOutputDir(time.Now())
+					if err == nil {
+						err = os.MkdirAll(dir, 0700)
+					}
+					if err == nil {
+						err = os.WriteFile(filepath.Join(dir, "command"), []byte(command+"\n"), 0600)
+					}
+					if err != nil {
+						m.errMsg = "Could not create the output directory: " + err.Error()
+						return m, nil
+					}
+					run = teeBroadcast(dir, run)
+					m.broadcastSavedTo = dir
+				}
+				return m, m.startBroadcast(command, hosts, run, m.broadcastRolling)
+			case "ctrl+r":
+				m.broadcastRolling = !m.broadcastRolling
+				return m, nil
+			case "ctrl+s":
+				m.broadcastSave = !m.broadcastSave
+				return m, nil
+			}
+		}
+		var cmd tea.Cmd
+		m.broadcastInput, cmd = m.broadcastInput.Update(msg)
+		return m, cmd
+	case deployKeyScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "esc":
+				m.deployInput.SetValue("")
+				m.deployKnown, m.deployMissing = nil, nil
+				m.screen = listScreen
+				return m, nil
+			case "ctrl+c":
+				return m, tea.Quit
+			case "enter":
+				if m.deployInput.Value() == "" {
+					return m, nil
+				}
+				return m, m.runDeployKey()
+			}
+		}
+		var cmd tea.Cmd
+		m.deployInput, cmd = m.deployInput.Update(msg)
+		return m, cmd
+	case tagsScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "esc":
+				m.screen = listScreen
+				return m, nil
+			case "ctrl+c":
+				return m, tea.Quit
+			case "enter":
+				edit, err := parseTagEdit(m.tagInput.Value())
+				if err != nil {
+					m.errMsg = err.Error()
+					return m, nil
+				}
+				if err := saveTagEdit(edit, m.tagHosts); err != nil {
+					m.errMsg = "Could not save hosts.json: " + err.Error()
+					return m, nil
+				}
+				edit.apply(m.metadata, m.tagHosts)
+				m.statusMsg = fmt.Sprintf("Tags of %d hosts changed: %s", len(m.tagHosts), edit)
+				m.screen = listScreen
+				return m, tea.Batch(m.refreshList(), m.refreshInfoBox())
+			}
+		}
+		var cmd tea.Cmd
+		m.tagInput, cmd = m.tagInput.Update(msg)
+		return m, cmd
+	case broadcastScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			if hosts := m.wrongPasswordHosts(); msg.String() == "p" && len(hosts) > 0 && m.deployKey != "" {
+				m.deployHosts = hosts
+				return m, m.startDeployKey(true)
+			}
+			if m.rollingWaiting {
+				switch msg.String() {
+				case "y", "enter":
+					return m, m.rollNext()
+				case "n":
+					m.stopRolling()
+					return m, nil
+				}
+			}
+			switch msg.String() {
+			case "esc", "q":
+				// Nobody would be there to confirm the next host
+				if m.rollingRunner != nil {
+					m.stopRolling()
+				}
+				m.screen = listScreen
+				return m, nil
+			case "D":
+				h, v := docStyle.GetFrameSize()
+				m.outputDiff = viewport.New(max(20, m.width-h), max(5, m.height-v-4))
+				m.outputDiff.SetContent(outputDiffView(groupOutputs(m.broadcastHosts)))
+				m.screen = outputDiffScreen
+				return m, nil
+			case "ctrl+c":
+				return m, tea.Quit
+			case "tab", "right", "l":
+				m.broadcastTab = (m.broadcastTab + 1) % (len(m.broadcastHosts) + 1)
+				m.showBroadcastTab()
+				m.broadcastView.GotoBottom()
+				return m, nil
+			case "shift+tab", "left", "h":
+				m.broadcastTab = (m.broadcastTab + len(m.broadcastHosts)) % (len(m.broadcastHosts) + 1)
+				m.showBroadcastTab()
+				m.broadcastView.GotoBottom()
+				return m, nil
+			}
+		}
+		if m.broadcastTab == 0 {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.broadcastView, cmd = m.broadcastView.Update(msg)
+		return m, cmd
+	case outputDiffScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "esc", "q":
+				m.screen = broadcastScreen
+				return m, nil
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+		var cmd tea.Cmd
+		m.outputDiff, cmd = m.outputDiff.Update(msg)
+		return m, cmd
+	case snippetOutputScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "esc", "q":
+				m.screen = snippetsScreen
+				return m, nil
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+		var cmd tea.Cmd
+		m.snippetOutput, cmd = m.snippetOutput.Update(msg)
+		return m, cmd
+	case tunnelsScreen:
+		switch msg := msg.(type) {
+		case tunnelsTickMsg:
+			if int(msg) != m.tunnelTick {
+				return m, nil
+			}
+			m.refreshDashboard()
+			return m, tickTunnels(m.tunnelTick)
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "up", "k":
+				m.tunnelCursor = max(0, m.tunnelCursor-1)
+			case "down", "j":
+				m.tunnelCursor = max(0, min(len(m.tunnelRows)-1, m.tunnelCursor+1))
+			case "enter", "s":
+				if len(m.tunnelRows) == 0 {
+					break
+				}
+				row := m.tunnelRows[m.tunnelCursor]
+				if row.running != nil {
+					m.tunnelStatus = "The tunnel is running already; press r to restart it."
+					break
+				}
+				return m.startDashboardTunnel(row)
+			case "x", "d", "r":
+				if len(m.tunnelRows) == 0 || m.tunnelRows[m.tunnelCursor].running == nil {
+					m.tunnelStatus = "The tunnel is not running."
+					break
+				}
+				row := m.tunnelRows[m.tunnelCursor]
+				if err := stopTunnel(*row.running); err != nil {
+					m.tunnelStatus = "Could not stop the tunnel: " + err.Error()
+					break
+				}
+				m.tunnelStatus = "Tunnel to " + row.host + " stopped."
+				m.refreshDashboard()
+				if msg.String() == "r" {
+					return m.startDashboardTunnel(row)
+				}
+			case "esc", "q":
+				m.tunnelTick++
+				m.screen = listScreen
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+		return m, nil
+	case transferScreen:
+		switch msg := msg.(type) {
+		case transferProgressMsg:
+			m.transferProgress = msg
+			return m, waitForTransfer(m.transferEvents)
+		case transferDoneMsg:
+			m.transferRunning = false
+			m.transferCancel()
+			m.transferResult = "Copied in " + time.Since(m.transferStarted).Round(time.Second).String() + "."
+			if msg.err != nil {
+				m.transferResult = "Copy failed: " + msg.err.Error()
+			}
+			return m, nil
+		case spinner.TickMsg:
+			if !m.transferRunning {
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.spinner, cmd = m.spinner.Update(msg)
+			return m, cmd
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "ctrl+c":
+				if m.transferCancel != nil {
+					m.transferCancel()
+				}
+				return m, tea.Quit
+			case "esc":
+				if m.transferRunning {
+					// The done message follows once the tool has stopped
+					m.transferCancel()
+					return m, nil
+				}
+				m.screen = listScreen
+				if m.transferResult != "" {
+					m.statusMsg = m.transferResult
+				}
+				return m, nil
+			}
+			if m.transferRunning || m.transferResult != "" {
+				return m, nil
+			}
+			switch msg.String() {
+			case "tab", "shift+tab":
+				m.transferInputs[m.transferField].Blur()
+				m.transferField = 1 - m.transferField
+				return m, m.transferInputs[m.transferField].Focus()
+			case "ctrl+d":
+				if m.transferHosts == nil {
+					m.transferSpec.upload = !m.transferSpec.upload
+				}
+				return m, nil
+			case "ctrl+t":
+				if m.transferHosts == nil {
+					m.transferSpec.rsync = !m.transferSpec.rsync
+				}
+				return m, nil
+			case "enter":
+				local := strings.TrimSpace(m.transferInputs[0].Value())
+				remote := strings.TrimSpace(m.transferInputs[1].Value())
+				if local == "" || remote == "" {
+					m.errMsg = "Enter both the local and the remote path."
+					return m, nil
+				}
+				if m.transferHosts != nil {
+					if _, err := os.Stat(local); err != nil {
+						m.errMsg = err.Error()
+						return m, nil
+					}
+					if err := checkFreeze(m.config, m.metadata, m.transferHosts); err != nil {
+						m.errMsg = err.Error()
+						return m, nil
+					}
+					title := fmt.Sprintf("copy %s to %s on %d hosts", local, remote, len(m.transferHosts))
+					m.broadcastSavedTo = ""
+					return m, m.startBroadcast(title, m.transferHosts, copyToHost(local, remote, m.config.connectTimeout(), m.config.execTimeout()), false)
+				}
+				m.transferSpec.local, m.transferSpec.remote = local, remote
+				m.transfer = true
+				return m.connectSelected()
+			}
+		}
+		var cmd tea.Cmd
+		m.transferInputs[m.transferField], cmd = m.transferInputs[m.transferField].Update(msg)
+		return m, cmd
+	case browserScreen:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			if msg.String() == "ctrl+c" {
+				closeBrowser(m.browser)
+				return m, tea.Quit
+			}
+			cmd, done := m.browser.update(msg)
+			if done {
+				closeBrowser(m.browser)
+				m.browser = nil
+				m.screen = listScreen
+			}
+			return m, cmd
+		case browserMsg:
+			m.browser.loaded(msg)
+		}
+		return m, nil
+	case jumpScreen:
+		if msg, ok := msg.(bastionCheckedMsg); ok {
+			m.jumpChecking = ""
+			if msg.err != nil {
+				m.errMsg = fmt.Sprintf("Cannot connect through %s: %v. Only bastions that log in with a key or the agent can be used.", msg.jump, msg.err)
+				return m, nil
+			}
+			m.jumpHost = msg.jump
+			m.saveJump = msg.save
+			m.screen = listScreen
+			return m.connectSelected()
+		}
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "up", "k":
+				m.jumpCursor = max(0, m.jumpCursor-1)
+			case "down", "j":
+				m.jumpCursor = max(0, min(len(m.jumpHosts)-1, m.jumpCursor+1))
+			case "enter", "p":
+				if len(m.jumpHosts) == 0 {
+					break
+				}
+				if m.metadata[m.selectedHost].NativeClient {
+					m.errMsg = "The built-in SSH client cannot connect through a bastion."
+					break
+				}
+				if m.jumpChecking != "" {
+					break
+				}
+				m.errMsg = ""
+				m.jumpChecking = m.jumpHosts[m.jumpCursor]
+				return m, checkBastion(m.jumpChecking, m.config.connectTimeout(), msg.String() == "p")
+			case "esc", "q":
+				m.screen = listScreen
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+		return m, nil
+	case connectionsScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "up", "k":
+				m.masterCursor = max(0, m.masterCursor-1)
+			case "down", "j":
+				m.masterCursor = max(0, min(len(m.masters)-1, m.masterCursor+1))
+			case "x", "d":
+				if len(m.masters) == 0 {
+					break
+				}
+				m.errMsg = ""
+				if err := closeMasterConnection(m.masters[m.masterCursor]); err != nil {
+					m.errMsg = "Could not close the connection: " + err.Error()
+				}
+				m.masters = masterConnections()
+				m.masterCursor = max(0, min(len(m.masters)-1, m.masterCursor))
+			case "esc", "q":
+				m.screen = listScreen
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+		return m, nil
+	case pivotScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "up", "k":
+				m.pivotRow = max(0, m.pivotRow-1)
+			case "down", "j":
+				m.pivotRow = min(len(m.pivotRows)-1, m.pivotRow+1)
+			case "left", "h":
+				m.pivotCol = max(0, m.pivotCol-1)
+			case "right", "l":
+				m.pivotCol = min(len(m.pivotEnvs)-1, m.pivotCol+1)
+			case "enter":
+				host := m.pivotRows[m.pivotRow].hosts[m.pivotEnvs[m.pivotCol]]
+				if host == "" {
+					break
+				}
+				m.screen = listScreen
+				m.selectHost(host)
+				return m.connectSelected()
+			case "esc", "q":
+				m.screen = listScreen
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+		return m, nil
+	case graphScreen, diffScreen, qrScreen, keysScreen:
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "esc", "q":
+				m.screen = listScreen
+				m.diffHost = ""
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+		return m, nil
+	case challengeScreen:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "esc":
+				m.challenge.reply <- nil
+				m.challenge = nil
+				m.screen = spinnerScreen
+				return m, waitForNative(m.nativeEvents)
+			case "enter":
+				m.challengeAnswers = append(m.challengeAnswers, m.challengeInput.Value())
+				if len(m.challengeAnswers) < len(m.challenge.questions) {
+					m.nextChallengeQuestion()
+					return m, nil
+				}
+				m.challenge.reply <- m.challengeAnswers
+				m.challenge = nil
+				m.spinnerText = "Verifying..."
+				m.screen = spinnerScreen
+				return m, tea.Batch(m.spinner.Tick, waitForNative(m.nativeEvents))
+			}
+		}
+		var cmd tea.Cmd
+		m.challengeInput, cmd = m.challengeInput.Update(msg)
+		return m, cmd
+	case spinnerScreen:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "esc":
+				if m.cancelLogin != nil {
+					m.cancelLogin()
+				}
+				if m.loggingIn {
+					m.statusMsg = "Login to " + m.selectedHost + " cancelled."
+				}
+				m.loggingIn = false
+				m.retryPending = false
+				m.copyKey = ""
+				m.forgetPassword()
+				m.screen = listScreen
+				return m, nil
+			}
+			return m, nil
+		case tunnelStartedMsg:
+			if m.socksStarting {
+				m.socksStarting = false
+				m.screen = listScreen
+				if msg.err != nil {
+					m.statusMsg = "Could not start the SOCKS proxy: " + msg.err.Error()
+					return m, nil
+				}
+				m.socksProxy = &msg.tunnel
+				return m, nil
+			}
+			status := fmt.Sprintf("Tunnel started (pid %d).", msg.tunnel.PID)
+			if msg.err != nil {
+				status = "Could not start the tunnel: " + msg.err.Error()
+			}
+			if m.fromDashboard {
+				m.fromDashboard = false
+				m.screen = tunnelsScreen
+				m.tunnelStatus = status
+				m.refreshDashboard()
+				m.tunnelTick++
+				return m, tickTunnels(m.tunnelTick)
+			}
+			m.screen = forwardsScreen
+			m.forwardStatus = status
+			m.refreshTunnels()
+			return m, nil
+		case browserOpenedMsg:
+			if msg.err != nil {
+				m.screen = listScreen
+				m.statusMsg = "Could not browse files on " + m.selectedHost + ": " + msg.err.Error()
+				return m, nil
+			}
+			m.browser = newFileBrowser(msg.client)
+			m.screen = browserScreen
+			return m, m.browser.run(m.browser.dirs(), "", nil)
+		case retryLoginMsg:
+			if !m.retryPending {
+				// The login was cancelled or started over in the meantime
+				return m, nil
+			}
+			attempt := m.loginAttempt + 1
+			model, cmd := m.login(m.password)
+			m.loginAttempt = attempt
+			return model, cmd
+		case copyKeyMsg:
+			m.loggingIn = false
+			if msg.wrongPassword {
+				delete(m.sessionPasswords, m.target())
+				m.forgetPassword()
+				m.errMsg = "Login failed: wrong password."
+				m.screen = passwordScreen
+				return m, nil
+			}
+			key := filepath.Base(m.copyKey)
+			m.copyKey = ""
+			m.screen = listScreen
+			if msg.err != nil {
+				m.forgetPassword()
+				m.statusMsg = "Could not install " + key + ": " + msg.err.Error()
+				return m, nil
+			}
+			if m.remember && m.vault != nil {
+				m.rememberErr = m.vault.Set(m.target(), m.password)
+			}
+			if m.sessionPasswords != nil {
+				m.sessionPasswords[m.target()] = bytes.Clone(m.password)
+			}
+			m.forgetPassword()
+			m.statusMsg = "Installed " + key + " on " + m.selectedHost + "; the next connection can use the key."
+			return m, nil
+		case remoteConfigMsg:
+			m.screen = listScreen
+			if msg.err != nil {
+				m.statusMsg = "Could not fetch ~/.ssh/config from " + msg.source + ": " + msg.err.Error()
+				return m, nil
+			}
+			if len(msg.blocks) == 0 {
+				m.statusMsg = msg.source + " has no Host blocks in ~/.ssh/config."
+				return m, nil
+			}
+			m.importSource = msg.source
+			m.importBlocks = msg.blocks
+			m.importCursor = 0
+			m.errMsg = ""
+			m.screen = importScreen
+			return m, nil
+		case staleKnownHostsMsg:
+			m.screen = listScreen
+			if msg.err != nil {
+				m.statusMsg = "Could not read known_hosts: " + msg.err.Error()
+				return m, nil
+			}
+			if len(msg.entries) == 0 {
+				m.statusMsg = "No stale known_hosts entries found."
+				return m, nil
+			}
+			m.staleHosts = msg.entries
+			m.staleSelected = map[int]bool{}
+			m.staleCursor = 0
+			m.errMsg = ""
+			m.screen = cleanupScreen
+			return m, nil
+		case groupPickMsg:
+			if msg.err != nil {
+				m.screen = listScreen
+				m.statusMsg = "Could not pick a host: " + msg.err.Error()
+				return m, nil
+			}
+			m.selectHost(msg.host)
+			return m.connectSelected()
+		case hostKeyMsg:
+			if msg.err == nil && msg.status != hostKeyKnown {
+				m.hostKey = msg
+				m.errMsg = ""
+				m.screen = hostKeyScreen
+				return m, nil
+			}
+			// Unreachable hosts fail the login test with a clearer error
+			m.hostKeyVerified[msg.host] = msg.err == nil
+			return m, m.startLoginTest()
+		case loginResultMsg:
+			if msg.conflict != nil {
+				m.conflict = *msg.conflict
+				m.errMsg = ""
+				m.screen = conflictScreen
+				return m, nil
+			}
+			if msg.restricted {
+				// Retrying or asking for the password again would not help
+				recordHistory(m.config, historyEntry{Host: m.selectedHost, Time: time.Now(), Failed: true})
+				m.loggingIn = false
+				m.screen = listScreen
+				m.statusMsg = m.selectedHost + " accepted the login but does not allow a shell (forced command or restricted account)"
+				m.forgetPassword()
+				if msg.detail != "" {
+					m.statusMsg += ": " + msg.detail
+				}
+				return m, nil
+			}
+			return m.loginFinished(msg)
+		case challengeMsg:
+			if len(msg.questions) == 0 {
+				// Informational round, nothing to answer
+				msg.reply <- []string{}
+				return m, waitForNative(m.nativeEvents)
+			}
+			m.challenge = &msg
+			m.challengeAnswers = nil
+			m.nextChallengeQuestion()
+			m.screen = challengeScreen
+			return m, nil
+		case nativeLoginMsg:
+			if errors.Is(msg.err, errChallengeCancelled) {
+				m.loggingIn = false
+				m.screen = listScreen
+				return m, nil
+			}
+			if errors.Is(msg.err, errNativeProxyJump) {
+				m.loggingIn = false
+				m.forgetPassword()
+				m.screen = listScreen
+				m.statusMsg = m.selectedHost + ": " + msg.err.Error()
+				return m, nil
+			}
+			m.nativeClient = msg.client
+			result := loginResultMsg{success: msg.err == nil, err: msg.err}
+			if msg.err != nil {
+				result.failure = classifyLoginFailure(msg.err, "")
+			}
+			return m.loginFinished(result)
+		default:
+			var cmd tea.Cmd
+			m.spinner, cmd = m.spinner.Update(msg)
+			return m, cmd
+		}
+	}
+	return m, nil
+}
+
+// refreshInfoBox shows the details of the hovered host, probing its time zone when enabled
+func (m *model) refreshInfoBox() tea.Cmd {
+	if m.list.Index() >= len(m.list.Items()) {
+		return nil
+	}
+	selected, ok := m.list.Items()[m.list.Index()].(hostItem)
+	if !ok {
+		return nil
+	}
+	m.infoBox = getHostInfo(selected.host)
+
+	certs, ok := m.certs[selected.host]
+	if !ok {
+		certs = hostCertificates(selected.host)
+		m.certs[selected.host] = certs
+	}
+	for _, c := range certs {
+		m.infoBox += "\n" + c.describe(time.Now())
+	}
+
+	m.infoBox += "\n" + m.agentStatus(selected.host)
+
+	keys, ok := m.knownKeys[selected.host]
+	if !ok {
+		keys, _ = knownHostKeys(selected.host)
+		m.knownKeys[selected.host] = keys
+	}
+	for _, k := range keys {
+		m.infoBox += "\n" + describeHostKey(k, m.metadata[selected.host].HostKeyPin)
+	}
+	if slices.Contains(m.changedKeys, selected.host) {
+		m.infoBox += "\nHost key changed since the last run!"
+	}
+	if notes := m.metadata[selected.host].Notes; notes != "" {
+		m.infoBox += "\nNotes: " + notes
+	}
+
+	zone := m.metadata[selected.host].Timezone
+	if zone == "" {
+		zone = m.timezones[selected.host]
+	}
+	if zone != "" {
+		m.infoBox += "\n" + formatHostTime(zone, time.Now())
+		return nil
+	}
+	if m.config.ProbeTimezones && !m.tzProbed[selected.host] {
+		m.tzProbed[selected.host] = true
+		return probeTimezone(selected.host)
+	}
+	return nil
+}
+
+// pinHostKey pins the next known key of a host, cycling through its keys and back to no pin
+func (m *model) pinHostKey(host string) {
+	meta := m.metadata[host]
+	meta.HostKeyPin = nextPin(m.knownKeys[host], meta.HostKeyPin)
+	if meta.HostKeyPin == "" && m.metadata[host].HostKeyPin == "" {
+		m.statusMsg = "No known host key to pin for " + host + "; connect once to add it to known_hosts."
+		return
+	}
+	if err := saveHostKeyPin(host, meta.HostKeyPin); err != nil {
+		m.statusMsg = "Could not save the pin: " + err.Error()
+		return
+	}
+	m.metadata[host] = meta
+	// The pin applies from the next connection on
+	delete(m.hostKeyVerified, host)
+	if meta.HostKeyPin == "" {
+		m.statusMsg = "Host key of " + host + " unpinned."
+	} else {
+		m.statusMsg = "Pinned host key " + meta.HostKeyPin + " for " + host + "."
+	}
+}
+
+// keygenNext completes the current step of the key generation wizard
+func (m *model) keygenNext() (tea.Model, tea.Cmd) {
+	m.errMsg = ""
+	switch m.keygenStep {
+	case keygenPath:
+		path := strings.TrimSpace(m.keygenInput.Value())
+		if path == "" {
+			m.errMsg = "Enter a path for the new key."
+			return m, nil
+		}
+		if _, err := os.Stat(expandHome(path)); err == nil {
+			m.errMsg = path + " already exists."
+			return m, nil
+		}
+		m.keygenPath = path
+		m.keygenStep = keygenPassphrase
+		m.keygenInput.SetValue("")
+		m.keygenInput.EchoMode = textinput.EchoPassword
+	case keygenPassphrase:
+		m.keygenPhrase = m.keygenInput.Value()
+		m.keygenInput.SetValue("")
+		m.keygenStep = keygenConfirm
+	case keygenConfirm:
+		if m.keygenInput.Value() != m.keygenPhrase {
+			m.errMsg = "Passphrases do not match; enter it again."
+			m.keygenInput.SetValue("")
+			m.keygenPhrase = ""
+			m.keygenStep = keygenPassphrase
+			return m, nil
+		}
+		comment := m.selectedHost
+		if usr, err := user.Current(); err == nil {
+			if hostname, err := os.Hostname(); err == nil {
+				comment = usr.Username + "@" + hostname
+			}
+		}
+		err := generateKey(expandHome(m.keygenPath), m.keygenPhrase, comment)
+		m.keygenInput.SetValue("")
+		m.keygenPhrase = ""
+		if err != nil {
+			m.errMsg = "Could not create the key: " + err.Error()
+			m.keygenStep = keygenPassphrase
+			return m, nil
+		}
+		if err := setHostIdentityFile(m.selectedHost, m.keygenPath); err != nil {
+			m.errMsg = "Created the key, but could not set it as IdentityFile: " + err.Error()
+		}
+		// Certificates are looked up next to the identity files
+		clear(m.certs)
+		m.keygenStep = keygenDeploy
+	}
+	return m, nil
+}
+
+// startCopyKey installs a public key on the selected host, using the password
+// known from this run when there is one and asking for it otherwise
+func (m *model) startCopyKey(pubKey string) (tea.Model, tea.Cmd) {
+	m.copyKey = pubKey
+	// The key is installed over password authentication, whatever the host normally uses
+	m.keyFile = ""
+	m.securityKey = ""
+	if encryptedIdentity(m.selectedHost) == "" {
+		if pw, ok := m.sessionPasswords[m.target()]; ok {
+			return m.login(bytes.Clone(pw))
+		}
+		if m.vault != nil {
+			if pw, ok := m.vault.Get(m.target()); ok {
+				return m.login(pw)
+			}
+		}
+	}
+	m.pwInput.SetValue("")
+	m.errMsg = ""
+	m.remember = false
+	m.addKeyToAgent = false
+	m.screen = passwordScreen
+	return m, nil
+}
+
+// hostIdentities returns the IdentityFiles of a host, cached after the first lookup
+func (m *model) hostIdentities(host string) []string {
+	ids, ok := m.identities[host]
+	if !ok {
+		if target, err := resolveSSHTarget(host); err == nil {
+			ids = target.identityFiles
+		}
+		m.identities[host] = ids
+	}
+	return ids
+}
+
+// agentStatus describes for the info box whether the agent holds the host's keys
+func (m *model) agentStatus(host string) string {
+	keys, err := agentKeys()
+	if err != nil {
+		return "Agent: not running"
+	}
+	missing := missingFromAgent(m.hostIdentities(host), keys)
+	if len(missing) == 0 {
+		return fmt.Sprintf("Agent: %d keys loaded", len(keys))
+	}
+	return fmt.Sprintf("Agent: %d keys loaded, %s not loaded (A to add)", len(keys), filepath.Base(missing[0]))
+}
+
+// addHostKeyToAgent loads the first identity of host the agent lacks, asking for
+// its passphrase when it is encrypted
+func (m *model) addHostKeyToAgent(host string) (tea.Model, tea.Cmd) {
+	keys, err := agentKeys()
+	if err != nil {
+		m.statusMsg = "Could not reach ssh-agent: " + err.Error()
+		return m, nil
+	}
+	missing := missingFromAgent(m.hostIdentities(host), keys)
+	if len(missing) == 0 {
+		m.statusMsg = "The agent already holds the keys of " + host + "."
+		return m, nil
+	}
+	if keyNeedsPassphrase(missing[0]) {
+		m.selectHost(host)
+		m.agentKeyFile = missing[0]
+		m.agentInput.SetValue("")
+		m.errMsg = ""
+		m.screen = agentScreen
+		return m, nil
+	}
+	if err := addToAgent(missing[0], nil); err != nil {
+		m.statusMsg = "Could not add " + filepath.Base(missing[0]) + " to the agent: " + err.Error()
+		return m, nil
+	}
+	m.statusMsg = "Added " + filepath.Base(missing[0]) + " to the agent."
+	return m, m.refreshInfoBox()
+}
+
+// reloadHosts re-reads ~/.ssh/config into the host list
+func (m *model) reloadHosts() {
+	path, err := sshConfigPath()
+	if err != nil {
+		return
+	}
+	if hosts, err := parseSSHConfig(path); err == nil {
+		items := make([]list.Item, len(hosts))
+		for i, h := range hosts {
+			items[i] = h
+		}
+		m.fullList = nil
+		m.list.SetItems(items)
+		m.refreshList()
+	}
+}
+
+// mergeImport writes the selected imported blocks to ~/.ssh/config
+func (m *model) mergeImport() (tea.Model, tea.Cmd) {
+	path, err := sshConfigPath()
+	if err != nil {
+		m.errMsg = err.Error()
+		return m, nil
+	}
+	local, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		m.errMsg = "Could not read ~/.ssh/config: " + err.Error()
+		return m, nil
+	}
+	merged, n := mergeRemoteBlocks(string(local), m.importSource, m.importBlocks)
+	if n == 0 {
+		m.errMsg = "Nothing selected to import."
+		return m, nil
+	}
+	if err := os.WriteFile(path+".bak", local, 0600); err != nil {
+		m.errMsg = "Could not back up ~/.ssh/config: " + err.Error()
+		return m, nil
+	}
+	if err := os.WriteFile(path, []byte(merged), 0600); err != nil {
+		m.errMsg = "Could not write ~/.ssh/config: " + err.Error()
+		return m, nil
+	}
+	m.reloadHosts()
+	m.screen = listScreen
+	m.statusMsg = fmt.Sprintf("Imported %d hosts from %s; the previous config is kept as ~/.ssh/config.bak.", n, m.importSource)
+	return m, m.refreshInfoBox()
+}
+
+// compareHosts picks host as the first side of a comparison, or as the second
+// one, in which case the differences are shown
+func (m *model) compareHosts(host string) (tea.Model, tea.Cmd) {
+	switch m.diffHost {
+	case "":
+		m.diffHost = host
+		m.statusMsg = "Comparing " + host + ": select another host and press D."
+		return m, nil
+	case host:
+		m.diffHost = ""
+		m.statusMsg = "Comparison cancelled."
+		return m, nil
+	}
+	left, err := hostSettings(m.diffHost, m.metadata[m.diffHost])
+	if err == nil {
+		var right map[string]string
+		if right, err = hostSettings(host, m.metadata[host]); err == nil {
+			rows, same := diffSettings(left, right)
+			m.diffView = diffView(m.diffHost, host, rows, same)
+		}
+	}
+	if err != nil {
+		m.statusMsg = "Could not compare hosts: " + err.Error()
+		return m, nil
+	}
+	m.selectedHost = host
+	m.screen = diffScreen
+	return m, nil
+}
+
+// removeStaleHosts deletes the selected known_hosts entries and returns to the list
+func (m *model) removeStaleHosts() (tea.Model, tea.Cmd) {
+	if len(m.staleSelected) == 0 {
+		m.errMsg = "Select entries with space first."
+		return m, nil
+	}
+	lines := map[string]map[int]bool{}
+	for i := range m.staleSelected {
+		e := m.staleHosts[i]
+		if lines[e.file] == nil {
+			lines[e.file] = map[int]bool{}
+		}
+		lines[e.file][e.line] = true
+	}
+	for file, l := range lines {
+		if err := removeKnownHostsLines(file, l); err != nil {
+			m.errMsg = "Could not update known_hosts: " + err.Error()
+			return m, nil
+		}
+	}
+	clear(m.knownKeys)
+	clear(m.hostKeyVerified)
+	m.screen = listScreen
+	m.statusMsg = fmt.Sprintf("Removed %d known_hosts entries; the previous file is kept as known_hosts.old.", len(m.staleSelected))
+	return m, m.refreshInfoBox()
+}
+
+// connectSelected moves on from the host list, unlocking the vault first when it is enabled
+func (m *model) connectSelected() (tea.Model, tea.Cmd) {
+	m.errMsg = ""
+	if m.confirmedHost != m.selectedHost {
+		for _, c := range hostCertificates(m.selectedHost) {
+			if c.expired(time.Now()) {
+				// Ask once; selecting the host again connects anyway
+				m.confirmedHost = m.selectedHost
+				m.screen = listScreen
+				m.statusMsg = fmt.Sprintf("Warning: certificate %s expired on %s. Press enter again to connect anyway.",
+					c.source, c.validBefore.Format("2006-01-02 15:04"))
+				return m, nil
+			}
+		}
+	}
+	m.confirmedHost = ""
+	m.direct = m.config.SkipLoginTest || m.metadata[m.selectedHost].SkipLoginTest
+	if m.direct {
+		// Only the host key is checked; ssh asks for whatever it needs in the session
+		m.keyFile = ""
+		m.securityKey = ""
+		return m.login(nil)
+	}
+	if m.config.SecretBackend == "vault" && m.vault == nil {
+		m.unlockInput.SetValue("")
+		m.screen = unlockScreen
+		if m.config.BiometricUnlock {
+			m.biometricPending = true
+			return m, tryBiometricUnlock()
+		}
+		return m, nil
+	}
+	return m.askPassword()
+}
+
+// askPassword logs in with a stored password when there is one, otherwise shows the password screen
+func (m *model) askPassword() (tea.Model, tea.Cmd) {
+	m.connectOpts = connectOptions{}
+	// Security keys need a touch, not a password
+	m.securityKey = securityKeyIdentity(m.selectedHost)
+	if m.securityKey != "" {
+		m.keyFile = ""
+		return m.login(nil)
+	}
+	// Without an agent, an encrypted key needs its passphrase instead of the host password
+	m.keyFile = encryptedIdentity(m.selectedHost)
+	if pw, ok := m.sessionPasswords[m.target()]; ok {
+		return m.login(bytes.Clone(pw))
+	}
+	if m.vault != nil {
+		if pw, ok := m.vault.Get(m.target()); ok {
+			return m.login(pw)
+		}
+	}
+	m.pwInput.SetValue("")
+	m.errMsg = ""
+	m.remember = false
+	m.addKeyToAgent = false
+	m.screen = passwordScreen
+	return m, nil
+}
+
+// login switches to the spinner screen and tests the password against the selected host
+func (m *model) login(password []byte) (tea.Model, tea.Cmd) {
+	m.password = password
+	// Hide a revealed password again before the screen can come back after a failure,
+	// and drop the typed copy
+	m.pwInput.EchoMode = textinput.EchoPassword
+	m.pwInput.SetValue("")
+	m.errMsg = ""
+	m.spinnerText = "Logging in..."
+	m.screen = spinnerScreen
+	m.loggingIn = true
+	m.loginStarted = time.Now()
+	m.loginAttempt = 1
+	m.retryPending = false
+	if m.cancelLogin != nil {
+		m.cancelLogin()
+	}
+	m.loginCtx, m.cancelLogin = context.WithCancel(context.Background())
+	// The key is checked for the port actually connected to
+	if !m.hostKeyVerified[m.target()] {
+		m.spinnerText = "Checking host key..."
+		return m, tea.Batch(m.spinner.Tick, checkHostKey(m.loginCtx, m.target(), m.metadata[m.selectedHost].HostKeyPin, m.config.connectTimeout(), jumpArgs(m.jumpHost)))
+	}
+	return m, m.startLoginTest()
+}
+
+// startLoginTest tests the credentials of the selected host, whose host key is verified
+func (m *model) startLoginTest() tea.Cmd {
+	m.spinnerText = "Logging in..."
+	m.screen = spinnerScreen
+	if m.copyKey != "" {
+		m.spinnerText = "Installing " + filepath.Base(m.copyKey) + "..."
+		return tea.Batch(m.spinner.Tick, copyPublicKey(m.loginCtx, m.target(), m.password, m.copyKey, m.config.connectTimeout()))
+	}
+	if m.direct {
+		m.loggingIn = false
+		if m.browse {
+			return m.openBrowser()
+		}
+		if m.transfer {
+			return m.startTransfer()
+		}
+		if m.openTunnel != nil {
+			return m.launchTunnel()
+		}
+		if m.snippet != nil {
+			return m.startSnippet()
+		}
+		m.shouldSSH = true
+		return tea.Quit
+	}
+	if m.securityKey != "" {
+		// ssh has the terminal while the key is touched, so the log is read afterwards
+		m.spinnerText = "Logging in... touch your security key (" + m.securityKey + ")"
+		args, debugLog := m.loginArgs(), ""
+		if m.debugLogin {
+			if path, err := newDebugLog(); err == nil {
+				args, debugLog = append(args, debugLogArgs(path)...), path
+			}
+		}
+		return trySecurityKeyLogin(m.loginCtx, m.target(), m.config.connectTimeout(), args, debugLog)
+	}
+	if m.metadata[m.selectedHost].NativeClient {
+		m.nativeEvents = make(chan tea.Msg)
+		return tea.Batch(m.spinner.Tick, nativeLogin(m.target(), string(m.password), m.keyFile, m.config.connectTimeout(), m.nativeEvents), waitForNative(m.nativeEvents))
+	}
+	return tea.Batch(m.spinner.Tick, m.debugLogged(func(args []string) tea.Cmd {
+		return tryLogin(m.loginCtx, m.target(), m.password, m.keyFile, m.config.connectTimeout(), args)
+	}))
+}
+
+// debugLogged starts a login test with the login arguments, logging it with
+// ssh -vvv when debugLogin is on
+func (m *model) debugLogged(test func(args []string) tea.Cmd) tea.Cmd {
+	if !m.debugLogin {
+		return test(m.loginArgs())
+	}
+	path, err := newDebugLog()
+	if err != nil {
+		return test(m.loginArgs())
+	}
+	return withDebugLog(test(append(m.loginArgs(), debugLogArgs(path)...)), path)
+}
+
+// loginFinished quits the TUI to start the session after a successful login,
+// or explains why it failed: on the password screen when another password may
+// help, otherwise under the host list
+func (m *model) loginFinished(result loginResultMsg) (tea.Model, tea.Cmd) {
+	m.loggingIn = false
+	if result.success {
+		if m.remember && m.vault != nil {
+			m.rememberErr = m.vault.Set(m.target(), m.password)
+		}
+		if m.addKeyToAgent && m.keyFile != "" {
+			m.agentErr = addToAgent(m.keyFile, m.password)
+		}
+		if m.saveJump {
+			m.jumpErr = setHostProxyJump(m.selectedHost, m.jumpHost)
+			m.saveJump = false
+		}
+		if m.sessionPasswords != nil {
+			m.sessionPasswords[m.target()] = bytes.Clone(m.password)
+		}
+		if m.browse {
+			return m, m.openBrowser()
+		}
+		if m.transfer {
+			return m, m.startTransfer()
+		}
+		if m.openTunnel != nil {
+			return m, m.launchTunnel()
+		}
+		if m.snippet != nil {
+			return m, m.startSnippet()
+		}
+		// Success: set flag and quit TUI
+		m.shouldSSH = true
+		return m, tea.Quit
+	}
+	recordHistory(m.config, historyEntry{Host: m.selectedHost, Time: time.Now(), Failed: true})
+	message := loginFailureMessage(result.failure, m.selectedHost, m.keyFile != "", result.detail)
+	if transientFailure(result.failure) && m.loginAttempt < loginAttempts && !m.config.DisableLoginRetry {
+		// Keep the password and the spinner screen for the next attempt
+		m.loggingIn = true
+		m.retryPending = true
+		m.spinnerText = fmt.Sprintf("%s Retrying in %s (attempt %d of %d)...",
+			message, backoff(m.loginAttempt+1), m.loginAttempt+1, loginAttempts)
+		return m, scheduleRetry(m.loginAttempt + 1)
+	}
+	m.forgetPassword()
+	if !credentialFailure(result.failure) {
+		m.screen = listScreen
+		m.statusMsg = message
+		m.showDebugLog(result.debugLog)
+		return m, nil
+	}
+	// A cached password that stopped working must not be retried
+	delete(m.sessionPasswords, m.target())
+	// Failure: go back to password input with error
+	m.screen = passwordScreen
+	m.errMsg = message
+	m.pwInput.SetValue("")
+	m.showDebugLog(result.debugLog)
+	return m, nil
+}
+
+// showDebugLog shows the -vvv log of a failed login test, if there is one,
+// before the screen that reports the failure
+func (m *model) showDebugLog(log string) {
+	if log == "" {
+		return
+	}
+	h, v := docStyle.GetFrameSize()
+	m.debugView = viewport.New(max(20, m.width-h), max(5, m.height-v-4))
+	m.debugView.SetContent(log)
+	m.debugView.GotoBottom()
+	m.debugReturn = m.screen
+	m.screen = debugLogScreen
+}
+
+// openBrowser starts the SFTP session of the file browser after a successful login,
+// over the connection of the built-in client or, for ssh, the login test's connection
+func (m *model) openBrowser() tea.Cmd {
+	m.spinnerText = "Opening files on " + m.selectedHost + "..."
+	m.screen = spinnerScreen
+	if m.nativeClient != nil {
+		conn := m.nativeClient
+		m.nativeClient = nil
+		m.forgetPassword()
+		return tea.Batch(m.spinner.Tick, openNativeSFTP(conn))
+	}
+	var password []byte
+	if m.securityKey == "" && !m.direct {
+		password = bytes.Clone(m.password)
+	}
+	keyFile := m.keyFile
+	m.forgetPassword()
+	return tea.Batch(m.spinner.Tick, openSFTP(m.target(), m.loginArgs(), password, keyFile))
+}
+
+// startTransfer runs the copy set up on transferScreen after a successful login,
+// over the login test's connection or with the verified password
+func (m *model) startTransfer() tea.Cmd {
+	m.transfer = false
+	m.transferRunning = true
+	m.transferProgress = transferProgressMsg{}
+	m.transferStarted = time.Now()
+	m.screen = transferScreen
+	opts := m.loginArgs()
+	var password []byte
+	if m.securityKey == "" && !m.direct {
+		password = bytes.Clone(m.password)
+	} else {
+		// Nothing can answer a prompt while the TUI is showing
+		opts = append([]string{"-o", "BatchMode=yes"}, opts...)
+	}
+	keyFile := m.keyFile
+	m.forgetPassword()
+	tool, args := transferArgs(m.transferSpec, m.loginUser, m.selectedHost, m.loginPort, opts)
+	var ctx context.Context
+	ctx, m.transferCancel = context.WithCancel(context.Background())
+	m.transferEvents = make(chan tea.Msg)
+	go runTransfer(ctx, tool, args, password, keyFile, m.transferEvents)
+	return tea.Batch(m.spinner.Tick, waitForTransfer(m.transferEvents))
+}
+
+// launchTunnel starts a tunnel with the forwards chosen on forwardsScreen or
+// with S after a successful login, authenticating like the login test did
+func (m *model) launchTunnel() tea.Cmd {
+	forwards := m.openTunnel
+	m.openTunnel = nil
+	m.spinnerText = "Starting tunnel to " + m.selectedHost + "..."
+	m.screen = spinnerScreen
+	opts := append(m.connectOpts.connectionArgs(), keepaliveArgs(m.metadata[m.selectedHost], m.config)...)
+	opts = append(opts, jumpArgs(m.jumpHost)...)
+	var password []byte
+	if m.securityKey == "" && !m.direct {
+		password = bytes.Clone(m.password)
+	}
+	host, target, keyFile := m.selectedHost, m.target(), m.keyFile
+	m.forgetPassword()
+	return tea.Batch(m.spinner.Tick, func() tea.Msg {
+		t, err := startTunnel(host, target, forwards, opts, password, keyFile)
+		return tunnelStartedMsg{tunnel: t, err: err}
+	})
+}
+
+// refreshTunnels lists the running tunnels of the selected host and keeps the cursor in range
+func (m *model) refreshTunnels() {
+	var err error
+	if m.tunnels, err = listTunnels(m.selectedHost); err != nil {
+		m.forwardStatus = "Could not list tunnels: " + err.Error()
+	}
+	m.forwardCursor = max(0, min(m.forwardCursor, len(m.forwards)+len(m.tunnels)-1))
+}
+
+// refreshDashboard lists the tunnels of all hosts and keeps the cursor in range
+func (m *model) refreshDashboard() {
+	tunnels, err := listTunnels("")
+	if err != nil {
+		m.tunnelStatus = "Could not list tunnels: " + err.Error()
+	}
+	m.tunnelRows = tunnelRows(tunnels, m.config.Forwards)
+	m.tunnelCursor = max(0, min(m.tunnelCursor, len(m.tunnelRows)-1))
+	m.socksProxy = findSocksProxy(tunnels, m.config.socksPort())
+}
+
+// loadSnippetLibrary reads snippets.yaml again, keeping the cursor in range
+func (m *model) loadSnippetLibrary() {
+	var err error
+	if m.snippets, err = loadSnippets(); err != nil {
+		m.snippetStatus = "Could not read the snippets: " + err.Error()
+	}
+	m.snippetCursor = max(0, min(len(m.snippets)-1, m.snippetCursor))
+}
+
+// runSnippetOnHost runs a snippet on the selected host after a login test
+func (m *model) runSnippetOnHost(s snippet) (tea.Model, tea.Cmd) {
+	m.selectHost(m.selectedHost)
+	m.snippet = &s
+	return m.connectSelected()
+}
+
+// startSnippet runs the snippet picked on snippetsScreen after a successful
+// login, over the login test's connection or with the verified password
+func (m *model) startSnippet() tea.Cmd {
+	s := *m.snippet
+	m.snippet = nil
+	m.screen = snippetsScreen
+	m.snippetRunning = true
+	m.snippetStatus = "Running " + s.Name + " on " + m.selectedHost + "..."
+	if m.nativeClient != nil {
+		client := m.nativeClient
+		m.nativeClient = nil
+		m.forgetPassword()
+		m.snippetCancel = func() { client.Close() }
+		return tea.Batch(m.spinner.Tick, runNativeSnippet(client, s, m.config.execMaxOutput()))
+	}
+	var password []byte
+	if m.securityKey == "" && !m.direct {
+		password = bytes.Clone(m.password)
+	}
+	keyFile := m.keyFile
+	m.forgetPassword()
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.execTimeout())
+	m.snippetCancel = cancel
+	return tea.Batch(m.spinner.Tick, runSnippet(ctx, s, m.target(), m.loginArgs(), password, keyFile, m.config.execMaxOutput()))
+}
+
+// startDashboardTunnel starts a tunnel with the forwards of row: a daemon is
+// started again as daemon, anything else after a login test of its host
+func (m *model) startDashboardTunnel(row tunnelRow) (tea.Model, tea.Cmd) {
+	if row.running != nil && row.running.Daemon {
+		m.fromDashboard = true
+		m.spinnerText = "Starting tunnel daemon for " + row.host + "..."
+		m.screen = spinnerScreen
+		return m, tea.Batch(m.spinner.Tick, func() tea.Msg {
+			dir, err := tunnelDir()
+			if err != nil {
+				return tunnelStartedMsg{err: err}
+			}
+			pid, _, err := startTunnelDaemon(dir, row.host, row.forwards)
+			return tunnelStartedMsg{tunnel: tunnel{Host: row.host, Forwards: row.forwards, PID: pid}, err: err}
+		})
+	}
+	if m.metadata[row.host].NativeClient {
+		m.tunnelStatus = "Tunnels run ssh, which cannot answer the prompts of hosts using the built-in client."
+		return m, nil
+	}
+	m.selectHost(row.host)
+	m.openTunnel = slices.Clone(row.forwards)
+	m.fromDashboard = true
+	return m.connectSelected()
+}
+
+// forgetPassword wipes the secret of the current login once it was used or rejected
+func (m *model) forgetPassword() {
+	clear(m.password)
+	m.password = nil
+}
+
+// nextChallengeQuestion prepares the input for the next unanswered keyboard-interactive question
+func (m *model) nextChallengeQuestion() {
+	i := len(m.challengeAnswers)
+	m.challengeInput.SetValue("")
+	m.challengeInput.EchoMode = textinput.EchoPassword
+	if m.challenge.echos[i] {
+		m.challengeInput.EchoMode = textinput.EchoNormal
+	}
+}
+
+// target returns what the connection is made to: the host alias, prefixed with the
+// user when one was chosen for this connection. A port chosen for the connection
+// makes it an ssh:// URI, which ssh, including ssh -G, accepts in place of a host.
+func (m *model) target() string {
+	target := m.selectedHost
+	if m.loginUser != "" {
+		target = m.loginUser + "@" + target
+	}
+	if m.loginPort != 0 {
+		target = fmt.Sprintf("ssh://%s:%d", target, m.loginPort)
+	}
+	return target
+}
+
+// selectHost makes host the target of the next connection
+func (m *model) selectHost(host string) {
+	m.selectedHost = host
+	m.loginUser = ""
+	m.loginPort = 0
+	m.jumpHost = ""
+	m.saveJump = false
+	m.sftp = false
+	m.browse = false
+	m.transfer = false
+	m.openTunnel = nil
+	m.socksStarting = false
+	m.fromDashboard = false
+	m.snippet = nil
+	m.selectedDesc = ""
+	for _, h := range m.hostItems() {
+		if h.host == host {
+			m.selectedDesc = h.desc
+		}
+	}
+}
+
+// hostItems returns all hosts in config order, regardless of the current filter,
+// the tag filter or collapsed sections
+func (m *model) hostItems() []hostItem {
+	if m.fullList != nil {
+		return slices.Clone(m.fullList)
+	}
+	var hosts []hostItem
+	for _, it := range m.list.Items() {
+		if h, ok := it.(hostItem); ok {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// setHost replaces a host in the list and among the hosts it hides
+func (m *model) setHost(h hostItem) {
+	for i, it := range m.list.Items() {
+		if l, ok := it.(hostItem); ok && l.host == h.host {
+			m.list.SetItem(i, h)
+		}
+	}
+	for i := range m.fullList {
+		if m.fullList[i].host == h.host {
+			m.fullList[i] = h
+		}
+	}
+}
+
+// unlockVault opens the vault with the master password, creating it on first use
+func unlockVault(master string) (*vault, error) {
+	if master == "" {
+		return nil, fmt.Errorf("master password cannot be empty")
+	}
+	path, err := vaultPath()
+	if err != nil {
+		return nil, err
+	}
+	if vaultExists(path) {
+		return openVault(path, master)
+	}
+	return createVault(path, master)
+}
+
+func tryLogin(ctx context.Context, host string, password []byte, keyFile string, timeout time.Duration, mux []string) tea.Cmd {
+	return func() tea.Msg {
+		// Try to SSH with sshpass and a quick command (exit)
+		args := append([]string{"-o", "StrictHostKeyChecking=yes", "-o", "BatchMode=no",
+			"-o", connectTimeoutOption(timeout)}, mux...)
+		cmd, secret, err := sshpassCommand(ctx, password, keyFile, append(args, host, "exit")...)
+		if err != nil {
+			return loginResultMsg{err: err}
+		}
+		defer secret.Close()
+		var stderr bytes.Buffer
+		cmd.Stdin = nil
+		cmd.Stdout = nil
+		cmd.Stderr = &stderr
+		// A master connection left running can hold stderr open after ssh exits
+		cmd.WaitDelay = time.Second
+		return loginResult(cmd.Run(), stderr.String())
+	}
+}
+
+// loginResult classifies the outcome of a login test from its error and stderr
+func loginResult(err error, stderr string) loginResultMsg {
+	// ErrWaitDelay means ssh succeeded but the master connection it left
+	// behind still holds stderr open
+	if err == nil || errors.Is(err, exec.ErrWaitDelay) {
+		return loginResultMsg{success: true}
+	}
+	if c, ok := parseHostKeyConflict(stderr); ok {
+		return loginResultMsg{err: err, conflict: &c}
+	}
+	if detail, restricted := restrictedAccountDetail(err, stderr); restricted {
+		return loginResultMsg{err: err, restricted: true, detail: detail}
+	}
+	detail := ""
+	if strings.TrimSpace(stderr) != "" {
+		detail = lastLine(stderr)
+	}
+	return loginResultMsg{err: err, failure: classifyLoginFailure(err, stderr), detail: detail}
+}
+
+// backKeys is the help for screens whose only action is going back
+func (m *model) backKeys() PasswordKeyMap {
+	return PasswordKeyMap{Esc: m.keys.Esc}
+}
+
+func (m *model) passwordHelpBar() string {
+	// Use the same style as the main list view's help text
+	helpStyle := m.list.Styles.HelpStyle
+	return helpStyle.Render("    esc    go back")
+}
+
+func (m *model) View() string {
+	switch m.screen {
+	case listScreen:
+		// Create info box style
+		infoBoxStyle := lipgloss.NewStyle().
+			Width(60).
+			Height(10).
+			Align(lipgloss.Left, lipgloss.Top).
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderForeground(lipgloss.Color("69")).
+			Padding(1, 1)
+
+		// Create the info box content
+		infoBox := infoBoxStyle.Render(m.infoBox)
+
+		// Join list and info box horizontally
+		content := lipgloss.JoinHorizontal(lipgloss.Top, m.list.View(), "  ", infoBox)
+
+		var b strings.Builder
+		b.WriteString(content)
+		b.WriteString("\n")
+		if len(m.changedKeys) > 0 {
+			warning := lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("#FFFFFF")).
+				Background(lipgloss.Color("#D70000")).
+				Padding(0, 1)
+			b.WriteString(warning.Render("Host key changed since the last run: " + strings.Join(m.changedKeys, ", ") + " — verify before connecting"))
+			b.WriteString("\n")
+		}
+		if m.statusMsg != "" {
+			b.WriteString(m.list.Styles.StatusBar.Render(m.statusMsg))
+			b.WriteString("\n")
+		}
+		if status := m.selectionStatus(); status != "" {
+			b.WriteString(m.list.Styles.StatusBar.Render(status))
+			b.WriteString("\n")
+		}
+		if m.tagBar || len(m.tagFilter) > 0 {
+			b.WriteString(m.tagBarView())
+			b.WriteString("\n")
+		}
+		if m.favoritesOnly {
+			b.WriteString(m.list.Styles.StatusBar.Render("Favorites only (ctrl+f lists all hosts)"))
+			b.WriteString("\n")
+		}
+		b.WriteString(m.list.Styles.StatusBar.Render(m.sortStatus()))
+		b.WriteString("\n")
+		if err := m.regexFilterError(); err != "" {
+			b.WriteString(m.list.Styles.StatusBar.Render(err))
+			b.WriteString("\n")
+		}
+		if p := m.socksProxy; p != nil {
+			b.WriteString(m.list.Styles.StatusBar.Render(fmt.Sprintf("SOCKS proxy via %s on %s, up %s (S to stop)",
+				p.Host, p.Forwards[0].localAddress(), time.Since(p.Started).Round(time.Minute))))
+			b.WriteString("\n")
+		}
+		b.WriteString(m.help.View(m.listKeys))
+		return docStyle.Render(b.String())
+	case passwordScreen:
+		var b strings.Builder
+
+		// Styled header with host name
+		header := m.target()
+		if m.jumpHost != "" {
+			header += " via " + m.jumpHost
+		}
+		if m.sftp {
+			header = "sftp " + header
+		}
+		header = headerStyle.Render(header)
+		b.WriteString(header)
+		b.WriteString("\n")
+
+		// Error message if any
+		if m.errMsg != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(m.errMsg))
+			b.WriteString("\n\n")
+		}
+
+		// "Enter password:" text styled like help text
+		helpStyle := lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{
+			Light: "#B2B2B2",
+			Dark:  "#4A4A4A",
+		})
+		if m.copyKey != "" {
+			b.WriteString(helpStyle.Render("enter password to install " + filepath.Base(m.copyKey) + ":"))
+		} else if m.keyFile != "" {
+			b.WriteString(helpStyle.Render("enter passphrase for key " + m.keyFile + ":"))
+		} else {
+			b.WriteString(helpStyle.Render("enter password:"))
+		}
+		b.WriteString("\n")
+
+		// Password input field
+		b.WriteString(m.pwInput.View())
+		b.WriteString("\n\n")
+
+		if m.vault != nil {
+			checkbox := "[ ]"
+			if m.remember {
+				checkbox = "[x]"
+			}
+			b.WriteString(helpStyle.Render(checkbox + " remember password for this host"))
+			b.WriteString("\n\n")
+		}
+		keys := m.keys
+		keys.AddToAgent.SetEnabled(m.keyFile != "")
+		if m.keyFile != "" {
+			checkbox := "[ ]"
+			if m.addKeyToAgent {
+				checkbox = "[x]"
+			}
+			b.WriteString(helpStyle.Render(checkbox + " add the key to ssh-agent after logging in"))
+			b.WriteString("\n\n")
+		}
+		b.WriteString(helpStyle.Render(m.connectOpts.String()))
+		b.WriteString("\n")
+		checkbox := "[ ]"
+		if m.debugLogin {
+			checkbox = "[x]"
+		}
+		b.WriteString(helpStyle.Render(checkbox + " -vvv log of the login test, shown when it fails"))
+		b.WriteString("\n\n")
+
+		// Help bar using the same system as the main list view
+		b.WriteString(m.help.View(keys))
+		return docStyle.Render(b.String())
+	case unlockScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("vault"))
+		b.WriteString("\n")
+		if m.errMsg != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(m.errMsg))
+			b.WriteString("\n\n")
+		}
+		prompt := "enter master password:"
+		if path, err := vaultPath(); err == nil && !vaultExists(path) {
+			prompt = "choose a master password for the new vault:"
+		} else if m.biometricPending {
+			prompt = "waiting for Touch ID (or enter master password):"
+		}
+		helpStyle := lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{
+			Light: "#B2B2B2",
+			Dark:  "#4A4A4A",
+		})
+		b.WriteString(helpStyle.Render(prompt))
+		b.WriteString("\n")
+		b.WriteString(m.unlockInput.View())
+		b.WriteString("\n\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case hostKeyScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render(m.hostKey.host))
+		b.WriteString("\n")
+		errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+		if m.errMsg != "" {
+			b.WriteString(errStyle.Render(m.errMsg))
+			b.WriteString("\n\n")
+		}
+		keyType, _ := keyTypeAndBits(m.hostKey.key)
+		if m.hostKey.status == hostKeyPinMismatch {
+			b.WriteString(errStyle.Bold(true).Render("WARNING: THE HOST KEY DOES NOT MATCH THE PINNED KEY!"))
+			b.WriteString("\n")
+			b.WriteString(fmt.Sprintf("Pinned:    %s\nPresented: %s %s\n\n", m.hostKey.pin, keyType, ssh.FingerprintSHA256(m.hostKey.key)))
+			b.WriteString("The connection is blocked. If the key change is expected, update or remove\n")
+			b.WriteString("\"host_key_pin\" in hosts.json, or unpin the host with P in the list.\n\n")
+			b.WriteString(m.help.View(m.backKeys()))
+			return docStyle.Render(b.String())
+		}
+		if m.hostKey.status == hostKeyChanged {
+			b.WriteString(errStyle.Bold(true).Render("WARNING: THE HOST KEY HAS CHANGED!"))
+			b.WriteString("\n")
+			b.WriteString("Someone could be eavesdropping on you (man-in-the-middle attack),\nor the host key has just been changed.\n\n")
+		} else {
+			b.WriteString("The authenticity of this host can't be established.\n\n")
+		}
+		b.WriteString(fmt.Sprintf("%s key fingerprint is %s\n", keyType, ssh.FingerprintSHA256(m.hostKey.key)))
+		b.WriteString(randomArt(m.hostKey.key))
+		b.WriteString("\n\n")
+		b.WriteString(fmt.Sprintf("Add it to %s? (y/n)\n\n", m.hostKey.knownHosts))
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case conflictScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render(m.selectedHost))
+		b.WriteString("\n")
+		errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+		if m.errMsg != "" {
+			b.WriteString(errStyle.Render(m.errMsg))
+			b.WriteString("\n\n")
+		}
+		b.WriteString(errStyle.Bold(true).Render("WARNING: THE HOST KEY OF " + m.conflict.host + " HAS CHANGED!"))
+		b.WriteString("\n")
+		b.WriteString("ssh refused to connect because the key no longer matches this known_hosts entry:\n\n")
+		b.WriteString(fmt.Sprintf("%s:%d\n", m.conflict.file, m.conflict.line))
+		if m.conflict.entry != "" {
+			b.WriteString("  " + m.conflict.entry + "\n")
+		}
+		b.WriteString("\nOnly remove it if you know why the key changed. The new key is shown for\nverification before connecting again.\n\n")
+		b.WriteString("Remove the old key with ssh-keygen -R? (y/n)\n\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case cleanupScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("known_hosts cleanup"))
+		b.WriteString("\n")
+		if m.errMsg != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(m.errMsg))
+			b.WriteString("\n\n")
+		}
+		b.WriteString(fmt.Sprintf("%d entries match no host in ~/.ssh/config or do not resolve:\n\n", len(m.staleHosts)))
+		for i, e := range m.staleHosts {
+			cursor, check := "  ", "[ ]"
+			if i == m.staleCursor {
+				cursor = "> "
+			}
+			if m.staleSelected[i] {
+				check = "[x]"
+			}
+			b.WriteString(cursor + check + " " + e.describe() + "\n")
+		}
+		b.WriteString("\n")
+		b.WriteString(m.help.View(CleanupKeyMap{
+			Toggle: key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "select")),
+			All:    key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "select all")),
+			Remove: key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "remove selected")),
+			Esc:    m.keys.Esc,
+		}))
+		return docStyle.Render(b.String())
+	case keygenScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("new key for " + m.selectedHost))
+		b.WriteString("\n")
+		if m.errMsg != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(m.errMsg))
+			b.WriteString("\n\n")
+		}
+		helpStyle := lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{
+			Light: "#B2B2B2",
+			Dark:  "#4A4A4A",
+		})
+		switch m.keygenStep {
+		case keygenPath:
+			b.WriteString(helpStyle.Render("save the ed25519 key as:"))
+			b.WriteString("\n" + m.keygenInput.View() + "\n\n")
+		case keygenPassphrase:
+			b.WriteString(helpStyle.Render("passphrase for " + m.keygenPath + " (empty for none):"))
+			b.WriteString("\n" + m.keygenInput.View() + "\n\n")
+		case keygenConfirm:
+			b.WriteString(helpStyle.Render("repeat the passphrase:"))
+			b.WriteString("\n" + m.keygenInput.View() + "\n\n")
+		case keygenDeploy:
+			if m.errMsg == "" {
+				b.WriteString(fmt.Sprintf("Created %s and added it as the first IdentityFile of %s.\n\n", m.keygenPath, m.selectedHost))
+			}
+			b.WriteString(fmt.Sprintf("Install the public key on %s now? (y/n)\n\n", m.selectedHost))
+		}
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case pubKeyScreen:
+		var b strings.Builder
+		if m.deployHosts != nil {
+			b.WriteString(headerStyle.Render(fmt.Sprintf("copy public key to %d marked hosts", len(m.deployHosts))))
+		} else {
+			b.WriteString(headerStyle.Render("copy public key to " + m.selectedHost))
+		}
+		b.WriteString("\n")
+		for i, k := range m.pubKeys {
+			cursor := "  "
+			if i == m.pubKeyCursor {
+				cursor = "> "
+			}
+			b.WriteString(cursor + k + "\n")
+		}
+		b.WriteString("\nThe key is added to ~/.ssh/authorized_keys on the host, logging in with its password.\n\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case agentScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render(m.selectedHost))
+		b.WriteString("\n")
+		if m.errMsg != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(m.errMsg))
+			b.WriteString("\n\n")
+		}
+		helpStyle := lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{
+			Light: "#B2B2B2",
+			Dark:  "#4A4A4A",
+		})
+		b.WriteString(helpStyle.Render("enter passphrase to add " + m.agentKeyFile + " to ssh-agent:"))
+		b.WriteString("\n")
+		b.WriteString(m.agentInput.View())
+		b.WriteString("\n\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case importScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("import ~/.ssh/config from " + m.importSource))
+		b.WriteString("\n")
+		if m.errMsg != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(m.errMsg))
+			b.WriteString("\n\n")
+		}
+		for i, block := range m.importBlocks {
+			cursor, check := "  ", "[ ]"
+			if i == m.importCursor {
+				cursor = "> "
+			}
+			if block.selected && block.status != blockSame {
+				check = "[x]"
+			} else if block.status == blockSame {
+				check = "   "
+			}
+			b.WriteString(cursor + check + " " + block.describe(m.importSource) + "\n")
+		}
+		if block := m.importBlocks[m.importCursor]; block.status != blockNew {
+			b.WriteString("\n" + strings.Join(block.lines, "\n") + "\n")
+		}
+		b.WriteString("\n")
+		b.WriteString(m.help.View(ImportKeyMap{
+			Toggle:  key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "select")),
+			Resolve: key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "replace/rename")),
+			Import:  key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "import selected")),
+			Esc:     m.keys.Esc,
+		}))
+		return docStyle.Render(b.String())
+	case keysScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("ssh keys"))
+		b.WriteString("\n")
+		b.WriteString(m.keysView)
+		b.WriteString("\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case qrScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render(m.selectedHost))
+		b.WriteString("\n")
+		b.WriteString(m.qrView)
+		b.WriteString("\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case diffScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render(m.diffHost + " vs " + m.selectedHost))
+		b.WriteString("\n")
+		b.WriteString(m.diffView)
+		b.WriteString("\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case overrideScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("connect to " + m.selectedHost))
+		b.WriteString("\n")
+		if m.errMsg != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(m.errMsg))
+			b.WriteString("\n\n")
+		}
+		helpStyle := lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{
+			Light: "#B2B2B2",
+			Dark:  "#4A4A4A",
+		})
+		prompt := "user for this connection only:"
+		if m.overridePort {
+			prompt = "port for this connection only:"
+		}
+		b.WriteString(helpStyle.Render(prompt))
+		b.WriteString("\n")
+		b.WriteString(m.overrideInput.View())
+		b.WriteString("\n\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case forwardsScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("port forwards for " + m.selectedHost))
+		b.WriteString("\n")
+		b.WriteString(forwardsView(m.forwards, m.forwardOn, m.tunnels, m.forwardCursor))
+		b.WriteString("\n")
+		if m.forwardAdding {
+			b.WriteString("new forward (L, R or D as for ssh): " + m.forwardInput.View() + "\n\n")
+		}
+		if m.forwardStatus != "" {
+			b.WriteString(m.forwardStatus + "\n\n")
+		}
+		b.WriteString(m.help.View(ForwardsKeyMap{
+			Toggle: key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "select")),
+			Add:    key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "add")),
+			Save:   key.NewBinding(key.WithKeys("w"), key.WithHelp("w", "save list")),
+			Start:  key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "start tunnel")),
+			Remove: key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "remove/stop")),
+			Esc:    m.keys.Esc,
+		}))
+		return docStyle.Render(b.String())
+	case snippetsScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("snippets for " + m.target()))
+		b.WriteString("\n")
+		if len(m.snippets) == 0 {
+			path, _ := snippetsPath()
+			b.WriteString("No snippets yet; add named commands to " + path + ", e.g.\n\n")
+			b.WriteString("- name: tail nginx logs\n  command: sudo tail -n 200 /var/log/nginx/error.log\n  key: n\n\nthen press r.\n")
+		}
+		b.WriteString(snippetsView(m.snippets, m.snippetCursor))
+		b.WriteString("\n")
+		if m.snippetRunning {
+			b.WriteString(m.spinner.View() + " ")
+		}
+		if m.snippetStatus != "" {
+			b.WriteString(m.snippetStatus + "\n\n")
+		}
+		b.WriteString(m.help.View(SnippetsKeyMap{
+			Run:    key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter/key", "run")),
+			Reload: key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "reload")),
+			Esc:    m.keys.Esc,
+		}))
+		return docStyle.Render(b.String())
+	case debugLogScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("ssh -vvv log of the login to " + m.target()))
+		b.WriteString("\n")
+		b.WriteString(m.debugView.View())
+		b.WriteString("\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case broadcastInputScreen:
+		var b strings.Builder
+		hosts := m.markedHosts()
+		b.WriteString(headerStyle.Render(fmt.Sprintf("run on %d marked hosts", len(hosts))))
+		b.WriteString("\n")
+		if m.errMsg != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(m.errMsg))
+			b.WriteString("\n\n")
 		}
-
-		var cmd tea.Cmd
-		m.list, cmd = m.list.Update(msg)
-
-		// Update info box content after list update
-		if m.list.Index() < len(m.list.Items()) {
-			if selected, ok := m.list.Items()[m.list.Index()].(hostItem); ok {
-				hostInfo := getHostInfo(selected.host)
-				m.infoBox = hostInfo
-			}
+		b.WriteString(strings.Join(hosts, ", ") + "\n\n")
+		b.WriteString("command: " + m.broadcastInput.View() + "\n\n")
+		rolling := "[ ]"
+		if m.broadcastRolling {
+			rolling = "[x]"
 		}
-
-		return m, cmd
-	case passwordScreen:
-		switch msg := msg.(type) {
-		case tea.KeyMsg:
-			switch msg.String() {
-			case "esc":
-				m.screen = listScreen
-				m.errMsg = ""
-				return m, nil
-			case "enter":
-				m.password = m.pwInput.Value()
-				m.errMsg = ""
-				m.screen = spinnerScreen
-				m.loggingIn = true
-				return m, tea.Batch(m.spinner.Tick, tryLogin(m.selectedHost, m.password))
-			}
+		b.WriteString(rolling + " rolling: one host at a time, confirming each next one (ctrl+r)\n")
+		save := "[ ]"
+		if m.broadcastSave {
+			save = "[x]"
 		}
-		var cmd tea.Cmd
-		m.pwInput, cmd = m.pwInput.Update(msg)
-		return m, cmd
-	case spinnerScreen:
-		switch msg := msg.(type) {
-		case loginResultMsg:
-			m.loggingIn = false
-			if msg.success {
-				// Success: set flag and quit TUI
-				m.shouldSSH = true
-				return m, tea.Quit
-			} else {
-				// Failure: go back to password input with error
-				m.screen = passwordScreen
-				m.errMsg = "Login failed: wrong password or SSH error."
-				m.pwInput.SetValue("")
-				return m, nil
+		b.WriteString(save + " save each host's output to a file (ctrl+s)\n\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case deployKeyScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render(fmt.Sprintf("install %s on %d hosts", filepath.Base(m.deployKey), len(m.deployHosts))))
+		b.WriteString("\n")
+		b.WriteString(fmt.Sprintf("No saved password for %s.\n", strings.Join(m.deployMissing, ", ")))
+		b.WriteString("Password to log in to them with:\n")
+		b.WriteString(m.deployInput.View() + "\n\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case tagsScreen:
+		var b strings.Builder
+		if len(m.tagHosts) == 1 {
+			b.WriteString(headerStyle.Render("tags of " + m.tagHosts[0]))
+		} else {
+			b.WriteString(headerStyle.Render(fmt.Sprintf("tags of %d marked hosts", len(m.tagHosts))))
+		}
+		b.WriteString("\n")
+		if m.errMsg != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(m.errMsg))
+			b.WriteString("\n\n")
+		}
+		b.WriteString("current: " + tagCounts(m.metadata, m.tagHosts) + "\n\n")
+		b.WriteString("tags to add, or to remove with a leading - (e.g. web prod -staging):\n")
+		b.WriteString(m.tagInput.View() + "\n\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case broadcastScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render(m.broadcastTitle))
+		b.WriteString("\n")
+		b.WriteString(broadcastTabs(m.broadcastHosts, m.broadcastTab) + "\n\n")
+		if m.broadcastTab == 0 {
+			b.WriteString(broadcastView(m.broadcastHosts))
+			b.WriteString("\n")
+			b.WriteString(broadcastSummary(m.broadcastHosts) + "\n\n")
+		} else {
+			b.WriteString(m.broadcastView.View() + "\n")
+		}
+		if m.rollingWaiting {
+			b.WriteString(m.rollingPrompt() + "\n\n")
+		}
+		if m.broadcastSavedTo != "" {
+			b.WriteString("Output saved in " + m.broadcastSavedTo + "\n\n")
+		}
+		if hosts := m.wrongPasswordHosts(); len(hosts) > 0 {
+			b.WriteString(fmt.Sprintf("Press p to try another password on the %d hosts that refused it.\n\n", len(hosts)))
+		}
+		b.WriteString(m.help.View(BroadcastKeyMap{
+			Tabs: key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab/←→", "switch host")),
+			Diff: key.NewBinding(key.WithKeys("D"), key.WithHelp("D", "compare outputs")),
+			Esc:  m.keys.Esc,
+		}))
+		return docStyle.Render(b.String())
+	case outputDiffScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("output of " + m.broadcastTitle + " compared"))
+		b.WriteString("\n")
+		b.WriteString(m.outputDiff.View())
+		b.WriteString("\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case snippetOutputScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render(m.snippetTitle))
+		b.WriteString("\n")
+		b.WriteString(m.snippetOutput.View())
+		b.WriteString("\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case tunnelsScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("tunnels"))
+		b.WriteString("\n")
+		b.WriteString(tunnelsView(m.tunnelRows, m.tunnelCursor))
+		b.WriteString("\n")
+		if m.tunnelStatus != "" {
+			b.WriteString(m.tunnelStatus + "\n\n")
+		}
+		b.WriteString(m.help.View(TunnelsKeyMap{
+			Start:   key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "start")),
+			Stop:    key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "stop")),
+			Restart: key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "restart")),
+			Esc:     m.keys.Esc,
+		}))
+		return docStyle.Render(b.String())
+	case transferScreen:
+		var b strings.Builder
+		if m.transferHosts != nil {
+			b.WriteString(headerStyle.Render(fmt.Sprintf("copy a file to %d marked hosts", len(m.transferHosts))))
+		} else {
+			b.WriteString(headerStyle.Render("copy files with " + m.target()))
+		}
+		b.WriteString("\n")
+		if m.errMsg != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(m.errMsg))
+			b.WriteString("\n\n")
+		}
+		if m.transferHosts != nil {
+			b.WriteString(strings.Join(m.transferHosts, ", ") + "\n")
+			b.WriteString("upload with scp, key-based, to the same path on each host\n\n")
+		} else {
+			direction := "download: " + m.selectedHost + " → this machine"
+			if m.transferSpec.upload {
+				direction = "upload: this machine → " + m.selectedHost
 			}
-		default:
-			var cmd tea.Cmd
-			m.spinner, cmd = m.spinner.Update(msg)
-			return m, cmd
+			tool := "scp"
+			if m.transferSpec.rsync {
+				tool = "rsync"
+			}
+			b.WriteString(fmt.Sprintf("%s  (ctrl+d to switch)\n", direction))
+			b.WriteString(fmt.Sprintf("using %s  (ctrl+t to switch)\n\n", tool))
 		}
-	}
-	return m, nil
-}
-
-func tryLogin(host, password string) tea.Cmd {
-	return func() tea.Msg {
-		// Try to SSH with sshpass and a quick command (exit)
-		cmd := exec.Command("sshpass", "-p", password, "ssh", "-o", "StrictHostKeyChecking=no", "-o", "BatchMode=no", host, "exit")
-		cmd.Stdin = nil
-		cmd.Stdout = nil
-		cmd.Stderr = nil
-		err := cmd.Run()
-		if err == nil {
-			return loginResultMsg{success: true}
+		b.WriteString("local path:\n" + m.transferInputs[0].View() + "\n")
+		b.WriteString("remote path:\n" + m.transferInputs[1].View() + "\n\n")
+		switch {
+		case m.transferRunning:
+			b.WriteString(m.spinner.View() + " " + transferStatus(m.transferSpec, m.transferProgress, m.transferStarted))
+			b.WriteString("\n\nPress esc to cancel.")
+		case m.transferResult != "":
+			b.WriteString(m.transferResult)
+			b.WriteString("\n\n" + m.help.View(m.backKeys()))
+		default:
+			b.WriteString("Press enter to copy, tab to switch between the paths.\n\n")
+			b.WriteString(m.help.View(m.backKeys()))
 		}
-		return loginResultMsg{success: false, err: err}
-	}
-}
-
-func (m *model) passwordHelpBar() string {
-	// Use the same style as the main list view's help text
-	helpStyle := m.list.Styles.HelpStyle
-	return helpStyle.Render("    esc    go back")
-}
-
-func (m *model) View() string {
-	switch m.screen {
-	case listScreen:
-		// Create info box style
-		infoBoxStyle := lipgloss.NewStyle().
-			Width(60).
-			Height(10).
-			Align(lipgloss.Left, lipgloss.Top).
-			BorderStyle(lipgloss.NormalBorder()).
-			BorderForeground(lipgloss.Color("69")).
-			Padding(1, 1)
-
-		// Create the info box content
-		infoBox := infoBoxStyle.Render(m.infoBox)
-
-		// Join list and info box horizontally
-		content := lipgloss.JoinHorizontal(lipgloss.Top, m.list.View(), "  ", infoBox)
-
+		return docStyle.Render(b.String())
+	case browserScreen:
 		var b strings.Builder
-		b.WriteString(content)
+		b.WriteString(headerStyle.Render("files on " + m.target()))
 		b.WriteString("\n")
-		b.WriteString(m.help.View(m.listKeys))
+		b.WriteString(m.browser.view())
+		b.WriteString("\n")
+		b.WriteString(m.help.View(BrowserKeyMap{
+			Switch: key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "other side")),
+			Open:   key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "open")),
+			Up:     key.NewBinding(key.WithKeys("backspace"), key.WithHelp("backspace", "parent")),
+			Copy:   key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "copy across")),
+			Rename: key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "rename")),
+			Delete: key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "delete")),
+			Esc:    m.keys.Esc,
+		}))
 		return docStyle.Render(b.String())
-	case passwordScreen:
+	case jumpScreen:
 		var b strings.Builder
-
-		// Styled header with host name
-		header := headerStyle.Render(m.selectedHost)
-		b.WriteString(header)
+		b.WriteString(headerStyle.Render("connect to " + m.selectedHost + " via"))
 		b.WriteString("\n")
-
-		// Error message if any
 		if m.errMsg != "" {
 			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(m.errMsg))
 			b.WriteString("\n\n")
 		}
-
-		// "Enter password:" text styled like help text
+		b.WriteString(jumpHostView(m.jumpHosts, m.jumpCursor, m.jumpConfigured))
+		b.WriteString("\n")
+		if m.jumpChecking != "" {
+			b.WriteString("Checking that " + m.jumpChecking + " logs in without a password...\n\n")
+		}
+		b.WriteString(m.help.View(JumpKeyMap{
+			Connect: key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "connect")),
+			Persist: key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "connect and save as ProxyJump if it works")),
+			Esc:     m.keys.Esc,
+		}))
+		return docStyle.Render(b.String())
+	case connectionsScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("open connections"))
+		b.WriteString("\n")
+		if m.errMsg != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(m.errMsg))
+			b.WriteString("\n\n")
+		}
+		b.WriteString(masterConnectionsView(m.masters, m.masterCursor))
+		b.WriteString(fmt.Sprintf("\nUnused connections close after %d seconds; at most %d are kept open.\n\n", m.config.multiplexIdle(), m.config.multiplexMax()))
+		b.WriteString(m.help.View(ConnectionsKeyMap{
+			Close: key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "close")),
+			Esc:   m.keys.Esc,
+		}))
+		return docStyle.Render(b.String())
+	case pivotScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("hosts across environments"))
+		b.WriteString("\n")
+		b.WriteString(envPivotView(m.pivotRows, m.pivotEnvs, m.pivotRow, m.pivotCol))
+		b.WriteString("\n")
+		b.WriteString(m.help.View(PivotKeyMap{
+			Move:    key.NewBinding(key.WithKeys("up", "down", "left", "right"), key.WithHelp("←↑↓→", "move")),
+			Connect: key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "connect")),
+			Esc:     m.keys.Esc,
+		}))
+		return docStyle.Render(b.String())
+	case graphScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render("dependencies of " + m.selectedHost))
+		b.WriteString("\n")
+		b.WriteString(m.graphView)
+		b.WriteString("\n")
+		b.WriteString(m.help.View(m.backKeys()))
+		return docStyle.Render(b.String())
+	case challengeScreen:
+		var b strings.Builder
+		b.WriteString(headerStyle.Render(m.selectedHost))
+		b.WriteString("\n")
 		helpStyle := lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{
 			Light: "#B2B2B2",
 			Dark:  "#4A4A4A",
 		})
-		b.WriteString(helpStyle.Render("enter password:"))
+		if m.challenge.name != "" {
+			b.WriteString(m.challenge.name)
+			b.WriteString("\n")
+		}
+		if m.challenge.instruction != "" {
+			b.WriteString(helpStyle.Render(m.challenge.instruction))
+			b.WriteString("\n")
+		}
+		b.WriteString(m.challenge.questions[len(m.challengeAnswers)])
 		b.WriteString("\n")
-
-		// Password input field
-		b.WriteString(m.pwInput.View())
+		b.WriteString(m.challengeInput.View())
 		b.WriteString("\n\n")
-
-		// Help bar using the same system as the main list view
-		b.WriteString(m.help.View(m.keys))
+		b.WriteString(m.help.View(m.backKeys()))
 		return docStyle.Render(b.String())
 	case spinnerScreen:
 		var b strings.Builder
 		b.WriteString("\n\n   ")
 		b.WriteString(m.spinner.View())
-		b.WriteString(" Logging in...")
+		b.WriteString(" " + m.spinnerText)
+		if m.loggingIn && !m.retryPending {
+			b.WriteString(fmt.Sprintf(" (%s", time.Since(m.loginStarted).Round(time.Second)))
+			if m.loginAttempt > 1 {
+				b.WriteString(fmt.Sprintf(", attempt %d of %d", m.loginAttempt, loginAttempts))
+			}
+			b.WriteString(")")
+		}
+		b.WriteString("\n\n")
+		b.WriteString(m.help.View(m.backKeys()))
 		return docStyle.Render(b.String())
 	}
 	return ""
@@ -328,6 +4068,16 @@ func (m *model) View() string {
 
 // parseSSHConfig parses the SSH config and returns hostItems with host and user@ip/ip as desc if available.
 func parseSSHConfig(path string) ([]hostItem, error) {
+	return parseSSHConfigFile(path, filepath.Dir(path), "", 0)
+}
+
+// maxIncludeDepth is how deeply Include directives may nest, as in ssh
+const maxIncludeDepth = 16
+
+// parseSSHConfigFile parses one file of the SSH config. Included files are read
+// in place; relative Include paths are resolved against dir, like ssh resolves
+// them against ~/.ssh. source is recorded with the hosts the file declares.
+func parseSSHConfigFile(path, dir, source string, depth int) ([]hostItem, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -341,6 +4091,14 @@ func parseSSHConfig(path string) ([]hostItem, error) {
 	var currentUser string
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
+		if configKeyword(line) == "include" && depth < maxIncludeDepth {
+			included, err := parseIncludes(strings.Fields(line)[1:], dir, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, included...)
+			continue
+		}
 		if strings.HasPrefix(strings.ToLower(line), "host ") {
 			// If we have a previous host group, add them
 			if len(currentHosts) > 0 {
@@ -354,7 +4112,7 @@ func parseSSHConfig(path string) ([]hostItem, error) {
 					} else if currentHostname != "" {
 						desc = currentHostname
 					}
-					items = append(items, hostItem{host: h, desc: desc})
+					items = append(items, hostItem{host: h, desc: desc, source: source})
 				}
 			}
 			fields := strings.Fields(line)
@@ -390,12 +4148,40 @@ func parseSSHConfig(path string) ([]hostItem, error) {
 			} else if currentHostname != "" {
 				desc = currentHostname
 			}
-			items = append(items, hostItem{host: h, desc: desc})
+			items = append(items, hostItem{host: h, desc: desc, source: source})
 		}
 	}
 	return items, scanner.Err()
 }
 
+// parseIncludes parses the files matched by the patterns of an Include line.
+// Patterns matching nothing are skipped, as ssh does.
+func parseIncludes(patterns []string, dir string, depth int) ([]hostItem, error) {
+	var items []hostItem
+	for _, pattern := range patterns {
+		pattern = expandHome(pattern)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			source := match
+			if rel, err := filepath.Rel(dir, match); err == nil && !strings.HasPrefix(rel, "..") {
+				source = rel
+			}
+			included, err := parseSSHConfigFile(match, dir, source, depth)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, included...)
+		}
+	}
+	return items, nil
+}
+
 // deleteHostFromConfig removes a host entry from the SSH config file
 func deleteHostFromConfig(hostToDelete string) error {
 	usr, err := user.Current()
@@ -687,6 +4473,52 @@ func checkSshpass() {
 }
 
 func main() {
+	// "connect <host>" or "connect group:<name>" skips the host list. Arguments
+	// after "--" are passed on to ssh for the session.
+	var target string
+	var extraSSHArgs []string
+	if socket := os.Getenv(sftpBridgeEnv); socket != "" {
+		// Started by sftp -D for a session of the built-in client
+		os.Exit(runSFTPBridge(socket))
+	}
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "--":
+			extraSSHArgs = os.Args[2:]
+		case "connect":
+			if len(os.Args) < 3 || len(os.Args) > 3 && os.Args[3] != "--" {
+				fmt.Println("Usage: list-ssh-hosts connect <host|group:name> [-- ssh arguments]")
+				os.Exit(2)
+			}
+			target = os.Args[2]
+			if len(os.Args) > 3 {
+				extraSSHArgs = os.Args[4:]
+			}
+		case "export-ics":
+			os.Exit(runExportICS(os.Args[2:]))
+		case "freeze", "unfreeze":
+			os.Exit(runFreeze(os.Args[1], os.Args[2:]))
+		case "stats":
+			os.Exit(runStats(os.Args[2:]))
+		case "runbook":
+			os.Exit(runRunbook(os.Args[2:]))
+		case "share":
+			os.Exit(runShare(os.Args[2:]))
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "export-bundle":
+			os.Exit(runExportBundle(os.Args[2:]))
+		case "import-bundle":
+			os.Exit(runImportBundle(os.Args[2:]))
+		case "exec":
+			os.Exit(runExec(os.Args[2:]))
+		case "report":
+			os.Exit(runReport(os.Args[2:]))
+		case "tunnel":
+			os.Exit(runTunnel(os.Args[2:]))
+		}
+	}
+
 	checkSshpass()
 	usr, err := user.Current()
 	if err != nil {
@@ -709,18 +4541,300 @@ func main() {
 		items[i] = it
 	}
 
-	m := initialModel(items)
-	if _, err := tea.NewProgram(m, tea.WithAltScreen()).Run(); err != nil {
-		fmt.Println("Error running program:", err)
+	cfg, err := loadAppConfig()
+	if err != nil {
+		fmt.Println("Could not read app config:", err)
+		os.Exit(1)
+	}
+
+	metadata, err := loadHostMetadata()
+	if err != nil {
+		fmt.Println("Could not read host metadata:", err)
 		os.Exit(1)
 	}
+	remoteLimiter = newBulkLimiter(cfg.Concurrency, metadata)
+	remoteConnectTimeout = cfg.connectTimeout()
+
+	var sessionPasswords map[string][]byte
+	if !cfg.DisablePasswordCache {
+		sessionPasswords = map[string][]byte{}
+	}
+	forgetPasswords := func() {
+		for _, pw := range sessionPasswords {
+			clear(pw)
+		}
+	}
+	// The vault stays unlocked for the hosts picked after a session, like the passwords
+	var unlockedVault *vault
+	var lastSession string
+	for {
+		state, err := loadAppState()
+		if err != nil {
+			fmt.Println("Could not read app state:", err)
+			os.Exit(1)
+		}
+
+		m := initialModel(items)
+		m.config = cfg
+		for _, g := range cfg.Groups {
+			if g.Key != "" && insideTmux() {
+				m.listKeys.GroupLayout.SetEnabled(true)
+			}
+		}
+		m.metadata = metadata
+		m.sortMode = cfg.Sort
+		if state.SortMode != "" {
+			m.sortMode = state.SortMode
+		}
+		if path, err := historyPath(); err == nil {
+			// Without the history, the recent and frecency orders are the config order
+			entries, _ := readHistory(path)
+			m.usage = usageFromHistory(entries, time.Now())
+		}
+		m.grouped = state.GroupedView
+		m.listKeys.FoldAll.SetEnabled(m.grouped)
+		for _, name := range state.CollapsedGroups {
+			m.collapsed[name] = true
+		}
+		m.refreshList()
+		for host, zone := range state.Timezones {
+			m.timezones[host] = zone
+		}
+		m.cachedKeys = state.HostKeys
+		m.sessionPasswords = sessionPasswords
+		m.vault = unlockedVault
+		m.extraSSHArgs = extraSSHArgs
+		m.statusMsg = lastSession
+		if target != "" {
+			host, err := resolveConnectTarget(target, cfg, metadata, parsed)
+			if err != nil {
+				fmt.Println("Could not connect:", err)
+				os.Exit(1)
+			}
+			m.selectHost(host)
+			_, m.startCmd = m.connectSelected()
+			target = ""
+		}
+		if _, err := tea.NewProgram(m, tea.WithAltScreen()).Run(); err != nil {
+			fmt.Println("Error running program:", err)
+			os.Exit(1)
+		}
+
+		if m.rememberErr != nil {
+			fmt.Println("Could not remember password:", m.rememberErr)
+		}
+		if m.agentErr != nil {
+			fmt.Println("Could not add key to the agent:", m.agentErr)
+		}
+		if m.jumpErr != nil {
+			fmt.Println("Could not save ProxyJump:", m.jumpErr)
+		}
+		unlockedVault = m.vault
+		// Unless the host list comes back, the process only runs the session from
+		// here on, which never asks for another host
+		if !cfg.ReturnToList {
+			forgetPasswords()
+		}
+
+		if !m.shouldSSH || m.selectedHost == "" {
+			forgetPasswords()
+			return
+		}
+		if cfg.SessionBanner {
+			fmt.Println(sessionBanner(m.selectedHost, metadata[m.selectedHost].Environment, cfg.EnvironmentColors))
+		}
+
+		// After TUI exits, if login was successful, run SSH
+		restoreTitle := func() {}
+		if !cfg.DisableTerminalTitle {
+			restoreTitle = setSessionTitle(m.selectedHost)
+		}
+		started := time.Now()
+		code, err := startSession(m)
+		restoreTitle()
+		recordHistory(cfg, historyEntry{Host: m.selectedHost, Time: started, Duration: time.Since(started).Round(time.Second)})
+		if !cfg.ReturnToList {
+			if err != nil {
+				fmt.Println("SSH session failed:", err)
+				os.Exit(1)
+			}
+			// Scripts and shells see the session's own result
+			os.Exit(code)
+		}
+
+		lastSession = fmt.Sprintf("Session on %s ended after %s (exit status %d)", m.selectedHost, time.Since(started).Round(time.Second), code)
+		if err != nil {
+			lastSession = fmt.Sprintf("Session on %s failed: %v", m.selectedHost, err)
+		}
+		// Pick up changes made during the session, such as pinned keys or hosts
+		// added to ~/.ssh/config, keeping what was loaded before when a file
+		// cannot be read now
+		if hosts, err := parseSSHConfig(sshConfigPath); err == nil && len(hosts) > 0 {
+			parsed = hosts
+			items = make([]list.Item, len(parsed))
+			for i, it := range parsed {
+				items[i] = it
+			}
+		}
+		if c, err := loadAppConfig(); err == nil {
+			cfg = c
+		}
+		if cfg.DisablePasswordCache {
+			forgetPasswords()
+			sessionPasswords = nil
+		}
+		if md, err := loadHostMetadata(); err == nil {
+			metadata = md
+		}
+		remoteLimiter = newBulkLimiter(cfg.Concurrency, metadata)
+		remoteConnectTimeout = cfg.connectTimeout()
+	}
+}
 
-	// After TUI exits, if login was successful, run SSH
-	if m.shouldSSH && m.selectedHost != "" && m.password != "" {
-		cmd := exec.Command("sshpass", "-p", m.password, "ssh", "-t", m.selectedHost, "env TERM=xterm-256color bash --login")
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Run()
+// loginArgs returns the extra ssh options of the login test, whose connection the session reuses
+func (m *model) loginArgs() []string {
+	args := append(m.multiplexArgs(), m.connectOpts.connectionArgs()...)
+	args = append(args, keepaliveArgs(m.metadata[m.selectedHost], m.config)...)
+	return append(args, jumpArgs(m.jumpHost)...)
+}
+
+// sessionArgs returns the ssh flags chosen for this session: the toggles of the
+// password screen, the bastion picked with J, then the arguments given after --
+func (m *model) sessionArgs() []string {
+	args := append(m.connectOpts.args(), jumpArgs(m.jumpHost)...)
+	return append(args, m.extraSSHArgs...)
+}
+
+// startSession runs the interactive SSH session after a successful login and
+// returns its exit code. The error is only set when the session could not run.
+func startSession(m *model) (int, error) {
+	if m.sftp {
+		return startSFTP(m)
+	}
+	remoteCmd := sessionCommand(m.selectedHost, m.metadata[m.selectedHost], m.config)
+	term := sessionTerm(m.metadata[m.selectedHost], m.config)
+	if m.nativeClient != nil {
+		// The connection is authenticated already
+		m.forgetPassword()
+		if args := m.sessionArgs(); len(args) > 0 {
+			fmt.Println("The built-in SSH client does not take ssh arguments; ignoring", strings.Join(args, " "))
+		}
+		interval, countMax := keepalive(m.metadata[m.selectedHost], m.config)
+		stop := keepAlive(m.nativeClient, interval, countMax)
+		defer stop()
+		return sessionExitCode(runNativeSession(m.nativeClient, remoteCmd, term))
+	}
+	if m.metadata[m.selectedHost].EternalTerminal {
+		reason := m.etUnavailable()
+		if reason == "" {
+			// et authenticates over the login test's connection, like the ssh session would
+			m.forgetPassword()
+			return runETSession(etArgs(m.target(), m.multiplexArgs(), remoteCmd), term)
+		}
+		fmt.Println("Connecting with ssh instead of Eternal Terminal:", reason)
+	}
+
+	// The login test left a master connection behind; the session attaches to
+	// it and only authenticates again if it has gone away in the meantime
+	args := append(m.multiplexArgs(), keepaliveArgs(m.metadata[m.selectedHost], m.config)...)
+	args = append(args, m.sessionArgs()...)
+	if remoteCmd != "" {
+		// ssh refuses a command line when the SSH config sets a RemoteCommand
+		args = append(args, "-o", "RemoteCommand=none")
+	}
+	args = append(args, "-t", m.target())
+	if remoteCmd != "" {
+		args = append(args, remoteCmd)
+	}
+	// Plain ssh keeps the terminal attached so touch, PIN and password prompts
+	// reach the user; otherwise sshpass answers with the password, which is kept
+	// for the session only when it may have to reconnect
+	var password []byte
+	if m.securityKey == "" && !m.direct {
+		password = bytes.Clone(m.password)
+		defer clear(password)
+	}
+	m.forgetPassword()
+	limit := m.metadata[m.selectedHost].Reconnect
+	record := recordSession(m.metadata[m.selectedHost], m.config)
+	attempt := 0
+	for {
+		started := time.Now()
+		recording := ""
+		if record {
+			var err error
+			if recording, err = recordingBase(m.selectedHost, started); err != nil {
+				fmt.Println("Not recording the session:", err)
+				recording = ""
+			}
+		}
+		code, err := runSSHSession(args, term, password, m.keyFile, m.config.Recorder, recording)
+		if err != nil {
+			return code, err
+		}
+		lasted := time.Since(started)
+		var again bool
+		if attempt, again = nextReconnect(code, lasted, attempt, limit); again {
+			delay := reconnectDelay(attempt)
+			fmt.Printf("\r\nConnection to %s lost; reconnecting in %s (attempt %d of %d, Ctrl+C to stop)...\r\n", m.selectedHost, delay, attempt, limit)
+			if !sleepUnlessInterrupted(delay) {
+				return code, nil
+			}
+			continue
+		}
+		if limit > 0 && attempt > limit {
+			fmt.Printf("Gave up reconnecting to %s after %d attempts.\n", m.selectedHost, limit)
+		}
+		// The exit status is that of the remote shell, not a failure to connect,
+		// unless the login was left to ssh, which then reports its own errors
+		if m.direct && code == sshConnectionError {
+			return code, nil
+		}
+		if code != 0 && lasted < quickExit && attempt == 0 {
+			fmt.Println("The server closed the session right away. The account may be limited to a forced command or have no shell.")
+		}
+		return code, nil
+	}
+}
+
+// runSSHSession runs ssh with args attached to the terminal, through sshpass
+// when password is set, and returns the exit code of the session. With a
+// recording path, the session runs under recorder, writing there.
+func runSSHSession(args []string, term string, password []byte, keyFile, recorder, recording string) (int, error) {
+	var cmd *exec.Cmd
+	if password == nil {
+		cmd = exec.Command("ssh", args...)
+	} else {
+		var secret *os.File
+		var err error
+		if cmd, secret, err = sshpassCommand(context.Background(), password, keyFile, args...); err != nil {
+			return 1, err
+		}
+		defer secret.Close()
+	}
+	if term != "" {
+		// ssh announces its own TERM to the host when requesting the terminal
+		cmd.Env = append(os.Environ(), "TERM="+term)
+	}
+	recorded := false
+	if recording != "" {
+		if err := recordCommand(cmd, recorder, runtime.GOOS, recording); err != nil {
+			fmt.Println("Not recording the session:", err)
+		} else {
+			recorded = true
+		}
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return 1, err
+	}
+	stop := forwardSignals(cmd.Process)
+	code, err := sessionExitCode(cmd.Wait())
+	stop()
+	if recorded {
+		fmt.Printf("Session recorded to %s.*\n", recording)
 	}
+	return code, err
 }
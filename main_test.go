@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -237,6 +238,36 @@ Host iponly
 	}
 }
 
+func TestParseSSHConfigInclude(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "config.d"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"config":            "Include config.d/*\n\nHost local\n    Hostname 10.0.0.1\n",
+		"config.d/work":     "Host ci1 ci2\n    Hostname 10.1.0.1\nInclude nested\n",
+		"config.d/personal": "Host pi\n    Hostname 192.168.1.2\n",
+		"nested":            "Host deep\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hosts, err := parseSSHConfig(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatalf("parseSSHConfig failed: %v", err)
+	}
+	var got []string
+	for _, h := range hosts {
+		got = append(got, h.host+"="+h.source)
+	}
+	want := "pi=config.d/personal deep=nested ci1=config.d/work ci2=config.d/work local="
+	if strings.Join(got, " ") != want {
+		t.Errorf("expected the hosts of included files with their file, got %v", got)
+	}
+}
+
 func TestParseSSHConfig_FileNotExist(t *testing.T) {
 	_, err := parseSSHConfig("/tmp/this_file_should_not_exist_1234567890")
 	if err == nil {
@@ -509,3 +540,79 @@ func deleteHostFromConfigFile(configPath, hostToDelete string) error {
 	newContent := strings.Join(newLines, "\n")
 	return os.WriteFile(configPath, []byte(newContent), 0644)
 }
+
+func TestLoginUserTarget(t *testing.T) {
+	m := initialModel(nil)
+	m.selectHost("web1")
+	if got := m.target(); got != "web1" {
+		t.Errorf("expected the alias, got %q", got)
+	}
+	m.loginUser = "root"
+	if got := m.target(); got != "root@web1" {
+		t.Errorf("expected root@web1, got %q", got)
+	}
+	// The user is chosen per connection
+	m.selectHost("db1")
+	if got := m.target(); got != "db1" {
+		t.Errorf("expected the user to be reset, got %q", got)
+	}
+}
+
+func TestRejectedPasswordEvictedForUser(t *testing.T) {
+	m := initialModel(nil)
+	m.config.DisableHistory = true
+	m.selectHost("web1")
+	m.loginUser = "root"
+	m.sessionPasswords = map[string][]byte{"root@web1": []byte("old"), "web1": []byte("other")}
+	m.loginFinished(loginResultMsg{failure: failureCredentials})
+	if _, ok := m.sessionPasswords["root@web1"]; ok {
+		t.Error("expected the rejected password of root@web1 to be evicted")
+	}
+	if _, ok := m.sessionPasswords["web1"]; !ok {
+		t.Error("expected the password of the default user to be kept")
+	}
+	m.sessionPasswords["root@web1"] = []byte("old")
+	m.screen = spinnerScreen
+	m.Update(copyKeyMsg{wrongPassword: true})
+	if _, ok := m.sessionPasswords["root@web1"]; ok {
+		t.Error("expected the password refused while installing a key to be evicted")
+	}
+}
+
+func TestRejectedPasswordEvictedForPort(t *testing.T) {
+	m := initialModel(nil)
+	m.config.DisableHistory = true
+	m.selectHost("web1")
+	m.loginPort = 2222
+	m.sessionPasswords = map[string][]byte{"ssh://web1:2222": []byte("old"), "web1": []byte("other")}
+	m.loginFinished(loginResultMsg{failure: failureCredentials})
+	if _, ok := m.sessionPasswords["ssh://web1:2222"]; ok {
+		t.Error("expected the rejected password of port 2222 to be evicted")
+	}
+	if _, ok := m.sessionPasswords["web1"]; !ok {
+		t.Error("expected the password of the default port to be kept")
+	}
+	m.sessionPasswords["ssh://web1:2222"] = []byte("old")
+	m.screen = spinnerScreen
+	m.Update(copyKeyMsg{wrongPassword: true})
+	if _, ok := m.sessionPasswords["ssh://web1:2222"]; ok {
+		t.Error("expected the password refused while installing a key to be evicted")
+	}
+}
+
+func TestLoginPortTarget(t *testing.T) {
+	m := initialModel(nil)
+	m.selectHost("web1")
+	m.loginPort = 2222
+	if got := m.target(); got != "ssh://web1:2222" {
+		t.Errorf("expected an ssh URI, got %q", got)
+	}
+	m.loginUser = "root"
+	if got := m.target(); got != "ssh://root@web1:2222" {
+		t.Errorf("expected the user in the URI, got %q", got)
+	}
+	m.selectHost("web1")
+	if got := m.target(); got != "web1" {
+		t.Errorf("expected the overrides to be reset, got %q", got)
+	}
+}
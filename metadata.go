@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// hostMeta holds per-host data kept by the app rather than in ~/.ssh/config
+type hostMeta struct {
+	Tags []string `json:"tags,omitempty"`
+	// Environment names the host's environment, such as prod or staging
+	Environment string `json:"environment,omitempty"`
+	// Timezone is the host's IANA time zone, taking precedence over the probed one
+	Timezone string `json:"timezone,omitempty"`
+	// DependsOn lists hosts this host needs, e.g. its database server
+	DependsOn []string `json:"depends_on,omitempty"`
+	// NativeClient connects with the built-in SSH client, which can answer
+	// keyboard-interactive (OTP, Duo) prompts
+	NativeClient bool `json:"native_client,omitempty"`
+	// Maintenance lists planned maintenance windows for the host
+	Maintenance []maintenanceWindow `json:"maintenance,omitempty"`
+	// HostKeyPin is the SHA256 fingerprint the host key must have; any other key blocks the connection
+	HostKeyPin string `json:"host_key_pin,omitempty"`
+	// SkipLoginTest connects right away and lets ssh ask for the password, e.g. for hosts with OTP
+	SkipLoginTest bool `json:"skip_login_test,omitempty"`
+	// Command is run instead of the login shell for interactive sessions, e.g. "tmux new -A -s main"
+	Command string `json:"command,omitempty"`
+	// Startup lists commands run at the start of interactive sessions, e.g. "cd /var/www && sudo -i"
+	Startup []string `json:"startup,omitempty"`
+	// TmuxSession runs the host's sessions in a tmux session named after it, attaching to it when it exists
+	TmuxSession bool `json:"tmux_session,omitempty"`
+	// Term overrides the TERM announced to the host, e.g. xterm-256color for hosts lacking the local terminal's terminfo
+	Term string `json:"term,omitempty"`
+	// EternalTerminal starts sessions with et, which survive network changes, when et and etserver are installed
+	EternalTerminal bool `json:"eternal_terminal,omitempty"`
+	// Reconnect is how many times in a row an ssh session that lost its connection is started again
+	Reconnect int `json:"reconnect,omitempty"`
+	// ServerAliveInterval and ServerAliveCountMax override the keepalive settings of config.json for the host
+	ServerAliveInterval int `json:"server_alive_interval,omitempty"`
+	ServerAliveCountMax int `json:"server_alive_count_max,omitempty"`
+	// Record records the host's ssh sessions in the recordings directory
+	Record bool `json:"record,omitempty"`
+	// Notes is free text about the host, shown in the info box and searched by the list filter
+	Notes string `json:"notes,omitempty"`
+	// Folder puts the host in a section of the grouped list view, instead of its smart group or first tag
+	Folder string `json:"folder,omitempty"`
+	// Favorite lists the host at the top of the list, starred
+	Favorite bool `json:"favorite,omitempty"`
+}
+
+// maintenanceWindow is a planned, possibly recurring, period of downtime
+type maintenanceWindow struct {
+	Summary string    `json:"summary"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	// RRule is an optional iCalendar recurrence rule, e.g. "FREQ=WEEKLY;BYDAY=SU"
+	RRule string `json:"rrule,omitempty"`
+}
+
+// hostMetadata maps host aliases to their metadata
+type hostMetadata map[string]hostMeta
+
+// metadataPath returns the location of the host metadata file in the app config directory
+func metadataPath() (string, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hosts.json"), nil
+}
+
+// loadHostMetadata reads hosts.json from the app config directory. A missing file yields no metadata.
+func loadHostMetadata() (hostMetadata, error) {
+	path, err := metadataPath()
+	if err != nil {
+		return hostMetadata{}, err
+	}
+	return readHostMetadata(path)
+}
+
+// readHostMetadata reads host metadata from the given path
+func readHostMetadata(path string) (hostMetadata, error) {
+	md := hostMetadata{}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return md, nil
+	}
+	if err != nil {
+		return md, err
+	}
+	err = json.Unmarshal(content, &md)
+	return md, err
+}
+
+// writeHostMetadata writes host metadata to the given path
+func writeHostMetadata(path string, md hostMetadata) error {
+	content, err := json.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0600)
+}
+
+// updateHostMetadata applies fn to hosts.json and writes it back. The file is
+// re-read first so edits made since startup are kept.
+func updateHostMetadata(fn func(hostMetadata)) error {
+	path, err := metadataPath()
+	if err != nil {
+		return err
+	}
+	md, err := readHostMetadata(path)
+	if err != nil {
+		return err
+	}
+	fn(md)
+	return writeHostMetadata(path, md)
+}
+
+// saveHostKeyPin sets the pinned host key of a host in hosts.json
+func saveHostKeyPin(host, pin string) error {
+	return updateHostMetadata(func(md hostMetadata) {
+		meta := md[host]
+		meta.HostKeyPin = pin
+		md[host] = meta
+	})
+}
+
+// hasTag reports whether the host is tagged with tag
+func (md hostMetadata) hasTag(host, tag string) bool {
+	return contains(md[host].Tags, tag)
+}
+
+// hostsWithTag returns the hosts from items that carry tag, in list order
+func (md hostMetadata) hostsWithTag(items []hostItem, tag string) []string {
+	var hosts []string
+	for _, it := range items {
+		if md.hasTag(it.host, tag) {
+			hosts = append(hosts, it.host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// gpuProbeCommand prints per-GPU utilization and temperature, a separator, then lm-sensors output
+const gpuProbeCommand = "nvidia-smi --query-gpu=utilization.gpu,temperature.gpu --format=csv,noheader,nounits 2>/dev/null; echo ---; sensors 2>/dev/null"
+
+// gpuMetrics summarizes the GPU and sensor readings of a host
+type gpuMetrics struct {
+	gpus        int
+	utilization int     // average GPU utilization in percent
+	gpuTemp     int     // hottest GPU in °C
+	sensorTemp  float64 // hottest lm-sensors reading in °C, 0 when unavailable
+	unreachable bool
+}
+
+type gpuMetricsMsg struct {
+	host    string
+	metrics gpuMetrics
+}
+
+// String renders the metrics as compact columns for the host list
+func (g gpuMetrics) String() string {
+	if g.unreachable {
+		return "metrics unavailable"
+	}
+	var cols []string
+	if g.gpus > 0 {
+		cols = append(cols, fmt.Sprintf("gpu %3d%% %3d°C", g.utilization, g.gpuTemp))
+	}
+	if g.sensorTemp > 0 {
+		cols = append(cols, fmt.Sprintf("sys %3.0f°C", g.sensorTemp))
+	}
+	return strings.Join(cols, "  ")
+}
+
+// probeGPU collects GPU and sensor metrics from a host in the background
+func probeGPU(host string) tea.Cmd {
+	return func() tea.Msg {
+		out, err := runRemote(host, gpuProbeCommand)
+		if err != nil && out == "" {
+			return gpuMetricsMsg{host: host, metrics: gpuMetrics{unreachable: true}}
+		}
+		return gpuMetricsMsg{host: host, metrics: parseGPUProbe(out)}
+	}
+}
+
+// parseGPUProbe parses the output of gpuProbeCommand
+func parseGPUProbe(out string) gpuMetrics {
+	var g gpuMetrics
+	nvidia, sensors, _ := strings.Cut(out, "---")
+
+	totalUtil := 0
+	for _, line := range strings.Split(nvidia, "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 2 {
+			continue
+		}
+		util, err1 := strconv.Atoi(strings.TrimSpace(fields[0]))
+		temp, err2 := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		g.gpus++
+		totalUtil += util
+		if temp > g.gpuTemp {
+			g.gpuTemp = temp
+		}
+	}
+	if g.gpus > 0 {
+		g.utilization = totalUtil / g.gpus
+	}
+
+	// lm-sensors lines look like "Package id 0:  +45.0°C  (high = +80.0°C, crit = +100.0°C)"
+	for _, line := range strings.Split(sensors, "\n") {
+		_, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "+") || !strings.HasSuffix(fields[0], "°C") {
+			continue
+		}
+		temp, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimPrefix(fields[0], "+"), "°C"), 64)
+		if err == nil && temp > g.sensorTemp {
+			g.sensorTemp = temp
+		}
+	}
+	return g
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestParseGPUProbe(t *testing.T) {
+	out := `87, 64
+41, 71
+---
+coretemp-isa-0000
+Adapter: ISA adapter
+Package id 0:  +45.0°C  (high = +80.0°C, crit = +100.0°C)
+Core 0:        +52.0°C  (high = +80.0°C, crit = +100.0°C)
+`
+	g := parseGPUProbe(out)
+	if g.gpus != 2 {
+		t.Errorf("expected 2 GPUs, got %d", g.gpus)
+	}
+	if g.utilization != 64 {
+		t.Errorf("expected average utilization 64, got %d", g.utilization)
+	}
+	if g.gpuTemp != 71 {
+		t.Errorf("expected hottest GPU 71°C, got %d", g.gpuTemp)
+	}
+	if g.sensorTemp != 52 {
+		t.Errorf("expected hottest sensor 52°C, got %v", g.sensorTemp)
+	}
+}
+
+func TestParseGPUProbe_NoGPU(t *testing.T) {
+	g := parseGPUProbe("---\n")
+	if g.gpus != 0 || g.sensorTemp != 0 {
+		t.Errorf("expected empty metrics, got %+v", g)
+	}
+	if g.String() != "" {
+		t.Errorf("expected empty column text, got %q", g.String())
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+)
+
+// Defaults for the master connections kept between login tests and sessions
+const (
+	// defaultMultiplexIdle is how long a master connection outlives its last client, in
+	// seconds. It only has to bridge the gap between the login test and the session.
+	defaultMultiplexIdle = 60
+	// defaultMultiplexMax caps the master connections open at once, each of which
+	// holds a server session and local file descriptors
+	defaultMultiplexMax = 10
+)
+
+// socketPathLimit is the longest path of a unix socket on macOS, the shorter of the usual limits
+const socketPathLimit = 103
+
+// controlDir returns the directory for master connection sockets, creating it.
+// It is in the user's runtime directory when there is one, else directly under
+// /tmp because socket paths are limited to about 100 bytes. Anyone can create
+// the latter first, so it is only used when it passes privateDir.
+func controlDir() (string, error) {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		dir := filepath.Join(runtimeDir, "lsh")
+		return dir, os.MkdirAll(dir, 0700)
+	}
+	dir := filepath.Join("/tmp", fmt.Sprintf("lsh-%d", os.Getuid()))
+	if err := os.Mkdir(dir, 0700); err != nil && !errors.Is(err, os.ErrExist) {
+		return "", err
+	}
+	return dir, privateDir(dir)
+}
+
+// privateDir checks that dir is a directory, not a link to one, that belongs to
+// the user and that no one else can enter
+func privateDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() || info.Mode().Perm() != 0700 || !ownedByUser(info) {
+		return fmt.Errorf("%s is not a private directory of the current user", dir)
+	}
+	return nil
+}
+
+// masterConnections returns the running master connections in controlDir
+func masterConnections() []masterConnection {
+	dir, err := controlDir()
+	if err != nil {
+		return nil
+	}
+	return listMasterConnections(dir)
+}
+
+// multiplexIdle returns how many seconds an unused master connection is kept
+func (c appConfig) multiplexIdle() int {
+	if c.MultiplexIdle <= 0 {
+		return defaultMultiplexIdle
+	}
+	return c.MultiplexIdle
+}
+
+// multiplexMax returns how many master connections may be open at once
+func (c appConfig) multiplexMax() int {
+	if c.MultiplexMax <= 0 {
+		return defaultMultiplexMax
+	}
+	return c.MultiplexMax
+}
+
+// multiplexArgs returns the ssh options that let the login test leave a master
+// connection to host behind for the session, so the user authenticates only once.
+// Once the maximum number of master connections is open, existing ones are still
+// used but no new ones are started. It returns nil where ssh cannot multiplex or
+// when disabled in config.json.
+func multiplexArgs(cfg appConfig, host string) []string {
+	if runtime.GOOS == "windows" || cfg.DisableMultiplexing {
+		return nil
+	}
+	dir, err := controlDir()
+	if err != nil {
+		return nil
+	}
+	// Sockets are named after user, alias and port so the connections screen can
+	// tell them apart; a hash keeps long names within the path limit
+	path := filepath.Join(dir, "%r@%n:%p")
+	if len(dir)+len(host)+40 > socketPathLimit {
+		path = filepath.Join(dir, "%C")
+	}
+	master := "auto"
+	if len(listMasterConnections(dir)) >= cfg.multiplexMax() {
+		master = "no"
+	}
+	return []string{
+		"-o", "ControlMaster=" + master,
+		"-o", "ControlPath=" + path,
+		"-o", fmt.Sprintf("ControlPersist=%d", cfg.multiplexIdle()),
+	}
+}
+
+// masterConnection is a live master connection
+type masterConnection struct {
+	name   string // user@alias:port, or a hash for long names
+	socket string
+	pid    int
+}
+
+// masterPID matches the answer of ssh -O check
+var masterPID = regexp.MustCompile(`pid=(\d+)`)
+
+// multiplexArgs returns the multiplexing options for the selected host. Arguments
+// given after -- may change how ssh connects, which a session attaching to the
+// login test's connection would ignore, so they turn multiplexing off. So does
+// a bastion picked with J: the socket is named after the host alone, and a
+// connection to it made without the bastion would be reused.
+func (m *model) multiplexArgs() []string {
+	if len(m.extraSSHArgs) > 0 || m.jumpHost != "" {
+		return nil
+	}
+	return multiplexArgs(m.config, m.selectedHost)
+}
+
+// listMasterConnections returns the master connections in dir that are still running.
+// Sockets left behind by masters that died are removed.
+func listMasterConnections(dir string) []masterConnection {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var conns []masterConnection
+	for _, e := range entries {
+		if e.Type()&os.ModeSocket == 0 {
+			continue
+		}
+		socket := filepath.Join(dir, e.Name())
+		out, err := exec.Command("ssh", "-S", socket, "-O", "check", "master").CombinedOutput()
+		if err != nil {
+			os.Remove(socket)
+			continue
+		}
+		conn := masterConnection{name: e.Name(), socket: socket}
+		if m := masterPID.FindSubmatch(out); m != nil {
+			conn.pid, _ = strconv.Atoi(string(m[1]))
+		}
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// closeMasterConnection ends a master connection and any sessions running over it
+func closeMasterConnection(c masterConnection) error {
+	out, err := exec.Command("ssh", "-S", c.socket, "-O", "exit", "master").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, lastLine(string(out)))
+	}
+	return nil
+}
+
+// masterConnectionsView renders the live master connections with the cursor at selected
+func masterConnectionsView(conns []masterConnection, selected int) string {
+	if len(conns) == 0 {
+		return "No connections are open.\n"
+	}
+	width := 0
+	for _, c := range conns {
+		width = max(width, len(c.name))
+	}
+	var s string
+	for i, c := range conns {
+		cursor := "  "
+		if i == selected {
+			cursor = "> "
+		}
+		s += fmt.Sprintf("%s%-*s  pid %d\n", cursor, width, c.name, c.pid)
+	}
+	return s
+}
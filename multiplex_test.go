@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMultiplexArgs(t *testing.T) {
+	if args := multiplexArgs(appConfig{DisableMultiplexing: true}, "web1"); args != nil {
+		t.Errorf("expected no options when disabled, got %v", args)
+	}
+	args := multiplexArgs(appConfig{}, "web1")
+	if runtime.GOOS == "windows" {
+		if args != nil {
+			t.Errorf("expected no options on windows, got %v", args)
+		}
+		return
+	}
+	dir, err := controlDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(args, "ControlPath="+filepath.Join(dir, "%r@%n:%p")) || !slices.Contains(args, "ControlPersist=60") {
+		t.Errorf("unexpected options %v", args)
+	}
+	args = multiplexArgs(appConfig{MultiplexIdle: 600}, strings.Repeat("x", 80))
+	if !slices.Contains(args, "ControlPath="+filepath.Join(dir, "%C")) || !slices.Contains(args, "ControlPersist=600") {
+		t.Errorf("expected a hashed socket name for a long alias, got %v", args)
+	}
+}
+
+func TestPrivateDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix permissions on windows")
+	}
+	dir := filepath.Join(t.TempDir(), "lsh")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := privateDir(dir); err != nil {
+		t.Errorf("expected %s to be accepted: %v", dir, err)
+	}
+	link := dir + "-link"
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := privateDir(link); err == nil {
+		t.Error("expected a symbolic link to be refused")
+	}
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := privateDir(dir); err == nil {
+		t.Error("expected a directory others can enter to be refused")
+	}
+}
+
+func TestLoginResultWaitDelay(t *testing.T) {
+	if msg := loginResult(exec.ErrWaitDelay, ""); !msg.success {
+		t.Errorf("expected a held stderr after a successful login to count as success, got %+v", msg)
+	}
+	if msg := loginResult(errors.New("exit status 5"), "Permission denied"); msg.success {
+		t.Errorf("expected failure, got %+v", msg)
+	}
+}
+
+func TestListMasterConnections(t *testing.T) {
+	dir := t.TempDir()
+	// A regular file is not a socket and is left alone
+	if err := os.WriteFile(filepath.Join(dir, "notes"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if conns := listMasterConnections(dir); len(conns) != 0 {
+		t.Errorf("expected no connections, got %v", conns)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes")); err != nil {
+		t.Errorf("expected other files to be kept: %v", err)
+	}
+
+	view := masterConnectionsView([]masterConnection{{name: "root@web1:22", pid: 42}, {name: "deploy@db1:2222", pid: 7}}, 1)
+	if view != "  root@web1:22     pid 42\n> deploy@db1:2222  pid 7\n" {
+		t.Errorf("unexpected view:\n%s", view)
+	}
+}
+
+func TestModelMultiplexArgs(t *testing.T) {
+	m := &model{selectedHost: "web1", extraSSHArgs: []string{"-o", "User=admin"}}
+	if args := m.multiplexArgs(); args != nil {
+		t.Errorf("expected no multiplexing with arguments after --, got %v", args)
+	}
+	m = &model{selectedHost: "web1", jumpHost: "bastion"}
+	if args := m.multiplexArgs(); args != nil {
+		t.Errorf("expected no multiplexing through a bastion, got %v", args)
+	}
+}
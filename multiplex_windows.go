@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// ownedByUser is always true on Windows, which does not multiplex connections
+func ownedByUser(info os.FileInfo) bool {
+	return true
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/term"
+)
+
+var errChallengeCancelled = errors.New("challenge cancelled")
+
+// errNativeProxyJump is returned for hosts behind a ProxyJump, which the built-in
+// client cannot connect through; connecting directly would bypass the bastion
+var errNativeProxyJump = errors.New("the built-in SSH client cannot connect through a ProxyJump")
+
+// sshTarget is where OpenSSH would connect for a host alias
+type sshTarget struct {
+	hostname      string
+	user          string
+	port          string
+	identityFiles []string
+	// certificateFiles lists the explicit CertificateFile options
+	certificateFiles []string
+	knownHostsFiles  []string
+	proxyJump        string
+}
+
+// challengeMsg carries a keyboard-interactive round from the server to the TUI.
+// The answers must be sent on reply, or nil to abort the login.
+type challengeMsg struct {
+	name        string
+	instruction string
+	questions   []string
+	echos       []bool
+	reply       chan []string
+}
+
+// nativeLoginMsg reports the outcome of a login with the built-in SSH client
+type nativeLoginMsg struct {
+	client *ssh.Client
+	err    error
+}
+
+// resolveSSHTarget asks OpenSSH for the effective hostname, user and port of a host
+func resolveSSHTarget(host string) (sshTarget, error) {
+	out, err := exec.Command("ssh", "-G", host).Output()
+	if err != nil {
+		return sshTarget{}, err
+	}
+	return parseSSHG(string(out)), nil
+}
+
+// parseSSHG reads the options of interest from `ssh -G` output
+func parseSSHG(out string) sshTarget {
+	t := sshTarget{port: "22"}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "hostname":
+			t.hostname = value
+		case "user":
+			t.user = value
+		case "port":
+			t.port = value
+		case "identityfile":
+			t.identityFiles = append(t.identityFiles, value)
+		case "certificatefile":
+			t.certificateFiles = append(t.certificateFiles, value)
+		case "userknownhostsfile":
+			t.knownHostsFiles = strings.Fields(value)
+		case "proxyjump":
+			if value != "none" {
+				t.proxyJump = value
+			}
+		}
+	}
+	return t
+}
+
+// nativeLogin connects with the built-in SSH client, relaying keyboard-interactive
+// prompts (OTP, Duo) to the TUI over events. Questions asking for the password are
+// answered with it automatically. When keyFile is set, secret is its passphrase instead.
+func nativeLogin(host, secret, keyFile string, timeout time.Duration, events chan<- tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		client, err := dialNative(host, secret, keyFile, timeout, events)
+		events <- nativeLoginMsg{client: client, err: err}
+		return nil
+	}
+}
+
+// waitForNative delivers the next event of a native login to the TUI
+func waitForNative(events <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+func dialNative(host, secret, keyFile string, timeout time.Duration, events chan<- tea.Msg) (*ssh.Client, error) {
+	target, err := resolveSSHTarget(host)
+	if err != nil {
+		return nil, err
+	}
+	if target.proxyJump != "" {
+		return nil, fmt.Errorf("%w (%s); remove \"native_client\" from the host in hosts.json to connect with ssh", errNativeProxyJump, target.proxyJump)
+	}
+	password := secret
+	var auth []ssh.AuthMethod
+	if keyFile != "" {
+		password = ""
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKeyWithPassphrase(content, []byte(secret))
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if target.user == "" {
+		if usr, err := user.Current(); err == nil {
+			target.user = usr.Username
+		}
+	}
+
+	challenge := func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		if len(questions) == 1 && password != "" && strings.Contains(strings.ToLower(questions[0]), "password") {
+			return []string{password}, nil
+		}
+		reply := make(chan []string)
+		events <- challengeMsg{name: name, instruction: instruction, questions: questions, echos: echos, reply: reply}
+		answers := <-reply
+		if answers == nil {
+			return nil, errChallengeCancelled
+		}
+		return answers, nil
+	}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	if password != "" {
+		auth = append(auth, ssh.Password(password))
+	}
+	auth = append(auth, ssh.KeyboardInteractive(challenge))
+
+	return ssh.Dial("tcp", net.JoinHostPort(target.hostname, target.port), &ssh.ClientConfig{
+		User:            target.user,
+		Auth:            auth,
+		HostKeyCallback: knownHostsCallback(target.knownHostsFiles),
+		Timeout:         timeout,
+	})
+}
+
+// knownHostsCallback verifies host keys against the host's UserKnownHostsFile
+// entries, as ssh does. The key was already verified on the host key screen, so
+// anything else is rejected.
+func knownHostsCallback(files []string) ssh.HostKeyCallback {
+	existing := existingFiles(files)
+	if len(existing) == 0 {
+		return rejectHostKey(errors.New("no known_hosts file"))
+	}
+	check, err := knownhosts.New(existing...)
+	if err != nil {
+		return rejectHostKey(err)
+	}
+	return check
+}
+
+func rejectHostKey(err error) ssh.HostKeyCallback {
+	return func(string, net.Addr, ssh.PublicKey) error {
+		return fmt.Errorf("cannot verify host key: %w", err)
+	}
+}
+
+// runNativeSession opens an interactive session over an established native connection,
+// running command or, when it is empty, the user's login shell. termType is announced
+// with the terminal request, defaulting to the local TERM.
+func runNativeSession(client *ssh.Client, command, termType string) error {
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdin = os.Stdin
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer term.Restore(fd, state)
+
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+		if termType == "" {
+			termType = os.Getenv("TERM")
+		}
+		if termType == "" {
+			termType = "xterm-256color"
+		}
+		if err := session.RequestPty(termType, height, width, ssh.TerminalModes{ssh.ECHO: 1}); err != nil {
+			return err
+		}
+		stop := watchWindowSize(session, fd)
+		defer stop()
+	}
+
+	if command != "" {
+		err = session.Start(command)
+	} else {
+		err = session.Shell()
+	}
+	if err != nil {
+		return err
+	}
+	return session.Wait()
+}
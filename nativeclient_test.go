@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestParseSSHG(t *testing.T) {
+	out := `user deploy
+hostname 198.51.100.50
+port 2222
+identityfile ~/.ssh/id_ed25519
+`
+	target := parseSSHG(out)
+	if target.hostname != "198.51.100.50" || target.user != "deploy" || target.port != "2222" {
+		t.Errorf("unexpected target %+v", target)
+	}
+}
+
+func TestParseSSHG_DefaultPort(t *testing.T) {
+	target := parseSSHG("hostname example.com\n")
+	if target.port != "22" {
+		t.Errorf("expected default port 22, got %q", target.port)
+	}
+}
+
+func TestParseSSHG_IdentityFiles(t *testing.T) {
+	target := parseSSHG("identityfile ~/.ssh/id_rsa\nidentityfile ~/.ssh/id_ed25519\n")
+	if len(target.identityFiles) != 2 || target.identityFiles[1] != "~/.ssh/id_ed25519" {
+		t.Errorf("unexpected identity files %v", target.identityFiles)
+	}
+}
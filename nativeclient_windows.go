@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "golang.org/x/crypto/ssh"
+
+// watchWindowSize is a no-op on Windows, which has no SIGWINCH
+func watchWindowSize(session *ssh.Session, fd int) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxDiffCells bounds the table diffLines fills; outputs whose differing parts
+// are larger are shown as entirely replaced
+const maxDiffCells = 4_000_000
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// outputGroup is an output of a broadcast with the hosts that printed exactly it
+type outputGroup struct {
+	output string
+	hosts  []string
+}
+
+// groupOutputs groups the finished hosts of a broadcast by identical output,
+// the most common output first and ties in host order
+func groupOutputs(hosts []broadcastHost) []outputGroup {
+	var groups []outputGroup
+	index := map[string]int{}
+	for _, h := range hosts {
+		if !h.done {
+			continue
+		}
+		i, ok := index[h.output]
+		if !ok {
+			i = len(groups)
+			index[h.output] = i
+			groups = append(groups, outputGroup{output: h.output})
+		}
+		groups[i].hosts = append(groups[i].hosts, h.host)
+	}
+	// Insertion sort keeps the host order of equally common outputs
+	for i := 1; i < len(groups); i++ {
+		for j := i; j > 0 && len(groups[j].hosts) > len(groups[j-1].hosts); j-- {
+			groups[j], groups[j-1] = groups[j-1], groups[j]
+		}
+	}
+	return groups
+}
+
+// diffLine is a line of a diff: kept (' '), removed ('-') or added ('+')
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// diffLines returns the edits turning a into b, from their longest common subsequence
+func diffLines(a, b []string) []diffLine {
+	var prefix, suffix []diffLine
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, diffLine{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append([]diffLine{{' ', a[len(a)-1]}}, suffix...)
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	lines := prefix
+	if len(a)*len(b) > maxDiffCells {
+		for _, l := range a {
+			lines = append(lines, diffLine{'-', l})
+		}
+		for _, l := range b {
+			lines = append(lines, diffLine{'+', l})
+		}
+		return append(lines, suffix...)
+	}
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i, j = i+1, j+1
+		case j == len(b) || i < len(a) && common[i+1][j] >= common[i][j+1]:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	return append(lines, suffix...)
+}
+
+// unifiedDiff renders the changes of lines as hunks with diffContext lines of
+// context, as diff -u does, coloring removed lines red and added ones green
+func unifiedDiff(lines []diffLine) string {
+	removed := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	added := lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	var b strings.Builder
+	for start := 0; start < len(lines); {
+		// Find the next change and the end of its hunk
+		first := start
+		for first < len(lines) && lines[first].op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		from := max(start, first-diffContext)
+		end, unchanged := first, 0
+		for end < len(lines) && unchanged <= 2*diffContext {
+			if lines[end].op == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+			end++
+		}
+		end -= max(0, unchanged-diffContext)
+
+		// Line numbers of the hunk in the old and the new output
+		oldLine, newLine := 1, 1
+		for _, l := range lines[:from] {
+			if l.op != '+' {
+				oldLine++
+			}
+			if l.op != '-' {
+				newLine++
+			}
+		}
+		var oldCount, newCount int
+		for _, l := range lines[from:end] {
+			if l.op != '+' {
+				oldCount++
+			}
+			if l.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+		for _, l := range lines[from:end] {
+			text := string(l.op) + l.text
+			switch l.op {
+			case '-':
+				text = removed.Render(text)
+			case '+':
+				text = added.Render(text)
+			}
+			b.WriteString(text + "\n")
+		}
+		start = end
+	}
+	return b.String()
+}
+
+// outputLines splits an output into lines, without an empty last one
+func outputLines(output string) []string {
+	if output == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+}
+
+// outputDiffView lists the distinct outputs of a broadcast with their hosts and
+// shows how each differs from the most common one
+func outputDiffView(groups []outputGroup) string {
+	var b strings.Builder
+	switch len(groups) {
+	case 0:
+		return "No host has finished yet.\n"
+	case 1:
+		fmt.Fprintf(&b, "All %d hosts printed the same output.\n", len(groups[0].hosts))
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%d distinct outputs:\n", len(groups))
+	for i, g := range groups {
+		fmt.Fprintf(&b, "  %c: %s\n", 'A'+i, strings.Join(g.hosts, ", "))
+	}
+	base := outputLines(groups[0].output)
+	for i, g := range groups[1:] {
+		fmt.Fprintf(&b, "\n--- A (%s)\n+++ %c (%s)\n", strings.Join(groups[0].hosts, ", "), 'B'+i, strings.Join(g.hosts, ", "))
+		b.WriteString(unifiedDiff(diffLines(base, outputLines(g.output))))
+	}
+	return b.String()
+}
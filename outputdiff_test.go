@@ -0,0 +1,66 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGroupOutputs(t *testing.T) {
+	groups := groupOutputs([]broadcastHost{
+		{host: "web1", done: true, output: "1.2\n"},
+		{host: "db", done: true, output: "1.1\n"},
+		{host: "web2", done: true, output: "1.2\n"},
+		{host: "edge", output: "1.0\n"},
+	})
+	if len(groups) != 2 || !slices.Equal(groups[0].hosts, []string{"web1", "web2"}) || !slices.Equal(groups[1].hosts, []string{"db"}) {
+		t.Errorf("unexpected groups %+v", groups)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines(strings.Split("a b c d", " "), strings.Split("a x c d e", " "))
+	var ops strings.Builder
+	for _, l := range got {
+		ops.WriteString(string(l.op) + l.text + " ")
+	}
+	if want := " a -b +x  c  d +e "; ops.String() != want {
+		t.Errorf("expected %q, got %q", want, ops.String())
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	var a, b []string
+	for i := 1; i <= 20; i++ {
+		a = append(a, strings.Repeat("x", i))
+	}
+	b = slices.Clone(a)
+	b[9] = "changed"
+	got := unifiedDiff(diffLines(a, b))
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if lines[0] != "@@ -7,7 +7,7 @@" {
+		t.Errorf("unexpected hunk header %q", lines[0])
+	}
+	if len(lines) != 9 {
+		t.Errorf("expected a hunk of 8 lines, got\n%s", got)
+	}
+	if unifiedDiff(diffLines(a, a)) != "" {
+		t.Error("expected no hunks for equal outputs")
+	}
+}
+
+func TestOutputDiffView(t *testing.T) {
+	same := outputDiffView([]outputGroup{{output: "ok\n", hosts: []string{"a", "b"}}})
+	if same != "All 2 hosts printed the same output.\n" {
+		t.Errorf("unexpected view %q", same)
+	}
+	view := outputDiffView([]outputGroup{
+		{output: "nginx 1.24\n", hosts: []string{"web1", "web2"}},
+		{output: "nginx 1.22\n", hosts: []string{"web3"}},
+	})
+	for _, want := range []string{"2 distinct outputs:", "A: web1, web2", "B: web3", "--- A (web1, web2)", "+++ B (web3)", "@@ -1,1 +1,1 @@"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view missing %q in\n%s", want, view)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import "sync"
+
+// runParallel calls run for every host on at most workers goroutines, or on one
+// per host when workers is 0, and returns once all hosts are done. Hosts are
+// started in order.
+func runParallel(hosts []string, workers int, run func(host string)) {
+	if workers <= 0 || workers > len(hosts) {
+		workers = len(hosts)
+	}
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range queue {
+				run(host)
+			}
+		}()
+	}
+	for _, h := range hosts {
+		queue <- h
+	}
+	close(queue)
+	wg.Wait()
+}
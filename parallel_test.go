@@ -0,0 +1,42 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunParallelLimitsWorkers(t *testing.T) {
+	hosts := []string{"a", "b", "c", "d", "e", "f"}
+	var mu sync.Mutex
+	running, peak := 0, 0
+	seen := map[string]bool{}
+	runParallel(hosts, 2, func(host string) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		seen[host] = true
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+	if peak != 2 {
+		t.Errorf("peak workers = %d, want 2", peak)
+	}
+	if len(seen) != len(hosts) {
+		t.Errorf("ran on %d hosts, want %d", len(seen), len(hosts))
+	}
+}
+
+func TestRunParallelAllHostsByDefault(t *testing.T) {
+	hosts := []string{"a", "b", "c"}
+	var wg sync.WaitGroup
+	wg.Add(len(hosts))
+	// Every host waits for the others, which only finishes when all run at once
+	runParallel(hosts, 0, func(string) {
+		wg.Done()
+		wg.Wait()
+	})
+}
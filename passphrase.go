@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// sshpassCommand runs ssh with sshArgs through sshpass, which feeds secret at the password
+// prompt by default, or at the key passphrase prompt when keyFile is set. The secret goes
+// through a pipe rather than -p, where it would show up in the process list; the returned
+// file is the pipe's read end, to be closed once the command has started.
+func sshpassCommand(ctx context.Context, secret []byte, keyFile string, sshArgs ...string) (*exec.Cmd, *os.File, error) {
+	return sshpassProgram(ctx, secret, keyFile, "ssh", sshArgs...)
+}
+
+// sshpassProgram is sshpassCommand for another program that authenticates with ssh, such as sftp
+func sshpassProgram(ctx context.Context, secret []byte, keyFile, program string, args ...string) (*exec.Cmd, *os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	// sshpass reads up to the end of the pipe, so no newline needs to be appended
+	_, err = w.Write(secret)
+	w.Close()
+	if err != nil {
+		r.Close()
+		return nil, nil, err
+	}
+	passArgs := []string{"-d", "3"}
+	if keyFile != "" {
+		passArgs = append(passArgs, "-P", "passphrase")
+	}
+	passArgs = append(append(passArgs, program), args...)
+	cmd := exec.CommandContext(ctx, "sshpass", passArgs...)
+	cmd.ExtraFiles = []*os.File{r}
+	return cmd, r, nil
+}
+
+// encryptedIdentity returns the first passphrase-protected IdentityFile of a host,
+// or "" when its keys need no passphrase or an agent can provide them
+func encryptedIdentity(host string) string {
+	if agentHasKeys() {
+		return ""
+	}
+	target, err := resolveSSHTarget(host)
+	if err != nil {
+		return ""
+	}
+	for _, f := range target.identityFiles {
+		if path := expandHome(f); keyNeedsPassphrase(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// agentHasKeys reports whether an SSH agent is running and holds at least one key
+func agentHasKeys() bool {
+	keys, err := agentKeys()
+	return err == nil && len(keys) > 0
+}
+
+// expandHome replaces a leading ~/ with the current user's home directory
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(usr.HomeDir, rest)
+}
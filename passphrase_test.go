@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestSSHPassCommand(t *testing.T) {
+	cmd, r, err := sshpassCommand(context.Background(), []byte("pw"), "", "host", "exit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if got := cmd.Args[1:]; !reflect.DeepEqual(got, []string{"-d", "3", "ssh", "host", "exit"}) {
+		t.Errorf("unexpected password args %v", got)
+	}
+	if slices.Contains(cmd.Args, "pw") {
+		t.Error("the password must not be passed on the command line")
+	}
+	secret, err := io.ReadAll(r)
+	if err != nil || string(secret) != "pw" {
+		t.Errorf("expected the password in the pipe, got %q (%v)", secret, err)
+	}
+
+	cmd, r, err = sshpassCommand(context.Background(), []byte("pw"), "/home/u/.ssh/id_ed25519", "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if got := cmd.Args[1:]; !reflect.DeepEqual(got, []string{"-d", "3", "-P", "passphrase", "ssh", "host"}) {
+		t.Errorf("unexpected passphrase args %v", got)
+	}
+}
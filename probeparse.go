@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commands whose output the parsers below understand
+const (
+	diskProbeCommand      = "df -P"
+	memoryProbeCommand    = "free -b"
+	osReleaseProbeCommand = "cat /etc/os-release"
+)
+
+// diskUsage is one file system from df -P, with sizes in bytes
+type diskUsage struct {
+	filesystem string
+	size       int64
+	used       int64
+	available  int64
+	capacity   int // used space in percent, as df rounds it
+	mount      string
+}
+
+// uptimeInfo is the content of an uptime line
+type uptimeInfo struct {
+	up    time.Duration
+	users int
+	load  [3]float64 // 1, 5 and 15 minute load averages
+}
+
+// memoryInfo is the output of free -b, in bytes
+type memoryInfo struct {
+	total     int64
+	used      int64
+	free      int64
+	available int64 // 0 when free is too old to report it
+	swapTotal int64
+	swapUsed  int64
+}
+
+// usedPercent returns the share of memory in use
+func (m memoryInfo) usedPercent() float64 {
+	if m.total == 0 {
+		return 0
+	}
+	return float64(m.used) / float64(m.total) * 100
+}
+
+// osRelease holds the fields of /etc/os-release that identify a system
+type osRelease struct {
+	id         string
+	name       string
+	versionID  string
+	prettyName string
+}
+
+// parseDF parses df -P output. The header tells the block size: 1024 on Linux, 512 on macOS.
+func parseDF(out string) ([]diskUsage, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	header := strings.Fields(lines[0])
+	if len(header) < 2 || !strings.HasSuffix(header[1], "-blocks") {
+		return nil, errors.New("no df -P header")
+	}
+	blockSize, err := strconv.ParseInt(strings.TrimSuffix(header[1], "-blocks"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected block size %q", header[1])
+	}
+	var disks []diskUsage
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		var nums [3]int64
+		for i := range nums {
+			if nums[i], err = strconv.ParseInt(fields[i+1], 10, 64); err != nil {
+				return nil, fmt.Errorf("unexpected df line %q", line)
+			}
+		}
+		capacity, err := strconv.Atoi(strings.TrimSuffix(fields[4], "%"))
+		if err != nil {
+			// File systems without blocks, like /proc on some systems, report "-"
+			capacity = 0
+		}
+		disks = append(disks, diskUsage{
+			filesystem: fields[0],
+			size:       nums[0] * blockSize,
+			used:       nums[1] * blockSize,
+			available:  nums[2] * blockSize,
+			capacity:   capacity,
+			// Mount points may contain spaces
+			mount: strings.Join(fields[5:], " "),
+		})
+	}
+	return disks, nil
+}
+
+// parseUptime parses an uptime line from Linux, BusyBox or macOS, e.g.
+// " 10:01:02 up 3 days,  2:03,  1 user,  load average: 0.10, 0.20, 0.30"
+func parseUptime(line string) (uptimeInfo, error) {
+	var info uptimeInfo
+	idx := strings.Index(line, "load average")
+	if idx < 0 {
+		return info, errors.New("no load average in uptime output")
+	}
+	// Linux prints "load average: 0.10, 0.20, 0.30", macOS "load averages: 1.10 1.20 1.30"
+	_, rest, _ := strings.Cut(line[idx:], ":")
+	fields := strings.Fields(strings.ReplaceAll(rest, ",", " "))
+	if len(fields) == 0 {
+		return info, errors.New("no load average in uptime output")
+	}
+	for i := 0; i < len(fields) && i < len(info.load); i++ {
+		load, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return info, err
+		}
+		info.load[i] = load
+	}
+
+	_, since, ok := strings.Cut(line[:idx], " up ")
+	if !ok {
+		return info, nil
+	}
+	for _, part := range strings.Split(since, ",") {
+		part = strings.TrimSpace(part)
+		if h, m, ok := strings.Cut(part, ":"); ok {
+			hours, err1 := strconv.Atoi(h)
+			minutes, err2 := strconv.Atoi(m)
+			if err1 == nil && err2 == nil {
+				info.up += time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute
+			}
+			continue
+		}
+		value, unit, ok := strings.Cut(part, " ")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		switch unit = strings.TrimSpace(unit); {
+		case strings.HasPrefix(unit, "day"):
+			info.up += time.Duration(n) * 24 * time.Hour
+		case strings.HasPrefix(unit, "hr"):
+			info.up += time.Duration(n) * time.Hour
+		case strings.HasPrefix(unit, "min"):
+			info.up += time.Duration(n) * time.Minute
+		case strings.HasPrefix(unit, "sec"):
+			info.up += time.Duration(n) * time.Second
+		case strings.HasPrefix(unit, "user"):
+			info.users = n
+		}
+	}
+	return info, nil
+}
+
+// parseFree parses free -b output by its column names, which differ between versions
+func parseFree(out string) (memoryInfo, error) {
+	var mem memoryInfo
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	columns := strings.Fields(lines[0])
+	found := false
+	for _, line := range lines[1:] {
+		label, rest, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		values := map[string]int64{}
+		for i, f := range strings.Fields(rest) {
+			if i >= len(columns) {
+				break
+			}
+			if n, err := strconv.ParseInt(f, 10, 64); err == nil {
+				values[columns[i]] = n
+			}
+		}
+		switch label {
+		case "Mem":
+			found = true
+			mem.total = values["total"]
+			mem.used = values["used"]
+			mem.free = values["free"]
+			mem.available = values["available"]
+		case "Swap":
+			mem.swapTotal = values["total"]
+			mem.swapUsed = values["used"]
+		}
+	}
+	if !found {
+		return mem, errors.New("no Mem line in free output")
+	}
+	return mem, nil
+}
+
+// parseOSRelease parses the KEY=value lines of /etc/os-release
+func parseOSRelease(out string) osRelease {
+	var rel osRelease
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil && strings.HasPrefix(value, `"`) {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `'"`)
+		}
+		switch key {
+		case "ID":
+			rel.id = value
+		case "NAME":
+			rel.name = value
+		case "VERSION_ID":
+			rel.versionID = value
+		case "PRETTY_NAME":
+			rel.prettyName = value
+		}
+	}
+	return rel
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDF(t *testing.T) {
+	linux := `Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/sda1         41152812 12345678  26683708      32% /
+tmpfs              8131520        0   8131520       0% /dev/shm
+/dev/sdb1          1000000   500000    500000      50% /mnt/backup disk
+`
+	disks, err := parseDF(linux)
+	if err != nil {
+		t.Fatalf("parseDF failed: %v", err)
+	}
+	if len(disks) != 3 {
+		t.Fatalf("expected 3 file systems, got %d", len(disks))
+	}
+	root := disks[0]
+	if root.filesystem != "/dev/sda1" || root.mount != "/" || root.size != 41152812*1024 || root.used != 12345678*1024 || root.available != 26683708*1024 || root.capacity != 32 {
+		t.Errorf("unexpected root file system %+v", root)
+	}
+	if disks[2].mount != "/mnt/backup disk" {
+		t.Errorf("expected the mount point with a space, got %q", disks[2].mount)
+	}
+
+	macos := `Filesystem     512-blocks      Used Available Capacity  Mounted on
+/dev/disk3s1s1  965595304  20091008 426773784     5%    /
+`
+	disks, err = parseDF(macos)
+	if err != nil {
+		t.Fatalf("parseDF failed: %v", err)
+	}
+	if len(disks) != 1 || disks[0].size != 965595304*512 {
+		t.Errorf("expected 512-byte blocks to be converted, got %+v", disks)
+	}
+
+	if _, err := parseDF("command not found"); err == nil {
+		t.Error("expected an error without a df header")
+	}
+}
+
+func TestParseUptime(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		up    time.Duration
+		users int
+		load  [3]float64
+	}{
+		{
+			name:  "linux",
+			line:  " 10:01:02 up 3 days,  2:03,  1 user,  load average: 4.00, 3.50, 3.00",
+			up:    3*24*time.Hour + 2*time.Hour + 3*time.Minute,
+			users: 1,
+			load:  [3]float64{4, 3.5, 3},
+		},
+		{
+			name:  "linux minutes",
+			line:  " 10:01:02 up 45 min,  2 users,  load average: 0.10, 0.20, 0.30",
+			up:    45 * time.Minute,
+			users: 2,
+			load:  [3]float64{0.1, 0.2, 0.3},
+		},
+		{
+			name:  "macos",
+			line:  "10:01  up 1 day, 5 mins, 3 users, load averages: 2.00 1.50 1.00",
+			up:    24*time.Hour + 5*time.Minute,
+			users: 3,
+			load:  [3]float64{2, 1.5, 1},
+		},
+		{
+			name: "busybox",
+			line: " 10:01:02 up 12:30,  load average: 0.00, 0.01, 0.05",
+			up:   12*time.Hour + 30*time.Minute,
+			load: [3]float64{0, 0.01, 0.05},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := parseUptime(tt.line)
+			if err != nil {
+				t.Fatalf("parseUptime failed: %v", err)
+			}
+			if info.up != tt.up || info.users != tt.users || info.load != tt.load {
+				t.Errorf("unexpected uptime %+v", info)
+			}
+		})
+	}
+	if _, err := parseUptime("garbage"); err == nil {
+		t.Error("expected an error without a load average")
+	}
+}
+
+func TestParseFree(t *testing.T) {
+	current := `               total        used        free      shared  buff/cache   available
+Mem:     16654544896  4294967296  2147483648   104857600 10212093952 12000000000
+Swap:     2147479552   104857600  2042621952
+`
+	mem, err := parseFree(current)
+	if err != nil {
+		t.Fatalf("parseFree failed: %v", err)
+	}
+	if mem.total != 16654544896 || mem.used != 4294967296 || mem.free != 2147483648 || mem.available != 12000000000 || mem.swapTotal != 2147479552 || mem.swapUsed != 104857600 {
+		t.Errorf("unexpected memory %+v", mem)
+	}
+	if p := mem.usedPercent(); p < 25.7 || p > 25.8 {
+		t.Errorf("unexpected used percentage %v", p)
+	}
+
+	old := `             total       used       free     shared    buffers     cached
+Mem:    8253472768 7948296192  305176576          0  331300864 5846810624
+-/+ buffers/cache: 1770184704 6483288064
+Swap:   2147479552          0 2147479552
+`
+	mem, err = parseFree(old)
+	if err != nil {
+		t.Fatalf("parseFree failed: %v", err)
+	}
+	if mem.total != 8253472768 || mem.available != 0 || mem.swapTotal != 2147479552 {
+		t.Errorf("unexpected memory from old free %+v", mem)
+	}
+
+	if _, err := parseFree("free: command not found"); err == nil {
+		t.Error("expected an error without a Mem line")
+	}
+}
+
+func TestParseOSRelease(t *testing.T) {
+	out := `# comment
+NAME="Ubuntu"
+VERSION_ID="22.04"
+ID=ubuntu
+PRETTY_NAME="Ubuntu 22.04.4 LTS"
+`
+	rel := parseOSRelease(out)
+	if rel.id != "ubuntu" || rel.name != "Ubuntu" || rel.versionID != "22.04" || rel.prettyName != "Ubuntu 22.04.4 LTS" {
+		t.Errorf("unexpected os-release %+v", rel)
+	}
+	if rel := parseOSRelease("NAME='Alpine Linux'\n"); rel.name != "Alpine Linux" {
+		t.Errorf("expected single quotes to be removed, got %q", rel.name)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sessionCommand returns the remote command for an interactive session. It is empty
+// by default, so the host starts the user's login shell (or the RemoteCommand of the
+// SSH config) as plain ssh would. A command set in the host's metadata, or else in the
+// app config, is run instead. With prompt injection enabled, the host alias and
+// environment are exported as LSH_HOST and LSH_ENV, and a snippet prefixes the bash
+// prompt with them in the environment's color. The host's startup commands run
+// first, or at the end of the snippet, followed by the login shell or command.
+// Without prompt injection they run in the shell that ssh starts for the command,
+// before the login shell replaces it: a cd or an export carries over, aliases and
+// functions do not, and an interactive command such as sudo -i holds the session
+// until it exits. Any command replaces the RemoteCommand of the SSH config.
+// With tmux sessions on, all of it runs in a tmux session named after the host,
+// which later connections attach to again.
+func sessionCommand(host string, meta hostMeta, cfg appConfig) string {
+	command := shellCommand(host, meta, cfg)
+	if !meta.TmuxSession && !cfg.TmuxSessions {
+		return command
+	}
+	tmux := "tmux new-session -A -s " + shellQuote(tmuxSessionName(host))
+	fallback := "exec $SHELL -l"
+	if command != "" {
+		// The command only runs when the session is created; attaching ignores it
+		tmux += " " + shellQuote(command)
+		fallback = command
+	}
+	// Hosts without tmux get the plain session
+	return "command -v tmux >/dev/null && exec " + tmux + " || " + fallback
+}
+
+// tmuxSessionName returns the name of the tmux session of host; tmux does not
+// allow dots and colons in session names
+func tmuxSessionName(host string) string {
+	return strings.NewReplacer(".", "_", ":", "_").Replace(host)
+}
+
+// shellCommand returns the remote command of a session outside tmux, see sessionCommand
+func shellCommand(host string, meta hostMeta, cfg appConfig) string {
+	startup := strings.Join(meta.Startup, "; ")
+	command := meta.Command
+	if command == "" {
+		command = cfg.Command
+	}
+	if command != "" {
+		if startup != "" {
+			return startup + "; " + command
+		}
+		return command
+	}
+	if !cfg.PromptInjection {
+		if startup != "" {
+			return startup + "; exec $SHELL -l"
+		}
+		return ""
+	}
+	snippet := promptSnippet(host, meta.Environment, cfg.EnvironmentColors)
+	for _, c := range meta.Startup {
+		snippet += c + "\n"
+	}
+	rc := base64.StdEncoding.EncodeToString([]byte(snippet))
+	// The base64 alphabet needs no quoting, so the snippet survives the remote login shell intact
+	return fmt.Sprintf("env LSH_HOST=%s LSH_ENV=%s bash -c 'exec bash --rcfile <(echo %s | base64 -d) -i'",
+		shellQuote(host), shellQuote(meta.Environment), rc)
+}
+
+// sessionTerm returns the TERM to announce to the host, from the host's metadata or
+// else the app config. It is empty when the local TERM should be used.
+func sessionTerm(meta hostMeta, cfg appConfig) string {
+	if meta.Term != "" {
+		return meta.Term
+	}
+	return cfg.Term
+}
+
+// promptSnippet is a bash rc file that loads the usual login files, then prefixes PS1
+func promptSnippet(host, env string, colors map[string]string) string {
+	label := host
+	if env != "" {
+		label = strings.ToUpper(env) + " " + host
+	}
+	return strings.Join([]string{
+		"[ -f /etc/profile ] && . /etc/profile",
+		"if [ -f ~/.bash_profile ]; then . ~/.bash_profile; elif [ -f ~/.profile ]; then . ~/.profile; elif [ -f ~/.bashrc ]; then . ~/.bashrc; fi",
+		fmt.Sprintf(`PS1='\[\e[1;97;%sm\] %s \[\e[0m\] '"$PS1"`, ansiBackground(string(environmentColor(env, colors))), label),
+	}, "\n") + "\n"
+}
+
+// ansiBackground converts a #RRGGBB color to a 24-bit ANSI background parameter
+func ansiBackground(hex string) string {
+	v, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(hex, "#")) != 6 {
+		return "45" // magenta
+	}
+	return fmt.Sprintf("48;2;%d;%d;%d", v>>16&0xff, v>>8&0xff, v&0xff)
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
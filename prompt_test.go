@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestSessionCommand_Default(t *testing.T) {
+	if got := sessionCommand("db1", hostMeta{}, appConfig{}); got != "" {
+		t.Errorf("expected the login shell to be left to the host, got %q", got)
+	}
+	meta := hostMeta{Command: "tmux new -A -s main"}
+	cfg := appConfig{PromptInjection: true, Command: "bash --login", Term: "xterm-256color"}
+	if got := sessionCommand("db1", meta, cfg); got != meta.Command {
+		t.Errorf("expected the host's command, got %q", got)
+	}
+	if got := sessionCommand("db1", hostMeta{}, cfg); got != cfg.Command {
+		t.Errorf("expected the configured command, got %q", got)
+	}
+	if got := sessionTerm(hostMeta{Term: "vt100"}, cfg); got != "vt100" {
+		t.Errorf("expected the host's TERM, got %q", got)
+	}
+	if got := sessionTerm(hostMeta{}, cfg); got != cfg.Term {
+		t.Errorf("expected the configured TERM, got %q", got)
+	}
+	if got := sessionTerm(hostMeta{}, appConfig{}); got != "" {
+		t.Errorf("expected the local TERM to be kept, got %q", got)
+	}
+}
+
+func TestSessionCommand_PromptInjection(t *testing.T) {
+	cfg := appConfig{PromptInjection: true}
+	got := sessionCommand("db1", hostMeta{Environment: "prod"}, cfg)
+	if !strings.Contains(got, "LSH_HOST='db1' LSH_ENV='prod'") {
+		t.Errorf("expected exported variables, got %q", got)
+	}
+
+	start := strings.Index(got, "echo ") + len("echo ")
+	end := strings.Index(got, " | base64 -d")
+	rc, err := base64.StdEncoding.DecodeString(got[start:end])
+	if err != nil {
+		t.Fatalf("snippet is not valid base64: %v", err)
+	}
+	if !strings.Contains(string(rc), "48;2;215;0;0m") || !strings.Contains(string(rc), " PROD db1 ") {
+		t.Errorf("expected colored prompt label in snippet, got:\n%s", rc)
+	}
+}
+
+func TestSessionCommand_Startup(t *testing.T) {
+	meta := hostMeta{Startup: []string{"cd /var/www", "sudo -i"}}
+	if got, want := sessionCommand("web1", meta, appConfig{}), "cd /var/www; sudo -i; exec $SHELL -l"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	meta.Command = "tmux new -A -s main"
+	if got, want := sessionCommand("web1", meta, appConfig{}), "cd /var/www; sudo -i; tmux new -A -s main"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	got := sessionCommand("web1", hostMeta{Startup: meta.Startup}, appConfig{PromptInjection: true})
+	start := strings.Index(got, "echo ") + len("echo ")
+	end := strings.Index(got, " | base64 -d")
+	rc, err := base64.StdEncoding.DecodeString(got[start:end])
+	if err != nil {
+		t.Fatalf("snippet is not valid base64: %v", err)
+	}
+	if !strings.HasSuffix(string(rc), "\ncd /var/www\nsudo -i\n") {
+		t.Errorf("expected the startup commands at the end of the snippet, got:\n%s", rc)
+	}
+}
+
+func TestSessionCommand_Tmux(t *testing.T) {
+	want := "command -v tmux >/dev/null && exec tmux new-session -A -s 'web1_example_com' || exec $SHELL -l"
+	if got := sessionCommand("web1.example.com", hostMeta{TmuxSession: true}, appConfig{}); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	want = "command -v tmux >/dev/null && exec tmux new-session -A -s 'db1' 'cd /srv; exec $SHELL -l' || cd /srv; exec $SHELL -l"
+	if got := sessionCommand("db1", hostMeta{Startup: []string{"cd /srv"}}, appConfig{TmuxSessions: true}); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if got := shellQuote("it's"); got != `'it'\''s'` {
+		t.Errorf("unexpected quoting %s", got)
+	}
+}
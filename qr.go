@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// sshURL returns the ssh:// URL mobile clients (Termius, Blink) open for a target
+func sshURL(t sshTarget) string {
+	u := url.URL{Scheme: "ssh", Host: net.JoinHostPort(t.hostname, t.port)}
+	if t.user != "" {
+		u.User = url.User(t.user)
+	}
+	return u.String()
+}
+
+// renderQR draws content as a QR code with half-block characters, two modules per line.
+// Colors are fixed so the code scans on dark terminal themes too.
+func renderQR(content string) (string, error) {
+	qr, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return "", err
+	}
+	bitmap := qr.Bitmap()
+	var b strings.Builder
+	for y := 0; y < len(bitmap); y += 2 {
+		for x := range bitmap[y] {
+			top := bitmap[y][x]
+			bottom := y+1 < len(bitmap) && bitmap[y+1][x]
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		if y+2 < len(bitmap) {
+			b.WriteString("\n")
+		}
+	}
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color("#000000")).Background(lipgloss.Color("#FFFFFF"))
+	return style.Render(b.String()), nil
+}
+
+// qrView renders the connection QR code of a host with its URL underneath
+func qrView(host string) (string, error) {
+	target, err := resolveSSHTarget(host)
+	if err != nil {
+		return "", err
+	}
+	link := sshURL(target)
+	code, err := renderQR(link)
+	if err != nil {
+		return "", err
+	}
+	view := code + "\n\n" + link + "\n"
+	if target.proxyJump != "" {
+		view += fmt.Sprintf("\nNote: %s is normally reached through %s, which the link does not include.\n", host, target.proxyJump)
+	}
+	return view, nil
+}
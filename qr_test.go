@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSSHURL(t *testing.T) {
+	got := sshURL(sshTarget{hostname: "staging.example.com", user: "deploy", port: "2222"})
+	if got != "ssh://deploy@staging.example.com:2222" {
+		t.Errorf("unexpected URL %s", got)
+	}
+	if got := sshURL(sshTarget{hostname: "10.0.0.1", port: "22"}); got != "ssh://10.0.0.1:22" {
+		t.Errorf("unexpected URL without user %s", got)
+	}
+	if got := sshURL(sshTarget{hostname: "2001:db8::1", port: "22"}); got != "ssh://[2001:db8::1]:22" {
+		t.Errorf("expected the IPv6 address in brackets, got %s", got)
+	}
+}
+
+func TestRenderQR(t *testing.T) {
+	code, err := renderQR("ssh://deploy@staging.example.com:2222")
+	if err != nil {
+		t.Fatalf("renderQR failed: %v", err)
+	}
+	if !strings.ContainsAny(code, "█▀▄") {
+		t.Errorf("expected block characters, got %q", code)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// recordingName is the timestamp naming a recording, sortable and valid in file names on all systems
+const recordingName = "2006-01-02T15-04-05"
+
+// recordSession reports whether sessions on a host are recorded, per its metadata or the app config
+func recordSession(meta hostMeta, cfg appConfig) bool {
+	return meta.Record || cfg.RecordSessions
+}
+
+// recordingBase returns the path, without extension, of a recording of a
+// session on host started at t, creating the host's recordings directory
+func recordingBase(host string, t time.Time) (string, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "recordings", strings.ReplaceAll(host, string(filepath.Separator), "_"))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, t.Format(recordingName)), nil
+}
+
+// recorderArgs returns the command running argv under a recorder, which
+// writes the terminal output with timing to files starting with base:
+// asciinema to base.cast; script to base.log, with util-linux (goos linux)
+// keeping the timing in base.timing for scriptreplay
+func recorderArgs(recorder, goos string, argv []string, base string) (string, []string, error) {
+	if recorder == "asciinema" {
+		quoted := make([]string, len(argv))
+		for i, a := range argv {
+			quoted[i] = shellQuote(a)
+		}
+		return "asciinema", []string{"rec", "-q", "-c", strings.Join(quoted, " "), base + ".cast"}, nil
+	}
+	switch goos {
+	case "windows":
+		return "", nil, errors.New("recording needs script or asciinema, which are not available on Windows")
+	case "linux":
+		quoted := make([]string, len(argv))
+		for i, a := range argv {
+			quoted[i] = shellQuote(a)
+		}
+		return "script", []string{"-q", "-e", "-f", "--timing=" + base + ".timing", "-c", strings.Join(quoted, " "), base + ".log"}, nil
+	}
+	// BSD and macOS script take the command as arguments; -r adds timestamps for script -p
+	return "script", append([]string{"-q", "-r", base + ".log"}, argv...), nil
+}
+
+// recordCommand makes cmd run under the recorder, writing to files starting with base
+func recordCommand(cmd *exec.Cmd, recorder, goos, base string) error {
+	tool, args, err := recorderArgs(recorder, goos, cmd.Args, base)
+	if err != nil {
+		return err
+	}
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return err
+	}
+	cmd.Path = path
+	cmd.Args = append([]string{tool}, args...)
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRecorderArgs(t *testing.T) {
+	argv := []string{"ssh", "-t", "it's"}
+	tests := []struct {
+		name     string
+		recorder string
+		goos     string
+		tool     string
+		args     []string
+	}{
+		{"linux", "", "linux", "script", []string{"-q", "-e", "-f", "--timing=/r/x.timing", "-c", `'ssh' '-t' 'it'\''s'`, "/r/x.log"}},
+		{"macos", "script", "darwin", "script", []string{"-q", "-r", "/r/x.log", "ssh", "-t", "it's"}},
+		{"asciinema", "asciinema", "windows", "asciinema", []string{"rec", "-q", "-c", `'ssh' '-t' 'it'\''s'`, "/r/x.cast"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool, args, err := recorderArgs(tt.recorder, tt.goos, argv, "/r/x")
+			if err != nil || tool != tt.tool || !slices.Equal(args, tt.args) {
+				t.Errorf("expected %s %q, got %s %q (%v)", tt.tool, tt.args, tool, args, err)
+			}
+		})
+	}
+	if _, _, err := recorderArgs("", "windows", argv, "/r/x"); err == nil {
+		t.Error("expected script to be unavailable on Windows")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probeTimeout bounds background commands run on remote hosts
+const probeTimeout = 10 * time.Second
+
+// remoteConnectTimeout bounds connecting for runRemote and runBatch; main sets it
+// from connect_timeout in the app config
+var remoteConnectTimeout = defaultConnectTimeout
+
+// remoteShell is the family of a remote login shell. sshd hands the command given to
+// ssh to the login shell, so how it must be quoted depends on that shell.
+type remoteShell int
+
+const (
+	shellPOSIX remoteShell = iota // sh, bash, zsh, dash, ksh
+	shellFish
+	shellCsh // csh and tcsh
+)
+
+// loginShells caches the detected login shell per host for the run
+var loginShells = struct {
+	sync.Mutex
+	hosts map[string]remoteShell
+}{hosts: map[string]remoteShell{}}
+
+// runRemote runs a non-interactive command on a host using key-based authentication
+// and returns its standard output. The command is run by sh as written: it is quoted
+// for the host's login shell first, so quotes, globs and variables are expanded once.
+func runRemote(host, command string) (string, error) {
+	return runRemoteTimeout(host, command, probeTimeout)
+}
+
+// runRemoteTimeout is runRemote with its own time limit, for commands that take longer than a probe
+func runRemoteTimeout(host, command string, timeout time.Duration) (string, error) {
+	defer remoteLimiter.acquire(host)()
+	return runBatch(host, remoteCommand(loginShell(host), "sh", command), timeout)
+}
+
+// runRemoteStream is runRemoteTimeout writing the output to stdout as it arrives,
+// for commands whose output may be too large to hold
+func runRemoteStream(host, command string, timeout time.Duration, stdout io.Writer) error {
+	defer remoteLimiter.acquire(host)()
+	return runBatchTo(host, remoteCommand(loginShell(host), "sh", command), timeout, stdout)
+}
+
+// runBatch runs command on a host as is, leaving it to the login shell
+func runBatch(host, command string, timeout time.Duration) (string, error) {
+	var out bytes.Buffer
+	err := runBatchTo(host, command, timeout, &out)
+	return out.String(), err
+}
+
+// runBatchTo is runBatch writing the output to stdout. As with exec.Cmd.Output,
+// the stderr of a failed command is kept in the returned *exec.ExitError.
+func runBatchTo(host, command string, timeout time.Duration, stdout io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ssh", "-o", "BatchMode=yes", "-o", connectTimeoutOption(remoteConnectTimeout), host, command)
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitErr.Stderr = stderr.Bytes()
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = ctx.Err()
+	}
+	return err
+}
+
+// loginShell detects the login shell of a host once per run. Hosts that cannot be
+// asked are assumed to use a POSIX shell, without caching the guess.
+func loginShell(host string) remoteShell {
+	loginShells.Lock()
+	sh, ok := loginShells.hosts[host]
+	loginShells.Unlock()
+	if ok {
+		return sh
+	}
+	// $SHELL reads the same in every shell family
+	out, err := runBatch(host, "echo $SHELL", probeTimeout)
+	if err != nil {
+		return shellPOSIX
+	}
+	sh = parseLoginShell(out)
+	loginShells.Lock()
+	loginShells.hosts[host] = sh
+	loginShells.Unlock()
+	return sh
+}
+
+// parseLoginShell maps the path of a login shell to its family
+func parseLoginShell(shell string) remoteShell {
+	switch path.Base(strings.TrimSpace(shell)) {
+	case "fish":
+		return shellFish
+	case "csh", "tcsh":
+		return shellCsh
+	}
+	return shellPOSIX
+}
+
+// quote quotes s as a single word for the shell
+func (sh remoteShell) quote(s string) string {
+	switch sh {
+	case shellFish:
+		// Within fish single quotes, backslashes escape quotes and backslashes
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+	case shellCsh:
+		// csh ends a quoted word at a newline unless it is escaped
+		return strings.ReplaceAll(shellQuote(s), "\n", "\\\n")
+	}
+	return shellQuote(s)
+}
+
+// remoteCommand wraps command so that interpreter (sh or bash) receives it exactly as
+// written, after the login shell has removed one level of quoting
+func remoteCommand(login remoteShell, interpreter, command string) string {
+	return interpreter + " -c " + login.quote(command)
+}
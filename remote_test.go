@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestParseLoginShell(t *testing.T) {
+	cases := map[string]remoteShell{
+		"/bin/bash\n":         shellPOSIX,
+		"/usr/bin/zsh":        shellPOSIX,
+		"/usr/local/bin/fish": shellFish,
+		"/bin/tcsh":           shellCsh,
+		"":                    shellPOSIX,
+	}
+	for in, want := range cases {
+		if got := parseLoginShell(in); got != want {
+			t.Errorf("parseLoginShell(%q) = %d, expected %d", in, got, want)
+		}
+	}
+}
+
+func TestRemoteShellQuote(t *testing.T) {
+	s := `it's a \ "test"` + "\n$HOME"
+	cases := map[remoteShell]string{
+		shellPOSIX: `'it'\''s a \ "test"` + "\n$HOME'",
+		shellFish:  `'it\'s a \\ "test"` + "\n$HOME'",
+		shellCsh:   `'it'\''s a \ "test"\` + "\n$HOME'",
+	}
+	for sh, want := range cases {
+		if got := sh.quote(s); got != want {
+			t.Errorf("shell %d: expected %q, got %q", sh, want, got)
+		}
+	}
+}
+
+func TestRemoteCommandRunsAsWritten(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	// The outer sh plays the remote login shell
+	command := `X='a b'; printf '%s|' "$X" 'c\d' "it's" *.nomatch`
+	out, err := exec.Command("sh", "-c", remoteCommand(shellPOSIX, "sh", command)).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(out); got != `a b|c\d|it's|*.nomatch|` {
+		t.Errorf("unexpected output %q", got)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportProbeCommand gathers what report shows, in sections separated by lines of
+// dashes: uptime and the number of CPUs, memory, the OS release and the file systems.
+// free and /etc/os-release are missing on macOS, which leaves their sections empty.
+const reportProbeCommand = "uptime; nproc 2>/dev/null || sysctl -n hw.ncpu; echo ---; " +
+	memoryProbeCommand + " 2>/dev/null; echo ---; " + osReleaseProbeCommand + " 2>/dev/null; echo ---; " + diskProbeCommand
+
+// Thresholds above which report flags a host
+const (
+	reportDiskWarn = 90  // percent of the fullest file system in use
+	reportLoadWarn = 1.0 // 1 minute load average per CPU
+)
+
+// hostReport is the health of one host as report shows it
+type hostReport struct {
+	host   string
+	err    error
+	up     time.Duration
+	load   [3]float64
+	cpus   int
+	memory memoryInfo // zero when free is not available
+	os     osRelease
+	disk   diskUsage // the fullest file system
+}
+
+// loadPerCPU returns the 1 minute load average divided over the CPUs
+func (r hostReport) loadPerCPU() float64 {
+	return r.load[0] / float64(max(1, r.cpus))
+}
+
+// warnings lists what is wrong with a host, for the last column of the report
+func (r hostReport) warnings() []string {
+	var warn []string
+	if r.disk.capacity >= reportDiskWarn {
+		warn = append(warn, "disk full")
+	}
+	if r.loadPerCPU() >= reportLoadWarn {
+		warn = append(warn, "high load")
+	}
+	return warn
+}
+
+// memoryColumn describes the memory in use, or "-" when it is unknown
+func (r hostReport) memoryColumn() string {
+	if r.memory.total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", r.memory.usedPercent())
+}
+
+// osColumn names the host's OS release, or "-" when it is unknown
+func (r hostReport) osColumn() string {
+	switch {
+	case r.os.prettyName != "":
+		return r.os.prettyName
+	case r.os.name != "":
+		return strings.TrimSpace(r.os.name + " " + r.os.versionID)
+	}
+	return "-"
+}
+
+// parseReport parses the output of reportProbeCommand
+func parseReport(host, out string) (hostReport, error) {
+	r := hostReport{host: host, cpus: 1}
+	var sections []string
+	var section []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == "---" {
+			sections = append(sections, strings.Join(section, "\n"))
+			section = nil
+			continue
+		}
+		section = append(section, line)
+	}
+	sections = append(sections, strings.Join(section, "\n"))
+	if len(sections) != 4 {
+		return r, errors.New("unexpected output")
+	}
+	head, free, release, df := sections[0], sections[1], sections[2], sections[3]
+	lines := strings.Split(strings.TrimSpace(head), "\n")
+	info, err := parseUptime(lines[0])
+	if err != nil {
+		return r, err
+	}
+	r.up, r.load = info.up, info.load
+	if n, err := strconv.Atoi(strings.TrimSpace(lines[len(lines)-1])); err == nil && n > 0 {
+		r.cpus = n
+	}
+	if strings.TrimSpace(free) != "" {
+		if r.memory, err = parseFree(free); err != nil {
+			return r, err
+		}
+	}
+	r.os = parseOSRelease(release)
+	disks, err := parseDF(df)
+	if err != nil {
+		return r, err
+	}
+	for _, d := range disks {
+		// Loop devices of snaps and images are always full
+		if d.size == 0 || strings.HasPrefix(d.filesystem, "/dev/loop") {
+			continue
+		}
+		if d.capacity > r.disk.capacity || r.disk.mount == "" {
+			r.disk = d
+		}
+	}
+	return r, nil
+}
+
+// formatUptime shortens an uptime to days and hours, or hours and minutes
+func formatUptime(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh", days, int(d.Hours())%24)
+	}
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// writeReport prints a table of the hosts' health, followed by a summary
+func writeReport(w io.Writer, reports []hostReport) {
+	width, osWidth := len("HOST"), len("OS")
+	for _, r := range reports {
+		width = max(width, len(r.host))
+		if r.err == nil {
+			osWidth = max(osWidth, len(r.osColumn()))
+		}
+	}
+	fmt.Fprintf(w, "%-*s  %-8s  %-16s  %-4s  %-4s  %-20s  %-*s  %s\n", width, "HOST", "UP", "LOAD", "CPUS", "MEM", "FULLEST DISK", osWidth, "OS", "NOTES")
+	var unreachable, flagged int
+	for _, r := range reports {
+		if r.err != nil {
+			unreachable++
+			fmt.Fprintf(w, "%-*s  %s\n", width, r.host, r.err)
+			continue
+		}
+		warn := r.warnings()
+		if len(warn) > 0 {
+			flagged++
+		}
+		load := fmt.Sprintf("%.2f %.2f %.2f", r.load[0], r.load[1], r.load[2])
+		disk := fmt.Sprintf("%d%% %s", r.disk.capacity, r.disk.mount)
+		fmt.Fprintf(w, "%-*s  %-8s  %-16s  %-4d  %-4s  %-20s  %-*s  %s\n", width, r.host, formatUptime(r.up), load, r.cpus,
+			r.memoryColumn(), disk, osWidth, r.osColumn(), strings.Join(warn, ", "))
+	}
+	fmt.Fprintf(w, "\n%d hosts: %d reachable, %d unreachable, %d with a disk over %d%% or a load over %.0f per CPU.\n",
+		len(reports), len(reports)-unreachable, unreachable, flagged, reportDiskWarn, reportLoadWarn)
+}
+
+// runReport implements "report [-tag tag] [-hosts expression] [-timeout duration] [-workers n]"
+func runReport(args []string) int {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	tag := fs.String("tag", "", "only report on hosts with this tag")
+	selection := fs.String("hosts", "", "only report on hosts matching this expression, e.g. 'tag:prod and not tag:db'")
+	timeout := fs.Duration("timeout", 30*time.Second, "time limit per host")
+	workers := fs.Int("workers", 0, "hosts to check at once (default exec_workers from config.json, or 32)")
+	fs.Parse(args)
+
+	cfg, err := loadAppConfig()
+	if err != nil {
+		fmt.Println("Could not read app config:", err)
+		return 1
+	}
+	md, err := loadHostMetadata()
+	if err != nil {
+		fmt.Println("Could not read host metadata:", err)
+		return 1
+	}
+	configPath, err := sshConfigPath()
+	if err != nil {
+		fmt.Println("Could not get current user:", err)
+		return 1
+	}
+	items, err := parseSSHConfig(configPath)
+	if err != nil {
+		fmt.Println("Could not parse ~/.ssh/config:", err)
+		return 1
+	}
+	filter := func(hostItem, hostMeta) bool { return true }
+	if *selection != "" {
+		if filter, err = parseHostSelection(*selection); err != nil {
+			fmt.Println(err)
+			return 2
+		}
+	}
+	var hosts []string
+	for _, h := range selectHosts(items, md, filter) {
+		if *tag == "" || md.hasTag(h, *tag) {
+			hosts = append(hosts, h)
+		}
+	}
+	if len(hosts) == 0 {
+		fmt.Println("No hosts to report on.")
+		return 1
+	}
+	if *workers <= 0 {
+		*workers = cfg.execWorkers()
+	}
+
+	remoteLimiter = newBulkLimiter(cfg.Concurrency, md)
+	remoteConnectTimeout = cfg.connectTimeout()
+	// Each host writes its own element of reports
+	reports := make([]hostReport, len(hosts))
+	index := map[string]int{}
+	for i, h := range hosts {
+		index[h] = i
+	}
+	runParallel(hosts, *workers, func(host string) {
+		out, err := runRemoteTimeout(host, reportProbeCommand, *timeout)
+		r, parseErr := parseReport(host, out)
+		// df exits with an error for mounts it cannot read, after listing the others
+		if res := bulkOutcome(err); res.Error != "" {
+			r.err = errors.New(res.Error)
+		} else if parseErr != nil {
+			r.err = parseErr
+		}
+		reports[index[host]] = r
+	})
+	writeReport(os.Stdout, reports)
+	for _, r := range reports {
+		if r.err != nil {
+			return 1
+		}
+	}
+	return 0
+}
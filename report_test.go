@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const reportOutput = ` 10:01:02 up 3 days,  2:03,  1 user,  load average: 9.10, 4.20, 2.30
+4
+---
+               total        used        free      shared  buff/cache   available
+Mem:     16000000000  4000000000  2000000000   100000000 10000000000 12000000000
+Swap:     2000000000           0  2000000000
+---
+NAME="Ubuntu"
+VERSION_ID="22.04"
+PRETTY_NAME="Ubuntu 22.04.4 LTS"
+---
+Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/sda1         10000000  5000000   5000000      50% /
+/dev/loop0           56832    56832         0     100% /snap/core/1
+/dev/sdb1         20000000 19000000   1000000      95% /var/lib/docker
+`
+
+func TestParseReport(t *testing.T) {
+	r, err := parseReport("web1", reportOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.up != 3*24*time.Hour+2*time.Hour+3*time.Minute || r.load != [3]float64{9.10, 4.20, 2.30} || r.cpus != 4 {
+		t.Errorf("unexpected report %+v", r)
+	}
+	if r.memoryColumn() != "25%" || r.osColumn() != "Ubuntu 22.04.4 LTS" {
+		t.Errorf("unexpected memory or OS %q, %q", r.memoryColumn(), r.osColumn())
+	}
+	if r.disk.mount != "/var/lib/docker" || r.disk.capacity != 95 {
+		t.Errorf("expected the docker disk as the fullest, got %+v", r.disk)
+	}
+	if got := strings.Join(r.warnings(), ", "); got != "disk full, high load" {
+		t.Errorf("unexpected warnings %q", got)
+	}
+	if _, err := parseReport("web1", "Permission denied\n"); err == nil {
+		t.Error("expected an error for output without the separator")
+	}
+
+	// macOS has neither free nor /etc/os-release
+	mac := " 9:00  up 2 days,  1:00, 2 users, load averages: 1.10 1.20 1.30\n8\n---\n---\n---\n" +
+		"Filesystem 512-blocks Used Available Capacity Mounted on\n/dev/disk3s1 2000 1000 1000 50% /\n"
+	r, err = parseReport("mac", mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.memoryColumn() != "-" || r.osColumn() != "-" || r.disk.size != 1024000 {
+		t.Errorf("unexpected report without free and os-release %+v", r)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	ok, _ := parseReport("web1", reportOutput)
+	var b strings.Builder
+	writeReport(&b, []hostReport{ok, {host: "db", err: errors.New("ssh failed")}})
+	out := b.String()
+	for _, want := range []string{
+		"web1  3d 2h     9.10 4.20 2.30    4     25%   95% /var/lib/docker   Ubuntu 22.04.4 LTS  disk full, high load",
+		"db    ssh failed",
+		"2 hosts: 1 reachable, 1 unreachable, 1 with a disk over 90% or a load over 1 per CPU.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q in\n%s", want, out)
+		}
+	}
+	if got := formatUptime(90 * time.Minute); got != "1h 30m" {
+		t.Errorf("unexpected uptime %q", got)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Exit codes of sshpass and ssh that mean the login itself failed. Any other
+// non-zero code comes from the remote side, after authentication succeeded.
+const (
+	sshpassWrongPassword  = 5
+	sshpassHostKeyUnknown = 6
+	sshConnectionError    = 255
+)
+
+// quickExit is how soon after starting a session ends to be considered closed right away
+const quickExit = 2 * time.Second
+
+// restrictedMessages are what servers say, in lower case, when they let an account
+// in but not run a command: git hosts, nologin shells and sftp-only accounts
+var restrictedMessages = []string{
+	"does not provide shell access",
+	"shell access is disabled",
+	"this account is currently not available",
+	"sftp connections only",
+	"only sftp",
+}
+
+// restrictedAccountDetail reports whether a failed login test had in fact authenticated,
+// but the server refused to run the test command, as with forced commands (git servers)
+// or accounts without a shell. Only exit code 1 with one of restrictedMessages counts,
+// as many other failures exit with 1 too. It returns the server's explanation.
+func restrictedAccountDetail(err error, stderr string) (string, bool) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		return "", false
+	}
+	for _, line := range strings.Split(stderr, "\n") {
+		lower := strings.ToLower(line)
+		for _, msg := range restrictedMessages {
+			if strings.Contains(lower, msg) {
+				return strings.TrimSpace(line), true
+			}
+		}
+	}
+	return "", false
+}
+
+// lastLine returns the last non-empty line of s
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
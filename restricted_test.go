@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func exitError(t *testing.T, code string) error {
+	t.Helper()
+	err := exec.Command("sh", "-c", "exit "+code).Run()
+	if err == nil {
+		t.Fatalf("expected exit %s to fail", code)
+	}
+	return err
+}
+
+func TestRestrictedAccountDetail(t *testing.T) {
+	stderr := "PTY allocation request failed on channel 0\nHi deploy! You've successfully authenticated, but GitHub does not provide shell access.\n"
+	detail, restricted := restrictedAccountDetail(exitError(t, "1"), stderr)
+	if !restricted {
+		t.Fatal("expected exit code 1 after authentication to count as restricted")
+	}
+	if detail != "Hi deploy! You've successfully authenticated, but GitHub does not provide shell access." {
+		t.Errorf("unexpected detail %q", detail)
+	}
+
+	if _, restricted := restrictedAccountDetail(exitError(t, "1"), "bash: line 1: exit: command not found\n"); restricted {
+		t.Error("exit code 1 without a known message should not count as restricted")
+	}
+	if _, restricted := restrictedAccountDetail(exitError(t, "2"), "This account is currently not available.\n"); restricted {
+		t.Error("only exit code 1 should count as restricted")
+	}
+	detail, restricted = restrictedAccountDetail(exitError(t, "1"), "This account is currently not available.\n")
+	if !restricted || detail != "This account is currently not available." {
+		t.Errorf("expected a nologin account to count as restricted, got %q", detail)
+	}
+
+	for _, code := range []string{"5", "6", "255"} {
+		if _, restricted := restrictedAccountDetail(exitError(t, code), ""); restricted {
+			t.Errorf("exit code %s is a login failure, not a restricted account", code)
+		}
+	}
+	if _, restricted := restrictedAccountDetail(nil, ""); restricted {
+		t.Error("success is not a restricted account")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Login tests failing for a transient reason are retried this many times in total,
+// waiting retryBackoff before the first retry and twice as long before each next one
+const (
+	loginAttempts = 3
+	retryBackoff  = 2 * time.Second
+)
+
+// retryLoginMsg starts the next attempt of a login test once its backoff has passed
+type retryLoginMsg struct{}
+
+// transientFailure reports whether a failure may go away by trying again shortly
+func transientFailure(failure int) bool {
+	return failure == failureTimeout || failure == failureRefused
+}
+
+// backoff returns how long to wait before attempt (2 or later)
+func backoff(attempt int) time.Duration {
+	return retryBackoff << (attempt - 2)
+}
+
+// scheduleRetry waits for the backoff of attempt, then asks for it to start
+func scheduleRetry(attempt int) tea.Cmd {
+	return tea.Tick(backoff(attempt), func(time.Time) tea.Msg {
+		return retryLoginMsg{}
+	})
+}
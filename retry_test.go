@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestBackoff(t *testing.T) {
+	if got := backoff(2); got != retryBackoff {
+		t.Errorf("expected the first retry after %s, got %s", retryBackoff, got)
+	}
+	if got := backoff(3); got != 2*retryBackoff {
+		t.Errorf("expected the backoff to double, got %s", got)
+	}
+	if !transientFailure(failureTimeout) || !transientFailure(failureRefused) {
+		t.Error("expected timeouts and refused connections to be retried")
+	}
+	if transientFailure(failureCredentials) || transientFailure(failureDNS) {
+		t.Error("expected wrong passwords and unknown host names not to be retried")
+	}
+}
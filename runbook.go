@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Effective options listed in a runbook, in ssh -G naming
+var (
+	runbookConnectionOptions = []string{"hostname", "user", "port", "proxyjump", "proxycommand", "identityfile", "certificatefile", "forwardagent"}
+	runbookTunnelOptions     = []string{"localforward", "remoteforward", "dynamicforward"}
+)
+
+// recentConnections is how many history entries a runbook lists
+const recentConnections = 10
+
+// runbook gathers what is known about a host for documenting it
+type runbook struct {
+	host     string
+	block    []string            // the host's lines in ~/.ssh/config
+	options  map[string][]string // effective options from ssh -G
+	meta     hostMeta
+	deps     dependencyGraph
+	history  []historyEntry
+	hostKeys []string
+}
+
+// parseSSHOptions collects all values per option from `ssh -G` output
+func parseSSHOptions(out string) map[string][]string {
+	options := map[string][]string{}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if ok {
+			options[key] = append(options[key], value)
+		}
+	}
+	return options
+}
+
+// writeMarkdown renders the runbook as Markdown
+func (r runbook) writeMarkdown(w io.Writer, now time.Time) {
+	fmt.Fprintf(w, "# %s\n\n", r.host)
+	if r.meta.Environment != "" {
+		fmt.Fprintf(w, "- Environment: %s\n", r.meta.Environment)
+	}
+	if len(r.meta.Tags) > 0 {
+		fmt.Fprintf(w, "- Tags: %s\n", strings.Join(r.meta.Tags, ", "))
+	}
+	if r.meta.Timezone != "" {
+		fmt.Fprintf(w, "- Time zone: %s\n", r.meta.Timezone)
+	}
+	fmt.Fprintf(w, "- Generated: %s\n\n", now.Format("2006-01-02 15:04 MST"))
+
+	fmt.Fprintln(w, "## Connecting")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "```sh\nssh %s\n```\n\n", r.host)
+	fmt.Fprintln(w, "| Option | Value |")
+	fmt.Fprintln(w, "| --- | --- |")
+	for _, opt := range runbookConnectionOptions {
+		for _, v := range r.options[opt] {
+			fmt.Fprintf(w, "| %s | `%s` |\n", opt, v)
+		}
+	}
+	fmt.Fprintln(w)
+	if len(r.hostKeys) > 0 {
+		fmt.Fprintln(w, "Host keys:")
+		fmt.Fprintln(w)
+		for _, k := range r.hostKeys {
+			fmt.Fprintf(w, "- `%s`\n", k)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(r.block) > 0 {
+		fmt.Fprintln(w, "From `~/.ssh/config`:")
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "```\n%s\n```\n\n", strings.TrimRight(strings.Join(r.block, "\n"), "\n\t "))
+	}
+
+	var tunnels []string
+	for _, opt := range runbookTunnelOptions {
+		for _, v := range r.options[opt] {
+			tunnels = append(tunnels, fmt.Sprintf("- %s `%s`", opt, v))
+		}
+	}
+	if len(tunnels) > 0 {
+		fmt.Fprintln(w, "## Tunnels")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, strings.Join(tunnels, "\n"))
+		fmt.Fprintln(w)
+	}
+
+	if len(r.deps[r.host]) > 0 || len(r.deps.dependents()[r.host]) > 0 {
+		fmt.Fprintln(w, "## Dependencies")
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "```\n%s```\n\n", dependencyView(r.deps, r.host))
+	}
+
+	if len(r.meta.Maintenance) > 0 {
+		fmt.Fprintln(w, "## Maintenance windows")
+		fmt.Fprintln(w)
+		for _, mw := range r.meta.Maintenance {
+			line := fmt.Sprintf("- %s: %s – %s", mw.Summary, mw.Start.Format("2006-01-02 15:04 MST"), mw.End.Format("2006-01-02 15:04 MST"))
+			if mw.RRule != "" {
+				line += fmt.Sprintf(" (repeats `%s`)", mw.RRule)
+			}
+			fmt.Fprintln(w, line)
+		}
+		fmt.Fprintln(w)
+	}
+
+	var recent []historyEntry
+	for i := len(r.history) - 1; i >= 0 && len(recent) < recentConnections; i-- {
+		if r.history[i].Host == r.host {
+			recent = append(recent, r.history[i])
+		}
+	}
+	if len(recent) > 0 {
+		fmt.Fprintln(w, "## Recent connections")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| When | Result | Length |")
+		fmt.Fprintln(w, "| --- | --- | --- |")
+		for _, e := range recent {
+			result, length := "ok", e.Duration.String()
+			if e.Failed {
+				result, length = "login failed", ""
+			}
+			fmt.Fprintf(w, "| %s | %s | %s |\n", e.Time.Format("2006-01-02 15:04"), result, length)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// runRunbook implements the "runbook <host> [file]" command
+func runRunbook(args []string) int {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Println("Usage: list-ssh-hosts runbook <host> [file]")
+		return 2
+	}
+	r := runbook{host: args[0]}
+
+	usr, err := user.Current()
+	if err != nil {
+		fmt.Println("Could not get current user:", err)
+		return 1
+	}
+	configPath := filepath.Join(usr.HomeDir, ".ssh", "config")
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		fmt.Println("Could not read ~/.ssh/config:", err)
+		return 1
+	}
+	block := getHostBlock(strings.Split(string(content), "\n"), r.host)
+	if block == nil {
+		fmt.Println("Unknown host:", r.host)
+		return 1
+	}
+	r.block = block.lines
+
+	out, err := exec.Command("ssh", "-G", r.host).Output()
+	if err != nil {
+		fmt.Println("Could not resolve the host's options:", err)
+		return 1
+	}
+	r.options = parseSSHOptions(string(out))
+
+	md, err := loadHostMetadata()
+	if err != nil {
+		fmt.Println("Could not read host metadata:", err)
+		return 1
+	}
+	r.meta = md[r.host]
+	if hosts, err := parseSSHConfig(configPath); err == nil {
+		r.deps = buildDependencyGraph(md, proxyJumps(hosts))
+	}
+	if keys, err := knownHostKeys(r.host); err == nil {
+		for _, k := range keys {
+			r.hostKeys = append(r.hostKeys, strings.TrimPrefix(describeHostKey(k, r.meta.HostKeyPin), "Host key "))
+		}
+	}
+	if path, err := historyPath(); err == nil {
+		r.history, _ = readHistory(path)
+	}
+
+	w := os.Stdout
+	if len(args) == 2 {
+		f, err := os.Create(args[1])
+		if err != nil {
+			fmt.Println("Could not create runbook file:", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	r.writeMarkdown(w, time.Now())
+	return 0
+}
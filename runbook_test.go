@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunbookMarkdown(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	deps := dependencyGraph{}
+	deps.add("app1", "db1")
+	r := runbook{
+		host:    "db1",
+		block:   []string{"Host db1", "    Hostname 10.0.0.5", ""},
+		options: parseSSHOptions("hostname 10.0.0.5\nuser postgres\nport 22\nlocalforward [localhost]:5432 [localhost]:5432\n"),
+		meta:    hostMeta{Environment: "prod", Tags: []string{"db"}},
+		deps:    deps,
+		history: []historyEntry{
+			{Host: "db1", Time: now.Add(-time.Hour), Duration: 90 * time.Second},
+			{Host: "app1", Time: now.Add(-time.Hour)},
+			{Host: "db1", Time: now.Add(-time.Minute), Failed: true},
+		},
+	}
+	var b strings.Builder
+	r.writeMarkdown(&b, now)
+	out := b.String()
+
+	for _, want := range []string{
+		"# db1\n",
+		"- Environment: prod\n",
+		"| user | `postgres` |\n",
+		"```\nHost db1\n    Hostname 10.0.0.5\n```\n",
+		"## Tunnels\n\n- localforward `[localhost]:5432 [localhost]:5432`\n",
+		"## Dependencies",
+		"app1",
+		"| 2025-08-01 11:59 | login failed |  |\n| 2025-08-01 11:00 | ok | 1m30s |\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("runbook is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "## Maintenance windows") {
+		t.Error("expected no maintenance section without windows")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/sahilm/fuzzy"
+)
+
+// hostSearchFields lists what the list filter matches a host against: its
+// alias, user@hostname, tags and notes
+func hostSearchFields(item hostItem, meta hostMeta) []string {
+	fields := []string{item.host}
+	if item.desc != "" {
+		fields = append(fields, item.desc)
+	}
+	fields = append(fields, meta.Tags...)
+	if meta.Notes != "" {
+		fields = append(fields, meta.Notes)
+	}
+	return fields
+}
+
+// fuzzyHostFilter returns a list filter that fuzzy matches the term against each
+// field of a host on its own, so a match cannot be spread over several fields,
+// and ranks the hosts by their best matching field. Hosts missing from fields
+// are matched on their alias. Only matches in the alias are highlighted.
+func fuzzyHostFilter(fields map[string][]string) list.FilterFunc {
+	return func(term string, targets []string) []list.Rank {
+		var ranks []list.Rank
+		scores := map[int]int{}
+		for i, host := range targets {
+			searched, ok := fields[host]
+			if !ok {
+				searched = []string{host}
+			}
+			matches := fuzzy.Find(term, searched)
+			if len(matches) == 0 {
+				continue
+			}
+			rank := list.Rank{Index: i}
+			if best := matches[0]; best.Index == 0 {
+				rank.MatchedIndexes = best.MatchedIndexes
+			}
+			scores[i] = matches[0].Score
+			ranks = append(ranks, rank)
+		}
+		sort.SliceStable(ranks, func(a, b int) bool {
+			return scores[ranks[a].Index] > scores[ranks[b].Index]
+		})
+		return ranks
+	}
+}
+
+// regexHostFilter returns a list filter that takes the term as a regular
+// expression and keeps the hosts whose alias or hostname it matches, in list
+// order. Matches in the alias are highlighted. An invalid expression matches
+// nothing.
+func regexHostFilter(hostnames map[string]string) list.FilterFunc {
+	return func(term string, targets []string) []list.Rank {
+		re, err := regexp.Compile(term)
+		if err != nil {
+			return nil
+		}
+		var ranks []list.Rank
+		for i, host := range targets {
+			if host == "" {
+				continue // section headings
+			}
+			if loc := re.FindStringIndex(host); loc != nil {
+				rank := list.Rank{Index: i}
+				for j := loc[0]; j < loc[1]; j++ {
+					rank.MatchedIndexes = append(rank.MatchedIndexes, j)
+				}
+				ranks = append(ranks, rank)
+			} else if re.MatchString(hostnames[host]) {
+				ranks = append(ranks, list.Rank{Index: i})
+			}
+		}
+		return ranks
+	}
+}
+
+// indexHosts points the list filter at the current hosts and their metadata.
+// The filter runs in the background, so it gets a copy of what it searches.
+func (m *model) indexHosts() {
+	if m.regexFilter {
+		hostnames := map[string]string{}
+		for _, h := range m.hostItems() {
+			hostnames[h.host] = h.hostname()
+		}
+		m.list.Filter = regexHostFilter(hostnames)
+		return
+	}
+	fields := map[string][]string{}
+	for _, h := range m.hostItems() {
+		fields[h.host] = hostSearchFields(h, m.metadata[h.host])
+	}
+	m.list.Filter = fuzzyHostFilter(fields)
+}
+
+// toggleRegexFilter switches the list filter between fuzzy search and regular
+// expressions, filtering again with what was typed
+func (m *model) toggleRegexFilter() {
+	m.regexFilter = !m.regexFilter
+	m.list.FilterInput.Prompt = "Filter: "
+	if m.regexFilter {
+		m.list.FilterInput.Prompt = "Regex: "
+	}
+	m.indexHosts()
+	switch state := m.list.FilterState(); state {
+	case list.Filtering:
+		m.list.SetFilterText(m.list.FilterValue())
+		m.list.SetFilterState(list.Filtering)
+	case list.FilterApplied:
+		m.list.SetFilterText(m.list.FilterValue())
+	}
+}
+
+// regexFilterError explains why the regular expression typed in the filter
+// does not compile, or returns "" when it does or fuzzy search is on
+func (m *model) regexFilterError() string {
+	if !m.regexFilter || m.list.FilterState() == list.Unfiltered {
+		return ""
+	}
+	if _, err := regexp.Compile(m.list.FilterValue()); err != nil {
+		return "Invalid regex: " + err.Error()
+	}
+	return ""
+}
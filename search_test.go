@@ -0,0 +1,86 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHostFilter(t *testing.T) {
+	items := []hostItem{
+		{host: "web1", desc: "deploy@10.0.0.5"},
+		{host: "db", desc: "10.1.2.3"},
+		{host: "bastion", desc: "admin@bastion.example.com"},
+	}
+	md := hostMetadata{
+		"db":      {Tags: []string{"postgres"}, Notes: "replica of the primary"},
+		"bastion": {Tags: []string{"prod"}},
+	}
+	fields := map[string][]string{}
+	targets := make([]string, len(items))
+	for i, item := range items {
+		fields[item.host] = hostSearchFields(item, md[item.host])
+		targets[i] = item.FilterValue()
+	}
+	filter := fuzzyHostFilter(fields)
+	matched := func(term string) []string {
+		var hosts []string
+		for _, r := range filter(term, targets) {
+			hosts = append(hosts, targets[r.Index])
+		}
+		return hosts
+	}
+	for term, want := range map[string][]string{
+		"10.0.0":   {"web1"},
+		"deploy":   {"web1"},
+		"postgres": {"db"},
+		"replica":  {"db"},
+		"prod":     {"bastion"},
+		"bastion":  {"bastion"},
+		"nothing":  nil,
+	} {
+		if got := matched(term); !slices.Equal(got, want) {
+			t.Errorf("%q: got %q, want %q", term, got, want)
+		}
+	}
+
+	// Only matches in the alias are highlighted
+	for _, r := range filter("web", targets) {
+		if targets[r.Index] == "web1" && !slices.Equal(r.MatchedIndexes, []int{0, 1, 2}) {
+			t.Errorf("unexpected highlight %v", r.MatchedIndexes)
+		}
+	}
+	if ranks := filter("postgres", targets); len(ranks) != 1 || ranks[0].MatchedIndexes != nil {
+		t.Errorf("expected a match without highlight, got %+v", ranks)
+	}
+	// Hosts not indexed yet are matched on their alias
+	if got := fuzzyHostFilter(nil)("bast", targets); len(got) != 1 || got[0].Index != 2 {
+		t.Errorf("unexpected ranks %+v", got)
+	}
+}
+
+func TestRegexHostFilter(t *testing.T) {
+	targets := []string{"web-prod-01", "web-prod-02", "web-staging-01", "db"}
+	hostnames := map[string]string{"db": "10.1.2.3", "web-prod-01": "10.0.0.5"}
+	filter := regexHostFilter(hostnames)
+	matched := func(term string) []string {
+		var hosts []string
+		for _, r := range filter(term, targets) {
+			hosts = append(hosts, targets[r.Index])
+		}
+		return hosts
+	}
+	for term, want := range map[string][]string{
+		`^web-prod-\d+$`: {"web-prod-01", "web-prod-02"},
+		`-01$`:           {"web-prod-01", "web-staging-01"},
+		`^10\.1\.`:       {"db"},
+		`^10\.0\.0\.5$`:  {"web-prod-01"},
+		`(`:              nil,
+	} {
+		if got := matched(term); !slices.Equal(got, want) {
+			t.Errorf("%q: got %q, want %q", term, got, want)
+		}
+	}
+	if ranks := filter("prod", targets); len(ranks) != 2 || !slices.Equal(ranks[0].MatchedIndexes, []int{4, 5, 6, 7}) {
+		t.Errorf("unexpected ranks %+v", ranks)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// trimPastedSecret drops the line break that password managers often copy along with a
+// password, which the text input would otherwise turn into a trailing space
+func trimPastedSecret(msg tea.Msg) tea.Msg {
+	k, ok := msg.(tea.KeyMsg)
+	if !ok || !k.Paste {
+		return msg
+	}
+	k.Runes = []rune(strings.TrimRight(string(k.Runes), "\r\n"))
+	return k
+}
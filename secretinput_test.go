@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestTrimPastedSecret(t *testing.T) {
+	pasted := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s3cret pass\r\n"), Paste: true}
+	if got := trimPastedSecret(pasted).(tea.KeyMsg); string(got.Runes) != "s3cret pass" {
+		t.Errorf("expected the line break to be trimmed, got %q", string(got.Runes))
+	}
+	typed := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("\n")}
+	if got := trimPastedSecret(typed).(tea.KeyMsg); string(got.Runes) != "\n" {
+		t.Errorf("expected typed input to be left alone, got %q", string(got.Runes))
+	}
+}
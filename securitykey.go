@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// securityKeyIdentity returns the FIDO2 (sk-ecdsa/sk-ed25519) identity a host
+// authenticates with, or "" when it uses none. A key in the agent counts only
+// when it is one of the host's IdentityFile or CertificateFile keys.
+func securityKeyIdentity(host string) string {
+	target, err := resolveSSHTarget(host)
+	if err != nil {
+		return ""
+	}
+	var file string
+	var blobs []string
+	paths := append(slices.Clone(target.certificateFiles), target.identityFiles...)
+	for i, f := range paths {
+		if i >= len(target.certificateFiles) {
+			f += ".pub"
+		}
+		content, err := os.ReadFile(expandHome(f))
+		if err != nil {
+			continue
+		}
+		fields := strings.Fields(string(content))
+		if !isSecurityKeyType(fields) || len(fields) < 2 {
+			continue
+		}
+		blobs = append(blobs, fields[1])
+		if file == "" && i >= len(target.certificateFiles) {
+			file = strings.TrimSuffix(f, ".pub")
+		}
+	}
+	if keys, err := agentKeys(); err == nil {
+		if key := agentSecurityKey(keys, blobs); key != "" {
+			return key
+		}
+	}
+	return file
+}
+
+// isSecurityKeyType reports whether the fields of a public key line describe an sk-* key
+func isSecurityKeyType(fields []string) bool {
+	return len(fields) > 0 && strings.HasPrefix(fields[0], "sk-")
+}
+
+// agentSecurityKey returns the comment of the first sk-* key of the agent
+// whose base64 blob is one of blobs
+func agentSecurityKey(keys []*agent.Key, blobs []string) string {
+	for _, k := range keys {
+		if isSecurityKeyType([]string{k.Type()}) && slices.Contains(blobs, base64.StdEncoding.EncodeToString(k.Blob)) {
+			return "agent key " + k.Comment
+		}
+	}
+	return ""
+}
+
+// securityKeyCommand is an ssh command that keeps a copy of its stderr while
+// it runs on the terminal
+type securityKeyCommand struct {
+	*exec.Cmd
+	stderr bytes.Buffer
+}
+
+func (c *securityKeyCommand) SetStdin(r io.Reader)  { c.Stdin = r }
+func (c *securityKeyCommand) SetStdout(w io.Writer) { c.Stdout = w }
+func (c *securityKeyCommand) SetStderr(w io.Writer) { c.Stderr = io.MultiWriter(w, &c.stderr) }
+
+// trySecurityKeyLogin tests a login that authenticates with a security key. There is no
+// password to feed, so ssh runs without sshpass and ssh-sk-helper talks to the device
+// directly while the user touches it. ssh gets the terminal for the touch and PIN
+// prompts, as the TUI cannot answer them. debugLog is the ssh -vvv log of the
+// test, or "".
+func trySecurityKeyLogin(ctx context.Context, host string, timeout time.Duration, mux []string, debugLog string) tea.Cmd {
+	args := append([]string{"-o", "StrictHostKeyChecking=yes", "-o", connectTimeoutOption(timeout),
+		"-o", "PasswordAuthentication=no", "-o", "KbdInteractiveAuthentication=no"}, mux...)
+	cmd := &securityKeyCommand{Cmd: exec.CommandContext(ctx, "ssh", append(args, host, "exit")...)}
+	return tea.Exec(cmd, func(err error) tea.Msg {
+		if debugLog != "" {
+			return debugLogResult(loginResult(err, cmd.stderr.String()), debugLog)
+		}
+		return loginResult(err, cmd.stderr.String())
+	})
+}
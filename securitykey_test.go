@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestIsSecurityKeyType(t *testing.T) {
+	tests := []struct {
+		line     []string
+		expected bool
+	}{
+		{[]string{"sk-ssh-ed25519@openssh.com", "AAAA", "yubikey"}, true},
+		{[]string{"sk-ecdsa-sha2-nistp256@openssh.com", "AAAA"}, true},
+		{[]string{"ssh-ed25519", "AAAA", "laptop"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isSecurityKeyType(tt.line); got != tt.expected {
+			t.Errorf("isSecurityKeyType(%v) = %v, expected %v", tt.line, got, tt.expected)
+		}
+	}
+}
+
+func TestAgentSecurityKey(t *testing.T) {
+	keys := []*agent.Key{
+		{Format: "ssh-ed25519", Blob: []byte("laptop"), Comment: "laptop"},
+		{Format: "sk-ssh-ed25519@openssh.com", Blob: []byte("yubikey"), Comment: "yubikey"},
+	}
+	if got := agentSecurityKey(keys, nil); got != "" {
+		t.Errorf("an sk key the host does not use should not count, got %q", got)
+	}
+	blobs := []string{base64.StdEncoding.EncodeToString([]byte("laptop")), base64.StdEncoding.EncodeToString([]byte("yubikey"))}
+	if got := agentSecurityKey(keys, blobs); got != "agent key yubikey" {
+		t.Errorf("agentSecurityKey() = %q, want the yubikey", got)
+	}
+}
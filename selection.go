@@ -0,0 +1,56 @@
+package main
+
+import "fmt"
+
+// Hosts are marked for bulk operations with space, or a range of them with v
+// (visual mode: v at one end, move, v or space at the other). The marks live
+// in the list items, so they follow filtering and sorting.
+
+// markedHosts returns the marked hosts in list order
+func (m *model) markedHosts() []string {
+	var hosts []string
+	for _, h := range m.hostItems() {
+		if h.marked {
+			hosts = append(hosts, h.host)
+		}
+	}
+	return hosts
+}
+
+// setMarked marks or unmarks hosts in the list
+func (m *model) setMarked(hosts []string, marked bool) {
+	set := map[string]bool{}
+	for _, h := range hosts {
+		set[h] = true
+	}
+	for _, h := range m.hostItems() {
+		if set[h.host] && h.marked != marked {
+			h.marked = marked
+			m.setHost(h)
+		}
+	}
+}
+
+// visualRange returns the shown hosts between the anchor of visual mode and the cursor
+func (m *model) visualRange() []string {
+	visible := m.list.VisibleItems()
+	from, to := min(m.visualAnchor, m.list.Index()), max(m.visualAnchor, m.list.Index())
+	var hosts []string
+	for i := max(0, from); i <= to && i < len(visible); i++ {
+		if h, ok := visible[i].(hostItem); ok {
+			hosts = append(hosts, h.host)
+		}
+	}
+	return hosts
+}
+
+// selectionStatus describes visual mode or the marked hosts for the line under the list
+func (m *model) selectionStatus() string {
+	if m.visualAnchor >= 0 {
+		return fmt.Sprintf("Visual: range of %d; v or space marks it, esc cancels", len(m.visualRange()))
+	}
+	if n := len(m.markedHosts()); n > 0 {
+		return fmt.Sprintf("Marked: %d (space toggles, esc clears)", n)
+	}
+	return ""
+}
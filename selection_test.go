@@ -0,0 +1,50 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+func selectionModel(hosts ...string) *model {
+	items := make([]list.Item, len(hosts))
+	for i, h := range hosts {
+		items[i] = hostItem{host: h}
+	}
+	return &model{list: list.New(items, list.NewDefaultDelegate(), 80, 40), visualAnchor: -1}
+}
+
+func TestHostItemTitleMarked(t *testing.T) {
+	if got := (hostItem{host: "web1"}).Title(); got != "web1" {
+		t.Errorf("Title() = %q, want %q", got, "web1")
+	}
+	if got := (hostItem{host: "web1", marked: true}).Title(); got != "✓ web1" {
+		t.Errorf("Title() = %q, want %q", got, "✓ web1")
+	}
+}
+
+func TestSetMarked(t *testing.T) {
+	m := selectionModel("a", "b", "c")
+	m.setMarked([]string{"c", "a"}, true)
+	if got := m.markedHosts(); !slices.Equal(got, []string{"a", "c"}) {
+		t.Errorf("markedHosts() = %v, want [a c]", got)
+	}
+	m.setMarked([]string{"a"}, false)
+	if got := m.markedHosts(); !slices.Equal(got, []string{"c"}) {
+		t.Errorf("markedHosts() = %v, want [c]", got)
+	}
+}
+
+func TestVisualRange(t *testing.T) {
+	m := selectionModel("a", "b", "c", "d")
+	m.list.Select(3)
+	m.visualAnchor = 1
+	if got := m.visualRange(); !slices.Equal(got, []string{"b", "c", "d"}) {
+		t.Errorf("visualRange() = %v, want [b c d]", got)
+	}
+	m.list.Select(0)
+	if got := m.visualRange(); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("visualRange() = %v, want [a b]", got)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// forwardSignals passes signals sent to this process on to the session's ssh
+// until stop is called, so closing the terminal or killing the wrapper ends the
+// session instead of leaving ssh behind. Keys like Ctrl+C reach ssh through the
+// terminal already.
+func forwardSignals(p *os.Process) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				p.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// sessionExitCode returns the exit code to leave with after a session ended with
+// err: the remote shell's status, or 128 plus the signal that killed ssh, as shells
+// report it. Errors other than an exit status are returned as they are.
+func sessionExitCode(err error) (int, error) {
+	var exitErr *exec.ExitError
+	var remoteErr *ssh.ExitError
+	switch {
+	case err == nil:
+		return 0, nil
+	case errors.As(err, &exitErr):
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal()), nil
+		}
+		return exitErr.ExitCode(), nil
+	case errors.As(err, &remoteErr):
+		return remoteErr.ExitStatus(), nil
+	}
+	return 1, err
+}
+
+// reconnectStable is how long a session must stay up for the reconnection
+// attempts before it to be forgotten
+const reconnectStable = time.Minute
+
+// nextReconnect decides whether an ssh session that ended with code after
+// lasting for lasted is started again, with at most limit attempts in a row.
+// attempt counts the attempts so far; the updated count is returned. Only a
+// lost connection (ssh's own exit code) counts, and not before the first
+// session got going, which would rather be a failure to log in.
+func nextReconnect(code int, lasted time.Duration, attempt, limit int) (int, bool) {
+	if limit <= 0 || code != sshConnectionError || attempt == 0 && lasted < quickExit {
+		return attempt, false
+	}
+	if lasted >= reconnectStable {
+		attempt = 0
+	}
+	attempt++
+	return attempt, attempt <= limit
+}
+
+// reconnectDelay returns how long to wait before reconnection attempt: the
+// login retry backoff, doubling up to maxReconnectDelay
+func reconnectDelay(attempt int) time.Duration {
+	if d := backoff(attempt + 1); d > 0 && d < maxReconnectDelay {
+		return d
+	}
+	return maxReconnectDelay
+}
+
+// sleepUnlessInterrupted waits for d and reports whether it was not cut short by Ctrl+C
+func sleepUnlessInterrupted(d time.Duration) bool {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+	select {
+	case <-signals:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
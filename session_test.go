@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestSessionExitCode(t *testing.T) {
+	if code, err := sessionExitCode(nil); code != 0 || err != nil {
+		t.Errorf("expected 0, got %d, %v", code, err)
+	}
+	if code, err := sessionExitCode(exec.Command("sh", "-c", "exit 7").Run()); code != 7 || err != nil {
+		t.Errorf("expected the exit status to be kept, got %d, %v", code, err)
+	}
+	if code, err := sessionExitCode(exec.Command("sh", "-c", "kill -TERM $$").Run()); code != 143 || err != nil {
+		t.Errorf("expected 128+SIGTERM, got %d, %v", code, err)
+	}
+	failure := errors.New("broken pipe")
+	if code, err := sessionExitCode(failure); code != 1 || err != failure {
+		t.Errorf("expected other errors to be returned, got %d, %v", code, err)
+	}
+}
+
+func TestNextReconnect(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      int
+		lasted    time.Duration
+		attempt   int
+		limit     int
+		want      int
+		reconnect bool
+	}{
+		{"disabled", sshConnectionError, time.Hour, 0, 0, 0, false},
+		{"remote exit", 1, time.Hour, 0, 3, 0, false},
+		{"dropped", sshConnectionError, 10 * time.Minute, 0, 3, 1, true},
+		{"first session never got going", sshConnectionError, time.Second, 0, 3, 0, false},
+		{"still unreachable", sshConnectionError, time.Second, 2, 3, 3, true},
+		{"gave up", sshConnectionError, time.Second, 3, 3, 4, false},
+		{"stable again", sshConnectionError, 2 * time.Minute, 3, 3, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reconnect := nextReconnect(tt.code, tt.lasted, tt.attempt, tt.limit)
+			if got != tt.want || reconnect != tt.reconnect {
+				t.Errorf("expected %d, %v; got %d, %v", tt.want, tt.reconnect, got, reconnect)
+			}
+		})
+	}
+}
+
+func TestReconnectDelay(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{1: 2 * time.Second, 3: 8 * time.Second, 6: time.Minute, 100: time.Minute} {
+		if got := reconnectDelay(attempt); got != want {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, want, got)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpBridgeEnv names the socket a bridge process connects sftp to; see runNativeSFTP
+const sftpBridgeEnv = "LSH_SFTP_BRIDGE"
+
+// sftpArgs returns the sftp arguments for a connection to host as user (when
+// set) on port (when not 0), with the ssh options in opts
+func sftpArgs(user, host string, port int, opts []string) []string {
+	args := append([]string{}, opts...)
+	if port != 0 {
+		args = append(args, "-P", strconv.Itoa(port))
+	}
+	if user != "" {
+		host = user + "@" + host
+	}
+	return append(args, host)
+}
+
+// startSFTP opens sftp to the selected host instead of a shell, reusing the
+// login test's connection or credentials, and returns its exit code
+func startSFTP(m *model) (int, error) {
+	if m.nativeClient != nil {
+		m.forgetPassword()
+		return sessionExitCode(runNativeSFTP(m.nativeClient))
+	}
+	opts := append(m.multiplexArgs(), m.connectOpts.connectionArgs()...)
+	args := sftpArgs(m.loginUser, m.selectedHost, m.loginPort, append(opts, jumpArgs(m.jumpHost)...))
+	var cmd *exec.Cmd
+	if m.securityKey != "" || m.direct {
+		cmd = exec.Command("sftp", args...)
+	} else {
+		var secret *os.File
+		var err error
+		cmd, secret, err = sshpassProgram(context.Background(), m.password, m.keyFile, "sftp", args...)
+		m.forgetPassword()
+		if err != nil {
+			return 1, err
+		}
+		defer secret.Close()
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return 1, err
+	}
+	stop := forwardSignals(cmd.Process)
+	defer stop()
+	return sessionExitCode(cmd.Wait())
+}
+
+// runNativeSFTP runs sftp over the sftp subsystem of an authenticated
+// connection of the built-in client. sftp -D talks to a local server program
+// over its stdin and stdout; this executable, started again in bridge mode,
+// plays that program and relays to the subsystem through a unix socket.
+func runNativeSFTP(client *ssh.Client) error {
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	remoteIn, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	remoteOut, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "lsh-sftp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "sftp.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		go func() {
+			io.Copy(remoteIn, conn)
+			remoteIn.Close()
+		}()
+		io.Copy(conn, remoteOut)
+	}()
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sftp", "-D", self)
+	cmd.Env = append(os.Environ(), sftpBridgeEnv+"="+socket)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	stop := forwardSignals(cmd.Process)
+	defer stop()
+	return cmd.Wait()
+}
+
+// runSFTPBridge relays stdin and stdout to the socket of runNativeSFTP
+func runSFTPBridge(socket string) int {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return 1
+	}
+	defer conn.Close()
+	go func() {
+		io.Copy(conn, os.Stdin)
+		conn.(*net.UnixConn).CloseWrite()
+	}()
+	io.Copy(os.Stdout, conn)
+	return 0
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSFTPArgs(t *testing.T) {
+	got := sftpArgs("root", "web1", 2222, []string{"-J", "bastion"})
+	if want := []string{"-J", "bastion", "-P", "2222", "root@web1"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := sftpArgs("", "web1", 0, nil); !slices.Equal(got, []string{"web1"}) {
+		t.Errorf("expected only the host, got %v", got)
+	}
+}
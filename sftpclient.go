@@ -0,0 +1,453 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SFTP version 3 packet types, as spoken by OpenSSH's sftp-server
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpWrite    = 6
+	sftpOpendir  = 11
+	sftpReaddir  = 12
+	sftpRemove   = 13
+	sftpRmdir    = 15
+	sftpRealpath = 16
+	sftpStat     = 17
+	sftpRename   = 18
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpName     = 104
+	sftpAttrs    = 105
+)
+
+const (
+	sftpAttrSize        = 0x1
+	sftpAttrUIDGID      = 0x2
+	sftpAttrPermissions = 0x4
+	sftpAttrACModTime   = 0x8
+	sftpAttrExtended    = 0x80000000
+
+	sftpOpenRead   = 0x1
+	sftpOpenWrite  = 0x2
+	sftpOpenCreate = 0x8
+	sftpOpenTrunc  = 0x10
+
+	sftpStatusOK  = 0
+	sftpStatusEOF = 1
+
+	// sftpChunk is the size of each read and write; OpenSSH accepts up to 256 KiB
+	sftpChunk = 32 * 1024
+)
+
+// fileEntry is a file or directory listed in the file browser
+type fileEntry struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// sftpClient is a minimal SFTP client for the file browser. Requests are sent
+// one at a time, so it needs no bookkeeping of outstanding ids.
+type sftpClient struct {
+	mu     sync.Mutex
+	w      io.Writer
+	r      io.Reader
+	closer func() error
+	nextID uint32
+}
+
+// sftpStatusError is a failure reported by the server
+type sftpStatusError struct {
+	code uint32
+	msg  string
+}
+
+func (e *sftpStatusError) Error() string {
+	if e.msg != "" {
+		return e.msg
+	}
+	return fmt.Sprintf("sftp error %d", e.code)
+}
+
+// newSFTPClient starts an SFTP session over w and r, the input and output of
+// the server's subsystem; closer ends the underlying connection
+func newSFTPClient(w io.Writer, r io.Reader, closer func() error) (*sftpClient, error) {
+	c := &sftpClient{w: w, r: r, closer: closer}
+	var init sftpPacket
+	init.uint32(3)
+	if err := c.send(sftpInit, init); err != nil {
+		return nil, err
+	}
+	typ, _, err := c.recv()
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpVersion {
+		return nil, fmt.Errorf("unexpected sftp packet %d instead of the version", typ)
+	}
+	return c, nil
+}
+
+// Close ends the session
+func (c *sftpClient) Close() error {
+	return c.closer()
+}
+
+func (c *sftpClient) send(typ byte, payload sftpPacket) error {
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header, uint32(len(payload)+1))
+	header[4] = typ
+	_, err := c.w.Write(append(header, payload...))
+	return err
+}
+
+func (c *sftpClient) recv() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 1 || length > 1<<20 {
+		return 0, nil, fmt.Errorf("invalid sftp packet length %d", length)
+	}
+	data := make([]byte, length-1)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return 0, nil, err
+	}
+	return header[4], data, nil
+}
+
+// request sends a request and returns the type and body of the reply, without its id.
+// A status reply other than OK is returned as error, EOF as io.EOF.
+func (c *sftpClient) request(typ byte, body sftpPacket) (byte, *sftpReader, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	var payload sftpPacket
+	payload.uint32(c.nextID)
+	if err := c.send(typ, append(payload, body...)); err != nil {
+		return 0, nil, err
+	}
+	replyType, data, err := c.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	reply := &sftpReader{data: data}
+	if id := reply.uint32(); id != c.nextID {
+		return 0, nil, fmt.Errorf("sftp reply for request %d instead of %d", id, c.nextID)
+	}
+	if replyType == sftpStatus {
+		code := reply.uint32()
+		msg := reply.string()
+		switch code {
+		case sftpStatusOK:
+			return replyType, reply, nil
+		case sftpStatusEOF:
+			return replyType, reply, io.EOF
+		}
+		return replyType, reply, &sftpStatusError{code: code, msg: msg}
+	}
+	return replyType, reply, reply.err
+}
+
+// expect is request for replies that must be of type want
+func (c *sftpClient) expect(typ byte, body sftpPacket, want byte) (*sftpReader, error) {
+	replyType, reply, err := c.request(typ, body)
+	if err != nil {
+		return nil, err
+	}
+	if replyType != want {
+		return nil, fmt.Errorf("unexpected sftp reply %d", replyType)
+	}
+	return reply, nil
+}
+
+// pathRequest sends a request whose only argument is a path and expects a status
+func (c *sftpClient) pathRequest(typ byte, p string) error {
+	var body sftpPacket
+	body.string(p)
+	_, err := c.expect(typ, body, sftpStatus)
+	return err
+}
+
+// realPath resolves p, such as "." for the home directory, to an absolute path
+func (c *sftpClient) realPath(p string) (string, error) {
+	var body sftpPacket
+	body.string(p)
+	reply, err := c.expect(sftpRealpath, body, sftpName)
+	if err != nil {
+		return "", err
+	}
+	if reply.uint32() < 1 {
+		return "", errors.New("no path returned")
+	}
+	return reply.string(), reply.err
+}
+
+// stat returns the attributes of p, following symbolic links
+func (c *sftpClient) stat(p string) (fileEntry, error) {
+	var body sftpPacket
+	body.string(p)
+	reply, err := c.expect(sftpStat, body, sftpAttrs)
+	if err != nil {
+		return fileEntry{}, err
+	}
+	entry := reply.attrs()
+	entry.name = path.Base(p)
+	return entry, reply.err
+}
+
+// readDir lists the directory dir, sorted by name
+func (c *sftpClient) readDir(dir string) ([]fileEntry, error) {
+	var body sftpPacket
+	body.string(dir)
+	reply, err := c.expect(sftpOpendir, body, sftpHandle)
+	if err != nil {
+		return nil, err
+	}
+	handle := reply.string()
+	defer c.closeHandle(handle)
+
+	var entries []fileEntry
+	for {
+		var body sftpPacket
+		body.string(handle)
+		reply, err := c.expect(sftpReaddir, body, sftpName)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for n := reply.uint32(); n > 0 && reply.err == nil; n-- {
+			name := reply.string()
+			reply.string() // the ls -l line
+			entry := reply.attrs()
+			entry.name = name
+			if name != "." && name != ".." {
+				entries = append(entries, entry)
+			}
+		}
+		if reply.err != nil {
+			return nil, reply.err
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries, nil
+}
+
+func (c *sftpClient) closeHandle(handle string) error {
+	return c.pathRequest(sftpClose, handle)
+}
+
+// remove deletes a file, or an empty directory when dir is set
+func (c *sftpClient) remove(p string, dir bool) error {
+	if dir {
+		return c.pathRequest(sftpRmdir, p)
+	}
+	return c.pathRequest(sftpRemove, p)
+}
+
+// rename moves oldPath to newPath, which must not exist
+func (c *sftpClient) rename(oldPath, newPath string) error {
+	var body sftpPacket
+	body.string(oldPath)
+	body.string(newPath)
+	_, err := c.expect(sftpRename, body, sftpStatus)
+	return err
+}
+
+// download copies the remote file p to w
+func (c *sftpClient) download(p string, w io.Writer) error {
+	handle, err := c.open(p, sftpOpenRead, 0)
+	if err != nil {
+		return err
+	}
+	defer c.closeHandle(handle)
+	for offset := uint64(0); ; {
+		var body sftpPacket
+		body.string(handle)
+		body.uint64(offset)
+		body.uint32(sftpChunk)
+		reply, err := c.expect(sftpRead, body, sftpData)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data := reply.string()
+		if reply.err != nil {
+			return reply.err
+		}
+		if _, err := io.WriteString(w, data); err != nil {
+			return err
+		}
+		offset += uint64(len(data))
+	}
+}
+
+// upload writes r to the remote file p, replacing it, with permissions perm
+func (c *sftpClient) upload(r io.Reader, p string, perm os.FileMode) error {
+	handle, err := c.open(p, sftpOpenWrite|sftpOpenCreate|sftpOpenTrunc, perm)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, sftpChunk)
+	var offset uint64
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			var body sftpPacket
+			body.string(handle)
+			body.uint64(offset)
+			body.string(string(buf[:n]))
+			if _, err := c.expect(sftpWrite, body, sftpStatus); err != nil {
+				c.closeHandle(handle)
+				return err
+			}
+			offset += uint64(n)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			c.closeHandle(handle)
+			return readErr
+		}
+	}
+	// Servers may only report write errors when the file is closed
+	return c.closeHandle(handle)
+}
+
+func (c *sftpClient) open(p string, flags uint32, perm os.FileMode) (string, error) {
+	var body sftpPacket
+	body.string(p)
+	body.uint32(flags)
+	if perm != 0 {
+		body.uint32(sftpAttrPermissions)
+		body.uint32(uint32(perm.Perm()))
+	} else {
+		body.uint32(0)
+	}
+	reply, err := c.expect(sftpOpen, body, sftpHandle)
+	if err != nil {
+		return "", err
+	}
+	return reply.string(), reply.err
+}
+
+// sftpPacket builds the body of a packet
+type sftpPacket []byte
+
+func (p *sftpPacket) uint32(v uint32) {
+	*p = binary.BigEndian.AppendUint32(*p, v)
+}
+
+func (p *sftpPacket) uint64(v uint64) {
+	*p = binary.BigEndian.AppendUint64(*p, v)
+}
+
+func (p *sftpPacket) string(s string) {
+	p.uint32(uint32(len(s)))
+	*p = append(*p, s...)
+}
+
+// sftpReader decodes the body of a packet; after the first error, reads
+// return zero values and err is set
+type sftpReader struct {
+	data []byte
+	err  error
+}
+
+func (r *sftpReader) uint32() uint32 {
+	if len(r.data) < 4 {
+		r.fail()
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *sftpReader) uint64() uint64 {
+	if len(r.data) < 8 {
+		r.fail()
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v
+}
+
+func (r *sftpReader) string() string {
+	n := r.uint32()
+	if uint32(len(r.data)) < n {
+		r.fail()
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
+
+func (r *sftpReader) fail() {
+	if r.err == nil {
+		r.err = errors.New("truncated sftp packet")
+	}
+	r.data = nil
+}
+
+// attrs decodes file attributes into an entry without a name
+func (r *sftpReader) attrs() fileEntry {
+	var entry fileEntry
+	flags := r.uint32()
+	if flags&sftpAttrSize != 0 {
+		entry.size = int64(r.uint64())
+	}
+	if flags&sftpAttrUIDGID != 0 {
+		r.uint32()
+		r.uint32()
+	}
+	if flags&sftpAttrPermissions != 0 {
+		entry.mode = unixMode(r.uint32())
+	}
+	if flags&sftpAttrACModTime != 0 {
+		r.uint32()
+		entry.modTime = time.Unix(int64(r.uint32()), 0)
+	}
+	if flags&sftpAttrExtended != 0 {
+		for n := r.uint32(); n > 0 && r.err == nil; n-- {
+			r.string()
+			r.string()
+		}
+	}
+	return entry
+}
+
+// unixMode converts st_mode bits as sent by the server to an os.FileMode
+func unixMode(m uint32) os.FileMode {
+	mode := os.FileMode(m & 0777)
+	switch m & 0170000 {
+	case 0040000:
+		mode |= os.ModeDir
+	case 0120000:
+		mode |= os.ModeSymlink
+	}
+	return mode
+}
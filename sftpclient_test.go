@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"slices"
+	"testing"
+)
+
+// fakeSFTPServer answers the requests of a directory listing of /home/u
+func fakeSFTPServer(t *testing.T, r io.Reader, w io.Writer) {
+	reply := func(typ byte, body sftpPacket) {
+		header := binary.BigEndian.AppendUint32(nil, uint32(len(body)+1))
+		w.Write(append(append(header, typ), body...))
+	}
+	readdirs := 0
+	for {
+		var header [5]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return
+		}
+		data := make([]byte, binary.BigEndian.Uint32(header[:4])-1)
+		io.ReadFull(r, data)
+		req := &sftpReader{data: data}
+		if header[4] == sftpInit {
+			var body sftpPacket
+			body.uint32(3)
+			reply(sftpVersion, body)
+			continue
+		}
+		var body sftpPacket
+		body.uint32(req.uint32())
+		switch header[4] {
+		case sftpRealpath:
+			body.uint32(1)
+			body.string("/home/u")
+			body.string("")
+			body.uint32(0)
+			reply(sftpName, body)
+		case sftpOpendir:
+			if p := req.string(); p != "/home/u" {
+				t.Errorf("unexpected directory %q", p)
+			}
+			body.string("h1")
+			reply(sftpHandle, body)
+		case sftpReaddir:
+			readdirs++
+			if readdirs > 1 {
+				body.uint32(sftpStatusEOF)
+				body.string("")
+				body.string("")
+				reply(sftpStatus, body)
+				continue
+			}
+			body.uint32(3)
+			for _, e := range []struct {
+				name string
+				mode uint32
+			}{{".", 0040755}, {"notes.txt", 0100644}, {"bin", 0040700}} {
+				body.string(e.name)
+				body.string("-rw-r--r-- ...")
+				body.uint32(sftpAttrSize | sftpAttrPermissions | sftpAttrACModTime)
+				body.uint64(42)
+				body.uint32(e.mode)
+				body.uint32(0)
+				body.uint32(1700000000)
+			}
+			reply(sftpName, body)
+		case sftpClose:
+			body.uint32(sftpStatusOK)
+			body.string("")
+			body.string("")
+			reply(sftpStatus, body)
+		default:
+			body.uint32(4)
+			body.string("unsupported")
+			body.string("")
+			reply(sftpStatus, body)
+		}
+	}
+}
+
+func TestSFTPReadDir(t *testing.T) {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	go fakeSFTPServer(t, serverR, serverW)
+	c, err := newSFTPClient(clientW, clientR, func() error { return clientW.Close() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	home, err := c.realPath(".")
+	if err != nil || home != "/home/u" {
+		t.Fatalf("expected /home/u, got %q (%v)", home, err)
+	}
+	entries, err := c.readDir(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.name)
+	}
+	if !slices.Equal(names, []string{"bin", "notes.txt"}) {
+		t.Fatalf("expected bin and notes.txt, got %v", names)
+	}
+	if !entries[0].mode.IsDir() || entries[0].mode.Perm() != 0700 {
+		t.Errorf("expected bin to be a directory with mode 0700, got %v", entries[0].mode)
+	}
+	if entries[1].size != 42 || entries[1].mode != 0644 || entries[1].modTime.Unix() != 1700000000 {
+		t.Errorf("unexpected attributes %+v", entries[1])
+	}
+
+	err = c.rename("/home/u/a", "/home/u/b")
+	if err == nil || err.Error() != "unsupported" {
+		t.Errorf("expected the server's message as error, got %v", err)
+	}
+}
+
+func TestFileModeAndSize(t *testing.T) {
+	if m := unixMode(0120777); m&os.ModeSymlink == 0 || m.Perm() != 0777 {
+		t.Errorf("expected a symbolic link, got %v", m)
+	}
+	if got := formatSize(1536); got != "1.5K" {
+		t.Errorf("expected 1.5K, got %s", got)
+	}
+	if got := formatSize(512); got != "512B" {
+		t.Errorf("expected 512B, got %s", got)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// sharePrefix marks an encrypted host share, so it survives being pasted into chat
+const sharePrefix = "lsh-share-v1:"
+
+var errWrongSharePassphrase = errors.New("wrong passphrase or damaged share")
+
+// shareCommandKeywords are the options of a shared block that run commands on
+// this machine; the user has to see and accept them before the host is imported
+var shareCommandKeywords = []string{"proxycommand", "localcommand", "knownhostscommand", "permitlocalcommand"}
+
+// hostShare is what a share carries: the host's config block and metadata, never passwords
+type hostShare struct {
+	Host     string   `json:"host"`
+	Config   []string `json:"config"`
+	Metadata hostMeta `json:"metadata,omitempty"`
+}
+
+// sealShare encrypts a share with a passphrase, the same way the vault is encrypted
+func sealShare(share hostShare, passphrase string) (string, error) {
+	plain, err := json.Marshal(share)
+	if err != nil {
+		return "", err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	gcm, err := newVaultCipher(deriveVaultKey(passphrase, salt))
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	content, err := json.Marshal(vaultFile{
+		Version: 1,
+		Salt:    salt,
+		Nonce:   nonce,
+		Data:    gcm.Seal(nil, nonce, plain, nil),
+	})
+	if err != nil {
+		return "", err
+	}
+	return sharePrefix + base64.RawURLEncoding.EncodeToString(content), nil
+}
+
+// openShare decrypts a share made by sealShare
+func openShare(text, passphrase string) (hostShare, error) {
+	var share hostShare
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(text), sharePrefix)
+	if !ok {
+		return share, errors.New("not a host share")
+	}
+	content, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return share, errWrongSharePassphrase
+	}
+	var f vaultFile
+	if err := json.Unmarshal(content, &f); err != nil {
+		return share, errWrongSharePassphrase
+	}
+	gcm, err := newVaultCipher(deriveVaultKey(passphrase, f.Salt))
+	if err != nil {
+		return share, err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return share, errWrongSharePassphrase
+	}
+	err = json.Unmarshal(plain, &share)
+	return share, err
+}
+
+// readPassphrase asks for a passphrase on the terminal without echoing it
+func readPassphrase(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	pw, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(pw), err
+}
+
+// sshConfigPath returns the location of the user's SSH config
+func sshConfigPath() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(usr.HomeDir, ".ssh", "config"), nil
+}
+
+// runShare implements "share <host> [file]"
+func runShare(args []string) int {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Println("Usage: list-ssh-hosts share <host> [file]")
+		return 2
+	}
+	path, err := sshConfigPath()
+	if err != nil {
+		fmt.Println("Could not get current user:", err)
+		return 1
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("Could not read ~/.ssh/config:", err)
+		return 1
+	}
+	block := getHostBlock(strings.Split(string(content), "\n"), args[0])
+	if block == nil {
+		fmt.Println("Unknown host:", args[0])
+		return 1
+	}
+	md, err := loadHostMetadata()
+	if err != nil {
+		fmt.Println("Could not read host metadata:", err)
+		return 1
+	}
+
+	passphrase, err := readPassphrase("Passphrase for the share: ")
+	if err != nil || passphrase == "" {
+		fmt.Println("A passphrase is required.")
+		return 1
+	}
+	if again, _ := readPassphrase("Repeat passphrase: "); again != passphrase {
+		fmt.Println("Passphrases do not match.")
+		return 1
+	}
+	lines := strings.Split(strings.TrimRight(strings.Join(block.lines, "\n"), "\n\t "), "\n")
+	// The block may list more aliases; the share carries it for this one only
+	lines[0] = "Host " + args[0]
+	text, err := sealShare(hostShare{Host: args[0], Config: lines, Metadata: md[args[0]]}, passphrase)
+	if err != nil {
+		fmt.Println("Could not encrypt the share:", err)
+		return 1
+	}
+
+	if len(args) == 2 {
+		if err := os.WriteFile(args[1], []byte(text+"\n"), 0600); err != nil {
+			fmt.Println("Could not write share:", err)
+			return 1
+		}
+	} else {
+		fmt.Println(text)
+	}
+	fmt.Fprintln(os.Stderr, "Send the passphrase separately. Import with: list-ssh-hosts import <file>")
+	return 0
+}
+
+// runImport implements "import <file|->", adding a shared host to ~/.ssh/config and hosts.json
+func runImport(args []string) int {
+	if len(args) != 1 {
+		fmt.Println("Usage: list-ssh-hosts import <file|->")
+		return 2
+	}
+	var text []byte
+	var err error
+	if args[0] == "-" {
+		text, err = io.ReadAll(os.Stdin)
+	} else {
+		text, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		fmt.Println("Could not read share:", err)
+		return 1
+	}
+	passphrase, err := readPassphrase("Passphrase: ")
+	if err != nil {
+		fmt.Println("Could not read passphrase:", err)
+		return 1
+	}
+	share, err := openShare(string(text), passphrase)
+	if err != nil {
+		fmt.Println("Could not open share:", err)
+		return 1
+	}
+	commands, err := checkShareConfig(share)
+	if err != nil {
+		fmt.Println("Refusing to import", share.Host+":", err)
+		return 1
+	}
+	if len(commands) > 0 {
+		fmt.Println("The share runs commands on this machine:")
+		for _, line := range commands {
+			fmt.Println("   ", line)
+		}
+		if !confirm("Import it anyway? [y/N] ") {
+			fmt.Println("Not imported.")
+			return 1
+		}
+	}
+
+	path, err := sshConfigPath()
+	if err != nil {
+		fmt.Println("Could not get current user:", err)
+		return 1
+	}
+	if err := importShare(path, share); err != nil {
+		fmt.Println("Could not import", share.Host+":", err)
+		return 1
+	}
+	if len(metadataFields(share.Metadata)) > 0 {
+		err := updateHostMetadata(func(md hostMetadata) { md[share.Host] = share.Metadata })
+		if err != nil {
+			fmt.Println("Imported the host, but could not save its metadata:", err)
+			return 1
+		}
+	}
+	fmt.Println("Imported", share.Host)
+	return 0
+}
+
+// configKeyword returns the lowercased keyword of an SSH config line, which may
+// be separated from its value by spaces or "="
+func configKeyword(line string) string {
+	line = strings.TrimSpace(line)
+	if i := strings.IndexAny(line, " \t="); i >= 0 {
+		line = line[:i]
+	}
+	return strings.ToLower(line)
+}
+
+// checkShareConfig makes sure a share holds exactly one block, for the host it
+// is named after, and returns its lines that run local commands
+func checkShareConfig(share hostShare) ([]string, error) {
+	if len(share.Config) == 0 {
+		return nil, errors.New("the share holds no config")
+	}
+	if fields := strings.Fields(share.Config[0]); len(fields) != 2 || !strings.EqualFold(fields[0], "Host") || fields[1] != share.Host {
+		return nil, fmt.Errorf("the share does not start with \"Host %s\"", share.Host)
+	}
+	var commands []string
+	for _, line := range share.Config[1:] {
+		switch keyword := configKeyword(line); {
+		case keyword == "host" || keyword == "match" || keyword == "include":
+			return nil, fmt.Errorf("the share holds a %s line", keyword)
+		case slices.Contains(shareCommandKeywords, keyword):
+			commands = append(commands, strings.TrimSpace(line))
+		}
+	}
+	return commands, nil
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to no
+func confirm(prompt string) bool {
+	fmt.Fprint(os.Stderr, prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// importShare appends a shared host block to the SSH config at path, refusing to
+// replace an existing host or to add anything but that one block. Lines that run
+// local commands are let through; runImport asks about them first.
+func importShare(path string, share hostShare) error {
+	if _, err := checkShareConfig(share); err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if getHostBlock(strings.Split(string(content), "\n"), share.Host) != nil {
+		return errors.New("a host with this name already exists in ~/.ssh/config")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	prefix := "\n"
+	if len(content) == 0 || strings.HasSuffix(string(content), "\n\n") {
+		prefix = ""
+	} else if !strings.HasSuffix(string(content), "\n") {
+		prefix = "\n\n"
+	}
+	_, err = f.WriteString(prefix + strings.Join(share.Config, "\n") + "\n")
+	return err
+}
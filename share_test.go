@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestShareRoundTrip(t *testing.T) {
+	share := hostShare{
+		Host:     "db1",
+		Config:   []string{"Host db1", "    Hostname 10.0.0.5", "    User postgres"},
+		Metadata: hostMeta{Environment: "prod"},
+	}
+	text, err := sealShare(share, "open sesame")
+	if err != nil {
+		t.Fatalf("sealShare failed: %v", err)
+	}
+	got, err := openShare(text+"\n", "open sesame")
+	if err != nil {
+		t.Fatalf("openShare failed: %v", err)
+	}
+	if got.Host != "db1" || len(got.Config) != 3 || got.Metadata.Environment != "prod" {
+		t.Errorf("unexpected share %+v", got)
+	}
+	if _, err := openShare(text, "wrong"); err != errWrongSharePassphrase {
+		t.Errorf("expected errWrongSharePassphrase, got %v", err)
+	}
+}
+
+func TestImportShare(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("Host web1\n    Hostname 10.0.0.1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	share := hostShare{Host: "db1", Config: []string{"Host db1", "    Hostname 10.0.0.5"}}
+	if err := importShare(path, share); err != nil {
+		t.Fatalf("importShare failed: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "Host web1\n    Hostname 10.0.0.1\n\nHost db1\n    Hostname 10.0.0.5\n" {
+		t.Errorf("unexpected config %q", content)
+	}
+	if err := importShare(path, share); err == nil {
+		t.Error("expected importing an existing host to fail")
+	}
+}
+
+func TestCheckShareConfig(t *testing.T) {
+	for _, config := range [][]string{
+		{"Host other", "    Hostname 10.0.0.5"},
+		{"Host db1 web1", "    Hostname 10.0.0.5"},
+		{"    Hostname 10.0.0.5"},
+		{"Host db1", "    Hostname 10.0.0.5", "Host *", "    User root"},
+		{"Host db1", "  Match all"},
+		{"Host db1", "  Include=~/evil"},
+	} {
+		if _, err := checkShareConfig(hostShare{Host: "db1", Config: config}); err == nil {
+			t.Errorf("expected %q to be refused", config)
+		}
+	}
+
+	share := hostShare{Host: "db1", Config: []string{
+		"Host db1",
+		"    Hostname 10.0.0.5",
+		"    ProxyCommand nc %h %p",
+		"    localcommand=touch /tmp/x",
+	}}
+	commands, err := checkShareConfig(share)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ProxyCommand nc %h %p", "localcommand=touch /tmp/x"}; !slices.Equal(commands, want) {
+		t.Errorf("commands = %q, want %q", commands, want)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// resultSink receives the result of each host of a bulk run as it finishes, for
+// consumers other than the terminal such as CI pipelines and audits. add is never
+// called concurrently.
+type resultSink interface {
+	add(host string, res bulkResult, output string) error
+	Close() error
+}
+
+// jsonlRecord is one line of a JSON lines sink
+type jsonlRecord struct {
+	Host       string    `json:"host"`
+	Command    string    `json:"command"`
+	Status     string    `json:"status"`
+	ExitCode   int       `json:"exit_code"`
+	Error      string    `json:"error,omitempty"`
+	Finished   time.Time `json:"finished"`
+	Output     string    `json:"output"`
+	OutputFile string    `json:"output_file,omitempty"` // the full output, when output was truncated
+}
+
+// jsonlSink writes one JSON object per host
+type jsonlSink struct {
+	command string
+	file    *os.File
+	enc     *json.Encoder
+}
+
+func newJSONLSink(path, command string) (*jsonlSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlSink{command: command, file: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (s *jsonlSink) add(host string, res bulkResult, output string) error {
+	return s.enc.Encode(jsonlRecord{
+		Host:       host,
+		Command:    s.command,
+		Status:     res.status(),
+		ExitCode:   res.ExitCode,
+		Error:      res.Error,
+		Finished:   res.Finished,
+		Output:     output,
+		OutputFile: res.Output,
+	})
+}
+
+func (s *jsonlSink) Close() error {
+	return s.file.Close()
+}
+
+// junitSuite is a JUnit XML report with one test case per host
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+// junitSink collects the hosts and writes the report on Close, as the totals
+// come first in the file
+type junitSink struct {
+	path  string
+	suite junitSuite
+}
+
+func newJUnitSink(path, command string) *junitSink {
+	return &junitSink{path: path, suite: junitSuite{Name: command}}
+}
+
+func (s *junitSink) add(host string, res bulkResult, output string) error {
+	c := junitCase{Name: host, Classname: "list-ssh-hosts.exec", SystemOut: output}
+	if !res.succeeded() {
+		message := fmt.Sprintf("exit %d", res.ExitCode)
+		if res.Error != "" {
+			message = res.Error
+		}
+		c.Failure = &junitFailure{Message: message, Type: res.status()}
+		s.suite.Failures++
+	}
+	s.suite.Tests++
+	s.suite.Cases = append(s.suite.Cases, c)
+	return nil
+}
+
+func (s *junitSink) Close() error {
+	content, err := xml.MarshalIndent(s.suite, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, append([]byte(xml.Header), append(content, '\n')...), 0644)
+}
+
+// dirSink writes <host>.out with the output and <host>.status with the exit code or error of each host
+type dirSink struct {
+	dir string
+}
+
+func newDirSink(dir string) (*dirSink, error) {
+	return &dirSink{dir: dir}, os.MkdirAll(dir, 0755)
+}
+
+func (s *dirSink) add(host string, res bulkResult, output string) error {
+	if err := writeStatusFile(s.dir, host, res); err != nil {
+		return err
+	}
+	if res.Output == "" {
+		return os.WriteFile(filepath.Join(s.dir, host+".out"), []byte(output), 0644)
+	}
+	// The output was truncated; the file gets all of it
+	return copyFile(res.Output, filepath.Join(s.dir, host+".out"))
+}
+
+func (s *dirSink) Close() error {
+	return nil
+}
+
+// writeStatusFile writes the exit code or error of a host to <host>.status in dir
+func writeStatusFile(dir, host string, res bulkResult) error {
+	status := fmt.Sprintf("exit %d\n", res.ExitCode)
+	if res.Error != "" {
+		status = res.Error + "\n"
+	}
+	return os.WriteFile(filepath.Join(dir, host+".status"), []byte(status), 0644)
+}
+
+// copyFile copies the content of src to dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultSinks(t *testing.T) {
+	dir := t.TempDir()
+	failing := exec.Command("sh", "-c", "exit 2").Run()
+	runner := func(host, command string, timeout time.Duration, stdout io.Writer) error {
+		if host == "db1" {
+			fmt.Fprint(stdout, strings.Repeat("x", 20))
+			return failing
+		}
+		fmt.Fprint(stdout, "ok\n")
+		return nil
+	}
+	jsonl, err := newJSONLSink(filepath.Join(dir, "results.jsonl"), "df -h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := newDirSink(filepath.Join(dir, "hosts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sinks := []resultSink{jsonl, newJUnitSink(filepath.Join(dir, "junit.xml"), "df -h"), hosts}
+	run := &bulkRun{Command: "df -h", Hosts: []string{"web1", "db1"}}
+	limits := bulkLimits{timeout: time.Minute, maxOutput: 10, outputDir: filepath.Join(dir, "output")}
+	if err := executeBulkRun(run, run.Hosts, filepath.Join(dir, "lastrun.json"), limits, io.Discard, runner, sinks); err != nil {
+		t.Fatalf("executeBulkRun failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "results.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a line per host, got:\n%s", content)
+	}
+	records := map[string]jsonlRecord{}
+	for _, line := range lines {
+		var rec jsonlRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid line %q: %v", line, err)
+		}
+		records[rec.Host] = rec
+	}
+	if rec := records["db1"]; rec.Status != resultFailed || rec.ExitCode != 2 || rec.Output != "xxxxxxxxxx" || rec.OutputFile == "" {
+		t.Errorf("unexpected record for db1: %+v", rec)
+	}
+	if rec := records["web1"]; rec.Status != resultSucceeded || rec.Output != "ok\n" || rec.Command != "df -h" {
+		t.Errorf("unexpected record for web1: %+v", rec)
+	}
+
+	content, err = os.ReadFile(filepath.Join(dir, "junit.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(content, []byte(`<testsuite name="df -h" tests="2" failures="1">`)) ||
+		!bytes.Contains(content, []byte(`<failure message="exit 2" type="failed"></failure>`)) {
+		t.Errorf("unexpected report:\n%s", content)
+	}
+
+	// The directory gets the full output, not only what was printed
+	if content, _ := os.ReadFile(filepath.Join(dir, "hosts", "db1.out")); string(content) != strings.Repeat("x", 20) {
+		t.Errorf("expected the full output of db1, got %q", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "hosts", "db1.status")); string(content) != "exit 2\n" {
+		t.Errorf("unexpected status of db1: %q", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "hosts", "web1.out")); string(content) != "ok\n" {
+		t.Errorf("unexpected output of web1: %q", content)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+// snippetScreenKeys are the keys of the snippets screen, which snippets cannot take
+var snippetScreenKeys = []string{"up", "down", "j", "k", "enter", "esc", "q", "r"}
+
+// snippet is a named command of the snippet library in snippets.yaml
+type snippet struct {
+	Name    string `yaml:"name"`
+	Command string `yaml:"command"`
+	// Key runs the snippet with one keystroke on the snippets screen; snippets
+	// without one get the digits 1 to 9 in order
+	Key string `yaml:"key,omitempty"`
+}
+
+// snippetOutputMsg reports the output of a snippet run on a host
+type snippetOutputMsg struct {
+	snippet snippet
+	output  string
+	err     error
+}
+
+// snippetsPath returns the location of the snippet library in the app config directory
+func snippetsPath() (string, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snippets.yaml"), nil
+}
+
+// loadSnippets reads the snippet library and assigns the keys of the snippets
+// that set none. A missing file yields no snippets.
+func loadSnippets() ([]snippet, error) {
+	path, err := snippetsPath()
+	if err != nil {
+		return nil, err
+	}
+	return readSnippets(path)
+}
+
+// readSnippets reads a snippet library from the given path
+func readSnippets(path string) ([]snippet, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snippets []snippet
+	if err := yaml.Unmarshal(content, &snippets); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	taken := map[string]bool{}
+	for i, s := range snippets {
+		switch {
+		case s.Name == "" || s.Command == "":
+			return nil, fmt.Errorf("%s: snippet %d needs a name and a command", path, i+1)
+		case s.Key == "":
+		case len([]rune(s.Key)) != 1 || slices.Contains(snippetScreenKeys, s.Key):
+			return nil, fmt.Errorf("%s: %q cannot use the key %q; pick a single character other than %s", path, s.Name, s.Key, strings.Join(snippetScreenKeys, ", "))
+		case taken[s.Key]:
+			return nil, fmt.Errorf("%s: the key %q is used by more than one snippet", path, s.Key)
+		}
+		taken[s.Key] = true
+	}
+	digit := 1
+	for i := range snippets {
+		for snippets[i].Key == "" && digit <= 9 {
+			if key := strconv.Itoa(digit); !taken[key] {
+				snippets[i].Key = key
+			}
+			digit++
+		}
+	}
+	return snippets, nil
+}
+
+// snippetsView lists the snippets with their keys and commands, with a cursor
+func snippetsView(snippets []snippet, cursor int) string {
+	var b strings.Builder
+	for i, s := range snippets {
+		pointer := "  "
+		if i == cursor {
+			pointer = "> "
+		}
+		key := " "
+		if s.Key != "" {
+			key = s.Key
+		}
+		fmt.Fprintf(&b, "%s[%s] %s\n", pointer, key, s.Name)
+		fmt.Fprintf(&b, "      %s\n", s.Command)
+	}
+	return b.String()
+}
+
+// snippetOutput keeps the first limit bytes of a snippet's combined output and
+// drops the rest, so a command that prints without end cannot exhaust memory
+type snippetOutput struct {
+	limit     int
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (o *snippetOutput) Write(p []byte) (int, error) {
+	room := o.limit - o.buf.Len()
+	if len(p) > room {
+		o.truncated = true
+	}
+	o.buf.Write(p[:max(0, min(room, len(p)))])
+	return len(p), nil
+}
+
+func (o *snippetOutput) String() string {
+	if o.truncated {
+		return o.buf.String() + fmt.Sprintf("\n[output truncated at %d bytes]", o.limit)
+	}
+	return o.buf.String()
+}
+
+// runSnippet runs a snippet on target with ssh in the background, with the
+// verified password when set, and reports up to maxOutput bytes of its combined output.
+// The command is handed to sh, so it behaves the same whatever the login shell.
+func runSnippet(ctx context.Context, s snippet, target string, opts []string, password []byte, keyFile string, maxOutput int) tea.Cmd {
+	return func() tea.Msg {
+		defer clear(password)
+		args := append(slices.Clone(opts), target, remoteCommand(shellPOSIX, "sh", s.Command))
+		var cmd *exec.Cmd
+		var secret *os.File
+		var err error
+		if password == nil {
+			cmd = exec.CommandContext(ctx, "ssh", append([]string{"-o", "BatchMode=yes"}, args...)...)
+		} else if cmd, secret, err = sshpassCommand(ctx, password, keyFile, args...); err != nil {
+			return snippetOutputMsg{snippet: s, err: err}
+		}
+		out := &snippetOutput{limit: maxOutput}
+		cmd.Stdout = out
+		cmd.Stderr = out
+		err = cmd.Start()
+		if secret != nil {
+			secret.Close()
+		}
+		if err == nil {
+			err = cmd.Wait()
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return snippetOutputMsg{snippet: s, output: out.String(), err: err}
+	}
+}
+
+// runNativeSnippet runs a snippet over the connection of the built-in client, then closes it
+func runNativeSnippet(client *ssh.Client, s snippet, maxOutput int) tea.Cmd {
+	return func() tea.Msg {
+		defer client.Close()
+		session, err := client.NewSession()
+		if err != nil {
+			return snippetOutputMsg{snippet: s, err: err}
+		}
+		defer session.Close()
+		out := &snippetOutput{limit: maxOutput}
+		session.Stdout = out
+		session.Stderr = out
+		err = session.Run(remoteCommand(shellPOSIX, "sh", s.Command))
+		return snippetOutputMsg{snippet: s, output: out.String(), err: err}
+	}
+}
+
+// snippetOutcome describes how a snippet ended, for the header of its output
+func snippetOutcome(err error) string {
+	var exitErr *exec.ExitError
+	var remoteErr *ssh.ExitError
+	switch {
+	case err == nil:
+		return "exit status 0"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timed out"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == sshConnectionError:
+		return "ssh failed"
+	case errors.As(err, &exitErr):
+		return fmt.Sprintf("exit status %d", exitErr.ExitCode())
+	case errors.As(err, &remoteErr):
+		return fmt.Sprintf("exit status %d", remoteErr.ExitStatus())
+	}
+	return err.Error()
+}
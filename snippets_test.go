@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadSnippets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snippets.yaml")
+	if snippets, err := readSnippets(path); err != nil || snippets != nil {
+		t.Fatalf("expected no snippets without a file, got %v, %v", snippets, err)
+	}
+	content := `
+- name: tail nginx logs
+  command: sudo tail -n 200 /var/log/nginx/error.log
+- name: restart service
+  command: sudo systemctl restart app
+  key: "1"
+- name: disk usage
+  command: df -h
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	snippets, err := readSnippets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, s := range snippets {
+		keys = append(keys, s.Key)
+	}
+	if strings.Join(keys, " ") != "2 1 3" {
+		t.Errorf("expected the free digits for snippets without a key, got %v", keys)
+	}
+	if got := snippetsView(snippets, 1); !strings.Contains(got, "> [1] restart service\n      sudo systemctl restart app\n") {
+		t.Errorf("expected the selected snippet with its key and command, got:\n%s", got)
+	}
+
+	for name, content := range map[string]string{
+		"no command": "- name: x\n",
+		"screen key": "- name: x\n  command: ls\n  key: q\n",
+		"long key":   "- name: x\n  command: ls\n  key: ab\n",
+		"same key":   "- name: x\n  command: ls\n  key: a\n- name: y\n  command: ls\n  key: a\n",
+		"not a list": "name: x\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readSnippets(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSnippetOutputLimit(t *testing.T) {
+	out := &snippetOutput{limit: 8}
+	out.Write([]byte("hello "))
+	out.Write([]byte("world"))
+	if got := out.String(); got != "hello wo\n[output truncated at 8 bytes]" {
+		t.Errorf("expected the output cut at the limit, got %q", got)
+	}
+	out = &snippetOutput{limit: 8}
+	out.Write([]byte("ok"))
+	if got := out.String(); got != "ok" {
+		t.Errorf("expected short output unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Orders of the host list, cycled with o. The last one used is kept in
+// state.json; before that, "sort" in config.json picks one.
+const (
+	sortConfig       = "config"       // the order of ~/.ssh/config
+	sortAlphabetical = "alphabetical" // by alias, in natural order
+	sortRecent       = "recent"       // last connected first
+	sortFrecency     = "frecency"     // connected to often and lately first
+	sortLatency      = "latency"      // quickest to reach first
+)
+
+// sortModes is the order in which o cycles through the sort modes
+var sortModes = []string{sortConfig, sortAlphabetical, sortRecent, sortFrecency, sortLatency}
+
+// nextSortMode returns the sort mode after mode, starting over after the last.
+// Unknown modes count as the config order.
+func nextSortMode(mode string) string {
+	i := slices.Index(sortModes, mode)
+	return sortModes[(max(0, i)+1)%len(sortModes)]
+}
+
+// sortLabel describes a sort mode for the status bar
+func sortLabel(mode string) string {
+	switch mode {
+	case sortRecent:
+		return "most recently used"
+	case sortAlphabetical, sortFrecency, sortLatency:
+		return mode
+	}
+	return "config order"
+}
+
+// hostUsage is what is known about using and reaching each host, for sorting
+type hostUsage struct {
+	last     map[string]time.Time     // last connection, counting failed attempts
+	frecency map[string]float64       // sum of frecencyWeight over the successful connections
+	latency  map[string]time.Duration // time to reach the ssh port, measured in the latency order
+}
+
+// frecencyWeight is what a connection of the given age adds to the frecency of
+// its host: the more recent, the more it counts, as zoxide weighs its entries
+func frecencyWeight(age time.Duration) float64 {
+	switch {
+	case age < time.Hour:
+		return 4
+	case age < 24*time.Hour:
+		return 2
+	case age < 7*24*time.Hour:
+		return 0.5
+	}
+	return 0.25
+}
+
+// usageFromHistory sums up the history as of now
+func usageFromHistory(entries []historyEntry, now time.Time) hostUsage {
+	u := hostUsage{last: map[string]time.Time{}, frecency: map[string]float64{}}
+	for _, e := range entries {
+		if e.Time.After(u.last[e.Host]) {
+			u.last[e.Host] = e.Time
+		}
+		if !e.Failed {
+			u.frecency[e.Host] += frecencyWeight(now.Sub(e.Time))
+		}
+	}
+	return u
+}
+
+// naturalCompare compares two aliases ignoring case, with runs of digits
+// compared by their value, so that web2 comes before web10
+func naturalCompare(a, b string) int {
+	x, y := strings.ToLower(a), strings.ToLower(b)
+	for x != "" && y != "" {
+		dx, dy := digitPrefix(x), digitPrefix(y)
+		if dx == "" || dy == "" {
+			// Compare a character, a digit sorting before a letter as in ASCII
+			if c := strings.Compare(x[:1], y[:1]); c != 0 {
+				return c
+			}
+			x, y = x[1:], y[1:]
+			continue
+		}
+		nx, ny := strings.TrimLeft(dx, "0"), strings.TrimLeft(dy, "0")
+		if c := cmp.Compare(len(nx), len(ny)); c != 0 {
+			return c
+		}
+		if c := strings.Compare(nx, ny); c != 0 {
+			return c
+		}
+		x, y = x[len(dx):], y[len(dy):]
+	}
+	if c := cmp.Compare(len(x), len(y)); c != 0 {
+		return c
+	}
+	// Equal but for case or leading zeros
+	return strings.Compare(a, b)
+}
+
+// digitPrefix returns the run of digits s starts with
+func digitPrefix(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// sortHosts orders hosts by mode. Hosts the mode knows nothing about, such as
+// those never connected to, follow the others in config order.
+func sortHosts(hosts []hostItem, mode string, usage hostUsage) []hostItem {
+	sorted := slices.Clone(hosts)
+	switch mode {
+	case sortAlphabetical:
+		slices.SortStableFunc(sorted, func(a, b hostItem) int { return naturalCompare(a.host, b.host) })
+	case sortRecent:
+		slices.SortStableFunc(sorted, func(a, b hostItem) int { return usage.last[b.host].Compare(usage.last[a.host]) })
+	case sortFrecency:
+		slices.SortStableFunc(sorted, func(a, b hostItem) int { return cmp.Compare(usage.frecency[b.host], usage.frecency[a.host]) })
+	case sortLatency:
+		slices.SortStableFunc(sorted, func(a, b hostItem) int {
+			da, okA := usage.latency[a.host]
+			db, okB := usage.latency[b.host]
+			if okA != okB {
+				// Unreachable and unmeasured hosts last
+				if okA {
+					return -1
+				}
+				return 1
+			}
+			return cmp.Compare(da, db)
+		})
+	}
+	return sorted
+}
+
+// cycleSortMode switches the list to the next sort mode and remembers it in
+// state.json. The latency order measures the hosts the first time it is used.
+func (m *model) cycleSortMode() tea.Cmd {
+	m.sortMode = nextSortMode(m.sortMode)
+	_ = updateAppState(func(st *appState) { st.SortMode = m.sortMode })
+	cmds := []tea.Cmd{m.refreshList(), m.refreshInfoBox()}
+	if m.sortMode == sortLatency && m.usage.latency == nil && !m.latencyProbing {
+		m.latencyProbing = true
+		var hosts []string
+		for _, h := range m.hostItems() {
+			hosts = append(hosts, h.host)
+		}
+		cmds = append(cmds, probeLatencies(hosts, m.config.execWorkers()))
+	}
+	return tea.Batch(cmds...)
+}
+
+// sortStatus names the order of the list for the status bar
+func (m *model) sortStatus() string {
+	status := "Sorted by " + sortLabel(m.sortMode) + " (o to change)"
+	if m.sortMode == sortLatency && m.latencyProbing {
+		status += ", measuring latency…"
+	}
+	return status
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSortHostsRecent(t *testing.T) {
+	now := time.Now()
+	usage := usageFromHistory([]historyEntry{
+		{Host: "b", Time: now.Add(-3 * time.Hour)},
+		{Host: "d", Time: now.Add(-time.Hour), Failed: true},
+		{Host: "b", Time: now.Add(-2 * time.Hour)},
+		{Host: "d", Time: now.Add(-4 * time.Hour)},
+	}, now)
+	if !usage.last["b"].Equal(now.Add(-2*time.Hour)) || !usage.last["d"].Equal(now.Add(-time.Hour)) {
+		t.Errorf("unexpected last connections %v", usage.last)
+	}
+	hosts := []hostItem{{host: "a"}, {host: "b"}, {host: "c"}, {host: "d"}}
+	if got := hostNames(sortHosts(hosts, sortRecent, usage)); !slices.Equal(got, []string{"d", "b", "a", "c"}) {
+		t.Errorf("recent order %q", got)
+	}
+	if got := hostNames(sortHosts(hosts, sortConfig, usage)); !slices.Equal(got, []string{"a", "b", "c", "d"}) {
+		t.Errorf("config order %q", got)
+	}
+	if got := hostNames(hosts); !slices.Equal(got, []string{"a", "b", "c", "d"}) {
+		t.Errorf("sortHosts changed its input to %q", got)
+	}
+}
+
+func TestSortHostsFrecency(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	var entries []historyEntry
+	// daily is used every day, old was used a lot a month ago, fresh once just now
+	for i := range 6 {
+		entries = append(entries, historyEntry{Host: "daily", Time: now.Add(-time.Duration(i) * day).Add(-2 * time.Hour)})
+	}
+	for range 8 {
+		entries = append(entries, historyEntry{Host: "old", Time: now.Add(-30 * day)})
+	}
+	entries = append(entries,
+		historyEntry{Host: "fresh", Time: now.Add(-time.Minute)},
+		historyEntry{Host: "broken", Time: now.Add(-time.Minute), Failed: true},
+	)
+	usage := usageFromHistory(entries, now)
+	if got := usage.frecency["daily"]; got != 2+5*0.5 {
+		t.Errorf("daily: frecency %v", got)
+	}
+	hosts := []hostItem{{host: "broken"}, {host: "old"}, {host: "fresh"}, {host: "daily"}, {host: "never"}}
+	if got := hostNames(sortHosts(hosts, sortFrecency, usage)); !slices.Equal(got, []string{"daily", "fresh", "old", "broken", "never"}) {
+		t.Errorf("frecency order %q", got)
+	}
+}
+
+func TestSortHostsAlphabeticalAndLatency(t *testing.T) {
+	hosts := []hostItem{{host: "web"}, {host: "Bastion"}, {host: "db"}, {host: "api"}}
+	if got := hostNames(sortHosts(hosts, sortAlphabetical, hostUsage{})); !slices.Equal(got, []string{"api", "Bastion", "db", "web"}) {
+		t.Errorf("alphabetical order %q", got)
+	}
+	usage := hostUsage{latency: map[string]time.Duration{"db": 40 * time.Millisecond, "api": 5 * time.Millisecond}}
+	if got := hostNames(sortHosts(hosts, sortLatency, usage)); !slices.Equal(got, []string{"api", "db", "web", "Bastion"}) {
+		t.Errorf("latency order %q", got)
+	}
+}
+
+func TestNextSortMode(t *testing.T) {
+	mode := sortConfig
+	var cycle []string
+	for range len(sortModes) {
+		mode = nextSortMode(mode)
+		cycle = append(cycle, mode)
+	}
+	if want := []string{sortAlphabetical, sortRecent, sortFrecency, sortLatency, sortConfig}; !slices.Equal(cycle, want) {
+		t.Errorf("cycle %q, want %q", cycle, want)
+	}
+	if got := nextSortMode("bogus"); got != sortAlphabetical {
+		t.Errorf("unknown modes should count as the config order, got %q", got)
+	}
+	if got := sortLabel(""); got != "config order" {
+		t.Errorf("sortLabel(\"\") = %q", got)
+	}
+}
+
+func TestNaturalCompare(t *testing.T) {
+	hosts := []string{"web10", "web2", "Web1", "web", "db-01", "db-1", "db-10", "db-2", "web2a", "web02"}
+	slices.SortFunc(hosts, naturalCompare)
+	want := []string{"db-01", "db-1", "db-2", "db-10", "web", "Web1", "web02", "web2", "web2a", "web10"}
+	if !slices.Equal(hosts, want) {
+		t.Errorf("got %q, want %q", hosts, want)
+	}
+	if naturalCompare("rack1-node9", "rack1-node10") >= 0 || naturalCompare("rack2-node1", "rack10-node1") >= 0 {
+		t.Error("expected every run of digits to compare by value")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+)
+
+// spoolWriter keeps the first limit bytes of a host's output in memory. Once the
+// output grows beyond that, all of it is written to a file instead, so a host that
+// prints without end cannot exhaust memory and nothing is lost.
+type spoolWriter struct {
+	limit int
+	path  string
+	head  bytes.Buffer
+	total int
+	file  *os.File
+	err   error // the first error writing the file
+}
+
+func newSpoolWriter(limit int, path string) *spoolWriter {
+	return &spoolWriter{limit: limit, path: path}
+}
+
+// Write never fails, so the remote command keeps running when the file cannot be written
+func (s *spoolWriter) Write(p []byte) (int, error) {
+	s.total += len(p)
+	if s.file == nil && s.err == nil && s.total > s.limit {
+		s.spool()
+	}
+	if room := s.limit - s.head.Len(); room > 0 {
+		s.head.Write(p[:min(room, len(p))])
+	}
+	if s.file != nil && s.err == nil {
+		_, s.err = s.file.Write(p)
+	}
+	return len(p), nil
+}
+
+// spool creates the file and writes the output kept in memory so far
+func (s *spoolWriter) spool() {
+	if s.err = os.MkdirAll(filepath.Dir(s.path), 0700); s.err != nil {
+		return
+	}
+	if s.file, s.err = os.OpenFile(s.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600); s.err != nil {
+		return
+	}
+	_, s.err = s.file.Write(s.head.Bytes())
+}
+
+// truncated reports whether more output arrived than is kept in memory
+func (s *spoolWriter) truncated() bool {
+	return s.total > s.limit
+}
+
+// Close closes the file, if the output was spooled, and returns the first error writing it
+func (s *spoolWriter) Close() error {
+	if s.file != nil {
+		if err := s.file.Close(); s.err == nil {
+			s.err = err
+		}
+	}
+	return s.err
+}
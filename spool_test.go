@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSpoolWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output", "web1.log")
+	s := newSpoolWriter(8, path)
+	s.Write([]byte("12345"))
+	if s.truncated() {
+		t.Error("expected output within the limit to stay in memory")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected no file before the limit is reached, got %v", err)
+	}
+	s.Write([]byte("6789"))
+	s.Write([]byte(strings.Repeat("x", 100)))
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !s.truncated() || s.head.String() != "12345678" {
+		t.Errorf("expected the first 8 bytes to be kept, got %q", s.head.String())
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "123456789"+strings.Repeat("x", 100) {
+		t.Errorf("expected the full output in the file, got %q", content)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// appState is bookkeeping the app persists between runs
+type appState struct {
+	// RoundRobin holds the index of the next host to use per group
+	RoundRobin map[string]int `json:"round_robin,omitempty"`
+	// Timezones caches the IANA time zone probed from each host
+	Timezones map[string]string `json:"timezones,omitempty"`
+	// HostKeys caches the known_hosts fingerprints of each host to spot keys changed between runs
+	HostKeys map[string][]string `json:"host_keys,omitempty"`
+	// GroupedView arranges the host list in sections, with CollapsedGroups folded
+	GroupedView     bool     `json:"grouped_view,omitempty"`
+	CollapsedGroups []string `json:"collapsed_groups,omitempty"`
+	// SortMode is the order of the host list last picked with o
+	SortMode string `json:"sort_mode,omitempty"`
+}
+
+// statePath returns the location of the state file in the app config directory
+func statePath() (string, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state.json"), nil
+}
+
+// readAppState reads the state file at path. A missing file yields an empty state.
+func readAppState(path string) (appState, error) {
+	var st appState
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	err = json.Unmarshal(content, &st)
+	return st, err
+}
+
+// writeAppState writes the state file to path
+func writeAppState(path string, st appState) error {
+	content, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0600)
+}
+
+// loadAppState reads the state file from the app config directory
+func loadAppState() (appState, error) {
+	path, err := statePath()
+	if err != nil {
+		return appState{}, err
+	}
+	return readAppState(path)
+}
+
+// updateAppState applies fn to the current state file and writes it back
+func updateAppState(fn func(*appState)) error {
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	st, err := readAppState(path)
+	if err != nil {
+		return err
+	}
+	fn(&st)
+	return writeAppState(path, st)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// hostStats summarizes the history of one host
+type hostStats struct {
+	host     string
+	sessions int
+	failures int
+	total    time.Duration
+}
+
+// failureRate is the share of attempts that failed, between 0 and 1
+func (s hostStats) failureRate() float64 {
+	attempts := s.sessions + s.failures
+	if attempts == 0 {
+		return 0
+	}
+	return float64(s.failures) / float64(attempts)
+}
+
+// averageSession is the mean length of the host's sessions
+func (s hostStats) averageSession() time.Duration {
+	if s.sessions == 0 {
+		return 0
+	}
+	return s.total / time.Duration(s.sessions)
+}
+
+// summarizeHistory groups history entries per host, most used hosts first
+func summarizeHistory(entries []historyEntry) []hostStats {
+	byHost := map[string]*hostStats{}
+	for _, e := range entries {
+		s, ok := byHost[e.Host]
+		if !ok {
+			s = &hostStats{host: e.Host}
+			byHost[e.Host] = s
+		}
+		if e.Failed {
+			s.failures++
+		} else {
+			s.sessions++
+			s.total += e.Duration
+		}
+	}
+	stats := make([]hostStats, 0, len(byHost))
+	for _, s := range byHost {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].sessions != stats[j].sessions {
+			return stats[i].sessions > stats[j].sessions
+		}
+		return stats[i].host < stats[j].host
+	})
+	return stats
+}
+
+// writeStats prints the usage report
+func writeStats(w io.Writer, entries []historyEntry) {
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "No connections recorded yet.")
+		return
+	}
+	var overall hostStats
+	for _, s := range summarizeHistory(entries) {
+		overall.sessions += s.sessions
+		overall.failures += s.failures
+		overall.total += s.total
+	}
+	fmt.Fprintf(w, "Since %s: %d sessions, %d failed logins (%.0f%%), average session %s\n\n",
+		entries[0].Time.Format("2006-01-02"), overall.sessions, overall.failures,
+		overall.failureRate()*100, overall.averageSession().Round(time.Second))
+
+	fmt.Fprintf(w, "%-30s %8s %8s %10s\n", "HOST", "SESSIONS", "FAILED", "AVG")
+	for _, s := range summarizeHistory(entries) {
+		fmt.Fprintf(w, "%-30s %8d %7.0f%% %10s\n", s.host, s.sessions, s.failureRate()*100, s.averageSession().Round(time.Second))
+	}
+}
+
+// runStats implements the "stats" command. It only reads the local history file.
+func runStats(args []string) int {
+	path, err := historyPath()
+	if err != nil {
+		fmt.Println("Could not locate history:", err)
+		return 1
+	}
+	entries, err := readHistory(path)
+	if err != nil {
+		fmt.Println("Could not read history:", err)
+		return 1
+	}
+	writeStats(os.Stdout, entries)
+	return 0
+}
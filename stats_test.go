@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryAndStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	start := time.Date(2025, 8, 1, 9, 0, 0, 0, time.UTC)
+	for _, e := range []historyEntry{
+		{Host: "db1", Time: start, Duration: 10 * time.Minute},
+		{Host: "db1", Time: start, Failed: true},
+		{Host: "web1", Time: start, Duration: time.Minute},
+		{Host: "db1", Time: start, Duration: 20 * time.Minute},
+		{Host: "web1", Time: start, Duration: 3 * time.Minute},
+		{Host: "web2", Time: start, Failed: true},
+	} {
+		if err := appendHistory(path, e); err != nil {
+			t.Fatalf("appendHistory failed: %v", err)
+		}
+	}
+	entries, err := readHistory(path)
+	if err != nil || len(entries) != 6 {
+		t.Fatalf("expected 6 entries, got %d (%v)", len(entries), err)
+	}
+
+	stats := summarizeHistory(entries)
+	if len(stats) != 3 || stats[0].host != "db1" || stats[1].host != "web1" || stats[2].host != "web2" {
+		t.Fatalf("unexpected order %+v", stats)
+	}
+	if stats[0].averageSession() != 15*time.Minute {
+		t.Errorf("expected db1 to average 15m, got %s", stats[0].averageSession())
+	}
+	if rate := stats[0].failureRate(); rate < 0.33 || rate > 0.34 {
+		t.Errorf("expected db1 to fail a third of the time, got %f", rate)
+	}
+	if stats[2].failureRate() != 1 || stats[2].averageSession() != 0 {
+		t.Errorf("unexpected web2 stats %+v", stats[2])
+	}
+
+	var b strings.Builder
+	writeStats(&b, entries)
+	if !strings.Contains(b.String(), "4 sessions, 2 failed logins (33%), average session 8m30s") {
+		t.Errorf("unexpected report:\n%s", b.String())
+	}
+}
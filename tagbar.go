@@ -0,0 +1,137 @@
+package main
+
+import (
+	"hash/fnv"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The tag bar (ctrl+t) narrows the list to the hosts carrying all of the tags
+// picked in it. refreshList leaves out the other hosts.
+
+// tagPalette colors the chips of tags that tag_colors in config.json leaves out
+var tagPalette = []string{"#D70000", "#D7AF00", "#0087D7", "#00AF5F", "#AF5FD7", "#D75F00", "#00AFAF", "#5F5FD7"}
+
+// tagColor picks the chip color of a tag, the same one on every run
+func tagColor(tag string, colors map[string]string) lipgloss.Color {
+	if c, ok := colors[tag]; ok {
+		return lipgloss.Color(c)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(tag))
+	return lipgloss.Color(tagPalette[h.Sum32()%uint32(len(tagPalette))])
+}
+
+// tagChip renders a tag as a colored chip
+func tagChip(tag string, colors map[string]string) string {
+	return lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFFFFF")).
+		Background(tagColor(tag, colors)).
+		Padding(0, 1).
+		Render(tag)
+}
+
+// tagChips renders tags as colored chips separated by spaces
+func tagChips(tags []string, colors map[string]string) string {
+	chips := make([]string, len(tags))
+	for i, t := range tags {
+		chips[i] = tagChip(t, colors)
+	}
+	return strings.Join(chips, " ")
+}
+
+// allTags lists the tags of the hosts in md, sorted and without repeats
+func allTags(md hostMetadata) []string {
+	var tags []string
+	for _, meta := range md {
+		for _, t := range meta.Tags {
+			if !contains(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// hasAllTags reports whether host carries every tag of tags
+func (md hostMetadata) hasAllTags(host string, tags []string) bool {
+	for _, t := range tags {
+		if !md.hasTag(host, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// tagBarTags lists the tags offered in the tag bar: those of the hosts, and
+// those picked in the filter that no host carries anymore
+func (m *model) tagBarTags() []string {
+	tags := allTags(m.metadata)
+	for _, t := range m.tagFilter {
+		if !contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// updateTagBar handles the keys of the tag bar: arrows move between the tags,
+// space picks or drops one, backspace drops all and enter or esc leave the bar
+func (m *model) updateTagBar(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	tags := m.tagBarTags()
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "left", "h":
+		m.tagBarCursor = max(0, m.tagBarCursor-1)
+	case "right", "l":
+		m.tagBarCursor = max(0, min(len(tags)-1, m.tagBarCursor+1))
+	case " ":
+		if m.tagBarCursor < len(tags) {
+			tag := tags[m.tagBarCursor]
+			if contains(m.tagFilter, tag) {
+				m.tagFilter = slices.DeleteFunc(m.tagFilter, func(t string) bool { return t == tag })
+			} else {
+				m.tagFilter = append(m.tagFilter, tag)
+			}
+			return m, tea.Batch(m.refreshList(), m.refreshInfoBox())
+		}
+	case "backspace":
+		m.tagFilter = nil
+		return m, tea.Batch(m.refreshList(), m.refreshInfoBox())
+	case "enter", "esc", "ctrl+t":
+		m.tagBar = false
+	}
+	return m, nil
+}
+
+// tagBarView shows the tags with those of the filter as chips, and the cursor
+// while the bar has the keys
+func (m *model) tagBarView() string {
+	plain := lipgloss.NewStyle().Faint(true).Padding(0, 1)
+	var chips []string
+	for i, t := range m.tagBarTags() {
+		chip := plain.Render(t)
+		if contains(m.tagFilter, t) {
+			chip = tagChip(t, m.config.TagColors)
+		}
+		if m.tagBar && i == m.tagBarCursor {
+			chip = "[" + chip + "]"
+		} else {
+			chip = " " + chip + " "
+		}
+		chips = append(chips, chip)
+	}
+	view := "Tags:" + strings.Join(chips, "")
+	if m.tagBar {
+		view += "  ←/→ move, space picks, backspace clears, enter closes"
+	} else {
+		view += "  (ctrl+t to change)"
+	}
+	return view
+}
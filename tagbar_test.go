@@ -0,0 +1,83 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestTagColor(t *testing.T) {
+	if tagColor("prod", nil) != tagColor("prod", nil) {
+		t.Error("a tag should always get the same color")
+	}
+	if got := tagColor("prod", map[string]string{"prod": "#FF0000"}); got != lipgloss.Color("#FF0000") {
+		t.Errorf("tag_colors should take precedence, got %q", got)
+	}
+}
+
+func TestAllTags(t *testing.T) {
+	md := hostMetadata{
+		"web1": {Tags: []string{"web", "prod"}},
+		"web2": {Tags: []string{"web", "staging"}},
+		"db":   {Environment: "prod"},
+	}
+	if got := allTags(md); !slices.Equal(got, []string{"prod", "staging", "web"}) {
+		t.Errorf("allTags() = %q", got)
+	}
+	if !md.hasAllTags("web1", []string{"prod", "web"}) || md.hasAllTags("web2", []string{"prod", "web"}) {
+		t.Error("hasAllTags should require every tag")
+	}
+	if !md.hasAllTags("db", nil) {
+		t.Error("every host has all of no tags")
+	}
+}
+
+func TestRefreshHostTags(t *testing.T) {
+	m := selectionModel("web1", "db", "web2")
+	m.metadata = hostMetadata{
+		"web1": {Tags: []string{"web", "prod"}},
+		"web2": {Tags: []string{"web"}},
+	}
+	m.setMarked([]string{"db"}, true)
+
+	m.tagFilter = []string{"web"}
+	m.refreshList()
+	if got := listedHosts(m); !slices.Equal(got, []string{"web1", "web2"}) {
+		t.Errorf("listed %q, want the hosts tagged web", got)
+	}
+	if m.list.Items()[0].(hostItem).chips == "" {
+		t.Error("expected the tags of web1 as chips")
+	}
+
+	m.tagFilter = []string{"web", "prod"}
+	m.refreshList()
+	if got := listedHosts(m); !slices.Equal(got, []string{"web1"}) {
+		t.Errorf("listed %q, want the hosts tagged web and prod", got)
+	}
+
+	// Hidden hosts still count, and come back in their place
+	if got := m.markedHosts(); !slices.Equal(got, []string{"db"}) {
+		t.Errorf("markedHosts() = %q, want [db]", got)
+	}
+	m.setMarked([]string{"db", "web1"}, false)
+	m.tagFilter = nil
+	m.refreshList()
+	if got := listedHosts(m); !slices.Equal(got, []string{"web1", "db", "web2"}) {
+		t.Errorf("listed %q, want all hosts", got)
+	}
+	if got := m.markedHosts(); got != nil {
+		t.Errorf("markedHosts() = %q, want none", got)
+	}
+}
+
+// listedHosts returns the hosts shown in the list, without section headings
+func listedHosts(m *model) []string {
+	var names []string
+	for _, it := range m.list.Items() {
+		if h, ok := it.(hostItem); ok {
+			names = append(names, h.host)
+		}
+	}
+	return names
+}
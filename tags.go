@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// tagEdit adds and removes tags on many hosts at once
+type tagEdit struct {
+	add    []string
+	remove []string
+}
+
+// parseTagEdit parses the tags to change, separated by spaces or commas: "web",
+// or "+web", adds the tag, "-old" removes it
+func parseTagEdit(input string) (tagEdit, error) {
+	var edit tagEdit
+	for _, word := range strings.FieldsFunc(input, func(r rune) bool { return r == ' ' || r == ',' }) {
+		remove := strings.HasPrefix(word, "-")
+		tag := strings.TrimLeft(word, "+-")
+		if tag == "" {
+			return tagEdit{}, fmt.Errorf("%q is not a tag", word)
+		}
+		if remove {
+			edit.remove = append(edit.remove, tag)
+		} else {
+			edit.add = append(edit.add, tag)
+		}
+	}
+	if len(edit.add) == 0 && len(edit.remove) == 0 {
+		return tagEdit{}, fmt.Errorf("enter tags to add, or to remove with a leading -")
+	}
+	return edit, nil
+}
+
+// String describes the edit, e.g. "+web -old"
+func (e tagEdit) String() string {
+	var words []string
+	for _, t := range e.add {
+		words = append(words, "+"+t)
+	}
+	for _, t := range e.remove {
+		words = append(words, "-"+t)
+	}
+	return strings.Join(words, " ")
+}
+
+// apply adds and removes the tags of hosts in md. Tags already present are not
+// added twice.
+func (e tagEdit) apply(md hostMetadata, hosts []string) {
+	for _, h := range hosts {
+		meta := md[h]
+		for _, t := range e.add {
+			if !contains(meta.Tags, t) {
+				meta.Tags = append(meta.Tags, t)
+			}
+		}
+		meta.Tags = slices.DeleteFunc(meta.Tags, func(t string) bool { return contains(e.remove, t) })
+		if len(meta.Tags) == 0 {
+			meta.Tags = nil
+		}
+		md[h] = meta
+	}
+}
+
+// saveTagEdit applies the edit to hosts in hosts.json
+func saveTagEdit(e tagEdit, hosts []string) error {
+	return updateHostMetadata(func(md hostMetadata) { e.apply(md, hosts) })
+}
+
+// tagCounts lists the tags of hosts with how many of them carry each, e.g.
+// "web (3/5), prod (5/5)", in order of first appearance
+func tagCounts(md hostMetadata, hosts []string) string {
+	var tags []string
+	count := map[string]int{}
+	for _, h := range hosts {
+		for _, t := range md[h].Tags {
+			if count[t] == 0 {
+				tags = append(tags, t)
+			}
+			count[t]++
+		}
+	}
+	if len(tags) == 0 {
+		return "no tags yet"
+	}
+	for i, t := range tags {
+		tags[i] = fmt.Sprintf("%s (%d/%d)", t, count[t], len(hosts))
+	}
+	return strings.Join(tags, ", ")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseTagEdit(t *testing.T) {
+	edit, err := parseTagEdit("web, +prod -old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(edit.add, []string{"web", "prod"}) || !slices.Equal(edit.remove, []string{"old"}) {
+		t.Errorf("unexpected edit %+v", edit)
+	}
+	if got := edit.String(); got != "+web +prod -old" {
+		t.Errorf("unexpected description %q", got)
+	}
+	for _, input := range []string{"", " , ", "web -"} {
+		if _, err := parseTagEdit(input); err == nil {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
+func TestTagEditApply(t *testing.T) {
+	md := hostMetadata{
+		"web1": {Tags: []string{"old", "web"}},
+		"web2": {Tags: []string{"old"}, Environment: "prod"},
+		"db":   {Tags: []string{"old"}},
+	}
+	tagEdit{add: []string{"web", "prod"}, remove: []string{"old"}}.apply(md, []string{"web1", "web2", "new"})
+	if got := md["web1"].Tags; !slices.Equal(got, []string{"web", "prod"}) {
+		t.Errorf("web1: unexpected tags %q", got)
+	}
+	if got := md["web2"]; !slices.Equal(got.Tags, []string{"web", "prod"}) || got.Environment != "prod" {
+		t.Errorf("web2: unexpected metadata %+v", got)
+	}
+	if got := md["new"].Tags; !slices.Equal(got, []string{"web", "prod"}) {
+		t.Errorf("new: unexpected tags %q", got)
+	}
+	if got := md["db"].Tags; !slices.Equal(got, []string{"old"}) {
+		t.Errorf("db should be left alone, got %q", got)
+	}
+	tagEdit{remove: []string{"old"}}.apply(md, []string{"db"})
+	if md["db"].Tags != nil {
+		t.Errorf("db: expected no tags, got %q", md["db"].Tags)
+	}
+}
+
+func TestTagCounts(t *testing.T) {
+	md := hostMetadata{
+		"web1": {Tags: []string{"web", "prod"}},
+		"web2": {Tags: []string{"web"}},
+	}
+	if got, want := tagCounts(md, []string{"web1", "web2"}), "web (2/2), prod (1/2)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := tagCounts(md, []string{"db"}); got != "no tags yet" {
+		t.Errorf("unexpected counts %q", got)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// detectTerminal returns the terminal emulator the tool runs in, when it is
+// one whose tabs can be opened from here: "kitty", "wezterm" or "iterm2"
+func detectTerminal(getenv func(string) string) string {
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "":
+		return "kitty"
+	case getenv("WEZTERM_PANE") != "" || getenv("TERM_PROGRAM") == "WezTerm":
+		return "wezterm"
+	case getenv("TERM_PROGRAM") == "iTerm.app":
+		return "iterm2"
+	}
+	return ""
+}
+
+// terminalMode returns how sessions open in terminal: "tab" (the default),
+// "window", or "off" as set in the terminal_tabs of config.json
+func (c appConfig) terminalMode(terminal string) string {
+	switch mode := c.TerminalTabs[terminal]; mode {
+	case "window", "off":
+		return mode
+	}
+	return "tab"
+}
+
+// terminalOpenCommand returns the command opening a tab or window (mode) of
+// terminal that connects to host with this tool (self), starting in dir
+func terminalOpenCommand(terminal, mode, self, host, dir string) (string, []string) {
+	switch terminal {
+	case "kitty":
+		// Needs allow_remote_control in kitty.conf
+		kind := "--type=tab"
+		if mode == "window" {
+			kind = "--type=os-window"
+		}
+		return "kitty", []string{"@", "launch", kind, "--tab-title", host, "--cwd", dir, self, "connect", host}
+	case "wezterm":
+		args := []string{"cli", "spawn", "--cwd", dir}
+		if mode == "window" {
+			args = append(args, "--new-window")
+		}
+		return "wezterm", append(args, "--", self, "connect", host)
+	}
+	// iTerm2 runs the command without a shell, so the directory is changed by one
+	command := "/bin/sh -c " + shellQuote("cd "+shellQuote(dir)+" && exec "+shellQuote(self)+" connect "+shellQuote(host))
+	create := "tell current window to create tab with default profile command " + appleScriptString(command)
+	if mode == "window" {
+		create = "create window with default profile command " + appleScriptString(command)
+	}
+	return "osascript", []string{"-e", `tell application "iTerm2" to ` + create}
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// openInTerminal connects to host in a new tab or window of terminal
+func openInTerminal(terminal, mode, host string) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		dir, _ = os.UserHomeDir()
+	}
+	tool, args := terminalOpenCommand(terminal, mode, self, host, dir)
+	out, err := exec.Command(tool, args...).CombinedOutput()
+	if detail := strings.TrimSpace(string(out)); err != nil && detail != "" {
+		return fmt.Errorf("%s: %w", detail, err)
+	}
+	if err != nil && errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%s is not on the PATH", tool)
+	}
+	return err
+}
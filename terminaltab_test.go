@@ -0,0 +1,43 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDetectTerminal(t *testing.T) {
+	tests := map[string]map[string]string{
+		"kitty":   {"KITTY_WINDOW_ID": "1", "TERM_PROGRAM": "iTerm.app"},
+		"wezterm": {"TERM_PROGRAM": "WezTerm"},
+		"iterm2":  {"TERM_PROGRAM": "iTerm.app"},
+		"":        {"TERM_PROGRAM": "Apple_Terminal"},
+	}
+	for want, env := range tests {
+		if got := detectTerminal(func(k string) string { return env[k] }); got != want {
+			t.Errorf("%v: expected %q, got %q", env, want, got)
+		}
+	}
+}
+
+func TestTerminalOpenCommand(t *testing.T) {
+	cfg := appConfig{TerminalTabs: map[string]string{"kitty": "window", "wezterm": "bogus", "iterm2": "off"}}
+	if cfg.terminalMode("kitty") != "window" || cfg.terminalMode("wezterm") != "tab" || cfg.terminalMode("iterm2") != "off" {
+		t.Errorf("unexpected modes %q %q %q", cfg.terminalMode("kitty"), cfg.terminalMode("wezterm"), cfg.terminalMode("iterm2"))
+	}
+
+	tool, args := terminalOpenCommand("kitty", "window", "/bin/jumphost", "web1", "/work")
+	want := []string{"@", "launch", "--type=os-window", "--tab-title", "web1", "--cwd", "/work", "/bin/jumphost", "connect", "web1"}
+	if tool != "kitty" || !slices.Equal(args, want) {
+		t.Errorf("expected kitty %q, got %s %q", want, tool, args)
+	}
+	tool, args = terminalOpenCommand("wezterm", "tab", "/bin/jumphost", "web1", "/work")
+	want = []string{"cli", "spawn", "--cwd", "/work", "--", "/bin/jumphost", "connect", "web1"}
+	if tool != "wezterm" || !slices.Equal(args, want) {
+		t.Errorf("expected wezterm %q, got %s %q", want, tool, args)
+	}
+	tool, args = terminalOpenCommand("iterm2", "tab", "/bin/jumphost", "web1", "/work")
+	want = []string{"-e", `tell application "iTerm2" to tell current window to create tab with default profile command "/bin/sh -c 'cd '\\''/work'\\'' && exec '\\''/bin/jumphost'\\'' connect '\\''web1'\\'''"`}
+	if tool != "osascript" || !slices.Equal(args, want) {
+		t.Errorf("expected osascript %q, got %s %q", want, tool, args)
+	}
+}
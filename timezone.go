@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// timezoneProbeCommand prints the remote time zone on Debian-style, systemd and other systems
+const timezoneProbeCommand = "cat /etc/timezone 2>/dev/null || timedatectl show -p Timezone --value 2>/dev/null || readlink /etc/localtime"
+
+type timezoneMsg struct {
+	host string
+	zone string
+	err  error
+}
+
+// parseTimezone extracts an IANA zone name from timezoneProbeCommand output
+func parseTimezone(out string) string {
+	zone := strings.TrimSpace(out)
+	// readlink prints e.g. /usr/share/zoneinfo/Europe/Amsterdam or /var/db/timezone/zoneinfo/UTC
+	if _, after, ok := strings.Cut(zone, "zoneinfo/"); ok {
+		zone = after
+	}
+	return zone
+}
+
+// probeTimezone asks a host for its time zone in the background
+func probeTimezone(host string) tea.Cmd {
+	return func() tea.Msg {
+		out, err := runRemote(host, timezoneProbeCommand)
+		if err != nil {
+			return timezoneMsg{host: host, err: err}
+		}
+		zone := parseTimezone(out)
+		if zone == "" {
+			return timezoneMsg{host: host, err: fmt.Errorf("no time zone reported")}
+		}
+		return timezoneMsg{host: host, zone: zone}
+	}
+}
+
+// formatHostTime renders the current time in a host's zone for the info box
+func formatHostTime(zone string, now time.Time) string {
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return "Time zone: " + zone
+	}
+	return fmt.Sprintf("Local time: %s (%s)", now.In(loc).Format("Mon 15:04 MST"), zone)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimezone(t *testing.T) {
+	tests := []struct {
+		out      string
+		expected string
+	}{
+		{"Europe/Amsterdam\n", "Europe/Amsterdam"},
+		{"/usr/share/zoneinfo/America/New_York\n", "America/New_York"},
+		{"/var/db/timezone/zoneinfo/UTC\n", "UTC"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := parseTimezone(tt.out); got != tt.expected {
+			t.Errorf("parseTimezone(%q) = %q, expected %q", tt.out, got, tt.expected)
+		}
+	}
+}
+
+func TestFormatHostTime(t *testing.T) {
+	now := time.Date(2025, 7, 21, 12, 0, 0, 0, time.UTC)
+	if got := formatHostTime("UTC", now); got != "Local time: Mon 12:00 UTC (UTC)" {
+		t.Errorf("unexpected formatted time %q", got)
+	}
+	if got := formatHostTime("Not/AZone", now); got != "Time zone: Not/AZone" {
+		t.Errorf("unexpected fallback %q", got)
+	}
+}
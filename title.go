@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Escape sequences for the terminal window title. The title is pushed onto the
+// terminal's title stack first so it can be popped back when the session ends.
+const (
+	titlePush = "\x1b[22;0t"
+	titlePop  = "\x1b[23;0t"
+)
+
+// writeTitle sets the terminal window/tab title
+func writeTitle(w io.Writer, title string) {
+	fmt.Fprintf(w, "%s\x1b]0;%s\x07", titlePush, title)
+}
+
+// setSessionTitle shows host in the terminal title, and as the tmux pane title when
+// running inside tmux. The returned function restores the previous titles.
+func setSessionTitle(host string) (restore func()) {
+	writeTitle(os.Stdout, host)
+
+	restoreTmux := func() {}
+	if os.Getenv("TMUX") != "" {
+		if out, err := exec.Command("tmux", "display-message", "-p", "#{pane_title}").Output(); err == nil {
+			previous := strings.TrimSpace(string(out))
+			if exec.Command("tmux", "select-pane", "-T", host).Run() == nil {
+				restoreTmux = func() { exec.Command("tmux", "select-pane", "-T", previous).Run() }
+			}
+		}
+	}
+
+	return func() {
+		restoreTmux()
+		fmt.Fprint(os.Stdout, titlePop)
+	}
+}
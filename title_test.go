@@ -0,0 +1,14 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteTitle(t *testing.T) {
+	var b strings.Builder
+	writeTitle(&b, "db1")
+	if got := b.String(); got != "\x1b[22;0t\x1b]0;db1\x07" {
+		t.Errorf("unexpected title sequence %q", got)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// insideTmux reports whether the tool runs in a tmux pane
+func insideTmux() bool {
+	return os.Getenv("TMUX") != ""
+}
+
+// tmuxOpenArgs returns the tmux arguments connecting to host with this tool
+// (self) in a new window named after the host, or in a split of the current
+// pane when split is set. The new pane starts in dir.
+func tmuxOpenArgs(self, host, dir string, split bool) []string {
+	args := []string{"new-window", "-n", host}
+	if split {
+		args = []string{"split-window", "-h"}
+	}
+	return append(args, "-c", dir, "--", self, "connect", host)
+}
+
+// openInTmux connects to host in a new tmux window or pane, leaving the host list where it is
+func openInTmux(host string, split bool) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = "~"
+	}
+	out, err := exec.Command("tmux", tmuxOpenArgs(self, host, dir, split)...).CombinedOutput()
+	if detail := strings.TrimSpace(string(out)); err != nil && detail != "" {
+		return errors.New(detail)
+	}
+	return err
+}
+
+// tmuxLayout arranges the panes of a tmux window with a host per pane
+type tmuxLayout struct {
+	// Layout is a tmux layout: tiled (the default), even-horizontal, even-vertical,
+	// main-horizontal, main-vertical, or a layout string from list-windows
+	Layout string `json:"layout,omitempty"`
+	// Sync synchronizes the input of the panes, so keystrokes go to every host at once
+	Sync bool `json:"sync,omitempty"`
+}
+
+// tmuxClusterArgs returns the tmux commands that fill the window target, whose
+// first pane already connects to hosts[0], with a pane per other host, and
+// arrange them as in layout. The panes are tiled after every split, or tmux
+// runs out of room for them.
+func tmuxClusterArgs(self, dir, target string, hosts []string, layout tmuxLayout) [][]string {
+	var cmds [][]string
+	for _, h := range hosts[1:] {
+		cmds = append(cmds,
+			[]string{"split-window", "-t", target, "-c", dir, "--", self, "connect", h},
+			[]string{"select-layout", "-t", target, "tiled"},
+		)
+	}
+	if layout.Layout != "" && layout.Layout != "tiled" {
+		cmds = append(cmds, []string{"select-layout", "-t", target, layout.Layout})
+	}
+	if layout.Sync {
+		cmds = append(cmds, []string{"set-window-option", "-t", target, "synchronize-panes", "on"})
+	}
+	return cmds
+}
+
+// openTmuxCluster connects to hosts in the panes of a new tmux window called
+// name, arranged as in layout; with synchronized input it works like cssh
+func openTmuxCluster(name string, hosts []string, layout tmuxLayout) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = "~"
+	}
+	run := func(args ...string) (string, error) {
+		out, err := exec.Command("tmux", args...).CombinedOutput()
+		detail := strings.TrimSpace(string(out))
+		if err != nil && detail != "" {
+			return "", errors.New(detail)
+		}
+		return detail, err
+	}
+	window, err := run("new-window", "-P", "-F", "#{window_id}", "-n", name, "-c", dir, "--", self, "connect", hosts[0])
+	if err != nil {
+		return err
+	}
+	for _, args := range tmuxClusterArgs(self, dir, window, hosts, layout) {
+		if _, err := run(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
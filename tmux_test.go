@@ -0,0 +1,43 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTmuxOpenArgs(t *testing.T) {
+	want := []string{"new-window", "-n", "web1", "-c", "/work", "--", "/bin/jumphost", "connect", "web1"}
+	if got := tmuxOpenArgs("/bin/jumphost", "web1", "/work", false); !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	want = []string{"split-window", "-h", "-c", "/work", "--", "/bin/jumphost", "connect", "web1"}
+	if got := tmuxOpenArgs("/bin/jumphost", "web1", "/work", true); !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestTmuxClusterArgs(t *testing.T) {
+	got := tmuxClusterArgs("/bin/jumphost", "/work", "@4", []string{"web1", "web2", "web3"}, tmuxLayout{Sync: true})
+	want := [][]string{
+		{"split-window", "-t", "@4", "-c", "/work", "--", "/bin/jumphost", "connect", "web2"},
+		{"select-layout", "-t", "@4", "tiled"},
+		{"split-window", "-t", "@4", "-c", "/work", "--", "/bin/jumphost", "connect", "web3"},
+		{"select-layout", "-t", "@4", "tiled"},
+		{"set-window-option", "-t", "@4", "synchronize-panes", "on"},
+	}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestTmuxClusterArgsLayout(t *testing.T) {
+	got := tmuxClusterArgs("/bin/jumphost", "/work", "@4", []string{"web1", "web2"}, tmuxLayout{Layout: "main-vertical"})
+	want := [][]string{
+		{"split-window", "-t", "@4", "-c", "/work", "--", "/bin/jumphost", "connect", "web2"},
+		{"select-layout", "-t", "@4", "tiled"},
+		{"select-layout", "-t", "@4", "main-vertical"},
+	}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
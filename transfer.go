@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// transferSpec describes a copy between this machine and the selected host
+type transferSpec struct {
+	upload bool
+	rsync  bool // use rsync rather than scp
+	// legacyRsync is set for rsync before 3.1, which has no --info=progress2
+	// and reports progress per file instead
+	legacyRsync bool
+	local       string
+	remote      string
+}
+
+// transferProgressMsg is a progress line of rsync
+type transferProgressMsg struct {
+	bytes   int64
+	percent int
+	rate    string
+	eta     string
+}
+
+// transferDoneMsg reports the end of a transfer
+type transferDoneMsg struct {
+	err error
+}
+
+// hasRsync reports whether rsync is installed locally, which makes it the default tool
+func hasRsync() bool {
+	_, err := exec.LookPath("rsync")
+	return err == nil
+}
+
+// rsyncProgress2 reports whether the local rsync has --info=progress2, which came
+// with 3.1. openrsync, the rsync of newer macOS, does not have it either.
+var rsyncProgress2 = sync.OnceValue(func() bool {
+	out, err := exec.Command("rsync", "--version").Output()
+	return err == nil && rsyncVersionAtLeast(string(out), 3, 1)
+})
+
+// rsyncVersionAtLeast reports whether the output of rsync --version, whose
+// first line reads like "rsync  version 3.2.7  protocol version 31", names
+// version major.minor or later
+func rsyncVersionAtLeast(out string, major, minor int) bool {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "rsync" || fields[1] != "version" {
+			continue
+		}
+		parts := strings.Split(fields[2], ".")
+		if len(parts) < 2 {
+			return false
+		}
+		gotMajor, err1 := strconv.Atoi(parts[0])
+		gotMinor, err2 := strconv.Atoi(parts[1])
+		return err1 == nil && err2 == nil && (gotMajor > major || gotMajor == major && gotMinor >= minor)
+	}
+	return false
+}
+
+// rsyncQuote quotes an argument of rsync's -e option. rsync splits the option
+// itself: it honours single and double quotes but not backslashes, and a quote
+// is doubled to appear inside a quoted argument.
+func rsyncQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t'\"") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// localOperand makes a local path safe as an operand of scp or rsync: a
+// relative path with a ":" gets "./" so it is not taken for host:path
+func localOperand(local string) string {
+	if !filepath.IsAbs(local) && strings.Contains(local, ":") {
+		return "./" + local
+	}
+	return local
+}
+
+// transferArgs returns the command line of a transfer to or from host (as
+// user when set, on port when not 0), with the ssh options in opts. The
+// operands follow "--", as in copyToHostArgs.
+func transferArgs(spec transferSpec, user, host string, port int, opts []string) (string, []string) {
+	if user != "" {
+		host = user + "@" + host
+	}
+	remote := host + ":" + spec.remote
+	src, dst := remote, localOperand(spec.local)
+	if spec.upload {
+		src, dst = localOperand(spec.local), remote
+	}
+	if spec.rsync {
+		ssh := []string{"ssh"}
+		for _, o := range opts {
+			ssh = append(ssh, rsyncQuote(o))
+		}
+		if port != 0 {
+			ssh = append(ssh, "-p", strconv.Itoa(port))
+		}
+		progress := "--info=progress2"
+		if spec.legacyRsync {
+			progress = "--progress"
+		}
+		return "rsync", []string{"-a", progress, "-e", strings.Join(ssh, " "), "--", src, dst}
+	}
+	args := append([]string{"-r"}, opts...)
+	if port != 0 {
+		args = append(args, "-P", strconv.Itoa(port))
+	}
+	return "scp", append(args, "--", src, dst)
+}
+
+// copyToHost uploads local to the path remote on host with scp over key-based
+// SSH, writing what scp reports to w; a broadcastRunner for the marked hosts
+func copyToHost(local, remote string, connectTimeout, timeout time.Duration) broadcastRunner {
+	return func(host string, w io.Writer) error {
+		defer remoteLimiter.acquire(host)()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "scp", copyToHostArgs(local, host, remote, connectTimeout)...)
+		cmd.Stdout, cmd.Stderr = w, w
+		err := cmd.Run()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = ctx.Err()
+		}
+		return err
+	}
+}
+
+// copyToHostArgs returns the scp arguments of copyToHost. The operands follow
+// "--" so that a path starting with "-" is not taken for an option, and a
+// relative local path with a ":" gets "./" so scp does not take it for a host.
+func copyToHostArgs(local, host, remote string, connectTimeout time.Duration) []string {
+	return []string{"-r", "-o", "BatchMode=yes", "-o", connectTimeoutOption(connectTimeout), "--", localOperand(local), host + ":" + remote}
+}
+
+// parseRsyncProgress parses a line of rsync --info=progress2, such as
+// "  1,234,567  45%  1.23MB/s    0:00:12 (xfr#1, to-chk=0/1)"
+func parseRsyncProgress(line string) (transferProgressMsg, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || !strings.HasSuffix(fields[1], "%") {
+		return transferProgressMsg{}, false
+	}
+	n, err := strconv.ParseInt(strings.ReplaceAll(fields[0], ",", ""), 10, 64)
+	if err != nil {
+		return transferProgressMsg{}, false
+	}
+	percent, err := strconv.Atoi(strings.TrimSuffix(fields[1], "%"))
+	if err != nil {
+		return transferProgressMsg{}, false
+	}
+	return transferProgressMsg{bytes: n, percent: percent, rate: fields[2], eta: fields[3]}, true
+}
+
+// scanLinesOrReturns splits output on line feeds and carriage returns, with
+// which rsync overwrites its progress line
+func scanLinesOrReturns(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// runTransfer runs the transfer in the background, sending its progress and
+// then a transferDoneMsg on events. Without password, ssh must not prompt.
+func runTransfer(ctx context.Context, tool string, args []string, password []byte, keyFile string, events chan<- tea.Msg) {
+	defer clear(password)
+	var cmd *exec.Cmd
+	var secret *os.File
+	var err error
+	if password == nil {
+		cmd = exec.CommandContext(ctx, tool, args...)
+	} else if cmd, secret, err = sshpassProgram(ctx, password, keyFile, tool, args...); err != nil {
+		events <- transferDoneMsg{err: err}
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		events <- transferDoneMsg{err: err}
+		return
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err = cmd.Start()
+	if secret != nil {
+		secret.Close()
+	}
+	if err != nil {
+		events <- transferDoneMsg{err: err}
+		return
+	}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Split(scanLinesOrReturns)
+	for scanner.Scan() {
+		if p, ok := parseRsyncProgress(scanner.Text()); ok {
+			events <- p
+		}
+	}
+	err = cmd.Wait()
+	if errors.Is(ctx.Err(), context.Canceled) {
+		err = errors.New("cancelled")
+	} else if detail := strings.TrimSpace(stderr.String()); err != nil && detail != "" {
+		err = errors.New(detail)
+	}
+	events <- transferDoneMsg{err: err}
+}
+
+// progressBar renders percent as a bar of width cells
+func progressBar(percent, width int) string {
+	filled := max(0, min(width, percent*width/100))
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}
+
+// transferStatus renders the progress of a running transfer; scp reports none
+// without a terminal, so only the elapsed time is shown for it
+func transferStatus(spec transferSpec, p transferProgressMsg, started time.Time) string {
+	elapsed := time.Since(started).Round(time.Second)
+	if !spec.rsync {
+		return fmt.Sprintf("Copying with scp... %s", elapsed)
+	}
+	return fmt.Sprintf("%s %3d%%  %s  %s  eta %s  (%s)", progressBar(p.percent, 30), p.percent, formatSize(p.bytes), p.rate, p.eta, elapsed)
+}
+
+// waitForTransfer delivers the next event of a running transfer to the TUI
+func waitForTransfer(events <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-events
+	}
+}
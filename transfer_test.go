@@ -0,0 +1,78 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTransferArgs(t *testing.T) {
+	spec := transferSpec{upload: true, rsync: true, local: "build/", remote: "/srv/app"}
+	tool, args := transferArgs(spec, "deploy", "web1", 2222, []string{"-o", "ControlPath=/tmp/lsh-0/%r@%n:%p"})
+	want := []string{"-a", "--info=progress2", "-e", "ssh -o ControlPath=/tmp/lsh-0/%r@%n:%p -p 2222", "--", "build/", "deploy@web1:/srv/app"}
+	if tool != "rsync" || !slices.Equal(args, want) {
+		t.Errorf("unexpected rsync command %s %q", tool, args)
+	}
+
+	spec.legacyRsync = true
+	_, args = transferArgs(spec, "", "web1", 0, []string{"-i", "/home/me/my keys/it's"})
+	want = []string{"-a", "--progress", "-e", "ssh -i '/home/me/my keys/it''s'", "--", "build/", "web1:/srv/app"}
+	if !slices.Equal(args, want) {
+		t.Errorf("unexpected rsync command %q", args)
+	}
+
+	spec = transferSpec{local: ".", remote: "/var/log/syslog"}
+	tool, args = transferArgs(spec, "", "web1", 0, []string{"-J", "bastion"})
+	if want := []string{"-r", "-J", "bastion", "--", "web1:/var/log/syslog", "."}; tool != "scp" || !slices.Equal(args, want) {
+		t.Errorf("unexpected scp command %s %q", tool, args)
+	}
+
+	// Local paths that look like an option or a host:path stay local paths
+	spec = transferSpec{upload: true, local: "-rf", remote: "/tmp/"}
+	if _, args = transferArgs(spec, "", "web1", 0, nil); !slices.Equal(args, []string{"-r", "--", "-rf", "web1:/tmp/"}) {
+		t.Errorf("unexpected scp command for a dash path %q", args)
+	}
+	spec = transferSpec{local: "logs:old", remote: "/var/log/", rsync: true}
+	_, args = transferArgs(spec, "", "web1", 0, nil)
+	if want := []string{"-a", "--info=progress2", "-e", "ssh", "--", "web1:/var/log/", "./logs:old"}; !slices.Equal(args, want) {
+		t.Errorf("unexpected rsync command for a path with a colon %q", args)
+	}
+}
+
+func TestRsyncVersionAtLeast(t *testing.T) {
+	for out, want := range map[string]bool{
+		"rsync  version 3.2.7  protocol version 31\nCopyright (C) 1996-2022": true,
+		"rsync  version 3.1.0  protocol version 31":                          true,
+		"rsync  version 2.6.9  protocol version 29":                          false,
+		"openrsync: protocol version 29\nrsync version 2.6.9 compatible":     false,
+		"": false,
+	} {
+		if got := rsyncVersionAtLeast(out, 3, 1); got != want {
+			t.Errorf("rsyncVersionAtLeast(%q) = %v, want %v", out, got, want)
+		}
+	}
+}
+
+func TestParseRsyncProgress(t *testing.T) {
+	p, ok := parseRsyncProgress("      1,234,567  45%    1.23MB/s    0:00:12 (xfr#1, to-chk=0/1)")
+	if !ok || p.bytes != 1234567 || p.percent != 45 || p.rate != "1.23MB/s" || p.eta != "0:00:12" {
+		t.Errorf("unexpected progress %+v (%v)", p, ok)
+	}
+	if _, ok := parseRsyncProgress("sending incremental file list"); ok {
+		t.Error("expected other output to be ignored")
+	}
+	if got := progressBar(50, 10); got != "[█████░░░░░]" {
+		t.Errorf("unexpected bar %s", got)
+	}
+}
+
+func TestCopyToHostArgs(t *testing.T) {
+	want := []string{"-r", "-o", "BatchMode=yes", "-o", "ConnectTimeout=7", "--", "dist/app.tar", "web1:/opt/app/"}
+	if got := copyToHostArgs("dist/app.tar", "web1", "/opt/app/", 7*time.Second); !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	want = []string{"-r", "-o", "BatchMode=yes", "-o", "ConnectTimeout=7", "--", "./backup:2024.tar", "web1:/opt/app/"}
+	if got := copyToHostArgs("backup:2024.tar", "web1", "/opt/app/", 7*time.Second); !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// tunnelStartup is how long a new tunnel must stay up to count as started;
+// ssh exits within it when a port cannot be bound or the login fails
+const tunnelStartup = 2 * time.Second
+
+// forward is a port forwarding as ssh takes it
+type forward struct {
+	// Kind is "L" (local), "R" (remote) or "D" (dynamic, SOCKS)
+	Kind string `json:"kind"`
+	// Spec is the argument of -L, -R or -D, e.g. "8080:localhost:80" or "1080"
+	Spec string `json:"spec"`
+}
+
+// parseForward parses a forward written as ssh flag, such as "-L 8080:localhost:80",
+// "R 9000:localhost:3000" or "D 1080"
+func parseForward(s string) (forward, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return forward{}, fmt.Errorf("%q is not a forward such as L 8080:localhost:80, R 9000:localhost:3000 or D 1080", s)
+	}
+	f := forward{Kind: strings.TrimPrefix(fields[0], "-"), Spec: fields[1]}
+	parts := splitForwardSpec(f.Spec)
+	switch f.Kind {
+	case "L", "R":
+		if len(parts) != 3 && len(parts) != 4 {
+			return forward{}, fmt.Errorf("%s needs [bind:]port:host:hostport", f.Kind)
+		}
+		if err := checkPort(parts[len(parts)-1], false); err != nil {
+			return forward{}, err
+		}
+		// A remote forward on port 0 lets the server pick the port
+		return f, checkPort(parts[len(parts)-3], f.Kind == "R")
+	case "D":
+		if len(parts) != 1 && len(parts) != 2 {
+			return forward{}, errors.New("D needs [bind:]port")
+		}
+		return f, checkPort(parts[len(parts)-1], false)
+	}
+	return forward{}, fmt.Errorf("unknown forward kind %q; use L, R or D", fields[0])
+}
+
+// splitForwardSpec splits a spec at the colons outside [brackets], which enclose IPv6 addresses
+func splitForwardSpec(spec string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range spec {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 {
+				parts = append(parts, strings.Trim(spec[start:i], "[]"))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.Trim(spec[start:], "[]"))
+}
+
+func checkPort(s string, zeroAllowed bool) error {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 0 || port > 65535 || port == 0 && !zeroAllowed {
+		return fmt.Errorf("%s is not a port number", s)
+	}
+	return nil
+}
+
+// String renders the forward as ssh flag
+func (f forward) String() string {
+	return "-" + f.Kind + " " + f.Spec
+}
+
+// localAddress returns the address a local or dynamic forward listens on, or "" for remote forwards
+func (f forward) localAddress() string {
+	parts := splitForwardSpec(f.Spec)
+	bind, port := "localhost", ""
+	switch {
+	case f.Kind == "L" && len(parts) == 4, f.Kind == "D" && len(parts) == 2:
+		bind, port = parts[0], parts[1]
+	case f.Kind == "L", f.Kind == "D":
+		port = parts[0]
+	default:
+		return ""
+	}
+	if bind == "" || bind == "*" || bind == "0.0.0.0" {
+		bind = "localhost"
+	}
+	return net.JoinHostPort(bind, port)
+}
+
+// forwardArgs returns the ssh flags of forwards
+func forwardArgs(forwards []forward) []string {
+	var args []string
+	for _, f := range forwards {
+		args = append(args, "-"+f.Kind, f.Spec)
+	}
+	return args
+}
+
+// tunnel is a background ssh process holding forwards open, recorded in the
+// tunnels directory so later runs can list and stop it
+type tunnel struct {
+	Host     string    `json:"host"`
+	Forwards []forward `json:"forwards"`
+	PID      int       `json:"pid"`
+	Started  time.Time `json:"started"`
+	LogFile  string    `json:"log_file"`
+	// ProcessStart is when the process with PID started, as processStartTime
+	// reports it, so that a reused pid is not taken for the tunnel
+	ProcessStart string `json:"process_start,omitempty"`
+	// Daemon is set for tunnels of the tunnel command, which reconnect when ssh exits
+	Daemon bool `json:"daemon,omitempty"`
+	// Reconnects counts how often a daemon had to start ssh again
+	Reconnects int `json:"reconnects,omitempty"`
+	// Listening tells for each forward whether its local port accepts connections;
+	// remote forwards are not checked
+	Listening []bool `json:"-"`
+}
+
+// tunnelStartedMsg reports the outcome of startTunnel
+type tunnelStartedMsg struct {
+	tunnel tunnel
+	err    error
+}
+
+// tunnelsTickMsg refreshes the tunnels dashboard; it carries the generation
+// of the timer so that one left running by an earlier visit is dropped
+type tunnelsTickMsg int
+
+// tickTunnels refreshes the tunnels dashboard in a few seconds
+func tickTunnels(generation int) tea.Cmd {
+	return tea.Tick(5*time.Second, func(time.Time) tea.Msg {
+		return tunnelsTickMsg(generation)
+	})
+}
+
+// tunnelDir returns the directory holding the records and logs of tunnels
+func tunnelDir() (string, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tunnels"), nil
+}
+
+// recordPath returns where the record of a tunnel is kept
+func (t tunnel) recordPath(dir string) string {
+	return filepath.Join(dir, strconv.Itoa(t.PID)+".json")
+}
+
+// running reports whether the process of t is still running. The pid alone is
+// not enough: once the tunnel has ended, another process may get it.
+func (t tunnel) running() bool {
+	if !processAlive(t.PID) || t.ProcessStart == "" {
+		return false
+	}
+	start, err := processStartTime(t.PID)
+	return err == nil && start == t.ProcessStart
+}
+
+// listTunnels returns the running tunnels of host, or of all hosts when host
+// is "", oldest first. Records of tunnels that have ended are removed.
+func listTunnels(host string) ([]tunnel, error) {
+	dir, err := tunnelDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var tunnels []tunnel
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var t tunnel
+		if err := json.Unmarshal(content, &t); err != nil || !t.running() {
+			os.Remove(path)
+			os.Remove(t.LogFile)
+			continue
+		}
+		if host != "" && t.Host != host {
+			continue
+		}
+		for _, f := range t.Forwards {
+			t.Listening = append(t.Listening, portListening(f.localAddress()))
+		}
+		tunnels = append(tunnels, t)
+	}
+	sort.Slice(tunnels, func(i, j int) bool { return tunnels[i].Started.Before(tunnels[j].Started) })
+	return tunnels, nil
+}
+
+// portListening reports whether something accepts connections on address
+func portListening(address string) bool {
+	if address == "" {
+		return false
+	}
+	conn, err := net.DialTimeout("tcp", address, 200*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// startTunnel starts ssh in the background with forwards to target and records
+// it. The tunnel authenticates on its own: a shared connection would keep the
+// forwards after the tunnel is stopped.
+func startTunnel(host, target string, forwards []forward, opts []string, password []byte, keyFile string) (tunnel, error) {
+	defer clear(password)
+	dir, err := tunnelDir()
+	if err != nil {
+		return tunnel{}, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return tunnel{}, err
+	}
+	logFile, err := os.CreateTemp(dir, "tunnel-*.log")
+	if err != nil {
+		return tunnel{}, err
+	}
+	defer logFile.Close()
+
+	args := append([]string{"-N", "-o", "ExitOnForwardFailure=yes", "-o", "ControlPath=none"}, opts...)
+	args = append(append(args, forwardArgs(forwards)...), target)
+	var cmd *exec.Cmd
+	var secret *os.File
+	if password == nil {
+		cmd = exec.Command("ssh", append([]string{"-o", "BatchMode=yes"}, args...)...)
+	} else if cmd, secret, err = sshpassCommand(context.Background(), password, keyFile, args...); err != nil {
+		return tunnel{}, err
+	}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachProcess(cmd)
+	err = cmd.Start()
+	if secret != nil {
+		secret.Close()
+	}
+	if err != nil {
+		return tunnel{}, err
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case err := <-exited:
+		content, _ := os.ReadFile(logFile.Name())
+		os.Remove(logFile.Name())
+		if detail := strings.TrimSpace(string(content)); detail != "" {
+			return tunnel{}, errors.New(detail)
+		}
+		if err == nil {
+			err = errors.New("ssh exited right away")
+		}
+		return tunnel{}, err
+	case <-time.After(tunnelStartup):
+	}
+
+	t := tunnel{Host: host, Forwards: forwards, PID: cmd.Process.Pid, Started: time.Now(), LogFile: logFile.Name()}
+	if t.ProcessStart, err = processStartTime(t.PID); err != nil {
+		killProcessGroup(t.PID)
+		os.Remove(logFile.Name())
+		return tunnel{}, err
+	}
+	return t, writeTunnelRecord(dir, t)
+}
+
+// writeTunnelRecord records a running tunnel in dir
+func writeTunnelRecord(dir string, t tunnel) error {
+	content, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.recordPath(dir), content, 0600)
+}
+
+// stopTunnel ends a tunnel and removes its record. A tunnel that has ended
+// already is not signalled, as its pid may belong to another process by now.
+func stopTunnel(t tunnel) error {
+	dir, err := tunnelDir()
+	if err != nil {
+		return err
+	}
+	if t.running() {
+		if err := killProcessGroup(t.PID); err != nil && t.running() {
+			return err
+		}
+	}
+	os.Remove(t.LogFile)
+	return os.Remove(t.recordPath(dir))
+}
+
+// forwardsView lists the forwards offered for a host with their selection,
+// then its running tunnels, with a cursor over both
+func forwardsView(forwards []forward, selected []bool, tunnels []tunnel, cursor int) string {
+	var b strings.Builder
+	if len(forwards) == 0 {
+		b.WriteString("No forwards yet; press a to add one.\n")
+	}
+	for i, f := range forwards {
+		pointer, check := "  ", "[ ]"
+		if i == cursor {
+			pointer = "> "
+		}
+		if selected[i] {
+			check = "[x]"
+		}
+		fmt.Fprintf(&b, "%s%s %s\n", pointer, check, f)
+	}
+	b.WriteString("\nRunning tunnels:\n")
+	if len(tunnels) == 0 {
+		b.WriteString("  none\n")
+	}
+	for i, t := range tunnels {
+		pointer := "  "
+		if len(forwards)+i == cursor {
+			pointer = "> "
+		}
+		kind := ""
+		if t.Daemon {
+			kind = fmt.Sprintf(" (daemon, %d reconnects)", t.Reconnects)
+		}
+		fmt.Fprintf(&b, "%spid %d%s, up %s: %s\n", pointer, t.PID, kind, time.Since(t.Started).Round(time.Second), t.forwardStates())
+	}
+	return b.String()
+}
+
+// forwardStates renders the forwards of a running tunnel with whether their local ports listen
+func (t tunnel) forwardStates() string {
+	var specs []string
+	for i, f := range t.Forwards {
+		spec := f.String()
+		if address := f.localAddress(); address != "" {
+			state := "not listening"
+			if i < len(t.Listening) && t.Listening[i] {
+				state = "listening"
+			}
+			spec += " (" + address + " " + state + ")"
+		}
+		specs = append(specs, spec)
+	}
+	return strings.Join(specs, ", ")
+}
+
+// tunnelRow is a line of the tunnels dashboard: a running tunnel, or the saved
+// forwards of a host that has no tunnel running
+type tunnelRow struct {
+	host     string
+	forwards []forward
+	running  *tunnel
+}
+
+// tunnelRows lists the running tunnels, then the hosts with saved forwards
+// and nothing running, by name
+func tunnelRows(tunnels []tunnel, saved map[string][]forward) []tunnelRow {
+	var rows []tunnelRow
+	running := map[string]bool{}
+	for i := range tunnels {
+		rows = append(rows, tunnelRow{host: tunnels[i].Host, forwards: tunnels[i].Forwards, running: &tunnels[i]})
+		running[tunnels[i].Host] = true
+	}
+	var hosts []string
+	for host, forwards := range saved {
+		if !running[host] && len(forwards) > 0 {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		rows = append(rows, tunnelRow{host: host, forwards: saved[host]})
+	}
+	return rows
+}
+
+// tunnelsView renders the dashboard rows as a table with a cursor. ssh does
+// not report how much went through a forward, so no traffic is shown.
+func tunnelsView(rows []tunnelRow, cursor int) string {
+	if len(rows) == 0 {
+		return "No tunnels are running and no forwards are saved; add some on the forwards screen (W) of a host.\n"
+	}
+	width := len("HOST")
+	for _, r := range rows {
+		width = max(width, len(r.host))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "  %-*s  %-8s  %-8s  %-7s  %s\n", width, "HOST", "STATE", "UPTIME", "PID", "FORWARDS")
+	for i, r := range rows {
+		pointer := "  "
+		if i == cursor {
+			pointer = "> "
+		}
+		saved := forwardFlags(r.forwards)
+		state, uptime, pid, forwards := "stopped", "-", "-", saved.String()
+		if t := r.running; t != nil {
+			state, pid, forwards = "running", strconv.Itoa(t.PID), t.forwardStates()
+			uptime = time.Since(t.Started).Round(time.Second).String()
+			if t.Daemon {
+				state = "daemon"
+				if t.Reconnects > 0 {
+					forwards += fmt.Sprintf(" [%d reconnects]", t.Reconnects)
+				}
+			}
+		}
+		fmt.Fprintf(&b, "%s%-*s  %-8s  %-8s  %-7s  %s\n", pointer, width, r.host, state, uptime, pid, forwards)
+	}
+	return b.String()
+}
+
+// findSocksProxy returns the tunnel that is the SOCKS proxy on port, if one is running
+func findSocksProxy(tunnels []tunnel, port int) *tunnel {
+	for _, t := range tunnels {
+		if len(t.Forwards) == 1 && t.Forwards[0] == (forward{Kind: "D", Spec: strconv.Itoa(port)}) {
+			return &t
+		}
+	}
+	return nil
+}
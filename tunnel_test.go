@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseForward(t *testing.T) {
+	for _, tt := range []struct {
+		in, address string
+	}{
+		{"L 8080:localhost:80", "localhost:8080"},
+		{"-L 127.0.0.1:8080:db:5432", "127.0.0.1:8080"},
+		{"D 1080", "localhost:1080"},
+		{"-D [::1]:1080", "[::1]:1080"},
+		{"R 0:localhost:3000", ""},
+		{"L 8080:[fe80::1]:80", "localhost:8080"},
+	} {
+		f, err := parseForward(tt.in)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if got := f.localAddress(); got != tt.address {
+			t.Errorf("%q: expected address %q, got %q", tt.in, tt.address, got)
+		}
+	}
+	for _, in := range []string{"L 8080", "X 1080", "D http", "L 0:localhost:80", "-L"} {
+		if _, err := parseForward(in); err == nil {
+			t.Errorf("expected %q to be rejected", in)
+		}
+	}
+}
+
+func TestFindSocksProxy(t *testing.T) {
+	tunnels := []tunnel{
+		{Host: "web1", PID: 1, Forwards: []forward{{Kind: "D", Spec: "1080"}, {Kind: "L", Spec: "8080:localhost:80"}}},
+		{Host: "web2", PID: 2, Forwards: []forward{{Kind: "D", Spec: "1080"}}},
+	}
+	if p := findSocksProxy(tunnels, 1080); p == nil || p.Host != "web2" {
+		t.Errorf("expected the proxy via web2, got %+v", p)
+	}
+	if p := findSocksProxy(tunnels, 1081); p != nil {
+		t.Errorf("expected no proxy on another port, got %+v", p)
+	}
+}
+
+func TestForwardsView(t *testing.T) {
+	forwards := []forward{{Kind: "L", Spec: "8080:localhost:80"}, {Kind: "D", Spec: "1080"}}
+	tunnels := []tunnel{{Host: "web1", Forwards: forwards[:1], PID: 42, Started: time.Now(), Listening: []bool{true}}}
+	got := forwardsView(forwards, []bool{true, false}, tunnels, 2)
+	for _, want := range []string{"  [x] -L 8080:localhost:80\n", "  [ ] -D 1080\n", "> pid 42, up 0s: -L 8080:localhost:80 (localhost:8080 listening)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestTunnelRows(t *testing.T) {
+	web := []forward{{Kind: "L", Spec: "8080:localhost:80"}}
+	tunnels := []tunnel{{Host: "web1", Forwards: web, PID: 42, Started: time.Now(), Daemon: true, Reconnects: 3}}
+	saved := map[string][]forward{"web1": web, "db1": {{Kind: "L", Spec: "5432:localhost:5432"}}, "app1": nil}
+	rows := tunnelRows(tunnels, saved)
+	if len(rows) != 2 || rows[0].running == nil || rows[0].running.PID != 42 || rows[1].host != "db1" || rows[1].running != nil {
+		t.Fatalf("expected the running tunnel then the stopped db1 forwards, got %+v", rows)
+	}
+	got := tunnelsView(rows, 1)
+	for _, want := range []string{
+		"  web1  daemon    0s        42       -L 8080:localhost:80 (localhost:8080 not listening) [3 reconnects]\n",
+		"> db1   stopped   -         -        -L 5432:localhost:5432\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestTunnelRunning(t *testing.T) {
+	start, err := processStartTime(os.Getpid())
+	if err != nil {
+		t.Skip("cannot read process start times:", err)
+	}
+	if !(tunnel{PID: os.Getpid(), ProcessStart: start}).running() {
+		t.Error("expected this process to count as running")
+	}
+	// The pid was reused by another process, or the record predates start times
+	for _, stale := range []tunnel{{PID: os.Getpid(), ProcessStart: "Thu Jan  1 00:00:00 1970"}, {PID: os.Getpid()}} {
+		if stale.running() {
+			t.Errorf("%+v should not count as running", stale)
+		}
+	}
+}
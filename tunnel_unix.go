@@ -0,0 +1,34 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// detachProcess starts cmd in its own session, so it outlives the terminal
+// and can be stopped together with its children
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// processAlive reports whether a process with pid is running
+func processAlive(pid int) bool {
+	return pid > 0 && syscall.Kill(pid, 0) == nil
+}
+
+// processStartTime returns when the process with pid started, as ps prints it,
+// to tell it apart from a later process given the same pid
+func processStartTime(pid int) (string, error) {
+	out, err := exec.Command("ps", "-o", "lstart=", "-p", strconv.Itoa(pid)).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// killProcessGroup stops a process started with detachProcess and its children,
+// such as ssh under sshpass
+func killProcessGroup(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGTERM)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxReconnectDelay caps the wait between two attempts of a tunnel daemon to start ssh
+const maxReconnectDelay = time.Minute
+
+// forwardFlags collects repeated -forward flags
+type forwardFlags []forward
+
+func (f *forwardFlags) String() string {
+	var s []string
+	for _, fw := range *f {
+		s = append(s, fw.String())
+	}
+	return strings.Join(s, ", ")
+}
+
+func (f *forwardFlags) Set(value string) error {
+	fw, err := parseForward(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, fw)
+	return nil
+}
+
+// runTunnel implements "tunnel <host>": it starts a daemon in the background
+// that holds the host's forwards open and reconnects whenever ssh exits
+func runTunnel(args []string) int {
+	fs := flag.NewFlagSet("tunnel", flag.ExitOnError)
+	var forwards forwardFlags
+	fs.Var(&forwards, "forward", `forward as for ssh, e.g. "L 8080:localhost:80" (repeatable; default: the host's "forwards" in config.json)`)
+	foreground := fs.Bool("foreground", false, "stay in the foreground instead of starting a daemon")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: list-ssh-hosts tunnel [-forward spec]... [-foreground] <host>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	host := fs.Arg(0)
+
+	if len(forwards) == 0 {
+		cfg, err := loadAppConfig()
+		if err != nil {
+			fmt.Println("Could not read app config:", err)
+			return 1
+		}
+		forwards = cfg.Forwards[host]
+	}
+	if len(forwards) == 0 {
+		fmt.Printf("No forwards for %s: pass -forward or save some on the forwards screen (W).\n", host)
+		return 2
+	}
+	dir, err := tunnelDir()
+	if err == nil {
+		err = os.MkdirAll(dir, 0700)
+	}
+	if err != nil {
+		fmt.Println("Could not create the tunnels directory:", err)
+		return 1
+	}
+	if *foreground {
+		// The keepalive settings of the host take precedence over the daemon's own
+		cfg, _ := loadAppConfig()
+		md, _ := loadHostMetadata()
+		if err := superviseTunnel(dir, host, forwards, keepaliveArgs(md[host], cfg)); err != nil {
+			fmt.Println("Tunnel failed:", err)
+			return 1
+		}
+		return 0
+	}
+
+	pid, logFile, err := startTunnelDaemon(dir, host, forwards)
+	if err != nil {
+		fmt.Printf("Could not start the tunnel to %s: %v\n", host, err)
+		return 1
+	}
+	fmt.Printf("Tunnel to %s running in the background (pid %d): %s\n", host, pid, forwards.String())
+	if content, _ := os.ReadFile(logFile); strings.Contains(string(content), "reconnecting") {
+		fmt.Printf("ssh did not stay connected so far; the daemon keeps trying:\n%s", content)
+	}
+	fmt.Println("Stop it on the tunnels screen (t) or the forwards screen (W) of the host.")
+	return 0
+}
+
+// startTunnelDaemon starts this command again, detached, as the daemon of a
+// tunnel and returns its pid and log file once it stayed up for tunnelStartup
+func startTunnelDaemon(dir, host string, forwards []forward) (int, string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return 0, "", err
+	}
+	daemonArgs := []string{"tunnel", "-foreground"}
+	for _, f := range forwards {
+		daemonArgs = append(daemonArgs, "-forward", f.Kind+" "+f.Spec)
+	}
+	logFile, err := os.CreateTemp(dir, "tunnel-*.log")
+	if err != nil {
+		return 0, "", err
+	}
+	defer logFile.Close()
+	cmd := exec.Command(self, append(daemonArgs, host)...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.Env = append(os.Environ(), "LSH_TUNNEL_LOG="+logFile.Name())
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		return 0, "", err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case <-exited:
+		content, _ := os.ReadFile(logFile.Name())
+		os.Remove(logFile.Name())
+		return 0, "", fmt.Errorf("it stopped right away: %s", strings.TrimSpace(string(content)))
+	case <-time.After(tunnelStartup):
+	}
+	return cmd.Process.Pid, logFile.Name(), nil
+}
+
+// superviseTunnel runs ssh with forwards to host until it is told to stop,
+// starting ssh again with growing delays whenever it exits. Logins cannot be
+// answered in the background, so the host must accept a key or an agent.
+// opts come before the daemon's options, so they override its keepalive.
+func superviseTunnel(dir, host string, forwards []forward, opts []string) error {
+	t := tunnel{Host: host, Forwards: forwards, PID: os.Getpid(), Started: time.Now(), Daemon: true, LogFile: os.Getenv("LSH_TUNNEL_LOG")}
+	if t.LogFile == "" {
+		t.LogFile = filepath.Join(dir, fmt.Sprintf("tunnel-%d.log", t.PID))
+	}
+	start, err := processStartTime(t.PID)
+	if err != nil {
+		return err
+	}
+	t.ProcessStart = start
+	if err := writeTunnelRecord(dir, t); err != nil {
+		return err
+	}
+	defer os.Remove(t.recordPath(dir))
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	args := append(slices.Clone(opts), "-N", "-o", "BatchMode=yes", "-o", "ExitOnForwardFailure=yes", "-o", "ControlPath=none",
+		"-o", "ServerAliveInterval=15", "-o", "ServerAliveCountMax=3")
+	args = append(append(args, forwardArgs(forwards)...), host)
+	delay := retryBackoff
+	for {
+		cmd := exec.Command("ssh", args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		started := time.Now()
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		exited := make(chan error, 1)
+		go func() { exited <- cmd.Wait() }()
+		select {
+		case <-stop:
+			cmd.Process.Signal(syscall.SIGTERM)
+			<-exited
+			return nil
+		case err := <-exited:
+			var exitErr *exec.ExitError
+			if err != nil && !errors.As(err, &exitErr) {
+				return err
+			}
+		}
+		// A connection that held for a while is reconnected quickly again
+		if time.Since(started) > maxReconnectDelay {
+			delay = retryBackoff
+		}
+		fmt.Printf("%s ssh exited; reconnecting in %s\n", time.Now().Format(time.RFC3339), delay)
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxReconnectDelay)
+		t.Reconnects++
+		if err := writeTunnelRecord(dir, t); err != nil {
+			return err
+		}
+	}
+}
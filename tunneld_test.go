@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestForwardFlags(t *testing.T) {
+	fs := flag.NewFlagSet("tunnel", flag.ContinueOnError)
+	var forwards forwardFlags
+	fs.Var(&forwards, "forward", "")
+	if err := fs.Parse([]string{"-forward", "L 8080:localhost:80", "-forward", "-D 1080", "web1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := forwards.String(); got != "-L 8080:localhost:80, -D 1080" {
+		t.Errorf("unexpected forwards %q", got)
+	}
+	if fs.Arg(0) != "web1" {
+		t.Errorf("expected the host to remain, got %v", fs.Args())
+	}
+	if err := fs.Parse([]string{"-forward", "L 8080"}); err == nil {
+		t.Error("expected an invalid forward to be rejected")
+	}
+}
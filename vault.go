@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters used to derive the vault key from the master password
+const (
+	vaultKDFTime    = 1
+	vaultKDFMemory  = 64 * 1024
+	vaultKDFThreads = 4
+	vaultKeyLen     = 32
+)
+
+var errWrongMasterPassword = errors.New("wrong master password")
+
+// vaultFile is the on-disk representation of the vault
+type vaultFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// vault is an encrypted local store of per-host passwords
+type vault struct {
+	path      string
+	salt      []byte
+	key       []byte
+	passwords map[string][]byte // kept in byte slices so they can be wiped
+}
+
+// vaultPath returns the location of the vault file in the app config directory
+func vaultPath() (string, error) {
+	dir, err := appConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "vault.json"), nil
+}
+
+// vaultExists reports whether a vault file has been created at path
+func vaultExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// createVault creates a new, empty vault protected by the master password
+func createVault(path, master string) (*vault, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	v := &vault{
+		path:      path,
+		salt:      salt,
+		key:       deriveVaultKey(master, salt),
+		passwords: map[string][]byte{},
+	}
+	return v, v.save()
+}
+
+// openVault decrypts the vault at path with the master password
+func openVault(path, master string) (*vault, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f vaultFile
+	if err := json.Unmarshal(content, &f); err != nil {
+		return nil, err
+	}
+
+	key := deriveVaultKey(master, f.Salt)
+	gcm, err := newVaultCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, errWrongMasterPassword
+	}
+	defer clear(plain)
+
+	var secrets map[string]vaultSecret
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, err
+	}
+	v := &vault{path: path, salt: f.Salt, key: key, passwords: map[string][]byte{}}
+	for host, pw := range secrets {
+		v.passwords[host] = pw
+	}
+	return v, nil
+}
+
+// Get returns a copy of the stored password for a host, for the caller to wipe
+func (v *vault) Get(host string) ([]byte, bool) {
+	pw, ok := v.passwords[host]
+	return bytes.Clone(pw), ok
+}
+
+// Set stores a copy of the password for a host and writes the vault to disk
+func (v *vault) Set(host string, password []byte) error {
+	clear(v.passwords[host])
+	v.passwords[host] = bytes.Clone(password)
+	return v.save()
+}
+
+// Delete removes the password for a host and writes the vault to disk
+func (v *vault) Delete(host string) error {
+	clear(v.passwords[host])
+	delete(v.passwords, host)
+	return v.save()
+}
+
+// save encrypts the vault with a fresh nonce and writes it to disk. The JSON
+// holding the passwords is written by hand and wiped, as encoding/json would
+// leave copies in its buffers.
+func (v *vault) save() error {
+	// Sized for the worst case, so that appending never leaves a copy behind
+	size := 2
+	for host, pw := range v.passwords {
+		size += 6*len(host) + 6*len(pw) + 6
+	}
+	plain := append(make([]byte, 0, size), '{')
+	defer func() { clear(plain) }()
+	for host, pw := range v.passwords {
+		if len(plain) > 1 {
+			plain = append(plain, ',')
+		}
+		name, err := json.Marshal(host)
+		if err != nil {
+			return err
+		}
+		plain = append(append(plain, name...), ':')
+		plain = appendJSONSecret(plain, pw)
+	}
+	plain = append(plain, '}')
+	gcm, err := newVaultCipher(v.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(vaultFile{
+		Version: 1,
+		Salt:    v.salt,
+		Nonce:   nonce,
+		Data:    gcm.Seal(nil, nonce, plain, nil),
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(v.path, content, 0600)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONSecret appends s to dst as a JSON string. dst must have room for
+// the result, or the copy append leaves behind is not wiped.
+func appendJSONSecret(dst, s []byte) []byte {
+	dst = append(dst, '"')
+	for _, c := range s {
+		switch {
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', c)
+		case c < 0x20:
+			dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return append(dst, '"')
+}
+
+// vaultSecret decodes a JSON string into bytes without passing through a Go string
+type vaultSecret []byte
+
+func (s *vaultSecret) UnmarshalJSON(data []byte) error {
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return errors.New("vault password is not a string")
+	}
+	data = data[1 : len(data)-1]
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] != '\\' {
+			out = append(out, data[i])
+			continue
+		}
+		i++
+		if i == len(data) {
+			return errors.New("bad escape in vault password")
+		}
+		switch data[i] {
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'u':
+			r, n := decodeJSONRune(data[i+1:])
+			if n == 0 {
+				return errors.New("bad escape in vault password")
+			}
+			out = utf8.AppendRune(out, r)
+			i += n
+		default:
+			out = append(out, data[i])
+		}
+	}
+	*s = out
+	return nil
+}
+
+// decodeJSONRune decodes the hex digits after \u, and a low surrogate escape
+// following a high one. It returns the rune and the number of bytes used.
+func decodeJSONRune(data []byte) (rune, int) {
+	hex := func(b []byte) (rune, bool) {
+		if len(b) < 4 {
+			return 0, false
+		}
+		var r rune
+		for _, c := range b[:4] {
+			i := strings.IndexByte(hexDigits, c|0x20) // lowercase letters, digits stay
+			if i < 0 {
+				return 0, false
+			}
+			r = r<<4 | rune(i)
+		}
+		return r, true
+	}
+	r, ok := hex(data)
+	if !ok {
+		return 0, 0
+	}
+	if utf16.IsSurrogate(r) && len(data) >= 10 && data[4] == '\\' && data[5] == 'u' {
+		if low, ok := hex(data[6:]); ok {
+			if pair := utf16.DecodeRune(r, low); pair != utf8.RuneError {
+				return pair, 10
+			}
+		}
+	}
+	return r, 4
+}
+
+func deriveVaultKey(master string, salt []byte) []byte {
+	return argon2.IDKey([]byte(master), salt, vaultKDFTime, vaultKDFMemory, vaultKDFThreads, vaultKeyLen)
+}
+
+func newVaultCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
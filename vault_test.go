@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestVault_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+
+	v, err := createVault(path, "correct horse")
+	if err != nil {
+		t.Fatalf("createVault failed: %v", err)
+	}
+	if err := v.Set("test-server", []byte("s3cret")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	reopened, err := openVault(path, "correct horse")
+	if err != nil {
+		t.Fatalf("openVault failed: %v", err)
+	}
+	pw, ok := reopened.Get("test-server")
+	if !ok || string(pw) != "s3cret" {
+		t.Errorf("expected stored password 's3cret', got %q (found=%v)", pw, ok)
+	}
+
+	if err := reopened.Delete("test-server"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok := reopened.Get("test-server"); ok {
+		t.Error("expected password to be removed")
+	}
+}
+
+func TestVault_Escapes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	v, err := createVault(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := map[string]string{"a": `q"u\o\"te`, "b": "tab\tnl\n\x01", "c": "ünï😀<&>", "d": ""}
+	for host, pw := range secrets {
+		if err := v.Set(host, []byte(pw)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reopened, err := openVault(path, "correct horse")
+	if err != nil {
+		t.Fatalf("openVault failed: %v", err)
+	}
+	for host, want := range secrets {
+		if got, ok := reopened.Get(host); !ok || string(got) != want {
+			t.Errorf("%s: expected %q, got %q", host, want, got)
+		}
+	}
+
+	// Vaults written by encoding/json escape more than save does
+	var s vaultSecret
+	if err := json.Unmarshal([]byte(`"\u003c\u00e9\ud83d\ude00\/"`), &s); err != nil || string(s) != "<é😀/" {
+		t.Errorf("expected escapes to be decoded, got %q, %v", s, err)
+	}
+}
+
+func TestVault_WrongMasterPassword(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	if _, err := createVault(path, "correct horse"); err != nil {
+		t.Fatalf("createVault failed: %v", err)
+	}
+
+	if _, err := openVault(path, "battery staple"); err != errWrongMasterPassword {
+		t.Errorf("expected errWrongMasterPassword, got %v", err)
+	}
+}